@@ -15,6 +15,7 @@ type contextKey string
 const (
 	contextKeyUserID        contextKey = "userID"
 	contextKeyChannelID     contextKey = "channelID"
+	contextKeyTeamID        contextKey = "teamID"
 	contextKeyPluginContext contextKey = "pluginContext"
 )
 
@@ -29,6 +30,47 @@ func (p *Plugin) ServeHTTP(c *plugin.Context, w http.ResponseWriter, r *http.Req
 	apiRouter.HandleFunc("/enable", p.requireChannelPermission(c, p.handleEnableChannelModeration)).Methods("POST")
 	apiRouter.HandleFunc("/disable", p.requireChannelPermission(c, p.handleDisableChannelModeration)).Methods("POST")
 	apiRouter.HandleFunc("/status", p.requireChannelPermission(c, p.handleGetChannelModerationStatus)).Methods("GET")
+	apiRouter.HandleFunc("/policy", p.requireChannelPermission(c, p.handleGetPolicy(PolicyScopeChannel))).Methods("GET")
+	apiRouter.HandleFunc("/policy", p.requireChannelPermission(c, p.handleSetPolicy(PolicyScopeChannel))).Methods("PUT")
+	apiRouter.HandleFunc("/policy", p.requireChannelPermission(c, p.handleDeletePolicy(PolicyScopeChannel))).Methods("DELETE")
+
+	reviewRouter := router.PathPrefix("/review").Subrouter()
+	reviewRouter.HandleFunc("", p.requireReviewer(c, p.handleListReviewItems)).Methods("GET")
+	reviewRouter.HandleFunc("/{postId}/approve", p.requireReviewer(c, p.handleApproveReviewItem)).Methods("POST")
+	reviewRouter.HandleFunc("/{postId}/remove", p.requireReviewer(c, p.handleRemoveReviewItem)).Methods("POST")
+
+	appealsRouter := router.PathPrefix("/appeals/{appealId}").Subrouter()
+	appealsRouter.HandleFunc("/submit", p.requireUser(c, p.handleSubmitAppeal)).Methods("POST")
+	appealsRouter.HandleFunc("/reinstate", p.requireReviewer(c, p.handleReinstateAppeal)).Methods("POST")
+	appealsRouter.HandleFunc("/uphold", p.requireReviewer(c, p.handleUpholdAppeal)).Methods("POST")
+
+	blocklistRouter := router.PathPrefix("/blocklist").Subrouter()
+	blocklistRouter.HandleFunc("", p.requireSystemAdmin(c, p.handleListBlocklistEntries)).Methods("GET")
+	blocklistRouter.HandleFunc("", p.requireSystemAdmin(c, p.handleAddBlocklistEntry)).Methods("POST")
+	blocklistRouter.HandleFunc("/{entryId}", p.requireSystemAdmin(c, p.handleRemoveBlocklistEntry)).Methods("DELETE")
+
+	monitorRouter := router.PathPrefix("/monitor").Subrouter()
+	monitorRouter.HandleFunc("/report", p.requireReviewer(c, p.handleGetMonitorReport)).Methods("GET")
+	monitorRouter.HandleFunc("/records", p.requireReviewer(c, p.handleListMonitorRecords)).Methods("GET")
+	monitorRouter.HandleFunc("/records/{recordId}/verdict", p.requireReviewer(c, p.handleSetMonitorVerdict)).Methods("POST")
+
+	scansRouter := router.PathPrefix("/scans").Subrouter()
+	scansRouter.HandleFunc("", p.requireSystemAdmin(c, p.handleListScanJobs)).Methods("GET")
+	scansRouter.HandleFunc("", p.requireSystemAdmin(c, p.handleStartScanJob)).Methods("POST")
+	scansRouter.HandleFunc("/{jobId}", p.requireSystemAdmin(c, p.handleGetScanJob)).Methods("GET")
+	scansRouter.HandleFunc("/{jobId}/pause", p.requireSystemAdmin(c, p.handleUpdateScanJob("pause"))).Methods("POST")
+	scansRouter.HandleFunc("/{jobId}/resume", p.requireSystemAdmin(c, p.handleUpdateScanJob("resume"))).Methods("POST")
+	scansRouter.HandleFunc("/{jobId}/cancel", p.requireSystemAdmin(c, p.handleUpdateScanJob("cancel"))).Methods("POST")
+
+	router.HandleFunc("/stats", p.requireSystemAdmin(c, p.handleGetStats)).Methods("GET")
+	router.HandleFunc("/metrics", p.requireMetricsAccess(c, p.handleGetMetrics)).Methods("GET")
+	router.HandleFunc("/health", p.requireMetricsAccess(c, p.handleGetHealth)).Methods("GET")
+	router.HandleFunc("/health/test", p.requireSystemAdmin(c, p.handleTestConnection)).Methods("POST")
+
+	teamRouter := router.PathPrefix("/teams/{teamId}/moderation").Subrouter()
+	teamRouter.HandleFunc("/policy", p.requireTeamPermission(c, p.handleGetPolicy(PolicyScopeTeam))).Methods("GET")
+	teamRouter.HandleFunc("/policy", p.requireTeamPermission(c, p.handleSetPolicy(PolicyScopeTeam))).Methods("PUT")
+	teamRouter.HandleFunc("/policy", p.requireTeamPermission(c, p.handleDeletePolicy(PolicyScopeTeam))).Methods("DELETE")
 
 	router.ServeHTTP(w, r)
 }
@@ -60,6 +102,33 @@ func (p *Plugin) requireChannelPermission(pluginContext *plugin.Context, next ht
 	}
 }
 
+// requireTeamPermission is a middleware that handles authentication and authorization for team level endpoints
+func (p *Plugin) requireTeamPermission(pluginContext *plugin.Context, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		teamID := vars["teamId"]
+
+		userID := r.Header.Get("Mattermost-User-ID")
+		if userID == "" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if !p.hasTeamPermission(userID, teamID) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		// Add userID, teamID, and pluginContext to context for use in handlers
+		ctx := context.WithValue(r.Context(), contextKeyUserID, userID)
+		ctx = context.WithValue(ctx, contextKeyTeamID, teamID)
+		ctx = context.WithValue(ctx, contextKeyPluginContext, pluginContext)
+		r = r.WithContext(ctx)
+
+		next(w, r)
+	}
+}
+
 func (p *Plugin) handleEnableChannelModeration(w http.ResponseWriter, r *http.Request) {
 	// Get userID, channelID, and pluginContext from context (set by middleware)
 	userID := r.Context().Value(contextKeyUserID).(string)
@@ -84,6 +153,7 @@ func (p *Plugin) handleEnableChannelModeration(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	p.notifyStoreChanged(storeNameExcludedChannels)
 	p.API.LogInfo("Channel moderation enabled via API", "channel_id", channelID, "user_id", userID)
 	auditRecord.Success()
 
@@ -114,6 +184,7 @@ func (p *Plugin) handleDisableChannelModeration(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	p.notifyStoreChanged(storeNameExcludedChannels)
 	p.API.LogInfo("Channel moderation disabled via API", "channel_id", channelID, "user_id", userID)
 	auditRecord.Success()
 
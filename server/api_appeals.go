@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+// requireUser is a middleware that only lets authenticated users through
+func (p *Plugin) requireUser(pluginContext *plugin.Context, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Header.Get("Mattermost-User-ID")
+		if userID == "" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeyUserID, userID)
+		ctx = context.WithValue(ctx, contextKeyPluginContext, pluginContext)
+		r = r.WithContext(ctx)
+
+		next(w, r)
+	}
+}
+
+func (p *Plugin) handleSubmitAppeal(w http.ResponseWriter, r *http.Request) {
+	p.handleAppealAction(w, r, appealActionSubmit, p.submitAppeal)
+}
+
+func (p *Plugin) handleReinstateAppeal(w http.ResponseWriter, r *http.Request) {
+	p.handleAppealAction(w, r, appealActionReinstate, p.reinstateAppeal)
+}
+
+func (p *Plugin) handleUpholdAppeal(w http.ResponseWriter, r *http.Request) {
+	p.handleAppealAction(w, r, appealActionUphold, p.upholdAppeal)
+}
+
+// handleAppealAction handles a click on one of the appeal buttons. On success the
+// buttons are replaced with the returned status text so they can't be clicked twice.
+func (p *Plugin) handleAppealAction(w http.ResponseWriter, r *http.Request, action string, act func(string, string, *model.AuditRecord) (string, error)) {
+	userID := r.Context().Value(contextKeyUserID).(string)
+	pluginContext := r.Context().Value(contextKeyPluginContext).(*plugin.Context)
+	appealID := mux.Vars(r)["appealId"]
+
+	auditRecord := plugin.MakeAuditRecordWithContext(auditEventTypeContentModeration, model.AuditStatusAttempt, pluginContext, userID, r.URL.Path)
+	auditRecord.AddMeta(auditMetaKeyUserID, userID)
+	auditRecord.AddMeta(auditMetaKeyAction, action)
+
+	if p.getConfiguration().AuditLoggingEnabled {
+		defer p.API.LogAuditRec(auditRecord)
+	}
+
+	var request model.PostActionIntegrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		auditRecord.AddErrorDesc(err.Error())
+		auditRecord.Fail()
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	status, err := act(appealID, userID, auditRecord)
+	if err != nil {
+		auditRecord.AddErrorDesc(err.Error())
+		auditRecord.Fail()
+		if text := appealErrorText(err); text != "" {
+			p.writeAppealResponse(w, &model.PostActionIntegrationResponse{EphemeralText: text})
+			return
+		}
+		p.API.LogError("Failed to handle appeal", "action", action, "appeal_id", appealID, "user_id", userID, "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	p.API.LogInfo("Appeal handled", "action", action, "appeal_id", appealID, "user_id", userID)
+	auditRecord.Success()
+
+	response := &model.PostActionIntegrationResponse{}
+	if post, appErr := p.API.GetPost(request.PostId); appErr == nil {
+		post = post.Clone()
+		model.ParseSlackAttachment(post, []*model.SlackAttachment{{Text: status}})
+		response.Update = post
+	} else {
+		p.API.LogWarn("Failed to get appeal post to update", "post_id", request.PostId, "err", appErr)
+		response.EphemeralText = status
+	}
+	p.writeAppealResponse(w, response)
+}
+
+func (p *Plugin) writeAppealResponse(w http.ResponseWriter, response *model.PostActionIntegrationResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		p.API.LogError("Failed to encode response", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// appealErrorText returns the message shown to the user for expected appeal errors
+func appealErrorText(err error) string {
+	switch {
+	case errors.Is(err, ErrAppealNotFound):
+		return "This appeal no longer exists. Removals can only be appealed for 7 days."
+	case errors.Is(err, ErrAppealAlreadyHandled):
+		return "This appeal was already handled."
+	case errors.Is(err, ErrAppealNotAllowed):
+		return "You are not allowed to act on this appeal."
+	case errors.Is(err, ErrAppealsDisabled):
+		return "Appeals are not enabled."
+	case errors.Is(err, ErrModerationUnavailable):
+		return "Content moderation is not available."
+	default:
+		return ""
+	}
+}
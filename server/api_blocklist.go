@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation/blocklist"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+// requireSystemAdmin is a middleware that only lets system admins through
+func (p *Plugin) requireSystemAdmin(pluginContext *plugin.Context, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Header.Get("Mattermost-User-ID")
+		if userID == "" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if !p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeyUserID, userID)
+		ctx = context.WithValue(ctx, contextKeyPluginContext, pluginContext)
+		r = r.WithContext(ctx)
+
+		next(w, r)
+	}
+}
+
+func (p *Plugin) handleListBlocklistEntries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(p.blocklistStore.ListEntries()); err != nil {
+		p.API.LogError("Failed to encode response", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (p *Plugin) handleAddBlocklistEntry(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(string)
+	pluginContext := r.Context().Value(contextKeyPluginContext).(*plugin.Context)
+
+	auditRecord := plugin.MakeAuditRecordWithContext(auditEventTypeManageBlocklist, model.AuditStatusAttempt, pluginContext, userID, r.URL.Path)
+	auditRecord.AddMeta(auditMetaKeyUserID, userID)
+	auditRecord.AddMeta(auditMetaKeyAction, "add")
+
+	if p.getConfiguration().AuditLoggingEnabled {
+		defer p.API.LogAuditRec(auditRecord)
+	}
+
+	var entry blocklist.Entry
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		auditRecord.AddErrorDesc(err.Error())
+		auditRecord.Fail()
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := entry.Validate(); err != nil {
+		auditRecord.AddErrorDesc(err.Error())
+		auditRecord.Fail()
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	added, err := p.addBlocklistEntry(entry, auditRecord)
+	if err != nil {
+		p.API.LogError("Failed to add blocklist entry", "user_id", userID, "err", err)
+		auditRecord.AddErrorDesc(err.Error())
+		auditRecord.Fail()
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	p.API.LogInfo("Blocklist entry added via API", "entry_id", added.ID, "user_id", userID)
+	auditRecord.Success()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(added); err != nil {
+		p.API.LogError("Failed to encode response", "err", err)
+	}
+}
+
+func (p *Plugin) handleRemoveBlocklistEntry(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(string)
+	pluginContext := r.Context().Value(contextKeyPluginContext).(*plugin.Context)
+	entryID := mux.Vars(r)["entryId"]
+
+	auditRecord := plugin.MakeAuditRecordWithContext(auditEventTypeManageBlocklist, model.AuditStatusAttempt, pluginContext, userID, r.URL.Path)
+	auditRecord.AddMeta(auditMetaKeyUserID, userID)
+	auditRecord.AddMeta(auditMetaKeyAction, "remove")
+
+	if p.getConfiguration().AuditLoggingEnabled {
+		defer p.API.LogAuditRec(auditRecord)
+	}
+
+	if err := p.removeBlocklistEntry(entryID, auditRecord); err != nil {
+		auditRecord.AddErrorDesc(err.Error())
+		auditRecord.Fail()
+		if errors.Is(err, ErrBlocklistEntryNotFound) {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		p.API.LogError("Failed to remove blocklist entry", "entry_id", entryID, "user_id", userID, "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	p.API.LogInfo("Blocklist entry removed via API", "entry_id", entryID, "user_id", userID)
+	auditRecord.Success()
+
+	w.WriteHeader(http.StatusOK)
+}
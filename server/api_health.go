@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation/openai"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+// ConnectionTestRequest is the body of a request to test a moderation configuration.
+// Without a moderator configuration the saved configuration is tested.
+type ConnectionTestRequest struct {
+	ModeratorConfig *ModeratorConfig `json:"moderator_config"`
+}
+
+func (p *Plugin) handleGetHealth(w http.ResponseWriter, r *http.Request) {
+	report := p.healthReport()
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Enabled && !report.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		p.API.LogError("Failed to encode response", "err", err)
+	}
+}
+
+func (p *Plugin) handleTestConnection(w http.ResponseWriter, r *http.Request) {
+	var request ConnectionTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	config := p.getConfiguration().Clone()
+	if request.ModeratorConfig != nil {
+		config.ModeratorConfig = withSavedSecrets(*request.ModeratorConfig, config.ModeratorConfig)
+	}
+
+	report := p.testConnection(config)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		p.API.LogError("Failed to encode response", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// withSavedSecrets replaces API keys that the System Console masked with the saved
+// keys. A saved key is only used with the endpoint it was saved for, so it can't be
+// sent to another server by testing a changed endpoint. Otherwise the masked key is
+// cleared.
+func withSavedSecrets(moderatorConfig, saved ModeratorConfig) ModeratorConfig {
+	if moderatorConfig.AzureAPIKey == model.FakeSetting {
+		moderatorConfig.AzureAPIKey = ""
+		if sameEndpoint(moderatorConfig.AzureEndpoint, saved.AzureEndpoint, "") {
+			moderatorConfig.AzureAPIKey = saved.AzureAPIKey
+		}
+	}
+	if moderatorConfig.OpenAIAPIKey == model.FakeSetting {
+		moderatorConfig.OpenAIAPIKey = ""
+		if sameEndpoint(moderatorConfig.OpenAIEndpoint, saved.OpenAIEndpoint, openai.DefaultEndpoint) {
+			moderatorConfig.OpenAIAPIKey = saved.OpenAIAPIKey
+		}
+	}
+	return moderatorConfig
+}
+
+// sameEndpoint returns true if both endpoints point to the same server, ignoring
+// surrounding whitespace and trailing slashes. An empty endpoint is defaultEndpoint.
+func sameEndpoint(endpoint, saved, defaultEndpoint string) bool {
+	normalize := func(endpoint string) string {
+		endpoint = strings.TrimRight(strings.TrimSpace(endpoint), "/")
+		if endpoint == "" {
+			return defaultEndpoint
+		}
+		return strings.ToLower(endpoint)
+	}
+	return normalize(endpoint) == normalize(saved)
+}
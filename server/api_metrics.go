@@ -0,0 +1,35 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// requireMetricsAccess is a middleware that lets scrapers presenting the configured
+// metrics token through as a bearer token, and system admins otherwise
+func (p *Plugin) requireMetricsAccess(pluginContext *plugin.Context, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := p.getConfiguration().MetricsToken
+		authorization := r.Header.Get("Authorization")
+		if token != "" && strings.HasPrefix(authorization, "Bearer ") &&
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(authorization, "Bearer ")), []byte(token)) == 1 {
+			next(w, r)
+			return
+		}
+
+		p.requireSystemAdmin(pluginContext, next)(w, r)
+	}
+}
+
+func (p *Plugin) handleGetMetrics(w http.ResponseWriter, r *http.Request) {
+	if p.metrics == nil {
+		http.Error(w, "Metrics are not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	promhttp.HandlerFor(p.metrics.registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+// MonitorVerdictRequest is the body of a request to record a verdict on a monitored post
+type MonitorVerdictRequest struct {
+	Verdict MonitorVerdict `json:"verdict"`
+}
+
+// monitorDaysFromRequest reads the optional days query parameter
+func monitorDaysFromRequest(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("days")
+	if value == "" {
+		return defaultMonitorReportDays, nil
+	}
+	return parseMonitorDays(value)
+}
+
+func (p *Plugin) handleGetMonitorReport(w http.ResponseWriter, r *http.Request) {
+	days, err := monitorDaysFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := p.monitorReport(days)
+	if err != nil {
+		p.API.LogError("Failed to build monitor report", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		p.API.LogError("Failed to encode response", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (p *Plugin) handleListMonitorRecords(w http.ResponseWriter, r *http.Request) {
+	days, err := monitorDaysFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	records, err := p.unreviewedMonitorRecords(days)
+	if err != nil {
+		p.API.LogError("Failed to list monitored posts", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(records); err != nil {
+		p.API.LogError("Failed to encode response", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (p *Plugin) handleSetMonitorVerdict(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(string)
+	pluginContext := r.Context().Value(contextKeyPluginContext).(*plugin.Context)
+	recordID := mux.Vars(r)["recordId"]
+
+	auditRecord := plugin.MakeAuditRecordWithContext(auditEventTypeReviewModeration, model.AuditStatusAttempt, pluginContext, userID, r.URL.Path)
+	auditRecord.AddMeta(auditMetaKeyUserID, userID)
+	auditRecord.AddMeta(auditMetaKeyAction, "monitor_verdict")
+
+	if p.getConfiguration().AuditLoggingEnabled {
+		defer p.API.LogAuditRec(auditRecord)
+	}
+
+	var request MonitorVerdictRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		auditRecord.AddErrorDesc(err.Error())
+		auditRecord.Fail()
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !isValidMonitorVerdict(request.Verdict) {
+		auditRecord.AddErrorDesc("invalid verdict")
+		auditRecord.Fail()
+		http.Error(w, "Invalid verdict", http.StatusBadRequest)
+		return
+	}
+
+	record, err := p.setMonitorVerdict(recordID, request.Verdict, userID, auditRecord)
+	if err != nil {
+		auditRecord.AddErrorDesc(err.Error())
+		auditRecord.Fail()
+		if errors.Is(err, ErrMonitorRecordNotFound) {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		p.API.LogError("Failed to record verdict on monitored post", "monitor_record_id", recordID, "user_id", userID, "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	auditRecord.Success()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(record); err != nil {
+		p.API.LogError("Failed to encode response", "err", err)
+	}
+}
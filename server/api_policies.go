@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+)
+
+// policyScopeID returns the team or channel ID the request applies to (set by middleware)
+func policyScopeID(r *http.Request, scope PolicyScope) string {
+	if scope == PolicyScopeTeam {
+		return r.Context().Value(contextKeyTeamID).(string)
+	}
+	return r.Context().Value(contextKeyChannelID).(string)
+}
+
+func (p *Plugin) handleGetPolicy(scope PolicyScope) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(contextKeyUserID).(string)
+		id := policyScopeID(r, scope)
+
+		policy, err := p.policiesStore.GetPolicy(scope, id)
+		if err != nil {
+			p.API.LogError("Failed to get moderation policy", "scope", string(scope), "id", id, "user_id", userID, "err", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if policy == nil {
+			policy = &ModerationPolicy{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(policy); err != nil {
+			p.API.LogError("Failed to encode response", "err", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func (p *Plugin) handleSetPolicy(scope PolicyScope) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(contextKeyUserID).(string)
+		pluginContext := r.Context().Value(contextKeyPluginContext).(*plugin.Context)
+		id := policyScopeID(r, scope)
+
+		auditRecord := p.makePolicyAuditRecord(pluginContext, r, scope, id, userID, "set")
+		if p.getConfiguration().AuditLoggingEnabled {
+			defer p.API.LogAuditRec(auditRecord)
+		}
+
+		var policy ModerationPolicy
+		if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+			auditRecord.AddErrorDesc(err.Error())
+			auditRecord.Fail()
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := policy.validate(); err != nil {
+			auditRecord.AddErrorDesc(err.Error())
+			auditRecord.Fail()
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		auditRecord.AddMeta(auditMetaKeyPolicy, policy)
+
+		if err := p.policiesStore.SetPolicy(scope, id, &policy); err != nil {
+			p.API.LogError("Failed to set moderation policy", "scope", string(scope), "id", id, "user_id", userID, "err", err)
+			auditRecord.AddErrorDesc(err.Error())
+			auditRecord.Fail()
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		p.notifyStoreChanged(storeNamePolicies)
+		p.API.LogInfo("Moderation policy set via API", "scope", string(scope), "id", id, "user_id", userID)
+		auditRecord.Success()
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (p *Plugin) handleDeletePolicy(scope PolicyScope) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(contextKeyUserID).(string)
+		pluginContext := r.Context().Value(contextKeyPluginContext).(*plugin.Context)
+		id := policyScopeID(r, scope)
+
+		auditRecord := p.makePolicyAuditRecord(pluginContext, r, scope, id, userID, "delete")
+		if p.getConfiguration().AuditLoggingEnabled {
+			defer p.API.LogAuditRec(auditRecord)
+		}
+
+		if err := p.policiesStore.DeletePolicy(scope, id); err != nil {
+			p.API.LogError("Failed to delete moderation policy", "scope", string(scope), "id", id, "user_id", userID, "err", err)
+			auditRecord.AddErrorDesc(err.Error())
+			auditRecord.Fail()
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		p.notifyStoreChanged(storeNamePolicies)
+		p.API.LogInfo("Moderation policy deleted via API", "scope", string(scope), "id", id, "user_id", userID)
+		auditRecord.Success()
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (p *Plugin) makePolicyAuditRecord(pluginContext *plugin.Context, r *http.Request, scope PolicyScope, id, userID, action string) *model.AuditRecord {
+	auditRecord := plugin.MakeAuditRecordWithContext(auditEventTypeManageModerationPolicy, model.AuditStatusAttempt, pluginContext, userID, r.URL.Path)
+	auditRecord.AddMeta(auditMetaKeyPolicyScope, string(scope))
+	if scope == PolicyScopeTeam {
+		auditRecord.AddMeta(auditMetaKeyTeamID, id)
+	} else {
+		auditRecord.AddMeta(auditMetaKeyChannelID, id)
+	}
+	auditRecord.AddMeta(auditMetaKeyUserID, userID)
+	auditRecord.AddMeta(auditMetaKeyAction, action)
+	return auditRecord
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+// requireReviewer is a middleware that only lets reviewers and system admins through
+func (p *Plugin) requireReviewer(pluginContext *plugin.Context, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Header.Get("Mattermost-User-ID")
+		if userID == "" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if !p.isReviewer(userID) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeyUserID, userID)
+		ctx = context.WithValue(ctx, contextKeyPluginContext, pluginContext)
+		r = r.WithContext(ctx)
+
+		next(w, r)
+	}
+}
+
+func (p *Plugin) handleListReviewItems(w http.ResponseWriter, r *http.Request) {
+	items, err := p.reviewStore.ListItems()
+	if err != nil {
+		p.API.LogError("Failed to list review items", "err", err)
+		http.Error(w, "Failed to list review items", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(items); err != nil {
+		p.API.LogError("Failed to encode response", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (p *Plugin) handleApproveReviewItem(w http.ResponseWriter, r *http.Request) {
+	p.handleReviewDecision(w, r, "approve", p.approveReviewItem)
+}
+
+func (p *Plugin) handleRemoveReviewItem(w http.ResponseWriter, r *http.Request) {
+	p.handleReviewDecision(w, r, "remove", p.removeReviewItem)
+}
+
+func (p *Plugin) handleReviewDecision(w http.ResponseWriter, r *http.Request, action string, decide func(string, *model.AuditRecord) error) {
+	userID := r.Context().Value(contextKeyUserID).(string)
+	pluginContext := r.Context().Value(contextKeyPluginContext).(*plugin.Context)
+	postID := mux.Vars(r)["postId"]
+
+	auditRecord := plugin.MakeAuditRecordWithContext(auditEventTypeReviewModeration, model.AuditStatusAttempt, pluginContext, userID, r.URL.Path)
+	auditRecord.AddMeta(auditMetaKeyUserID, userID)
+	auditRecord.AddMeta(auditMetaKeyAction, action)
+
+	if p.getConfiguration().AuditLoggingEnabled {
+		defer p.API.LogAuditRec(auditRecord)
+	}
+
+	if err := decide(postID, auditRecord); err != nil {
+		auditRecord.AddErrorDesc(err.Error())
+		auditRecord.Fail()
+		if errors.Is(err, ErrReviewItemNotFound) {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		p.API.LogError("Failed to review post", "action", action, "post_id", postID, "user_id", userID, "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	p.API.LogInfo("Post reviewed via API", "action", action, "post_id", postID, "user_id", userID)
+	auditRecord.Success()
+
+	w.WriteHeader(http.StatusOK)
+}
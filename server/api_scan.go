@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+func (p *Plugin) handleListScanJobs(w http.ResponseWriter, r *http.Request) {
+	jobs, err := p.scanJobsStore.ListJobs()
+	if err != nil {
+		p.API.LogError("Failed to list scan jobs", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if jobs == nil {
+		jobs = []*ScanJob{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(jobs); err != nil {
+		p.API.LogError("Failed to encode response", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (p *Plugin) handleGetScanJob(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["jobId"]
+
+	job, err := p.scanJobsStore.GetJob(jobID)
+	if err != nil {
+		p.API.LogError("Failed to get scan job", "job_id", jobID, "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if job == nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		p.API.LogError("Failed to encode response", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (p *Plugin) handleStartScanJob(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(string)
+	pluginContext := r.Context().Value(contextKeyPluginContext).(*plugin.Context)
+
+	auditRecord := plugin.MakeAuditRecordWithContext(auditEventTypeManageScanJob, model.AuditStatusAttempt, pluginContext, userID, r.URL.Path)
+	auditRecord.AddMeta(auditMetaKeyUserID, userID)
+	auditRecord.AddMeta(auditMetaKeyAction, "start")
+
+	if p.getConfiguration().AuditLoggingEnabled {
+		defer p.API.LogAuditRec(auditRecord)
+	}
+
+	var request ScanJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		auditRecord.AddErrorDesc(err.Error())
+		auditRecord.Fail()
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := request.Validate(); err != nil {
+		auditRecord.AddErrorDesc(err.Error())
+		auditRecord.Fail()
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	auditRecord.AddMeta(auditMetaKeyPolicyScope, string(request.Scope))
+
+	job, err := p.startScanJob(userID, request)
+	if err != nil {
+		p.API.LogError("Failed to start scan job", "scope", string(request.Scope), "scope_id", request.ScopeID, "user_id", userID, "err", err)
+		auditRecord.AddErrorDesc(err.Error())
+		auditRecord.Fail()
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	p.API.LogInfo("Scan job started via API", "job_id", job.ID, "scope", string(job.Scope), "scope_id", job.ScopeID, "mode", string(job.Mode), "user_id", userID)
+	auditRecord.AddMeta(auditMetaKeyScanJobID, job.ID)
+	auditRecord.Success()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		p.API.LogError("Failed to encode response", "err", err)
+	}
+}
+
+// handleUpdateScanJob returns a handler that pauses, resumes or cancels a scan job
+func (p *Plugin) handleUpdateScanJob(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(contextKeyUserID).(string)
+		pluginContext := r.Context().Value(contextKeyPluginContext).(*plugin.Context)
+		jobID := mux.Vars(r)["jobId"]
+
+		auditRecord := plugin.MakeAuditRecordWithContext(auditEventTypeManageScanJob, model.AuditStatusAttempt, pluginContext, userID, r.URL.Path)
+		auditRecord.AddMeta(auditMetaKeyUserID, userID)
+		auditRecord.AddMeta(auditMetaKeyAction, action)
+		auditRecord.AddMeta(auditMetaKeyScanJobID, jobID)
+
+		if p.getConfiguration().AuditLoggingEnabled {
+			defer p.API.LogAuditRec(auditRecord)
+		}
+
+		job, err := p.updateScanJob(action, jobID)
+		if err != nil {
+			auditRecord.AddErrorDesc(err.Error())
+			auditRecord.Fail()
+			switch {
+			case errors.Is(err, ErrScanJobNotFound):
+				http.Error(w, "Not found", http.StatusNotFound)
+			case errors.Is(err, ErrScanJobNotRunning), errors.Is(err, ErrScanJobNotPaused), errors.Is(err, ErrScanJobFinished):
+				http.Error(w, err.Error(), http.StatusConflict)
+			default:
+				p.API.LogError("Failed to update scan job", "job_id", jobID, "action", action, "user_id", userID, "err", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		p.API.LogInfo("Scan job updated via API", "job_id", jobID, "action", action, "user_id", userID)
+		auditRecord.Success()
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(job); err != nil {
+			p.API.LogError("Failed to encode response", "err", err)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+func (p *Plugin) handleGetStats(w http.ResponseWriter, r *http.Request) {
+	days := defaultStatsReportDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		var err error
+		if days, err = parseStatsDays(value); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	report, err := p.statsReport(days)
+	if err != nil {
+		p.API.LogError("Failed to build moderation statistics", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		p.API.LogError("Failed to encode response", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
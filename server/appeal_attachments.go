@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+const (
+	// appealAttachmentsKVKeyPrefix stores the copies of the attachments preserved
+	// for an appeal, so they can be released once the appeal expires or is resolved
+	appealAttachmentsKVKeyPrefix = "appeal_attachments_"
+	appealAttachmentsIndexKVKey  = "appeal_attachments_index"
+
+	// appealAttachmentsCleanupInterval is how often the copies of appeals that
+	// expired or were resolved are released
+	appealAttachmentsCleanupInterval = time.Hour
+)
+
+// preservedAttachments is stored under appealAttachmentsKVKeyPrefix. Unlike the
+// appeal, it doesn't expire, so the copies can still be released after the appeal did.
+type preservedAttachments struct {
+	FileIDs     []string `json:"file_ids"`
+	PreservedAt int64    `json:"preserved_at"`
+}
+
+// preserveAttachments copies the attachments of a post that is about to be removed,
+// so they can be reinstated with it if the appeal succeeds. The copies are kept in
+// the direct channel of the bot with itself, where no user can see them, and are
+// released when the appeal expires or is resolved. Returns the IDs of the copies,
+// or nil if appeals are disabled.
+func (p *PostProcessor) preserveAttachments(api plugin.API, appealID string, post *model.Post) []string {
+	if p.appealsStore == nil || len(post.FileIds) == 0 {
+		return nil
+	}
+
+	channel, appErr := api.GetDirectChannel(p.botID, p.botID)
+	if appErr != nil {
+		api.LogError("Failed to get channel for attachments of removed post", "post_id", post.Id, "err", appErr)
+		return nil
+	}
+
+	fileIDs := copyAttachments(api, post.FileIds, channel.Id)
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	// The copies are recorded before the appeal is created, so they are released
+	// even if creating the appeal fails
+	if err := recordPreservedAttachments(api, appealID, fileIDs); err != nil {
+		api.LogError("Failed to record attachments of removed post", "post_id", post.Id, "err", err)
+		if err := p.releaseFiles(api, fileIDs); err != nil {
+			api.LogError("Failed to release attachments of removed post", "post_id", post.Id, "err", err)
+		}
+		return nil
+	}
+	return fileIDs
+}
+
+// restoreAttachments copies the preserved attachments of an appeal to the channel
+// of the removed post, so they can be attached to the reinstated post
+func restoreAttachments(api plugin.API, appeal *Appeal) []string {
+	if appeal.Post == nil || len(appeal.Post.FileIds) == 0 {
+		return nil
+	}
+	return copyAttachments(api, appeal.Post.FileIds, appeal.ChannelID)
+}
+
+// copyAttachments uploads copies of files to a channel. Files that can't be copied
+// are skipped.
+func copyAttachments(api plugin.API, fileIDs []string, channelID string) []string {
+	var copies []string
+	for _, fileID := range fileIDs {
+		info, appErr := api.GetFileInfo(fileID)
+		if appErr != nil {
+			api.LogError("Failed to get attachment", "file_id", fileID, "err", appErr)
+			continue
+		}
+		data, appErr := api.GetFile(fileID)
+		if appErr != nil {
+			api.LogError("Failed to read attachment", "file_id", fileID, "err", appErr)
+			continue
+		}
+		copied, appErr := api.UploadFile(data, channelID, info.Name)
+		if appErr != nil {
+			api.LogError("Failed to copy attachment", "file_id", fileID, "channel_id", channelID, "err", appErr)
+			continue
+		}
+		copies = append(copies, copied.Id)
+	}
+	return copies
+}
+
+func recordPreservedAttachments(api plugin.API, appealID string, fileIDs []string) error {
+	data, err := json.Marshal(preservedAttachments{
+		FileIDs:     fileIDs,
+		PreservedAt: model.GetMillis(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal preserved attachments")
+	}
+	if appErr := api.KVSet(appealAttachmentsKVKeyPrefix+appealID, data); appErr != nil {
+		return errors.Wrap(appErr, "failed to store preserved attachments")
+	}
+
+	if err := newKVIndex(api, appealAttachmentsIndexKVKey).add(appealID); err != nil {
+		if appErr := api.KVDelete(appealAttachmentsKVKeyPrefix + appealID); appErr != nil {
+			api.LogError("Failed to delete preserved attachments", "appeal_id", appealID, "err", appErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// releaseAppealAttachments deletes the preserved attachments of an appeal
+func (p *PostProcessor) releaseAppealAttachments(api plugin.API, appealID string) error {
+	preserved, err := getPreservedAttachments(api, appealID)
+	if err != nil {
+		return err
+	}
+
+	if preserved != nil {
+		if err := p.releaseFiles(api, preserved.FileIDs); err != nil {
+			return err
+		}
+		// The record is only deleted once the files are, so a failed release is
+		// retried by the cleanup
+		if appErr := api.KVDelete(appealAttachmentsKVKeyPrefix + appealID); appErr != nil {
+			return errors.Wrap(appErr, "failed to delete preserved attachments")
+		}
+	}
+	return newKVIndex(api, appealAttachmentsIndexKVKey).remove(appealID)
+}
+
+// releaseFiles deletes files that are not attached to any post. The plugin API
+// can't delete files, but deleting a post deletes its attachments, so the files
+// are attached to a post in the direct channel of the bot with itself, which is
+// deleted right away.
+func (p *PostProcessor) releaseFiles(api plugin.API, fileIDs []string) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	channel, appErr := api.GetDirectChannel(p.botID, p.botID)
+	if appErr != nil {
+		return errors.Wrap(appErr, "failed to get channel of preserved attachments")
+	}
+	post, appErr := api.CreatePost(&model.Post{
+		UserId:    p.botID,
+		ChannelId: channel.Id,
+		FileIds:   fileIDs,
+	})
+	if appErr != nil {
+		return errors.Wrap(appErr, "failed to attach preserved attachments")
+	}
+	if appErr := api.DeletePost(post.Id); appErr != nil {
+		return errors.Wrap(appErr, "failed to delete preserved attachments")
+	}
+	return nil
+}
+
+// releaseAppealAttachmentsLoop periodically releases the preserved attachments of
+// appeals that expired or were resolved
+func (p *PostProcessor) releaseAppealAttachmentsLoop(api plugin.API) {
+	ticker := time.NewTicker(appealAttachmentsCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.releaseStaleAppealAttachments(api)
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// releaseStaleAppealAttachments releases the preserved attachments of appeals that
+// expired, were never created or were resolved without releasing them. Releasing
+// the same attachments on several nodes at once is harmless.
+func (p *PostProcessor) releaseStaleAppealAttachments(api plugin.API) {
+	appealIDs, err := newKVIndex(api, appealAttachmentsIndexKVKey).list()
+	if err != nil {
+		api.LogError("Failed to list preserved attachments of appeals", "err", err)
+		return
+	}
+
+	now := model.GetMillis()
+	for _, appealID := range appealIDs {
+		preserved, err := getPreservedAttachments(api, appealID)
+		if err != nil {
+			api.LogError("Failed to get preserved attachments of appeal", "appeal_id", appealID, "err", err)
+			continue
+		}
+		// Recently preserved attachments may belong to an appeal that is still being created
+		if preserved != nil && now-preserved.PreservedAt < appealAttachmentsCleanupInterval.Milliseconds() {
+			continue
+		}
+
+		appeal, err := p.appealsStore.GetAppeal(appealID)
+		if err != nil {
+			api.LogError("Failed to get appeal of preserved attachments", "appeal_id", appealID, "err", err)
+			continue
+		}
+		if appeal != nil && (appeal.Status == AppealStatusAvailable || appeal.Status == AppealStatusPending) {
+			continue
+		}
+
+		if err := p.releaseAppealAttachments(api, appealID); err != nil {
+			api.LogError("Failed to release preserved attachments of appeal", "appeal_id", appealID, "err", err)
+		}
+	}
+}
+
+func getPreservedAttachments(api plugin.API, appealID string) (*preservedAttachments, error) {
+	data, appErr := api.KVGet(appealAttachmentsKVKeyPrefix + appealID)
+	if appErr != nil {
+		return nil, errors.Wrap(appErr, "failed to get preserved attachments")
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var preserved preservedAttachments
+	if err := json.Unmarshal(data, &preserved); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal preserved attachments")
+	}
+	return &preserved, nil
+}
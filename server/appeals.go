@@ -0,0 +1,286 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	appealActionSubmit    = "appeal_submit"
+	appealActionReinstate = "appeal_reinstate"
+	appealActionUphold    = "appeal_uphold"
+)
+
+const (
+	appealRequestText          = "If you believe this post was removed by mistake, you can ask a moderator to review it."
+	appealSubmittedText        = "_Your appeal was submitted to the moderators._"
+	appealModeratorTemplate    = "@%s appealed the removal of their post in ~%s:\n\n%s\n\n**Moderation scores:** %s"
+	appealReinstatedText       = "_Reinstated by @%s._"
+	appealUpheldText           = "_Removal upheld by @%s._"
+	appealReinstatedDMTemplate = "_Your appeal was accepted and your post with the following content has been reinstated:_\n\n%s"
+	appealUpheldDMTemplate     = "_Your appeal was reviewed by a moderator and the removal of your post with the following content was upheld:_\n\n%s"
+)
+
+var (
+	ErrAppealNotFound       = errors.New("appeal not found")
+	ErrAppealAlreadyHandled = errors.New("appeal was already handled")
+	ErrAppealNotAllowed     = errors.New("user is not allowed to act on this appeal")
+	ErrAppealsDisabled      = errors.New("appeals are not enabled")
+)
+
+// appealRequestAttachments builds the interactive attachment that lets an author appeal a removal
+func appealRequestAttachments(pluginID, appealID string) []*model.SlackAttachment {
+	return []*model.SlackAttachment{{
+		Text: appealRequestText,
+		Actions: []*model.PostAction{{
+			Id:   "appeal",
+			Type: model.PostActionTypeButton,
+			Name: "Appeal",
+			Integration: &model.PostActionIntegration{
+				URL: appealActionURL(pluginID, appealID, "submit"),
+			},
+		}},
+	}}
+}
+
+// appealModeratorAttachments builds the interactive attachment moderators use to decide an appeal
+func appealModeratorAttachments(pluginID, appealID string) []*model.SlackAttachment {
+	return []*model.SlackAttachment{{
+		Actions: []*model.PostAction{
+			{
+				Id:    "reinstate",
+				Type:  model.PostActionTypeButton,
+				Name:  "Reinstate",
+				Style: "good",
+				Integration: &model.PostActionIntegration{
+					URL: appealActionURL(pluginID, appealID, "reinstate"),
+				},
+			},
+			{
+				Id:    "uphold",
+				Type:  model.PostActionTypeButton,
+				Name:  "Uphold removal",
+				Style: "danger",
+				Integration: &model.PostActionIntegration{
+					URL: appealActionURL(pluginID, appealID, "uphold"),
+				},
+			},
+		},
+	}}
+}
+
+func appealActionURL(pluginID, appealID, action string) string {
+	return fmt.Sprintf("/plugins/%s/appeals/%s/%s", pluginID, appealID, action)
+}
+
+// formatModerationScores renders moderation scores in a stable order
+func formatModerationScores(result moderation.Result) string {
+	if len(result) == 0 {
+		return "none"
+	}
+
+	categories := make([]string, 0, len(result))
+	for category := range result {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	scores := make([]string, 0, len(categories))
+	for _, category := range categories {
+		scores = append(scores, fmt.Sprintf("%s: %d", category, result[category]))
+	}
+	return strings.Join(scores, ", ")
+}
+
+// submitAppeal routes an appeal from the author of a removed post to the moderators channel
+func (p *Plugin) submitAppeal(appealID, userID string, auditRecord *model.AuditRecord) (string, error) {
+	appeal, err := p.getAppeal(appealID, auditRecord)
+	if err != nil {
+		return "", err
+	}
+	if appeal.UserID != userID {
+		return "", ErrAppealNotAllowed
+	}
+	if appeal.Status != AppealStatusAvailable {
+		return "", ErrAppealAlreadyHandled
+	}
+
+	appealsChannelID := p.getConfiguration().AppealsChannelID
+	if appealsChannelID == "" {
+		return "", ErrAppealsDisabled
+	}
+
+	appeal.Status = AppealStatusPending
+	appeal.AppealedAt = model.GetMillis()
+	if err := p.updateAppeal(appeal, AppealStatusAvailable); err != nil {
+		return "", err
+	}
+
+	username, channelName := getDisplayNames(p.API, appeal.UserID, appeal.ChannelID)
+	moderatorPost := &model.Post{
+		UserId:    p.postProcessor.botID,
+		ChannelId: appealsChannelID,
+		Message: fmt.Sprintf(appealModeratorTemplate,
+			username, channelName, quoteMessage(appeal.Message), formatModerationScores(appeal.Result)),
+	}
+	model.ParseSlackAttachment(moderatorPost, appealModeratorAttachments(p.API.GetPluginID(), appeal.ID))
+	if _, appErr := p.API.CreatePost(moderatorPost); appErr != nil {
+		// Let the author try again later
+		appeal.Status = AppealStatusAvailable
+		appeal.AppealedAt = 0
+		if err := p.updateAppeal(appeal, AppealStatusPending); err != nil {
+			p.API.LogError("Failed to reset appeal after failing to notify moderators", "appeal_id", appeal.ID, "err", err)
+		}
+		return "", errors.Wrap(appErr, "failed to post appeal to the moderators channel")
+	}
+
+	return appealSubmittedText, nil
+}
+
+// reinstateAppeal recreates a removed post with its original author and thread
+func (p *Plugin) reinstateAppeal(appealID, moderatorID string, auditRecord *model.AuditRecord) (string, error) {
+	appeal, err := p.resolveAppeal(appealID, moderatorID, AppealStatusReinstated, auditRecord)
+	if err != nil {
+		return "", err
+	}
+
+	// The strike is removed first, so a restriction it caused doesn't keep the
+	// author from getting the post back
+	p.postProcessor.removeStrike(p.API, appeal.UserID, appeal.PostID)
+	if err := p.reinstatePost(appeal); err != nil {
+		appeal.Status = AppealStatusPending
+		appeal.ResolvedBy = ""
+		appeal.ResolvedAt = 0
+		if resetErr := p.updateAppeal(appeal, AppealStatusReinstated); resetErr != nil {
+			p.API.LogError("Failed to reset appeal after failing to reinstate post", "appeal_id", appeal.ID, "err", resetErr)
+		}
+		return "", err
+	}
+
+	p.notifyAppealAuthor(appeal, appealReinstatedDMTemplate)
+	return fmt.Sprintf(appealReinstatedText, getUsername(p.API, moderatorID)), nil
+}
+
+// upholdAppeal keeps the post removed and lets the author know
+func (p *Plugin) upholdAppeal(appealID, moderatorID string, auditRecord *model.AuditRecord) (string, error) {
+	appeal, err := p.resolveAppeal(appealID, moderatorID, AppealStatusUpheld, auditRecord)
+	if err != nil {
+		return "", err
+	}
+
+	p.releaseAppealAttachments(appeal)
+	p.notifyAppealAuthor(appeal, appealUpheldDMTemplate)
+	return fmt.Sprintf(appealUpheldText, getUsername(p.API, moderatorID)), nil
+}
+
+func (p *Plugin) resolveAppeal(appealID, moderatorID string, status AppealStatus, auditRecord *model.AuditRecord) (*Appeal, error) {
+	appeal, err := p.getAppeal(appealID, auditRecord)
+	if err != nil {
+		return nil, err
+	}
+	if appeal.Status != AppealStatusPending {
+		return nil, ErrAppealAlreadyHandled
+	}
+
+	appeal.Status = status
+	appeal.ResolvedBy = moderatorID
+	appeal.ResolvedAt = model.GetMillis()
+	if err := p.updateAppeal(appeal, AppealStatusPending); err != nil {
+		return nil, err
+	}
+	return appeal, nil
+}
+
+func (p *Plugin) reinstatePost(appeal *Appeal) error {
+	// The recreated post goes through moderation again, so the content needs
+	// to be approved first to prevent it from being removed again
+	if err := approveContent(p.API, appeal.UserID, appeal.ChannelID, appeal.Message); err != nil {
+		return err
+	}
+
+	rootID := appeal.RootID
+	if rootID != "" {
+		if _, appErr := p.API.GetPost(rootID); appErr != nil {
+			p.API.LogWarn("Thread of appealed post no longer exists, reinstating it as a root post", "appeal_id", appeal.ID, "root_id", rootID)
+			rootID = ""
+		}
+	}
+
+	post := reinstatedPost(appeal, rootID, restoreAttachments(p.API, appeal))
+	done := pluginPostWrites.beginCreate(post)
+	defer done()
+	if _, appErr := p.API.CreatePost(post); appErr != nil {
+		return errors.Wrap(appErr, "failed to reinstate post")
+	}
+
+	p.releaseAppealAttachments(appeal)
+	return nil
+}
+
+// releaseAppealAttachments deletes the attachments preserved for a resolved appeal.
+// If it fails, the cleanup of the post processor releases them later.
+func (p *Plugin) releaseAppealAttachments(appeal *Appeal) {
+	if err := p.postProcessor.releaseAppealAttachments(p.API, appeal.ID); err != nil {
+		p.API.LogError("Failed to release preserved attachments of appeal", "appeal_id", appeal.ID, "err", err)
+	}
+}
+
+// reinstatedPost returns the post to create for a reinstated appeal. It keeps the
+// props and creation time of the removed post, with the restored copies of its
+// attachments, so it shows up as it was.
+func reinstatedPost(appeal *Appeal, rootID string, fileIDs []string) *model.Post {
+	removed := appeal.Post
+	post := &model.Post{
+		UserId:    appeal.UserID,
+		ChannelId: appeal.ChannelID,
+		RootId:    rootID,
+		Message:   removed.Message,
+		Type:      removed.Type,
+		Hashtags:  removed.Hashtags,
+		FileIds:   fileIDs,
+		CreateAt:  removed.CreateAt,
+	}
+	post.SetProps(removed.GetProps())
+	return post
+}
+
+func (p *Plugin) getAppeal(appealID string, auditRecord *model.AuditRecord) (*Appeal, error) {
+	auditRecord.AddMeta(auditMetaKeyAppealID, appealID)
+
+	if p.postProcessor == nil {
+		return nil, ErrModerationUnavailable
+	}
+
+	appeal, err := p.appealsStore.GetAppeal(appealID)
+	if err != nil {
+		return nil, err
+	}
+	if appeal == nil {
+		return nil, ErrAppealNotFound
+	}
+	auditRecord.AddMeta(auditMetaKeyPostID, appeal.PostID)
+	auditRecord.AddMeta(auditMetaKeyChannelID, appeal.ChannelID)
+	auditRecord.AddMeta(auditMetaKeyResult, appeal.Result)
+	return appeal, nil
+}
+
+func (p *Plugin) updateAppeal(appeal *Appeal, previousStatus AppealStatus) error {
+	if err := p.appealsStore.UpdateAppeal(appeal, previousStatus); err != nil {
+		if errors.Is(err, ErrAppealStatusChanged) {
+			return ErrAppealAlreadyHandled
+		}
+		return err
+	}
+	return nil
+}
+
+func (p *Plugin) notifyAppealAuthor(appeal *Appeal, template string) {
+	if err := p.postProcessor.sendDirectMessage(p.API, appeal.UserID, fmt.Sprintf(template, appeal.Message)); err != nil {
+		p.API.LogError("Failed to notify author of appeal decision", "appeal_id", appeal.ID, "user_id", appeal.UserID, "err", err)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+const (
+	appealKVKeyPrefix = "appeal_"
+
+	// appealWindow is how long a user has to appeal the removal of a post
+	appealWindow = 7 * 24 * time.Hour
+)
+
+type AppealStatus string
+
+const (
+	AppealStatusAvailable  AppealStatus = "available"
+	AppealStatusPending    AppealStatus = "pending"
+	AppealStatusReinstated AppealStatus = "reinstated"
+	AppealStatusUpheld     AppealStatus = "upheld"
+)
+
+var ErrAppealStatusChanged = errors.New("appeal status was changed by another request")
+
+// Appeal keeps the content of a removed post so its author can contest the removal
+type Appeal struct {
+	ID         string            `json:"id"`
+	PostID     string            `json:"post_id"`
+	UserID     string            `json:"user_id"`
+	ChannelID  string            `json:"channel_id"`
+	RootID     string            `json:"root_id"`
+	Message    string            `json:"message"`
+	Result     moderation.Result `json:"result"`
+	Status     AppealStatus      `json:"status"`
+	ResolvedBy string            `json:"resolved_by,omitempty"`
+	RemovedAt  int64             `json:"removed_at"`
+	AppealedAt int64             `json:"appealed_at,omitempty"`
+	ResolvedAt int64             `json:"resolved_at,omitempty"`
+
+	// Post is the removed post, with copies of its attachments, so it can be
+	// reinstated as it was
+	Post *model.Post `json:"post,omitempty"`
+}
+
+type AppealsStore interface {
+	CreateAppeal(appeal *Appeal) error
+	GetAppeal(id string) (*Appeal, error)
+	// UpdateAppeal saves the appeal if its stored status still matches previousStatus
+	UpdateAppeal(appeal *Appeal, previousStatus AppealStatus) error
+}
+
+type appealsStore struct {
+	api plugin.API
+}
+
+func newAppealsStore(api plugin.API) *appealsStore {
+	return &appealsStore{
+		api: api,
+	}
+}
+
+func (s *appealsStore) CreateAppeal(appeal *Appeal) error {
+	data, err := json.Marshal(appeal)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal appeal")
+	}
+
+	// Appeals that are never submitted expire with the appeal window
+	if appErr := s.api.KVSetWithExpiry(appealKVKeyPrefix+appeal.ID, data, int64(appealWindow/time.Second)); appErr != nil {
+		return errors.Wrap(appErr, "failed to store appeal")
+	}
+	return nil
+}
+
+func (s *appealsStore) GetAppeal(id string) (*Appeal, error) {
+	appeal, _, err := s.getAppealWithData(id)
+	return appeal, err
+}
+
+func (s *appealsStore) UpdateAppeal(appeal *Appeal, previousStatus AppealStatus) error {
+	stored, oldData, err := s.getAppealWithData(appeal.ID)
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		return errors.New("appeal not found")
+	}
+	if stored.Status != previousStatus {
+		return ErrAppealStatusChanged
+	}
+
+	data, err := json.Marshal(appeal)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal appeal")
+	}
+
+	saved, appErr := s.api.KVCompareAndSet(appealKVKeyPrefix+appeal.ID, oldData, data)
+	if appErr != nil {
+		return errors.Wrap(appErr, "failed to update appeal")
+	}
+	if !saved {
+		return ErrAppealStatusChanged
+	}
+	return nil
+}
+
+func (s *appealsStore) getAppealWithData(id string) (*Appeal, []byte, error) {
+	data, appErr := s.api.KVGet(appealKVKeyPrefix + id)
+	if appErr != nil {
+		return nil, nil, errors.Wrap(appErr, "failed to get appeal")
+	}
+	if data == nil {
+		return nil, nil, nil
+	}
+
+	var appeal Appeal
+	if err := json.Unmarshal(data, &appeal); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to unmarshal appeal")
+	}
+	return &appeal, data, nil
+}
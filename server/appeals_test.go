@@ -0,0 +1,410 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var _ AppealsStore = (*MockAppealsStore)(nil)
+
+type MockAppealsStore struct {
+	appeals map[string]*Appeal
+}
+
+func NewMockAppealsStore() *MockAppealsStore {
+	return &MockAppealsStore{
+		appeals: make(map[string]*Appeal),
+	}
+}
+
+func (m *MockAppealsStore) CreateAppeal(appeal *Appeal) error {
+	stored := *appeal
+	m.appeals[appeal.ID] = &stored
+	return nil
+}
+
+func (m *MockAppealsStore) GetAppeal(id string) (*Appeal, error) {
+	appeal, ok := m.appeals[id]
+	if !ok {
+		return nil, nil
+	}
+	stored := *appeal
+	return &stored, nil
+}
+
+func (m *MockAppealsStore) UpdateAppeal(appeal *Appeal, previousStatus AppealStatus) error {
+	stored, ok := m.appeals[appeal.ID]
+	if !ok || stored.Status != previousStatus {
+		return ErrAppealStatusChanged
+	}
+	updated := *appeal
+	m.appeals[appeal.ID] = &updated
+	return nil
+}
+
+func newAppealTestPlugin(api *plugintest.API, appeals *MockAppealsStore) *Plugin {
+	p := &Plugin{
+		configuration: &configuration{AppealsChannelID: "appeals_channel"},
+		appealsStore:  appeals,
+		postProcessor: &PostProcessor{
+			botID:         "bot123",
+			postCache:     newPostCache(),
+			cleanupTicker: time.NewTicker(24 * time.Hour),
+		},
+	}
+	p.SetAPI(api)
+	return p
+}
+
+func newTestAppeal(status AppealStatus) *Appeal {
+	return &Appeal{
+		ID:        "appeal123",
+		PostID:    "post123",
+		UserID:    "user456",
+		ChannelID: "channel123",
+		RootID:    "root123",
+		Message:   "original message",
+		Result:    moderation.Result{"hate": 4, "violence": 2},
+		Status:    status,
+		Post: &model.Post{
+			Id:        "post123",
+			UserId:    "user456",
+			ChannelId: "channel123",
+			RootId:    "root123",
+			Message:   "original message",
+		},
+	}
+}
+
+func TestFormatModerationScores(t *testing.T) {
+	assert.Equal(t, "hate: 4, violence: 2", formatModerationScores(moderation.Result{"violence": 2, "hate": 4}))
+	assert.Equal(t, "none", formatModerationScores(nil))
+}
+
+func TestPlugin_submitAppeal(t *testing.T) {
+	t.Run("posts appeal to the appeals channel", func(t *testing.T) {
+		appeals := NewMockAppealsStore()
+		appeals.appeals["appeal123"] = newTestAppeal(AppealStatusAvailable)
+
+		api := &plugintest.API{}
+		api.On("GetUser", "user456").Return(&model.User{Username: "author"}, nil)
+		api.On("GetChannel", "channel123").Return(&model.Channel{Name: "town-square"}, nil)
+		api.On("GetPluginID").Return("com.mattermost.content-moderation")
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "appeals_channel" &&
+				post.UserId == "bot123" &&
+				len(post.Attachments()) == 1 &&
+				len(post.Attachments()[0].Actions) == 2
+		})).Return(&model.Post{}, nil)
+
+		p := newAppealTestPlugin(api, appeals)
+		record := plugin.MakeAuditRecord("test", model.AuditStatusAttempt)
+
+		status, err := p.submitAppeal("appeal123", "user456", record)
+		require.NoError(t, err)
+		assert.Equal(t, appealSubmittedText, status)
+		assert.Equal(t, AppealStatusPending, appeals.appeals["appeal123"].Status)
+		api.AssertExpectations(t)
+	})
+
+	t.Run("rejects appeal from another user", func(t *testing.T) {
+		appeals := NewMockAppealsStore()
+		appeals.appeals["appeal123"] = newTestAppeal(AppealStatusAvailable)
+
+		api := &plugintest.API{}
+		p := newAppealTestPlugin(api, appeals)
+		record := plugin.MakeAuditRecord("test", model.AuditStatusAttempt)
+
+		_, err := p.submitAppeal("appeal123", "other_user", record)
+		assert.ErrorIs(t, err, ErrAppealNotAllowed)
+		assert.Equal(t, AppealStatusAvailable, appeals.appeals["appeal123"].Status)
+	})
+
+	t.Run("rejects appeal submitted twice", func(t *testing.T) {
+		appeals := NewMockAppealsStore()
+		appeals.appeals["appeal123"] = newTestAppeal(AppealStatusPending)
+
+		api := &plugintest.API{}
+		p := newAppealTestPlugin(api, appeals)
+		record := plugin.MakeAuditRecord("test", model.AuditStatusAttempt)
+
+		_, err := p.submitAppeal("appeal123", "user456", record)
+		assert.ErrorIs(t, err, ErrAppealAlreadyHandled)
+	})
+
+	t.Run("returns not found for expired appeal", func(t *testing.T) {
+		api := &plugintest.API{}
+		p := newAppealTestPlugin(api, NewMockAppealsStore())
+		record := plugin.MakeAuditRecord("test", model.AuditStatusAttempt)
+
+		_, err := p.submitAppeal("appeal123", "user456", record)
+		assert.ErrorIs(t, err, ErrAppealNotFound)
+	})
+
+	t.Run("resets appeal when moderators can't be notified", func(t *testing.T) {
+		appeals := NewMockAppealsStore()
+		appeals.appeals["appeal123"] = newTestAppeal(AppealStatusAvailable)
+
+		api := &plugintest.API{}
+		api.On("GetUser", "user456").Return(&model.User{Username: "author"}, nil)
+		api.On("GetChannel", "channel123").Return(&model.Channel{Name: "town-square"}, nil)
+		api.On("GetPluginID").Return("com.mattermost.content-moderation")
+		api.On("CreatePost", mock.Anything).Return(nil, model.NewAppError("CreatePost", "error", nil, "", 500))
+
+		p := newAppealTestPlugin(api, appeals)
+		record := plugin.MakeAuditRecord("test", model.AuditStatusAttempt)
+
+		_, err := p.submitAppeal("appeal123", "user456", record)
+		assert.Error(t, err)
+		assert.Equal(t, AppealStatusAvailable, appeals.appeals["appeal123"].Status)
+	})
+}
+
+func TestPlugin_reinstateAppeal(t *testing.T) {
+	t.Run("recreates post in original thread", func(t *testing.T) {
+		appeals := NewMockAppealsStore()
+		appeals.appeals["appeal123"] = newTestAppeal(AppealStatusPending)
+
+		api := &plugintest.API{}
+		api.On("KVSetWithExpiry", approvedContentKey("user456", "channel123", "original message"), []byte("1"), mock.Anything).Return(nil)
+		api.On("GetPost", "root123").Return(&model.Post{Id: "root123"}, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.UserId == "user456" &&
+				post.ChannelId == "channel123" &&
+				post.RootId == "root123" &&
+				post.Message == "original message"
+		})).Return(&model.Post{}, nil)
+		api.On("GetDirectChannel", "bot123", "user456").Return(&model.Channel{Id: "dm_channel"}, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "dm_channel"
+		})).Return(&model.Post{}, nil)
+		api.On("GetUser", "moderator789").Return(&model.User{Username: "moderator"}, nil)
+		api.On("KVGet", appealAttachmentsKVKeyPrefix+"appeal123").Return(nil, nil)
+		api.On("KVGet", appealAttachmentsIndexKVKey).Return(nil, nil)
+
+		p := newAppealTestPlugin(api, appeals)
+		record := plugin.MakeAuditRecord("test", model.AuditStatusAttempt)
+
+		status, err := p.reinstateAppeal("appeal123", "moderator789", record)
+		require.NoError(t, err)
+		assert.Equal(t, "_Reinstated by @moderator._", status)
+		assert.Equal(t, AppealStatusReinstated, appeals.appeals["appeal123"].Status)
+		assert.Equal(t, "moderator789", appeals.appeals["appeal123"].ResolvedBy)
+		api.AssertExpectations(t)
+	})
+
+	t.Run("recreates post as root post if thread was deleted", func(t *testing.T) {
+		appeals := NewMockAppealsStore()
+		appeals.appeals["appeal123"] = newTestAppeal(AppealStatusPending)
+
+		api := &plugintest.API{}
+		api.On("KVSetWithExpiry", approvedContentKey("user456", "channel123", "original message"), []byte("1"), mock.Anything).Return(nil)
+		api.On("GetPost", "root123").Return(nil, model.NewAppError("GetPost", "not found", nil, "", 404))
+		api.On("LogWarn", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "channel123" && post.RootId == ""
+		})).Return(&model.Post{}, nil)
+		api.On("GetDirectChannel", "bot123", "user456").Return(&model.Channel{Id: "dm_channel"}, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "dm_channel"
+		})).Return(&model.Post{}, nil)
+		api.On("GetUser", "moderator789").Return(&model.User{Username: "moderator"}, nil)
+		api.On("KVGet", appealAttachmentsKVKeyPrefix+"appeal123").Return(nil, nil)
+		api.On("KVGet", appealAttachmentsIndexKVKey).Return(nil, nil)
+
+		p := newAppealTestPlugin(api, appeals)
+		record := plugin.MakeAuditRecord("test", model.AuditStatusAttempt)
+
+		_, err := p.reinstateAppeal("appeal123", "moderator789", record)
+		require.NoError(t, err)
+		api.AssertExpectations(t)
+	})
+
+	t.Run("restores the props, attachments and creation time of the post", func(t *testing.T) {
+		appeal := newTestAppeal(AppealStatusPending)
+		appeal.Post = &model.Post{
+			Id:        "post123",
+			UserId:    "user456",
+			ChannelId: "channel123",
+			RootId:    "root123",
+			Message:   "original message",
+			FileIds:   []string{"copy1"},
+			CreateAt:  1000,
+		}
+		model.ParseSlackAttachment(appeal.Post, []*model.SlackAttachment{{Text: "attachment text"}})
+		appeals := NewMockAppealsStore()
+		appeals.appeals["appeal123"] = appeal
+
+		api := &plugintest.API{}
+		api.On("KVSetWithExpiry", approvedContentKey("user456", "channel123", "original message"), []byte("1"), mock.Anything).Return(nil)
+		api.On("GetPost", "root123").Return(&model.Post{Id: "root123"}, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "channel123" &&
+				post.Id == "" &&
+				post.Message == "original message" &&
+				len(post.Attachments()) == 1 && post.Attachments()[0].Text == "attachment text" &&
+				len(post.FileIds) == 1 && post.FileIds[0] == "restored1" &&
+				post.CreateAt == 1000
+		})).Return(&model.Post{}, nil)
+		api.On("GetDirectChannel", "bot123", "user456").Return(&model.Channel{Id: "dm_channel"}, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "dm_channel"
+		})).Return(&model.Post{}, nil)
+		api.On("GetUser", "moderator789").Return(&model.User{Username: "moderator"}, nil)
+
+		// The preserved copy is restored to the channel and released
+		api.On("GetFileInfo", "copy1").Return(&model.FileInfo{Id: "copy1", Name: "report.pdf"}, nil)
+		api.On("GetFile", "copy1").Return([]byte("content"), nil)
+		api.On("UploadFile", []byte("content"), "channel123", "report.pdf").Return(&model.FileInfo{Id: "restored1"}, nil)
+		preserved, err := json.Marshal(preservedAttachments{FileIDs: []string{"copy1"}})
+		require.NoError(t, err)
+		api.On("KVGet", appealAttachmentsKVKeyPrefix+"appeal123").Return(preserved, nil)
+		api.On("GetDirectChannel", "bot123", "bot123").Return(&model.Channel{Id: "bot_channel"}, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "bot_channel" && len(post.FileIds) == 1 && post.FileIds[0] == "copy1"
+		})).Return(&model.Post{Id: "release_post"}, nil)
+		api.On("DeletePost", "release_post").Return(nil)
+		api.On("KVDelete", appealAttachmentsKVKeyPrefix+"appeal123").Return(nil)
+		api.On("KVGet", appealAttachmentsIndexKVKey).Return([]byte(`["appeal123"]`), nil)
+		api.On("KVCompareAndSet", appealAttachmentsIndexKVKey, []byte(`["appeal123"]`), []byte(`[]`)).Return(true, nil)
+
+		p := newAppealTestPlugin(api, appeals)
+		record := plugin.MakeAuditRecord("test", model.AuditStatusAttempt)
+
+		_, err = p.reinstateAppeal("appeal123", "moderator789", record)
+		require.NoError(t, err)
+		api.AssertExpectations(t)
+	})
+
+	t.Run("rejects appeal that was not submitted", func(t *testing.T) {
+		appeals := NewMockAppealsStore()
+		appeals.appeals["appeal123"] = newTestAppeal(AppealStatusAvailable)
+
+		api := &plugintest.API{}
+		p := newAppealTestPlugin(api, appeals)
+		record := plugin.MakeAuditRecord("test", model.AuditStatusAttempt)
+
+		_, err := p.reinstateAppeal("appeal123", "moderator789", record)
+		assert.ErrorIs(t, err, ErrAppealAlreadyHandled)
+	})
+}
+
+func TestPlugin_upholdAppeal(t *testing.T) {
+	appeals := NewMockAppealsStore()
+	appeals.appeals["appeal123"] = newTestAppeal(AppealStatusPending)
+
+	api := &plugintest.API{}
+	api.On("GetDirectChannel", "bot123", "user456").Return(&model.Channel{Id: "dm_channel"}, nil)
+	api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+		return post.ChannelId == "dm_channel"
+	})).Return(&model.Post{}, nil)
+	api.On("GetUser", "moderator789").Return(&model.User{Username: "moderator"}, nil)
+	api.On("KVGet", appealAttachmentsKVKeyPrefix+"appeal123").Return(nil, nil)
+	api.On("KVGet", appealAttachmentsIndexKVKey).Return(nil, nil)
+
+	p := newAppealTestPlugin(api, appeals)
+	record := plugin.MakeAuditRecord("test", model.AuditStatusAttempt)
+
+	status, err := p.upholdAppeal("appeal123", "moderator789", record)
+	require.NoError(t, err)
+	assert.Equal(t, "_Removal upheld by @moderator._", status)
+	assert.Equal(t, AppealStatusUpheld, appeals.appeals["appeal123"].Status)
+	api.AssertExpectations(t)
+
+	_, err = p.upholdAppeal("appeal123", "moderator789", record)
+	assert.ErrorIs(t, err, ErrAppealAlreadyHandled)
+}
+
+func TestPostProcessor_createAppeal_preservesAttachments(t *testing.T) {
+	api := newKVTestAPI()
+	api.On("GetDirectChannel", "bot123", "bot123").Return(&model.Channel{Id: "bot_channel"}, nil)
+	api.On("GetFileInfo", "file1").Return(&model.FileInfo{Id: "file1", Name: "report.pdf"}, nil)
+	api.On("GetFile", "file1").Return([]byte("content"), nil)
+	api.On("UploadFile", []byte("content"), "bot_channel", "report.pdf").Return(&model.FileInfo{Id: "copy1"}, nil)
+
+	appeals := NewMockAppealsStore()
+	processor := &PostProcessor{botID: "bot123", appealsStore: appeals}
+	post := &model.Post{
+		Id:        "post123",
+		UserId:    "user456",
+		ChannelId: "channel123",
+		Message:   "original message",
+		FileIds:   []string{"file1"},
+		CreateAt:  1000,
+		Metadata:  &model.PostMetadata{},
+	}
+
+	fileIDs := processor.preserveAttachments(api, "appeal123", post)
+	assert.Equal(t, []string{"copy1"}, fileIDs)
+
+	preserved, err := getPreservedAttachments(api, "appeal123")
+	require.NoError(t, err)
+	require.NotNil(t, preserved)
+	assert.Equal(t, []string{"copy1"}, preserved.FileIDs)
+	ids, err := newKVIndex(api, appealAttachmentsIndexKVKey).list()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"appeal123"}, ids)
+
+	record := plugin.MakeAuditRecord("test", model.AuditStatusAttempt)
+	appealID := processor.createAppeal(api, "appeal123", post, fileIDs, moderation.Result{"hate": 6}, record)
+	require.Equal(t, "appeal123", appealID)
+
+	appeal := appeals.appeals[appealID]
+	require.NotNil(t, appeal.Post)
+	assert.Equal(t, model.StringArray{"copy1"}, appeal.Post.FileIds)
+	assert.Equal(t, int64(1000), appeal.Post.CreateAt)
+	assert.Nil(t, appeal.Post.Metadata)
+	assert.Equal(t, model.StringArray{"file1"}, post.FileIds, "the removed post is left unchanged")
+}
+
+func TestPostProcessor_releaseStaleAppealAttachments(t *testing.T) {
+	stale := model.GetMillis() - 2*appealAttachmentsCleanupInterval.Milliseconds()
+	preserve := func(t *testing.T, api plugin.API, appealID, fileID string, preservedAt int64) {
+		data, err := json.Marshal(preservedAttachments{FileIDs: []string{fileID}, PreservedAt: preservedAt})
+		require.NoError(t, err)
+		require.Nil(t, api.KVSet(appealAttachmentsKVKeyPrefix+appealID, data))
+		require.NoError(t, newKVIndex(api, appealAttachmentsIndexKVKey).add(appealID))
+	}
+
+	api := newKVTestAPI()
+	api.On("GetDirectChannel", "bot123", "bot123").Return(&model.Channel{Id: "bot_channel"}, nil)
+	api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+		return post.ChannelId == "bot_channel"
+	})).Return(func(post *model.Post) (*model.Post, *model.AppError) {
+		return &model.Post{Id: "release_" + post.FileIds[0]}, nil
+	})
+	api.On("DeletePost", mock.Anything).Return(nil)
+
+	appeals := NewMockAppealsStore()
+	appeals.appeals["pending"] = &Appeal{ID: "pending", Status: AppealStatusPending}
+	appeals.appeals["upheld"] = &Appeal{ID: "upheld", Status: AppealStatusUpheld}
+	preserve(t, api, "expired", "file_expired", stale)
+	preserve(t, api, "pending", "file_pending", stale)
+	preserve(t, api, "upheld", "file_upheld", stale)
+	preserve(t, api, "recent", "file_recent", model.GetMillis())
+
+	processor := &PostProcessor{botID: "bot123", appealsStore: appeals}
+	processor.releaseStaleAppealAttachments(api)
+
+	api.AssertCalled(t, "DeletePost", "release_file_expired")
+	api.AssertCalled(t, "DeletePost", "release_file_upheld")
+	api.AssertNumberOfCalls(t, "DeletePost", 2)
+
+	ids, err := newKVIndex(api, appealAttachmentsIndexKVKey).list()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pending", "recent"}, ids)
+	for _, appealID := range []string{"expired", "upheld"} {
+		preserved, err := getPreservedAttachments(api, appealID)
+		require.NoError(t, err)
+		assert.Nil(t, preserved)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+const (
+	approvedContentKVKeyPrefix = "approved_"
+	approvedContentTTL         = 24 * time.Hour
+)
+
+// approvedContentKey identifies a message posted by a user in a channel. Content
+// is only approved for the same author and channel it was reviewed in.
+func approvedContentKey(userID, channelID, message string) string {
+	hash := sha256.Sum256([]byte(userID + ":" + channelID + ":" + message))
+	return approvedContentKVKeyPrefix + hex.EncodeToString(hash[:])
+}
+
+// approveContent records that a reviewer approved a message, so restoring or
+// reinstating it does not cause it to be flagged again
+func approveContent(api plugin.API, userID, channelID, message string) error {
+	key := approvedContentKey(userID, channelID, message)
+	if appErr := api.KVSetWithExpiry(key, []byte("1"), int64(approvedContentTTL/time.Second)); appErr != nil {
+		return errors.Wrap(appErr, "failed to store approved content")
+	}
+	return nil
+}
+
+func isContentApproved(api plugin.API, userID, channelID, message string) (bool, error) {
+	data, appErr := api.KVGet(approvedContentKey(userID, channelID, message))
+	if appErr != nil {
+		return false, errors.Wrap(appErr, "failed to get approved content")
+	}
+	return data != nil, nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+)
+
+const (
+	// attachmentChunkLength is the most characters of attachment text sent to the
+	// moderation provider at once, well below the limits of the providers
+	attachmentChunkLength = 4000
+	// maxAttachmentTextLength caps the attachment text moderated per post, so a
+	// large document can't exhaust the rate limit
+	maxAttachmentTextLength = 20000
+	// maxPlainTextFileSize is the largest plain text file downloaded when the
+	// server has not extracted its content
+	maxPlainTextFileSize = 1024 * 1024
+)
+
+// plainTextExtensions are the files whose content can be moderated as is when the
+// server has not extracted it, e.g. because document search is disabled
+var plainTextExtensions = map[string]struct{}{
+	"txt":      {},
+	"text":     {},
+	"md":       {},
+	"markdown": {},
+	"csv":      {},
+	"log":      {},
+}
+
+// queueAttachedContent queues the text of a post's attachments and the images
+// attached to or linked from it for moderation, and returns the keys of the
+// queued content. The message itself is queued by the hooks.
+func (p *PostProcessor) queueAttachedContent(api plugin.API, post *model.Post) []string {
+	files := p.attachedFiles(api, post)
+	keys := p.queueAttachments(api, post, files)
+	return append(keys, p.queueImages(api, post, files)...)
+}
+
+// queuePostAttachedContent queues the attached content of a post when the post is
+// queued, so it is moderated while the post waits in the queue. The keys of the
+// queued content are kept until the post is processed.
+func (p *PostProcessor) queuePostAttachedContent(api plugin.API, post *model.Post) {
+	if p.moderationProcessor == nil || (!p.moderateAttachments && !p.moderateImages) {
+		return
+	}
+	if p.isExcluded(api, post.UserId, post.ChannelId) {
+		return
+	}
+
+	keys := p.queueAttachedContent(api, post)
+	if len(keys) == 0 {
+		return
+	}
+
+	p.attachedKeysLock.Lock()
+	defer p.attachedKeysLock.Unlock()
+	if p.attachedKeys == nil {
+		p.attachedKeys = make(map[*model.Post][]string)
+	}
+	p.attachedKeys[post] = keys
+}
+
+// takeAttachedKeys returns the keys of the attached content queued with a post
+// and forgets them
+func (p *PostProcessor) takeAttachedKeys(post *model.Post) []string {
+	p.attachedKeysLock.Lock()
+	defer p.attachedKeysLock.Unlock()
+	keys := p.attachedKeys[post]
+	delete(p.attachedKeys, post)
+	return keys
+}
+
+// attachedFiles returns the attachments of a post if their content is moderated
+func (p *PostProcessor) attachedFiles(api plugin.API, post *model.Post) []*model.FileInfo {
+	if p.moderationProcessor == nil || (!p.moderateAttachments && !p.moderateImages) {
+		return nil
+	}
+
+	var files []*model.FileInfo
+	for _, fileID := range post.FileIds {
+		info, appErr := api.GetFileInfo(fileID)
+		if appErr != nil {
+			api.LogError("Failed to get attachment for moderation", "post_id", post.Id, "file_id", fileID, "err", appErr)
+			continue
+		}
+		files = append(files, info)
+	}
+	return files
+}
+
+// queueAttachments queues the text of attachments for moderation and returns the
+// queued chunks. Returns nil if attachments are not moderated or have no text.
+func (p *PostProcessor) queueAttachments(api plugin.API, post *model.Post, files []*model.FileInfo) []string {
+	if !p.moderateAttachments || p.moderationProcessor == nil {
+		return nil
+	}
+
+	chunks := attachmentChunks(api, post.Id, files)
+	for _, chunk := range chunks {
+		p.moderationProcessor.queueMessage(api, chunk, moderationPriorityNormal)
+	}
+	return chunks
+}
+
+// waitForPostResult waits for the results of a post's message and attached
+// content and combines them into the result of the post. Returns nil if any
+// result did not arrive before the timeout.
+func (p *PostProcessor) waitForPostResult(post *model.Post, keys []string, timeout time.Duration) *moderationResult {
+	if text := postText(post); text != "" {
+		keys = append([]string{text}, keys...)
+	}
+
+	deadline := time.Now().Add(timeout)
+	var combined *moderationResult
+	for _, key := range keys {
+		result := p.resultsCache.waitForResult(key, time.Until(deadline))
+		if result == nil {
+			return nil
+		}
+		combined = combineResults(combined, result)
+	}
+	return combined
+}
+
+// combineResults merges the result of other content of the same post into the
+// combined result. The highest severity of each category counts, and flagged
+// content flags the post even if other content could not be moderated. Policies
+// with other thresholds re-evaluate the severities, see effectivePolicy.postResult.
+func combineResults(combined, result *moderationResult) *moderationResult {
+	if combined == nil {
+		return result
+	}
+
+	merged := &moderationResult{
+		code:      combined.code,
+		result:    moderation.Result{},
+		err:       combined.err,
+		timestamp: combined.timestamp,
+	}
+	for category, severity := range combined.result {
+		merged.result[category] = severity
+	}
+	for category, severity := range result.result {
+		if current, ok := merged.result[category]; !ok || severity > current {
+			merged.result[category] = severity
+		}
+	}
+	if resultCodeRank(result.code) > resultCodeRank(merged.code) {
+		merged.code = result.code
+	}
+	if merged.err == nil {
+		merged.err = result.err
+	}
+	return merged
+}
+
+// resultCodeRank orders result codes by which one decides the result of a post
+func resultCodeRank(code moderationResultCode) int {
+	switch code {
+	case moderationResultFlagged:
+		return 3
+	case moderationResultPending:
+		return 2
+	case moderationResultError:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// attachmentChunks returns the text of attachments, split into chunks small
+// enough for the moderation providers
+func attachmentChunks(api plugin.API, postID string, files []*model.FileInfo) []string {
+	var chunks []string
+	remaining := maxAttachmentTextLength
+	for _, info := range files {
+		text := strings.TrimSpace(attachmentText(api, info))
+		if length := utf8.RuneCountInString(text); length > remaining {
+			api.LogWarn("Attachment text exceeds the moderated length and is only partially moderated",
+				"post_id", postID, "file_id", info.Id, "length", length, "moderated", remaining)
+			text = truncateRunes(text, remaining)
+		}
+		remaining -= utf8.RuneCountInString(text)
+		chunks = append(chunks, chunkText(text, attachmentChunkLength)...)
+		if remaining <= 0 {
+			break
+		}
+	}
+	return chunks
+}
+
+// attachmentText returns the text the server extracted from an attachment, or the
+// content of plain text files it did not extract
+func attachmentText(api plugin.API, info *model.FileInfo) string {
+	if info.Content != "" {
+		return stripNUL(info.Content)
+	}
+	if _, ok := plainTextExtensions[strings.ToLower(info.Extension)]; !ok || info.Size > maxPlainTextFileSize {
+		return ""
+	}
+
+	data, appErr := api.GetFile(info.Id)
+	if appErr != nil {
+		api.LogError("Failed to read attachment for moderation", "file_id", info.Id, "err", appErr)
+		return ""
+	}
+	if !utf8.Valid(data) {
+		return ""
+	}
+	return stripNUL(string(data))
+}
+
+// stripNUL removes NUL characters, which are reserved for the keys of queued images
+func stripNUL(text string) string {
+	return strings.ReplaceAll(text, "\x00", "")
+}
+
+// chunkText splits text into chunks of at most maxLength characters, breaking at
+// whitespace where possible so words are not cut in half
+func chunkText(text string, maxLength int) []string {
+	var chunks []string
+	runes := []rune(text)
+	for len(runes) > 0 {
+		if unicode.IsSpace(runes[0]) {
+			runes = runes[1:]
+			continue
+		}
+
+		end := len(runes)
+		if end > maxLength {
+			end = maxLength
+			for i := maxLength; i > maxLength/2; i-- {
+				if unicode.IsSpace(runes[i]) {
+					end = i
+					break
+				}
+			}
+		}
+
+		if chunk := strings.TrimSpace(string(runes[:end])); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		runes = runes[end:]
+	}
+	return chunks
+}
+
+// truncateRunes returns the first maxLength characters of text
+func truncateRunes(text string, maxLength int) string {
+	if utf8.RuneCountInString(text) <= maxLength {
+		return text
+	}
+	return string([]rune(text)[:maxLength])
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkText(t *testing.T) {
+	assert.Empty(t, chunkText("", 10))
+	assert.Equal(t, []string{"short text"}, chunkText("short text", 10))
+	assert.Equal(t, []string{"one two", "three four"}, chunkText("one two three four", 10))
+
+	// Text without whitespace is cut at the maximum length
+	assert.Equal(t, []string{"aaaaa", "aaaaa", "aa"}, chunkText(strings.Repeat("a", 12), 5))
+
+	// Lengths are counted in characters, not bytes
+	assert.Equal(t, []string{"äöü", "äöü"}, chunkText("äöüäöü", 3))
+}
+
+func TestCombineResults(t *testing.T) {
+	processed := &moderationResult{code: moderationResultProcessed, result: moderation.Result{"Hate": 2, "Violence": 0}}
+	flagged := &moderationResult{code: moderationResultFlagged, result: moderation.Result{"Hate": 0, "Violence": 6}}
+	failed := &moderationResult{code: moderationResultError, err: errors.New("provider unavailable")}
+
+	assert.Same(t, processed, combineResults(nil, processed))
+
+	combined := combineResults(processed, flagged)
+	assert.Equal(t, moderationResultFlagged, combined.code)
+	assert.Equal(t, moderation.Result{"Hate": 2, "Violence": 6}, combined.result)
+
+	combined = combineResults(processed, failed)
+	assert.Equal(t, moderationResultError, combined.code)
+	assert.EqualError(t, combined.err, "provider unavailable")
+
+	// A flagged text flags the post even if another text failed
+	combined = combineResults(failed, flagged)
+	assert.Equal(t, moderationResultFlagged, combined.code)
+}
+
+func TestEffectivePolicy_postResult(t *testing.T) {
+	processed := &moderationResult{code: moderationResultProcessed, result: moderation.Result{"Hate": 2, "Violence": 0}}
+	failed := &moderationResult{code: moderationResultError, err: errors.New("provider unavailable")}
+	combined := combineResults(processed, failed)
+
+	policy := effectivePolicy{threshold: 4, thresholdsOverridden: true}
+	assert.Same(t, combined, policy.postResult(combined), "content below the thresholds leaves the error")
+
+	// A policy with a lower threshold flags the moderated text although an image failed
+	policy.threshold = 2
+	result := policy.postResult(combined)
+	assert.Equal(t, moderationResultFlagged, result.code)
+	assert.True(t, policy.isFlagged(result))
+	assert.Equal(t, moderationResultError, combined.code, "the combined result is not changed")
+
+	assert.Same(t, failed, policy.postResult(failed))
+}
+
+func TestAttachmentChunks(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("GetFile", "txt").Return([]byte("plain text"), nil)
+
+	chunks := attachmentChunks(api, "post1", []*model.FileInfo{
+		{Id: "pdf", Extension: "pdf", Content: "extracted text"},
+		{Id: "txt", Extension: "txt", Size: 10},
+		{Id: "png", Extension: "png", MimeType: "image/png", Size: 10},
+		{Id: "large", Extension: "txt", Size: maxPlainTextFileSize + 1},
+	})
+	assert.Equal(t, []string{"extracted text", "plain text"}, chunks)
+	api.AssertNotCalled(t, "GetFile", "large")
+
+	t.Run("caps the text moderated per post", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("LogWarn", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+
+		chunks := attachmentChunks(api, "post1", []*model.FileInfo{
+			{Id: "long", Content: strings.Repeat("word ", maxAttachmentTextLength)},
+			{Id: "other", Content: "more text"},
+		})
+		var total int
+		for _, chunk := range chunks {
+			assert.LessOrEqual(t, len(chunk), attachmentChunkLength)
+			total += len(chunk)
+		}
+		assert.LessOrEqual(t, total, maxAttachmentTextLength)
+		assert.NotContains(t, chunks, "more text")
+	})
+}
+
+func TestPostProcessor_processPostAttachments(t *testing.T) {
+	newProcessor := func(t *testing.T, api *plugintest.API) *PostProcessor {
+		resultsCache := newModerationResultsCache()
+		moderationProcessor, err := newModerationProcessor(resultsCache, &keywordModerator{}, 4, nil, 6000, 10, 1, nil, nil, nil)
+		require.NoError(t, err)
+		moderationProcessor.start(api)
+		t.Cleanup(moderationProcessor.stop)
+
+		return &PostProcessor{
+			botID:                "bot123",
+			excludedUsers:        map[string]struct{}{},
+			excludedChannelStore: NewMockExcludedChannelsStore([]string{}),
+			defaultPolicy:        effectivePolicy{threshold: 4, action: enforcementActionDelete},
+			moderateAttachments:  true,
+			moderationProcessor:  moderationProcessor,
+			resultsCache:         resultsCache,
+			postCache:            newPostCache(),
+			postsCh:              make(chan *model.Post, 1),
+		}
+	}
+
+	t.Run("deletes a post with a flagged attachment", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("GetChannel", "channel123").Return(&model.Channel{Id: "channel123", Type: model.ChannelTypeOpen}, nil)
+		api.On("GetFileInfo", "file1").Return(&model.FileInfo{Id: "file1", Extension: "txt", Size: 20}, nil)
+		api.On("GetFile", "file1").Return([]byte("something offensive"), nil)
+		api.On("KVGet", approvedContentKey("user456", "channel123", "")).Return(nil, nil)
+		api.On("DeletePost", "post123").Return(nil)
+		api.On("GetDirectChannel", "bot123", "user456").Return(&model.Channel{Id: "dm_channel"}, nil)
+		api.On("CreatePost", mock.Anything).Return(&model.Post{}, nil)
+
+		processor := newProcessor(t, api)
+		post := &model.Post{Id: "post123", UserId: "user456", ChannelId: "channel123", FileIds: []string{"file1"}}
+
+		// The attachment is moderated while the post waits in the queue
+		processor.queuePost(api, post)
+		api.AssertCalled(t, "GetFile", "file1")
+		assert.True(t, processor.processPost(api, <-processor.postsCh))
+		api.AssertCalled(t, "DeletePost", "post123")
+		api.AssertNumberOfCalls(t, "GetFileInfo", 1)
+	})
+
+	t.Run("leaves a post with harmless attachments", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("GetChannel", "channel123").Return(&model.Channel{Id: "channel123", Type: model.ChannelTypeOpen}, nil)
+		api.On("GetFileInfo", "file1").Return(&model.FileInfo{Id: "file1", Content: "meeting notes"}, nil)
+
+		processor := newProcessor(t, api)
+		post := &model.Post{Id: "post123", UserId: "user456", ChannelId: "channel123", FileIds: []string{"file1"}}
+
+		processor.queuePost(api, post)
+		assert.True(t, processor.processPost(api, <-processor.postsCh))
+		api.AssertNotCalled(t, "DeletePost", mock.Anything)
+	})
+
+	t.Run("ignores attachments when disabled", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("GetChannel", "channel123").Return(&model.Channel{Id: "channel123", Type: model.ChannelTypeOpen}, nil)
+		processor := newProcessor(t, api)
+		processor.moderateAttachments = false
+		processor.postsCh = make(chan *model.Post, 1)
+
+		processor.queuePost(api, &model.Post{Id: "post123", FileIds: []string{"file1"}})
+		assert.Empty(t, processor.postsCh)
+
+		start := time.Now()
+		assert.True(t, processor.processPost(api, &model.Post{Id: "post123", UserId: "user456", ChannelId: "channel123", FileIds: []string{"file1"}}))
+		assert.Less(t, time.Since(start), time.Second)
+		api.AssertNotCalled(t, "GetFileInfo", mock.Anything)
+	})
+}
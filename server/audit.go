@@ -3,12 +3,34 @@ package main
 const (
 	auditEventTypeManageChannelModeration = "manageChannelModeration"
 	auditEventTypeContentModeration       = "contentModeration"
+	auditEventTypeChannelModeration       = "channelContentModeration"
+	auditEventTypeProfileModeration       = "profileContentModeration"
+	auditEventTypeManageModerationPolicy  = "manageModerationPolicy"
+	auditEventTypeReviewModeration        = "reviewModeration"
+	auditEventTypeManageStrikes           = "manageStrikes"
+	auditEventTypeManageBlocklist         = "manageBlocklist"
+	auditEventTypeManageScanJob           = "manageScanJob"
 	auditMetaKeyAction                    = "action"
+	auditMetaKeyAppealID                  = "appeal_id"
+	auditMetaKeyBlocklistEntry            = "blocklist_entry"
+	auditMetaKeyApproved                  = "approved_by_reviewer"
+	auditMetaKeyChannelFields             = "channel_fields"
 	auditMetaKeyChannelID                 = "channel_id"
 	auditMetaKeyExcluded                  = "exclusion_reason"
 	auditMetaKeyFlagged                   = "flagged"
+	auditMetaKeyMonitorRecordID           = "monitor_record_id"
+	auditMetaKeyPolicy                    = "policy"
+	auditMetaKeyPolicyScope               = "policy_scope"
+	auditMetaKeyPostID                    = "post_id"
+	auditMetaKeyProfileFields             = "profile_fields"
 	auditMetaKeyResult                    = "result"
+	auditMetaKeySanction                  = "sanction"
+	auditMetaKeyScanJobID                 = "scan_job_id"
+	auditMetaKeyStrikes                   = "strikes"
+	auditMetaKeyTargetUserID              = "target_user_id"
+	auditMetaKeyTeamID                    = "team_id"
 	auditMetaKeyThreshold                 = "threshold"
 	auditMetaKeyUserID                    = "user_id"
+	auditMetaKeyVerdict                   = "verdict"
 	auditParamKeyPost                     = "post"
 )
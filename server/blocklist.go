@@ -0,0 +1,46 @@
+package main
+
+import (
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation/blocklist"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+var ErrBlocklistEntryNotFound = errors.New("blocklist entry not found")
+
+// addBlocklistEntry stores a validated entry and starts matching it right away
+func (p *Plugin) addBlocklistEntry(entry blocklist.Entry, auditRecord *model.AuditRecord) (blocklist.Entry, error) {
+	added, err := p.blocklistStore.AddEntry(entry)
+	if err != nil {
+		return blocklist.Entry{}, errors.Wrap(err, "failed to add blocklist entry")
+	}
+	auditRecord.AddMeta(auditMetaKeyBlocklistEntry, added)
+
+	p.reloadBlocklist()
+	p.notifyStoreChanged(storeNameBlocklist)
+	return added, nil
+}
+
+// removeBlocklistEntry removes an entry and stops matching it right away
+func (p *Plugin) removeBlocklistEntry(id string, auditRecord *model.AuditRecord) error {
+	auditRecord.AddMeta(auditMetaKeyBlocklistEntry, id)
+
+	removed, err := p.blocklistStore.RemoveEntry(id)
+	if err != nil {
+		return errors.Wrap(err, "failed to remove blocklist entry")
+	}
+	if !removed {
+		return ErrBlocklistEntryNotFound
+	}
+
+	p.reloadBlocklist()
+	p.notifyStoreChanged(storeNameBlocklist)
+	return nil
+}
+
+// reloadBlocklist updates the blocklist moderator with the stored entries
+func (p *Plugin) reloadBlocklist() {
+	if err := p.blocklistModerator.SetEntries(p.blocklistStore.ListEntries()); err != nil {
+		p.API.LogError("Failed to reload blocklist", "err", err)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation/blocklist"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+)
+
+const blocklistKVKey = "blocklist_entries"
+
+type BlocklistStore interface {
+	// AddEntry validates and stores a new entry, and returns it with its assigned ID
+	AddEntry(entry blocklist.Entry) (blocklist.Entry, error)
+	// RemoveEntry removes an entry and returns false if it did not exist
+	RemoveEntry(id string) (bool, error)
+	ListEntries() []blocklist.Entry
+	// Reload replaces the cached entries with the stored ones, after another node changed them
+	Reload() error
+}
+
+type blocklistStore struct {
+	cacheLock sync.Mutex
+	cache     []blocklist.Entry
+	loaded    bool
+	api       plugin.API
+}
+
+func newBlocklistStore(api plugin.API) (*blocklistStore, error) {
+	s := &blocklistStore{
+		api: api,
+	}
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+	if err := s.loadCacheWithoutLock(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *blocklistStore) AddEntry(entry blocklist.Entry) (blocklist.Entry, error) {
+	if err := entry.Validate(); err != nil {
+		return blocklist.Entry{}, err
+	}
+
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+
+	entry.ID = model.NewId()
+	s.cache = append(s.cache, entry)
+	if err := s.saveCacheWithoutLock(); err != nil {
+		s.cache = s.cache[:len(s.cache)-1]
+		return blocklist.Entry{}, err
+	}
+	return entry, nil
+}
+
+func (s *blocklistStore) RemoveEntry(id string) (bool, error) {
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+
+	for i, entry := range s.cache {
+		if entry.ID != id {
+			continue
+		}
+		previous := s.cache
+		s.cache = append(append([]blocklist.Entry(nil), s.cache[:i]...), s.cache[i+1:]...)
+		if err := s.saveCacheWithoutLock(); err != nil {
+			s.cache = previous
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// ListEntries returns the entries in the order they were added
+func (s *blocklistStore) ListEntries() []blocklist.Entry {
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+	return append([]blocklist.Entry(nil), s.cache...)
+}
+
+func (s *blocklistStore) Reload() error {
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+
+	previous := s.cache
+	s.cache = nil
+	s.loaded = false
+	if err := s.loadCacheWithoutLock(); err != nil {
+		s.cache = previous
+		s.loaded = true
+		return err
+	}
+	return nil
+}
+
+func (s *blocklistStore) loadCacheWithoutLock() error {
+	if s.loaded {
+		return nil
+	}
+
+	var appErr *model.AppError
+	data, appErr := s.api.KVGet(blocklistKVKey)
+	if appErr != nil {
+		return appErr
+	}
+	if data == nil {
+		s.loaded = true
+		return nil
+	}
+
+	if err := json.Unmarshal(data, &s.cache); err != nil {
+		return err
+	}
+
+	s.loaded = true
+	return nil
+}
+
+func (s *blocklistStore) saveCacheWithoutLock() error {
+	data, err := json.Marshal(s.cache)
+	if err != nil {
+		return err
+	}
+
+	if appErr := s.api.KVSet(blocklistKVKey, data); appErr != nil {
+		return appErr
+	}
+
+	return nil
+}
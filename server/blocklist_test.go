@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation/blocklist"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newBlocklistTestPlugin(t *testing.T, api *plugintest.API) *Plugin {
+	api.On("KVGet", blocklistKVKey).Return(nil, nil).Once()
+	store, err := newBlocklistStore(api)
+	require.NoError(t, err)
+	moderator, err := blocklist.New(nil)
+	require.NoError(t, err)
+
+	p := &Plugin{
+		configuration:      &configuration{},
+		blocklistStore:     store,
+		blocklistModerator: moderator,
+	}
+	p.SetAPI(api)
+	return p
+}
+
+func TestPlugin_blocklistEntries(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("KVSet", blocklistKVKey, mock.Anything).Return(nil)
+	p := newBlocklistTestPlugin(t, api)
+	record := plugin.MakeAuditRecord("test", model.AuditStatusAttempt)
+
+	added, err := p.addBlocklistEntry(blocklist.Entry{
+		Pattern:   "badword",
+		WholeWord: true,
+		Category:  "Hate",
+		Severity:  6,
+	}, record)
+	require.NoError(t, err)
+	assert.NotEmpty(t, added.ID)
+
+	result, err := p.blocklistModerator.ModerateText(context.Background(), "this is a BadWord here")
+	require.NoError(t, err)
+	assert.Equal(t, 6, result["Hate"])
+
+	result, err = p.blocklistModerator.ModerateText(context.Background(), "this is badwords here")
+	require.NoError(t, err)
+	assert.Equal(t, 0, result["Hate"], "whole word entries must not match inside other words")
+
+	require.NoError(t, p.removeBlocklistEntry(added.ID, record))
+	result, err = p.blocklistModerator.ModerateText(context.Background(), "this is a badword here")
+	require.NoError(t, err)
+	assert.Empty(t, result)
+
+	assert.ErrorIs(t, p.removeBlocklistEntry(added.ID, record), ErrBlocklistEntryNotFound)
+}
+
+func TestParseBlocklistEntry(t *testing.T) {
+	entry, err := parseBlocklistEntry([]string{"--regex", "--whole-word", "Hate", "5", "bad", "phrase"})
+	require.NoError(t, err)
+	assert.Equal(t, blocklist.Entry{
+		Pattern:   "bad phrase",
+		Regex:     true,
+		WholeWord: true,
+		Category:  "Hate",
+		Severity:  5,
+	}, entry)
+
+	_, err = parseBlocklistEntry([]string{"--unknown", "Hate", "5", "bad"})
+	assert.Error(t, err)
+
+	_, err = parseBlocklistEntry([]string{"Hate", "high", "bad"})
+	assert.Error(t, err)
+
+	_, err = parseBlocklistEntry([]string{"Hate", "5"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+// channelField is a user visible field of a channel that is moderated
+type channelField string
+
+const (
+	channelFieldDisplayName channelField = "display name"
+	channelFieldHeader      channelField = "header"
+	channelFieldPurpose     channelField = "purpose"
+)
+
+// The props of the system posts Mattermost adds to a channel when its display
+// name, header or purpose changes, holding the previous and the new value
+const (
+	postPropsOldDisplayName = "old_displayname"
+	postPropsNewDisplayName = "new_displayname"
+	postPropsOldHeader      = "old_header"
+	postPropsNewHeader      = "new_header"
+	postPropsOldPurpose     = "old_purpose"
+	postPropsNewPurpose     = "new_purpose"
+)
+
+// moderatedChannelDisplayName replaces a flagged display name of a new channel,
+// which has no previous display name to revert to
+const moderatedChannelDisplayName = "Renamed by content moderation"
+
+const (
+	// maxChannelAdminPages caps the channel members scanned for channel admins to notify
+	maxChannelAdminPages   = 10
+	channelMembersPageSize = 200
+)
+
+const (
+	channelChangeRevertedDMTemplate    = "_Your change to the %s of ~%s was flagged by content moderation and reverted:_\n\n%s"
+	channelChangeFlaggedDMTemplate     = "_Your change to the %s of ~%s was flagged by content moderation:_\n\n%s"
+	channelChangeRevertedAdminTemplate = "A change by @%s to the %s of ~%s was flagged by content moderation and reverted:\n\n%s"
+	channelChangeFlaggedAdminTemplate  = "A change by @%s to the %s of ~%s was flagged by content moderation:\n\n%s"
+)
+
+// channelChange is a change to a moderated field of a channel
+type channelChange struct {
+	field    channelField
+	oldValue string
+	newValue string
+}
+
+// channelChangeFromPost returns the change announced by a system post, and false
+// if the post doesn't announce a change to a moderated field
+func channelChangeFromPost(post *model.Post) (channelChange, bool) {
+	var field channelField
+	var oldProp, newProp string
+	switch post.Type {
+	case model.PostTypeDisplaynameChange:
+		field, oldProp, newProp = channelFieldDisplayName, postPropsOldDisplayName, postPropsNewDisplayName
+	case model.PostTypeHeaderChange:
+		field, oldProp, newProp = channelFieldHeader, postPropsOldHeader, postPropsNewHeader
+	case model.PostTypePurposeChange:
+		field, oldProp, newProp = channelFieldPurpose, postPropsOldPurpose, postPropsNewPurpose
+	default:
+		return channelChange{}, false
+	}
+
+	oldValue, _ := post.GetProp(oldProp).(string)
+	newValue, _ := post.GetProp(newProp).(string)
+	return channelChange{field: field, oldValue: oldValue, newValue: newValue}, true
+}
+
+// isChannelChangePost returns true if a system post announces a change to a moderated field of a channel
+func isChannelChangePost(post *model.Post) bool {
+	_, ok := channelChangeFromPost(post)
+	return ok
+}
+
+// newChannelChanges returns the moderated fields of a new channel as changes that
+// revert to an empty header and purpose and to a placeholder display name
+func newChannelChanges(channel *model.Channel) []channelChange {
+	var changes []channelChange
+	if channel.DisplayName != "" {
+		changes = append(changes, channelChange{field: channelFieldDisplayName, oldValue: moderatedChannelDisplayName, newValue: channel.DisplayName})
+	}
+	if channel.Header != "" {
+		changes = append(changes, channelChange{field: channelFieldHeader, newValue: channel.Header})
+	}
+	if channel.Purpose != "" {
+		changes = append(changes, channelChange{field: channelFieldPurpose, newValue: channel.Purpose})
+	}
+	return changes
+}
+
+// channelFieldValue returns a pointer to the value of a field of a channel
+func channelFieldValue(channel *model.Channel, field channelField) *string {
+	switch field {
+	case channelFieldDisplayName:
+		return &channel.DisplayName
+	case channelFieldHeader:
+		return &channel.Header
+	default:
+		return &channel.Purpose
+	}
+}
+
+// processChannelChange moderates the change announced by a system post. If the
+// new value is flagged, the change is reverted and the system post, which shows
+// the new value, is deleted. Returns false if no result arrived in time.
+func (p *PostProcessor) processChannelChange(api plugin.API, post *model.Post) bool {
+	change, ok := channelChangeFromPost(post)
+	if !ok || strings.TrimSpace(change.newValue) == "" {
+		return true
+	}
+	return p.moderateChannelChanges(api, post.ChannelId, post.UserId, []channelChange{change}, post.Id)
+}
+
+// moderateNewChannel moderates the display name, header and purpose of a new channel
+func (p *PostProcessor) moderateNewChannel(api plugin.API, channel *model.Channel) {
+	changes := newChannelChanges(channel)
+	if len(changes) == 0 {
+		return
+	}
+	p.moderateChannelChanges(api, channel.Id, channel.CreatorId, changes, "")
+}
+
+// moderateChannelChanges moderates changes a user made to a channel and enforces
+// the policy of the channel: changes are only reported if the policy flags or
+// monitors content, and reverted otherwise. The user and the channel admins are
+// notified of flagged changes. Returns false if no result arrived in time.
+func (p *PostProcessor) moderateChannelChanges(api plugin.API, channelID, userID string, changes []channelChange, systemPostID string) bool {
+	record := plugin.MakeAuditRecord(auditEventTypeChannelModeration, model.AuditStatusAttempt)
+	record.AddMeta(auditMetaKeyChannelID, channelID)
+	record.AddMeta(auditMetaKeyUserID, userID)
+
+	if !p.shouldModerateUser(userID, record) ||
+		!p.shouldModerateChannel(api, channelID, record) {
+		reason, _ := record.Meta[auditMetaKeyExcluded].(string)
+		p.stats.recordExcluded(reason)
+		return true
+	}
+
+	for _, change := range changes {
+		if p.moderationProcessor != nil {
+			p.moderationProcessor.queueMessage(api, change.newValue, moderationPriorityHigh)
+		}
+	}
+
+	policy := p.resolvePolicy(api, channelID)
+	deadline := time.Now().Add(waitForResultTimeout)
+	var flagged []channelChange
+	var flaggedCategories []string
+	for _, change := range changes {
+		result := p.resultsCache.waitForResult(change.newValue, time.Until(deadline))
+		if result == nil || result.code == moderationResultPending {
+			p.stats.recordTimeout()
+			p.metrics.recordTimeout()
+			errMsg := "Failed to complete content moderation of channel"
+			api.LogError(errMsg, "channel_id", channelID, "field", string(change.field), "err", context.DeadlineExceeded)
+			p.logAuditFail(api, record, errMsg, context.DeadlineExceeded)
+			return false
+		}
+		if result.code == moderationResultError {
+			p.stats.recordError()
+			p.metrics.recordError()
+			api.LogError("Content moderation error", "err", result.err, "channel_id", channelID, "field", string(change.field))
+			continue
+		}
+
+		p.stats.recordChecked()
+		p.metrics.recordChecked()
+		if policy.isFlagged(result) {
+			flagged = append(flagged, change)
+			flaggedCategories = append(flaggedCategories, policy.flaggedCategories(result.result)...)
+		}
+	}
+
+	if len(flagged) == 0 {
+		record.AddMeta(auditMetaKeyFlagged, false)
+		p.logAuditSuccess(api, record)
+		return true
+	}
+
+	action := policy.enforcementAction()
+	p.stats.recordFlagged(flaggedCategories, action)
+	p.metrics.recordFlagged(flaggedCategories)
+	record.AddMeta(auditMetaKeyFlagged, true)
+	record.AddMeta(auditMetaKeyChannelFields, channelFieldNames(flagged))
+
+	revert := action != enforcementActionFlag && action != enforcementActionMonitor
+	if revert {
+		record.AddMeta(auditMetaKeyAction, "revert")
+		if err := revertChannelChanges(api, channelID, flagged); err != nil {
+			errMsg := "Failed to revert channel change flagged by content moderation"
+			api.LogError(errMsg, "channel_id", channelID, "err", err)
+			p.logAuditFail(api, record, errMsg, err)
+			return true
+		}
+		if systemPostID != "" {
+			if err := api.DeletePost(systemPostID); err != nil {
+				api.LogError("Failed to delete post announcing flagged channel change", "post_id", systemPostID, "err", err)
+			}
+		}
+	} else {
+		record.AddMeta(auditMetaKeyAction, string(enforcementActionFlag))
+	}
+
+	p.notifyChannelChange(api, channelID, userID, flagged, revert)
+	p.logAuditSuccess(api, record)
+	return true
+}
+
+// revertChannelChanges restores the previous values of flagged fields, unless
+// they changed again in the meantime
+func revertChannelChanges(api plugin.API, channelID string, changes []channelChange) error {
+	channel, appErr := api.GetChannel(channelID)
+	if appErr != nil {
+		return errors.Wrap(appErr, "failed to get channel")
+	}
+
+	changed := false
+	for _, change := range changes {
+		if value := channelFieldValue(channel, change.field); *value == change.newValue {
+			*value = change.oldValue
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	if _, appErr := api.UpdateChannel(channel); appErr != nil {
+		return errors.Wrap(appErr, "failed to update channel")
+	}
+	return nil
+}
+
+// notifyChannelChange lets the user who changed a channel and the channel admins
+// know that the change was flagged
+func (p *PostProcessor) notifyChannelChange(api plugin.API, channelID, userID string, changes []channelChange, reverted bool) {
+	dmTemplate, adminTemplate := channelChangeFlaggedDMTemplate, channelChangeFlaggedAdminTemplate
+	if reverted {
+		dmTemplate, adminTemplate = channelChangeRevertedDMTemplate, channelChangeRevertedAdminTemplate
+	}
+
+	username, channelName := getDisplayNames(api, userID, channelID)
+	admins := p.channelAdmins(api, channelID)
+	for _, change := range changes {
+		quoted := quoteMessage(change.newValue)
+		if err := p.sendDirectMessage(api, userID, fmt.Sprintf(dmTemplate, change.field, channelName, quoted)); err != nil {
+			api.LogError("Failed to notify user of flagged channel change", "channel_id", channelID, "user_id", userID, "err", err)
+		}
+
+		message := fmt.Sprintf(adminTemplate, username, change.field, channelName, quoted)
+		for _, adminID := range admins {
+			if adminID == userID {
+				continue
+			}
+			if err := p.sendDirectMessage(api, adminID, message); err != nil {
+				api.LogError("Failed to notify channel admin of flagged channel change", "channel_id", channelID, "admin_id", adminID, "err", err)
+			}
+		}
+	}
+}
+
+// channelAdmins returns the IDs of the admins of a channel
+func (p *PostProcessor) channelAdmins(api plugin.API, channelID string) []string {
+	var admins []string
+	for page := 0; page < maxChannelAdminPages; page++ {
+		members, appErr := api.GetChannelMembers(channelID, page, channelMembersPageSize)
+		if appErr != nil {
+			api.LogError("Failed to get channel members to notify channel admins", "channel_id", channelID, "err", appErr)
+			break
+		}
+		for _, member := range members {
+			if member.SchemeAdmin && member.UserId != p.botID {
+				admins = append(admins, member.UserId)
+			}
+		}
+		if len(members) < channelMembersPageSize {
+			break
+		}
+	}
+	return admins
+}
+
+// channelFieldNames returns the names of the fields of changes for the audit log
+func channelFieldNames(changes []channelChange) []string {
+	names := make([]string, 0, len(changes))
+	for _, change := range changes {
+		names = append(names, string(change.field))
+	}
+	return names
+}
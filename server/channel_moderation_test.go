@@ -0,0 +1,121 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelChangeFromPost(t *testing.T) {
+	post := &model.Post{Type: model.PostTypeHeaderChange}
+	post.AddProp(postPropsOldHeader, "old header")
+	post.AddProp(postPropsNewHeader, "new header")
+
+	change, ok := channelChangeFromPost(post)
+	require.True(t, ok)
+	assert.Equal(t, channelChange{field: channelFieldHeader, oldValue: "old header", newValue: "new header"}, change)
+
+	_, ok = channelChangeFromPost(&model.Post{Type: model.PostTypeJoinChannel})
+	assert.False(t, ok)
+	_, ok = channelChangeFromPost(&model.Post{Message: "hello"})
+	assert.False(t, ok)
+}
+
+func TestPostProcessor_moderateChannelChanges(t *testing.T) {
+	newProcessor := func(t *testing.T, api *plugintest.API, action enforcementAction) *PostProcessor {
+		resultsCache := newModerationResultsCache()
+		moderationProcessor, err := newModerationProcessor(resultsCache, &keywordModerator{}, 4, nil, 6000, 10, 1, nil, nil, nil)
+		require.NoError(t, err)
+		moderationProcessor.start(api)
+		t.Cleanup(moderationProcessor.stop)
+
+		return &PostProcessor{
+			botID:                "bot123",
+			excludedUsers:        map[string]struct{}{},
+			excludedChannelStore: NewMockExcludedChannelsStore([]string{}),
+			defaultPolicy:        effectivePolicy{threshold: 4, action: action},
+			moderationProcessor:  moderationProcessor,
+			moderateChannels:     true,
+			resultsCache:         resultsCache,
+			postCache:            newPostCache(),
+		}
+	}
+
+	newAPI := func(channel *model.Channel) *plugintest.API {
+		api := &plugintest.API{}
+		api.On("GetChannel", "channel123").Return(channel, nil)
+		api.On("GetUser", "user456").Return(&model.User{Id: "user456", Username: "someone"}, nil)
+		api.On("GetChannelMembers", "channel123", 0, channelMembersPageSize).Return(model.ChannelMembers{
+			{UserId: "user456", SchemeAdmin: true},
+			{UserId: "admin789", SchemeAdmin: true},
+			{UserId: "member000"},
+		}, nil)
+		api.On("GetDirectChannel", "bot123", mock.Anything).Return(&model.Channel{Id: "dm_channel"}, nil)
+		api.On("CreatePost", mock.Anything).Return(&model.Post{}, nil)
+		return api
+	}
+
+	headerChangePost := func(header string) *model.Post {
+		post := &model.Post{Id: "post123", UserId: "user456", ChannelId: "channel123", Type: model.PostTypeHeaderChange}
+		post.AddProp(postPropsOldHeader, "old header")
+		post.AddProp(postPropsNewHeader, header)
+		return post
+	}
+
+	t.Run("reverts a flagged header change", func(t *testing.T) {
+		api := newAPI(&model.Channel{Id: "channel123", Name: "town-square", Type: model.ChannelTypeOpen, Header: "something offensive"})
+		api.On("UpdateChannel", mock.MatchedBy(func(channel *model.Channel) bool {
+			return channel.Header == "old header"
+		})).Return(&model.Channel{}, nil)
+		api.On("DeletePost", "post123").Return(nil)
+
+		processor := newProcessor(t, api, enforcementActionDelete)
+		assert.True(t, processor.processPost(api, headerChangePost("something offensive")))
+
+		api.AssertCalled(t, "UpdateChannel", mock.Anything)
+		api.AssertCalled(t, "DeletePost", "post123")
+		api.AssertCalled(t, "GetDirectChannel", "bot123", "user456")
+		api.AssertCalled(t, "GetDirectChannel", "bot123", "admin789")
+		api.AssertNotCalled(t, "GetDirectChannel", "bot123", "member000")
+	})
+
+	t.Run("only notifies if the policy flags content", func(t *testing.T) {
+		api := newAPI(&model.Channel{Id: "channel123", Name: "town-square", Type: model.ChannelTypeOpen})
+
+		processor := newProcessor(t, api, enforcementActionFlag)
+		assert.True(t, processor.processPost(api, headerChangePost("something offensive")))
+
+		api.AssertNotCalled(t, "UpdateChannel", mock.Anything)
+		api.AssertNotCalled(t, "DeletePost", mock.Anything)
+		api.AssertCalled(t, "GetDirectChannel", "bot123", "admin789")
+	})
+
+	t.Run("ignores harmless changes", func(t *testing.T) {
+		api := newAPI(&model.Channel{Id: "channel123", Type: model.ChannelTypeOpen})
+
+		processor := newProcessor(t, api, enforcementActionDelete)
+		assert.True(t, processor.processPost(api, headerChangePost("release schedule")))
+
+		api.AssertNotCalled(t, "UpdateChannel", mock.Anything)
+		api.AssertNotCalled(t, "CreatePost", mock.Anything)
+	})
+
+	t.Run("renames a new channel with a flagged display name", func(t *testing.T) {
+		channel := &model.Channel{Id: "channel123", Name: "new-channel", Type: model.ChannelTypeOpen, CreatorId: "user456",
+			DisplayName: "offensive name", Purpose: "planning"}
+		api := newAPI(channel.DeepCopy())
+		api.On("UpdateChannel", mock.MatchedBy(func(updated *model.Channel) bool {
+			return updated.DisplayName == moderatedChannelDisplayName && updated.Purpose == "planning"
+		})).Return(&model.Channel{}, nil)
+
+		processor := newProcessor(t, api, enforcementActionDelete)
+		processor.moderateNewChannel(api, channel)
+
+		api.AssertCalled(t, "UpdateChannel", mock.Anything)
+		api.AssertNotCalled(t, "DeletePost", mock.Anything)
+	})
+}
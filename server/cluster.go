@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+const (
+	clusterEventHeartbeat        = "heartbeat"
+	clusterEventModerationResult = "moderation_result"
+	clusterEventStoreChanged     = "store_changed"
+
+	// clusterHeartbeatInterval is how often each node announces itself to the others
+	clusterHeartbeatInterval = 30 * time.Second
+
+	// clusterNodeTimeout is how long a node counts as active after its last heartbeat
+	clusterNodeTimeout = 3 * clusterHeartbeatInterval
+
+	// messageLockKVKeyPrefix is the prefix of the KV keys that make sure only one
+	// node moderates a message. Locks expire so a node that goes away mid-request
+	// doesn't keep the message from being moderated.
+	messageLockKVKeyPrefix = "message_lock_"
+	messageLockTTL         = 2 * moderationAPITimeout
+)
+
+// Names of the stores whose changes are broadcast to the other nodes
+const (
+	storeNameExcludedChannels = "excluded_channels"
+	storeNamePolicies         = "policies"
+	storeNameBlocklist        = "blocklist"
+)
+
+type clusterHeartbeat struct {
+	NodeID string `json:"node_id"`
+}
+
+// clusterModerationResult identifies the moderated message by its hash, so the
+// message itself is not sent to the other nodes
+type clusterModerationResult struct {
+	NodeID      string            `json:"node_id"`
+	MessageHash string            `json:"message_hash"`
+	Result      moderation.Result `json:"result"`
+}
+
+type clusterStoreChanged struct {
+	NodeID string `json:"node_id"`
+	Store  string `json:"store"`
+}
+
+// clusterCoordinator keeps track of the other nodes of a high availability
+// cluster, so work and the provider rate limit can be shared between them
+type clusterCoordinator struct {
+	api    plugin.API
+	nodeID string
+
+	nodesLock sync.Mutex
+	nodes     map[string]time.Time
+
+	done chan struct{}
+}
+
+func newClusterCoordinator(api plugin.API) *clusterCoordinator {
+	return &clusterCoordinator{
+		api:    api,
+		nodeID: model.NewId(),
+		nodes:  make(map[string]time.Time),
+		done:   make(chan struct{}),
+	}
+}
+
+func (c *clusterCoordinator) start() {
+	c.publishHeartbeat()
+
+	go func() {
+		ticker := time.NewTicker(clusterHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.publishHeartbeat()
+			case <-c.done:
+				return
+			}
+		}
+	}()
+}
+
+func (c *clusterCoordinator) stop() {
+	close(c.done)
+}
+
+// activeNodes returns the number of nodes, including this one, that sent a heartbeat recently
+func (c *clusterCoordinator) activeNodes() int {
+	c.nodesLock.Lock()
+	defer c.nodesLock.Unlock()
+
+	active := 1
+	for nodeID, lastSeen := range c.nodes {
+		if time.Since(lastSeen) > clusterNodeTimeout {
+			delete(c.nodes, nodeID)
+			continue
+		}
+		active++
+	}
+	return active
+}
+
+func (c *clusterCoordinator) recordHeartbeat(nodeID string) {
+	if nodeID == c.nodeID {
+		return
+	}
+
+	c.nodesLock.Lock()
+	defer c.nodesLock.Unlock()
+	c.nodes[nodeID] = time.Now()
+}
+
+// lockMessage returns true if this node may moderate the message, and false if
+// another node is already moderating it
+func (c *clusterCoordinator) lockMessage(message string) (bool, error) {
+	saved, appErr := c.api.KVSetWithOptions(messageLockKey(message), []byte(c.nodeID), model.PluginKVSetOptions{
+		Atomic:          true,
+		OldValue:        nil,
+		ExpireInSeconds: int64(messageLockTTL / time.Second),
+	})
+	if appErr != nil {
+		return false, errors.Wrap(appErr, "failed to lock message")
+	}
+	return saved, nil
+}
+
+func (c *clusterCoordinator) publishHeartbeat() {
+	c.publish(clusterEventHeartbeat, clusterHeartbeat{NodeID: c.nodeID})
+}
+
+// publishResult shares a moderation result with the other nodes, so they don't
+// have to moderate the same message
+func (c *clusterCoordinator) publishResult(message string, result moderation.Result) {
+	c.publish(clusterEventModerationResult, clusterModerationResult{
+		NodeID:      c.nodeID,
+		MessageHash: messageHash(message),
+		Result:      result,
+	})
+}
+
+// publishStoreChanged tells the other nodes to reload a store
+func (c *clusterCoordinator) publishStoreChanged(store string) {
+	c.publish(clusterEventStoreChanged, clusterStoreChanged{NodeID: c.nodeID, Store: store})
+}
+
+func (c *clusterCoordinator) publish(eventID string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		c.api.LogError("Failed to marshal cluster event", "event", eventID, "err", err)
+		return
+	}
+
+	if err := c.api.PublishPluginClusterEvent(
+		model.PluginClusterEvent{Id: eventID, Data: data},
+		model.PluginClusterEventSendOptions{SendType: model.PluginClusterEventSendTypeReliable},
+	); err != nil {
+		c.api.LogError("Failed to publish cluster event", "event", eventID, "err", err)
+	}
+}
+
+func messageLockKey(message string) string {
+	return messageLockKVKeyPrefix + messageHash(message)
+}
+
+// OnPluginClusterEvent handles events published by the plugin on other nodes
+func (p *Plugin) OnPluginClusterEvent(_ *plugin.Context, ev model.PluginClusterEvent) {
+	if p.cluster == nil {
+		return
+	}
+
+	switch ev.Id {
+	case clusterEventHeartbeat:
+		var heartbeat clusterHeartbeat
+		if err := json.Unmarshal(ev.Data, &heartbeat); err != nil {
+			p.API.LogError("Failed to unmarshal cluster heartbeat", "err", err)
+			return
+		}
+		p.cluster.recordHeartbeat(heartbeat.NodeID)
+	case clusterEventModerationResult:
+		var result clusterModerationResult
+		if err := json.Unmarshal(ev.Data, &result); err != nil {
+			p.API.LogError("Failed to unmarshal shared moderation result", "err", err)
+			return
+		}
+		if result.NodeID == p.cluster.nodeID || result.MessageHash == "" {
+			return
+		}
+		if p.moderationProcessor != nil {
+			p.moderationProcessor.applyHashedResult(result.MessageHash, result.Result)
+		}
+	case clusterEventStoreChanged:
+		var changed clusterStoreChanged
+		if err := json.Unmarshal(ev.Data, &changed); err != nil {
+			p.API.LogError("Failed to unmarshal store change", "err", err)
+			return
+		}
+		if changed.NodeID == p.cluster.nodeID {
+			return
+		}
+		p.reloadStore(changed.Store)
+	}
+}
+
+// reloadStore reloads a store that was changed on another node
+func (p *Plugin) reloadStore(store string) {
+	var err error
+	switch store {
+	case storeNameExcludedChannels:
+		err = p.excludedChannelStore.Reload()
+	case storeNamePolicies:
+		err = p.policiesStore.Reload()
+	case storeNameBlocklist:
+		if err = p.blocklistStore.Reload(); err == nil {
+			p.reloadBlocklist()
+		}
+	default:
+		p.API.LogWarn("Ignoring change of unknown store", "store", store)
+		return
+	}
+	if err != nil {
+		p.API.LogError("Failed to reload store changed on another node", "store", store, "err", err)
+	}
+}
+
+// notifyStoreChanged tells the other nodes to reload a store changed on this node
+func (p *Plugin) notifyStoreChanged(store string) {
+	if p.cluster != nil {
+		p.cluster.publishStoreChanged(store)
+	}
+}
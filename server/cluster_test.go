@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func newTestClusterEvent(t *testing.T, id string, payload any) model.PluginClusterEvent {
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+	return model.PluginClusterEvent{Id: id, Data: data}
+}
+
+func TestClusterCoordinator_activeNodes(t *testing.T) {
+	c := newClusterCoordinator(&plugintest.API{})
+	assert.Equal(t, 1, c.activeNodes())
+
+	c.recordHeartbeat("node2")
+	c.recordHeartbeat("node3")
+	c.recordHeartbeat(c.nodeID)
+	assert.Equal(t, 3, c.activeNodes())
+
+	c.nodes["node3"] = time.Now().Add(-2 * clusterNodeTimeout)
+	assert.Equal(t, 2, c.activeNodes())
+}
+
+func TestModerationProcessor_updateRateLimit(t *testing.T) {
+	c := newClusterCoordinator(&plugintest.API{})
+	c.recordHeartbeat("node2")
+	processor, err := newModerationProcessor(newModerationResultsCache(), &fakeModerator{}, 4, nil, 600, 10, 1, c, nil, nil)
+	require.NoError(t, err)
+	defer processor.stop()
+
+	processor.updateRateLimit()
+
+	assert.Equal(t, rate.Limit(5), processor.limiter.Limit())
+	assert.Equal(t, 5, processor.limiter.Burst())
+}
+
+func TestModerationProcessor_lockMessage(t *testing.T) {
+	newProcessor := func(api *plugintest.API) *ModerationProcessor {
+		c := newClusterCoordinator(api)
+		c.recordHeartbeat("node2")
+		processor, err := newModerationProcessor(newModerationResultsCache(), &fakeModerator{result: moderation.Result{"Hate": 0}}, 4, nil, 600, 10, 1, c, nil, nil)
+		require.NoError(t, err)
+		processor.moderationResultsCache.setResultPending("message")
+		return processor
+	}
+
+	t.Run("moderates and shares result when lock is acquired", func(t *testing.T) {
+		api := &plugintest.API{}
+		processor := newProcessor(api)
+		defer processor.stop()
+		api.On("KVSetWithOptions", messageLockKey("message"), mock.Anything, mock.Anything).Return(true, nil)
+		api.On("PublishPluginClusterEvent", mock.MatchedBy(func(ev model.PluginClusterEvent) bool {
+			var shared clusterModerationResult
+			return ev.Id == clusterEventModerationResult &&
+				json.Unmarshal(ev.Data, &shared) == nil &&
+				shared.MessageHash == messageHash("message") &&
+				!strings.Contains(string(ev.Data), "message\"")
+		}), mock.Anything).Return(nil)
+
+		processor.moderateMessage(api, "message", moderationPriorityNormal)
+
+		assert.False(t, processor.moderationResultsCache.isPending("message"))
+		api.AssertExpectations(t)
+	})
+
+	t.Run("leaves message to the node that locked it", func(t *testing.T) {
+		api := &plugintest.API{}
+		processor := newProcessor(api)
+		defer processor.stop()
+		moderator := processor.moderator.(*fakeModerator)
+		api.On("KVSetWithOptions", messageLockKey("message"), mock.Anything, mock.Anything).Return(false, nil)
+
+		processor.moderateMessage(api, "message", moderationPriorityNormal)
+
+		assert.True(t, processor.moderationResultsCache.isPending("message"))
+		assert.Equal(t, 0, moderator.calls)
+	})
+}
+
+func TestPlugin_OnPluginClusterEvent(t *testing.T) {
+	t.Run("applies moderation result from another node", func(t *testing.T) {
+		api := &plugintest.API{}
+		processor, err := newModerationProcessor(newModerationResultsCache(), &fakeModerator{}, 4, nil, 600, 10, 1, nil, nil, nil)
+		require.NoError(t, err)
+		defer processor.stop()
+		processor.moderationResultsCache.setResultPending("message")
+
+		p := &Plugin{moderationProcessor: processor, cluster: newClusterCoordinator(api)}
+		p.SetAPI(api)
+		p.OnPluginClusterEvent(nil, newTestClusterEvent(t, clusterEventModerationResult, clusterModerationResult{
+			NodeID:      "node2",
+			MessageHash: messageHash("message"),
+			Result:      moderation.Result{"Hate": 6},
+		}))
+
+		result := processor.moderationResultsCache.waitForResult("message", time.Second)
+		require.NotNil(t, result)
+		assert.Equal(t, moderationResultFlagged, result.code)
+	})
+
+	t.Run("keeps moderation result of message that is not known yet", func(t *testing.T) {
+		api := &plugintest.API{}
+		processor, err := newModerationProcessor(newModerationResultsCache(), &fakeModerator{}, 4, nil, 600, 10, 1, nil, nil, nil)
+		require.NoError(t, err)
+		defer processor.stop()
+
+		p := &Plugin{moderationProcessor: processor, cluster: newClusterCoordinator(api)}
+		p.SetAPI(api)
+		p.OnPluginClusterEvent(nil, newTestClusterEvent(t, clusterEventModerationResult, clusterModerationResult{
+			NodeID:      "node2",
+			MessageHash: messageHash("message"),
+			Result:      moderation.Result{"Hate": 0},
+		}))
+
+		assert.False(t, processor.moderationResultsCache.setResultPending("message"))
+		result := processor.moderationResultsCache.waitForResult("message", time.Second)
+		require.NotNil(t, result)
+		assert.Equal(t, moderationResultProcessed, result.code)
+	})
+
+	t.Run("reloads store changed on another node", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("KVGet", excludedChannelsKVKey).Return(nil, nil).Once()
+		store, err := newExcludedChannelsStore(api)
+		require.NoError(t, err)
+
+		data, err := json.Marshal([]ExcludedChannelInfo{{ID: "channel123", Name: "town-square"}})
+		require.NoError(t, err)
+		api.On("KVGet", excludedChannelsKVKey).Return(data, nil).Once()
+
+		p := &Plugin{excludedChannelStore: store, cluster: newClusterCoordinator(api)}
+		p.SetAPI(api)
+		p.OnPluginClusterEvent(nil, newTestClusterEvent(t, clusterEventStoreChanged, clusterStoreChanged{
+			NodeID: "node2",
+			Store:  storeNameExcludedChannels,
+		}))
+
+		excluded, err := store.IsExcluded("channel123")
+		require.NoError(t, err)
+		assert.True(t, excluded)
+	})
+
+	t.Run("records heartbeat", func(t *testing.T) {
+		p := &Plugin{cluster: newClusterCoordinator(&plugintest.API{})}
+		p.OnPluginClusterEvent(nil, newTestClusterEvent(t, clusterEventHeartbeat, clusterHeartbeat{NodeID: "node2"}))
+
+		assert.Equal(t, 2, p.cluster.activeNodes())
+	})
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/mattermost/mattermost/server/public/model"
@@ -16,9 +17,78 @@ func (p *Plugin) registerSlashCommands() error {
 		{Item: "enable", HelpText: "Enable moderation for channel"},
 		{Item: "status", HelpText: "Print moderation status for this channel"},
 		{Item: "list", HelpText: "List all excluded channels you have permission to manage"},
+		{Item: "policy", HelpText: "Print the moderation policy that applies to this channel"},
 	})
 	moderationAutoComplete.AddCommand(channelAutoComplete)
 
+	reviewAutoComplete := model.NewAutocompleteData("review", "", "Review posts held by content moderation")
+	reviewAutoComplete.AddCommand(model.NewAutocompleteData("list", "", "List posts waiting for review"))
+	approveAutoComplete := model.NewAutocompleteData("approve", "[post_id]", "Restore a post waiting for review")
+	approveAutoComplete.AddTextArgument("ID of the post to restore", "[post_id]", "")
+	reviewAutoComplete.AddCommand(approveAutoComplete)
+	removeAutoComplete := model.NewAutocompleteData("remove", "[post_id]", "Delete a post waiting for review")
+	removeAutoComplete.AddTextArgument("ID of the post to delete", "[post_id]", "")
+	reviewAutoComplete.AddCommand(removeAutoComplete)
+	moderationAutoComplete.AddCommand(reviewAutoComplete)
+
+	strikesAutoComplete := model.NewAutocompleteData("strikes", "", "Manage strikes of users whose posts were removed")
+	strikesAutoComplete.AddCommand(model.NewAutocompleteData("list", "", "List users with strikes"))
+	showAutoComplete := model.NewAutocompleteData("show", "[@username]", "Show the strikes of a user")
+	showAutoComplete.AddTextArgument("User to show strikes for", "[@username]", "")
+	strikesAutoComplete.AddCommand(showAutoComplete)
+	resetAutoComplete := model.NewAutocompleteData("reset", "[@username]", "Remove all strikes and sanctions of a user")
+	resetAutoComplete.AddTextArgument("User to reset strikes for", "[@username]", "")
+	strikesAutoComplete.AddCommand(resetAutoComplete)
+	moderationAutoComplete.AddCommand(strikesAutoComplete)
+
+	blocklistAutoComplete := model.NewAutocompleteData("blocklist", "", "Manage the terms and patterns of the blocklist moderator")
+	blocklistAutoComplete.AddCommand(model.NewAutocompleteData("list", "", "List blocklist entries"))
+	addAutoComplete := model.NewAutocompleteData("add", "[--regex] [--whole-word] [--case-sensitive] [category] [severity] [pattern]", "Add a term or regular expression to the blocklist")
+	addAutoComplete.AddTextArgument("Options, category, severity (0-6) and pattern", "[--regex] [--whole-word] [--case-sensitive] [category] [severity] [pattern]", "")
+	blocklistAutoComplete.AddCommand(addAutoComplete)
+	removeEntryAutoComplete := model.NewAutocompleteData("remove", "[entry_id]", "Remove an entry from the blocklist")
+	removeEntryAutoComplete.AddTextArgument("ID of the entry to remove", "[entry_id]", "")
+	blocklistAutoComplete.AddCommand(removeEntryAutoComplete)
+	moderationAutoComplete.AddCommand(blocklistAutoComplete)
+
+	monitorAutoComplete := model.NewAutocompleteData("monitor", "", "Evaluate what content moderation would flag in monitor mode")
+	monitorReportAutoComplete := model.NewAutocompleteData("report", "[days]", "Show flags and false positive rates per category")
+	monitorReportAutoComplete.AddTextArgument("Number of days to report on, 7 by default", "[days]", "")
+	monitorAutoComplete.AddCommand(monitorReportAutoComplete)
+	monitorListAutoComplete := model.NewAutocompleteData("list", "[days]", "List monitored posts waiting for a verdict")
+	monitorListAutoComplete.AddTextArgument("Number of days to list posts for, 7 by default", "[days]", "")
+	monitorAutoComplete.AddCommand(monitorListAutoComplete)
+	confirmAutoComplete := model.NewAutocompleteData("confirm", "[flag_id]", "Confirm that a monitored post should have been flagged")
+	confirmAutoComplete.AddTextArgument("ID of the monitored flag", "[flag_id]", "")
+	monitorAutoComplete.AddCommand(confirmAutoComplete)
+	falsePositiveAutoComplete := model.NewAutocompleteData("false-positive", "[flag_id]", "Mark a monitored post as a false positive")
+	falsePositiveAutoComplete.AddTextArgument("ID of the monitored flag", "[flag_id]", "")
+	monitorAutoComplete.AddCommand(falsePositiveAutoComplete)
+	moderationAutoComplete.AddCommand(monitorAutoComplete)
+
+	scanAutoComplete := model.NewAutocompleteData("scan", "", "Moderate the existing posts of a channel or team")
+	startScanAutoComplete := model.NewAutocompleteData("start", "[channel|team] [report|enforce] [from] [to]", "Scan the posts of this channel or team")
+	startScanAutoComplete.AddTextArgument("Scope, mode and optional date range as YYYY-MM-DD", "[channel|team] [report|enforce] [from] [to]", "")
+	scanAutoComplete.AddCommand(startScanAutoComplete)
+	scanAutoComplete.AddCommand(model.NewAutocompleteData("list", "", "List scan jobs"))
+	showScanAutoComplete := model.NewAutocompleteData("show", "[job_id]", "Show the progress and flagged posts of a scan job")
+	showScanAutoComplete.AddTextArgument("ID of the scan job to show", "[job_id]", "")
+	scanAutoComplete.AddCommand(showScanAutoComplete)
+	pauseScanAutoComplete := model.NewAutocompleteData("pause", "[job_id]", "Pause a running scan job")
+	pauseScanAutoComplete.AddTextArgument("ID of the scan job to pause", "[job_id]", "")
+	scanAutoComplete.AddCommand(pauseScanAutoComplete)
+	resumeScanAutoComplete := model.NewAutocompleteData("resume", "[job_id]", "Resume a paused scan job")
+	resumeScanAutoComplete.AddTextArgument("ID of the scan job to resume", "[job_id]", "")
+	scanAutoComplete.AddCommand(resumeScanAutoComplete)
+	cancelScanAutoComplete := model.NewAutocompleteData("cancel", "[job_id]", "Cancel a scan job")
+	cancelScanAutoComplete.AddTextArgument("ID of the scan job to cancel", "[job_id]", "")
+	scanAutoComplete.AddCommand(cancelScanAutoComplete)
+	moderationAutoComplete.AddCommand(scanAutoComplete)
+
+	statsAutoComplete := model.NewAutocompleteData("stats", "[days]", "Show moderation statistics of the last days")
+	statsAutoComplete.AddTextArgument("Number of days to show statistics for, 30 by default", "[days]", "")
+	moderationAutoComplete.AddCommand(statsAutoComplete)
+
 	command := model.Command{
 		Trigger:          "moderation",
 		DisplayName:      "Content Moderation",
@@ -36,13 +106,39 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 		return &model.CommandResponse{}, nil
 	}
 
-	if len(parts) < 3 || parts[1] != "channel" {
+	// The stats command is the only one that works without arguments
+	if len(parts) >= 2 && parts[1] == "stats" {
+		return p.executeStatsCommand(args, parts[2:])
+	}
+
+	if len(parts) < 3 {
+		return &model.CommandResponse{
+			Text: "Error: invalid moderation command",
+		}, nil
+	}
+
+	switch parts[1] {
+	case "channel":
+		return p.executeChannelCommand(args, parts[2])
+	case "review":
+		return p.executeReviewCommand(args, parts[2:])
+	case "strikes":
+		return p.executeStrikesCommand(args, parts[2:])
+	case "blocklist":
+		return p.executeBlocklistCommand(args, parts[2:])
+	case "monitor":
+		return p.executeMonitorCommand(args, parts[2:])
+	case "scan":
+		return p.executeScanCommand(args, parts[2:])
+	default:
 		return &model.CommandResponse{
 			Text: "Error: invalid moderation command",
 		}, nil
 	}
+}
 
-	switch parts[2] {
+func (p *Plugin) executeChannelCommand(args *model.CommandArgs, action string) (*model.CommandResponse, *model.AppError) {
+	switch action {
 	case "disable":
 		return p.executeDisableCommand(args)
 	case "enable":
@@ -51,6 +147,8 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 		return p.executeStatusCommand(args)
 	case "list":
 		return p.executeListCommand(args)
+	case "policy":
+		return p.executePolicyCommand(args)
 	default:
 		return &model.CommandResponse{
 			Text: "Error: invalid moderation command",
@@ -86,6 +184,7 @@ func (p *Plugin) executeDisableCommand(args *model.CommandArgs) (*model.CommandR
 		}, nil
 	}
 
+	p.notifyStoreChanged(storeNameExcludedChannels)
 	p.API.LogInfo("Channel moderation disabled", "channel_id", args.ChannelId, "user_id", args.UserId)
 	auditRecord.Success()
 
@@ -121,6 +220,7 @@ func (p *Plugin) executeEnableCommand(args *model.CommandArgs) (*model.CommandRe
 		}, nil
 	}
 
+	p.notifyStoreChanged(storeNameExcludedChannels)
 	p.API.LogInfo("Channel moderation enabled", "channel_id", args.ChannelId, "user_id", args.UserId)
 	auditRecord.Success()
 
@@ -183,6 +283,48 @@ func (p *Plugin) executeListCommand(args *model.CommandArgs) (*model.CommandResp
 	return &model.CommandResponse{Text: response}, nil
 }
 
+func (p *Plugin) executePolicyCommand(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+	if !p.hasChannelPermission(args.UserId, args.ChannelId) {
+		return &model.CommandResponse{
+			Text: "You must be a channel admin or system admin to see the moderation policy.",
+		}, nil
+	}
+
+	if p.postProcessor == nil {
+		return &model.CommandResponse{
+			Text: "Content moderation is not active.",
+		}, nil
+	}
+
+	policy := p.postProcessor.resolvePolicy(p.API, args.ChannelId)
+
+	var categories []string
+	for category, threshold := range policy.categoryThresholds {
+		if threshold.disabled {
+			categories = append(categories, fmt.Sprintf("%s: disabled", category))
+			continue
+		}
+		categories = append(categories, fmt.Sprintf("%s: %d", category, threshold.value))
+	}
+	sort.Strings(categories)
+
+	response := fmt.Sprintf("Moderation policy for this channel:\n- Action: %s\n- Threshold: %d",
+		policy.enforcementAction(), policy.threshold)
+	if len(categories) > 0 {
+		response += "\n- Category thresholds: " + strings.Join(categories, ", ")
+	}
+
+	return &model.CommandResponse{Text: response}, nil
+}
+
+func (p *Plugin) hasTeamPermission(userID, teamID string) bool {
+	if p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+		return true
+	}
+
+	return p.API.HasPermissionToTeam(userID, teamID, model.PermissionManageTeam)
+}
+
 func (p *Plugin) hasChannelPermission(userID, channelID string) bool {
 	if p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
 		return true
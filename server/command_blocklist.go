@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation/blocklist"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+const blocklistAddUsage = "Usage: `/moderation blocklist add [--regex] [--whole-word] [--case-sensitive] [category] [severity] [pattern]`"
+
+func (p *Plugin) executeBlocklistCommand(args *model.CommandArgs, parts []string) (*model.CommandResponse, *model.AppError) {
+	if !p.API.HasPermissionTo(args.UserId, model.PermissionManageSystem) {
+		return &model.CommandResponse{
+			Text: "You must be a system admin to manage the blocklist.",
+		}, nil
+	}
+
+	switch parts[0] {
+	case "list":
+		return p.executeBlocklistListCommand()
+	case "add":
+		return p.executeBlocklistAddCommand(args, parts[1:])
+	case "remove":
+		if len(parts) < 2 {
+			return &model.CommandResponse{
+				Text: "Error: missing entry ID. Usage: `/moderation blocklist remove [entry_id]`",
+			}, nil
+		}
+		return p.executeBlocklistRemoveCommand(args, parts[1])
+	default:
+		return &model.CommandResponse{
+			Text: "Error: invalid moderation command",
+		}, nil
+	}
+}
+
+func (p *Plugin) executeBlocklistListCommand() (*model.CommandResponse, *model.AppError) {
+	entries := p.blocklistStore.ListEntries()
+	if len(entries) == 0 {
+		return &model.CommandResponse{
+			Text: "The blocklist is empty.",
+		}, nil
+	}
+
+	var lines []string
+	for _, entry := range entries {
+		lines = append(lines, fmt.Sprintf("- `%s`: `%s` (%s %d%s)",
+			entry.ID, entry.Pattern, entry.Category, entry.Severity, describeBlocklistOptions(entry)))
+	}
+
+	response := fmt.Sprintf("Blocklist entries:\n%s", strings.Join(lines, "\n"))
+	return &model.CommandResponse{Text: response}, nil
+}
+
+func (p *Plugin) executeBlocklistAddCommand(args *model.CommandArgs, parts []string) (*model.CommandResponse, *model.AppError) {
+	entry, err := parseBlocklistEntry(parts)
+	if err != nil {
+		return &model.CommandResponse{
+			Text: fmt.Sprintf("Error: %s. %s", err.Error(), blocklistAddUsage),
+		}, nil
+	}
+
+	auditRecord := plugin.MakeAuditRecord(auditEventTypeManageBlocklist, model.AuditStatusAttempt)
+	auditRecord.AddMeta(auditMetaKeyUserID, args.UserId)
+	auditRecord.AddMeta(auditMetaKeyAction, "add")
+
+	if p.getConfiguration().AuditLoggingEnabled {
+		defer p.API.LogAuditRec(auditRecord)
+	}
+
+	added, err := p.addBlocklistEntry(entry, auditRecord)
+	if err != nil {
+		p.API.LogError("Failed to add blocklist entry", "user_id", args.UserId, "err", err)
+		auditRecord.AddErrorDesc(err.Error())
+		auditRecord.Fail()
+		return &model.CommandResponse{
+			Text: "Failed to add blocklist entry.",
+		}, nil
+	}
+
+	p.API.LogInfo("Blocklist entry added", "entry_id", added.ID, "user_id", args.UserId)
+	auditRecord.Success()
+
+	return &model.CommandResponse{
+		Text: fmt.Sprintf("Added blocklist entry `%s`.", added.ID),
+	}, nil
+}
+
+func (p *Plugin) executeBlocklistRemoveCommand(args *model.CommandArgs, entryID string) (*model.CommandResponse, *model.AppError) {
+	auditRecord := plugin.MakeAuditRecord(auditEventTypeManageBlocklist, model.AuditStatusAttempt)
+	auditRecord.AddMeta(auditMetaKeyUserID, args.UserId)
+	auditRecord.AddMeta(auditMetaKeyAction, "remove")
+
+	if p.getConfiguration().AuditLoggingEnabled {
+		defer p.API.LogAuditRec(auditRecord)
+	}
+
+	if err := p.removeBlocklistEntry(entryID, auditRecord); err != nil {
+		auditRecord.AddErrorDesc(err.Error())
+		auditRecord.Fail()
+		if errors.Is(err, ErrBlocklistEntryNotFound) {
+			return &model.CommandResponse{
+				Text: "That blocklist entry does not exist.",
+			}, nil
+		}
+		p.API.LogError("Failed to remove blocklist entry", "entry_id", entryID, "user_id", args.UserId, "err", err)
+		return &model.CommandResponse{
+			Text: "Failed to remove blocklist entry.",
+		}, nil
+	}
+
+	p.API.LogInfo("Blocklist entry removed", "entry_id", entryID, "user_id", args.UserId)
+	auditRecord.Success()
+
+	return &model.CommandResponse{
+		Text: "The blocklist entry has been removed.",
+	}, nil
+}
+
+// parseBlocklistEntry parses the arguments of the add command. Options come first,
+// followed by the category, the severity and the pattern, which may contain spaces.
+func parseBlocklistEntry(parts []string) (blocklist.Entry, error) {
+	var entry blocklist.Entry
+	for len(parts) > 0 && strings.HasPrefix(parts[0], "--") {
+		switch parts[0] {
+		case "--regex":
+			entry.Regex = true
+		case "--whole-word":
+			entry.WholeWord = true
+		case "--case-sensitive":
+			entry.CaseSensitive = true
+		default:
+			return entry, errors.Errorf("unknown option %s", parts[0])
+		}
+		parts = parts[1:]
+	}
+
+	if len(parts) < 3 {
+		return entry, errors.New("missing arguments")
+	}
+
+	severity, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return entry, errors.Errorf("invalid severity '%s'", parts[1])
+	}
+	entry.Category = parts[0]
+	entry.Severity = severity
+	entry.Pattern = strings.Join(parts[2:], " ")
+
+	if err := entry.Validate(); err != nil {
+		return entry, err
+	}
+	return entry, nil
+}
+
+func describeBlocklistOptions(entry blocklist.Entry) string {
+	var options []string
+	if entry.Regex {
+		options = append(options, "regex")
+	}
+	if entry.WholeWord {
+		options = append(options, "whole word")
+	}
+	if entry.CaseSensitive {
+		options = append(options, "case sensitive")
+	}
+	if len(options) == 0 {
+		return ""
+	}
+	return ", " + strings.Join(options, ", ")
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+func (p *Plugin) executeMonitorCommand(args *model.CommandArgs, parts []string) (*model.CommandResponse, *model.AppError) {
+	if !p.isReviewer(args.UserId) {
+		return &model.CommandResponse{
+			Text: "You must be a moderation reviewer or system admin to see monitored posts.",
+		}, nil
+	}
+
+	switch parts[0] {
+	case "report", "list":
+		days := defaultMonitorReportDays
+		if len(parts) > 1 {
+			var err error
+			if days, err = parseMonitorDays(parts[1]); err != nil {
+				return &model.CommandResponse{
+					Text: fmt.Sprintf("Error: %s. Usage: `/moderation monitor %s [days]`", err.Error(), parts[0]),
+				}, nil
+			}
+		}
+		if parts[0] == "report" {
+			return p.executeMonitorReportCommand(days)
+		}
+		return p.executeMonitorListCommand(days)
+	case "confirm", "false-positive":
+		if len(parts) < 2 {
+			return &model.CommandResponse{
+				Text: fmt.Sprintf("Error: missing flag ID. Usage: `/moderation monitor %s [flag_id]`", parts[0]),
+			}, nil
+		}
+		verdict := MonitorVerdictConfirmed
+		if parts[0] == "false-positive" {
+			verdict = MonitorVerdictFalsePositive
+		}
+		return p.executeMonitorVerdictCommand(args, parts[1], verdict)
+	default:
+		return &model.CommandResponse{
+			Text: "Error: invalid moderation command",
+		}, nil
+	}
+}
+
+func (p *Plugin) executeMonitorReportCommand(days int) (*model.CommandResponse, *model.AppError) {
+	report, err := p.monitorReport(days)
+	if err != nil {
+		p.API.LogError("Failed to build monitor report", "err", err)
+		return &model.CommandResponse{
+			Text: "Failed to build monitor report.",
+		}, nil
+	}
+
+	if report.Flagged == 0 {
+		return &model.CommandResponse{
+			Text: fmt.Sprintf("No posts would have been flagged from %s to %s.", report.From, report.To),
+		}, nil
+	}
+
+	var categories []string
+	for name := range report.Categories {
+		categories = append(categories, name)
+	}
+	sort.Strings(categories)
+
+	response := fmt.Sprintf("%d posts would have been flagged from %s to %s.\n\n", report.Flagged, report.From, report.To) +
+		"| Category | Flagged | Reviewed | Confirmed | False positives | False positive rate |\n" +
+		"|---|---|---|---|---|---|"
+	for _, name := range categories {
+		category := report.Categories[name]
+		rate := "-"
+		if category.Reviewed > 0 {
+			rate = fmt.Sprintf("%.0f%%", category.FalsePositiveRate*100)
+		}
+		response += fmt.Sprintf("\n| %s | %d | %d | %d | %d | %s |", name,
+			category.Flagged, category.Reviewed, category.Confirmed, category.FalsePositives, rate)
+	}
+
+	return &model.CommandResponse{Text: response}, nil
+}
+
+func (p *Plugin) executeMonitorListCommand(days int) (*model.CommandResponse, *model.AppError) {
+	records, err := p.unreviewedMonitorRecords(days)
+	if err != nil {
+		p.API.LogError("Failed to list monitored posts", "err", err)
+		return &model.CommandResponse{
+			Text: "Failed to list monitored posts.",
+		}, nil
+	}
+
+	if len(records) == 0 {
+		return &model.CommandResponse{
+			Text: "There are no monitored posts waiting for a verdict.",
+		}, nil
+	}
+
+	var lines []string
+	for _, record := range records {
+		username, channelName := getDisplayNames(p.API, record.UserID, record.ChannelID)
+		var severities []string
+		for _, category := range record.FlaggedCategories {
+			severities = append(severities, fmt.Sprintf("%s %d/%d", category, record.Result[category], record.Thresholds[category]))
+		}
+		flaggedAt := time.UnixMilli(record.FlaggedAt).UTC().Format(time.RFC1123)
+		lines = append(lines, fmt.Sprintf("- `%s` by @%s in ~%s, flagged %s for %s:\n%s",
+			record.ID, username, channelName, flaggedAt, strings.Join(severities, ", "), quoteMessage(record.Message)))
+	}
+
+	response := fmt.Sprintf("The following posts would have been flagged. Use `/moderation monitor confirm [flag_id]` "+
+		"or `/moderation monitor false-positive [flag_id]` to record a verdict:\n%s", strings.Join(lines, "\n"))
+	return &model.CommandResponse{Text: response}, nil
+}
+
+func (p *Plugin) executeMonitorVerdictCommand(args *model.CommandArgs, id string, verdict MonitorVerdict) (*model.CommandResponse, *model.AppError) {
+	auditRecord := plugin.MakeAuditRecord(auditEventTypeReviewModeration, model.AuditStatusAttempt)
+	auditRecord.AddMeta(auditMetaKeyUserID, args.UserId)
+	auditRecord.AddMeta(auditMetaKeyAction, "monitor_verdict")
+
+	if p.getConfiguration().AuditLoggingEnabled {
+		defer p.API.LogAuditRec(auditRecord)
+	}
+
+	if _, err := p.setMonitorVerdict(id, verdict, args.UserId, auditRecord); err != nil {
+		auditRecord.AddErrorDesc(err.Error())
+		auditRecord.Fail()
+		if errors.Is(err, ErrMonitorRecordNotFound) {
+			return &model.CommandResponse{
+				Text: "That monitored post does not exist.",
+			}, nil
+		}
+		p.API.LogError("Failed to record verdict on monitored post", "monitor_record_id", id, "user_id", args.UserId, "err", err)
+		return &model.CommandResponse{
+			Text: "Failed to record verdict.",
+		}, nil
+	}
+
+	auditRecord.Success()
+
+	return &model.CommandResponse{
+		Text: "The verdict has been recorded.",
+	}, nil
+}
+
+func parseMonitorDays(value string) (int, error) {
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 1 || days > maxMonitorReportDays {
+		return 0, errors.Errorf("days must be a number between 1 and %d", maxMonitorReportDays)
+	}
+	return days, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+func (p *Plugin) executeReviewCommand(args *model.CommandArgs, parts []string) (*model.CommandResponse, *model.AppError) {
+	if !p.isReviewer(args.UserId) {
+		return &model.CommandResponse{
+			Text: "You must be a moderation reviewer or system admin to review posts.",
+		}, nil
+	}
+
+	switch parts[0] {
+	case "list":
+		return p.executeReviewListCommand()
+	case "approve", "remove":
+		if len(parts) < 2 {
+			return &model.CommandResponse{
+				Text: fmt.Sprintf("Error: missing post ID. Usage: `/moderation review %s [post_id]`", parts[0]),
+			}, nil
+		}
+		return p.executeReviewDecisionCommand(args, parts[0], parts[1])
+	default:
+		return &model.CommandResponse{
+			Text: "Error: invalid moderation command",
+		}, nil
+	}
+}
+
+func (p *Plugin) executeReviewListCommand() (*model.CommandResponse, *model.AppError) {
+	items, err := p.reviewStore.ListItems()
+	if err != nil {
+		p.API.LogError("Failed to list review items", "err", err)
+		return &model.CommandResponse{
+			Text: "Error: failed to list the posts waiting for review",
+		}, nil
+	}
+	if len(items) == 0 {
+		return &model.CommandResponse{
+			Text: "There are no posts waiting for review.",
+		}, nil
+	}
+
+	var lines []string
+	for _, item := range items {
+		username := item.UserID
+		if user, appErr := p.API.GetUser(item.UserID); appErr == nil {
+			username = user.Username
+		}
+		flaggedAt := time.UnixMilli(item.FlaggedAt).UTC().Format(time.RFC1123)
+		lines = append(lines, fmt.Sprintf("- `%s` by @%s, flagged %s:\n%s",
+			item.PostID, username, flaggedAt, quoteMessage(item.Message)))
+	}
+
+	response := fmt.Sprintf("The following posts are waiting for review:\n%s", strings.Join(lines, "\n"))
+	return &model.CommandResponse{Text: response}, nil
+}
+
+func (p *Plugin) executeReviewDecisionCommand(args *model.CommandArgs, action, postID string) (*model.CommandResponse, *model.AppError) {
+	auditRecord := plugin.MakeAuditRecord(auditEventTypeReviewModeration, model.AuditStatusAttempt)
+	auditRecord.AddMeta(auditMetaKeyUserID, args.UserId)
+	auditRecord.AddMeta(auditMetaKeyAction, action)
+
+	if p.getConfiguration().AuditLoggingEnabled {
+		defer p.API.LogAuditRec(auditRecord)
+	}
+
+	decide, result := p.approveReviewItem, "restored"
+	if action == "remove" {
+		decide, result = p.removeReviewItem, "removed"
+	}
+
+	if err := decide(postID, auditRecord); err != nil {
+		auditRecord.AddErrorDesc(err.Error())
+		auditRecord.Fail()
+		if errors.Is(err, ErrReviewItemNotFound) {
+			return &model.CommandResponse{
+				Text: "That post is not waiting for review.",
+			}, nil
+		}
+		p.API.LogError("Failed to review post", "action", action, "post_id", postID, "user_id", args.UserId, "err", err)
+		return &model.CommandResponse{
+			Text: "Failed to review post.",
+		}, nil
+	}
+
+	p.API.LogInfo("Post reviewed", "action", action, "post_id", postID, "user_id", args.UserId)
+	auditRecord.Success()
+
+	return &model.CommandResponse{
+		Text: fmt.Sprintf("The post has been %s.", result),
+	}, nil
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+const (
+	scanStartUsage = "Usage: `/moderation scan start [channel|team] [report|enforce] [from] [to]`, with dates as YYYY-MM-DD"
+	scanDateLayout = "2006-01-02"
+
+	// maxScanFindingsListed is the number of flagged posts shown by the show command
+	maxScanFindingsListed = 20
+)
+
+func (p *Plugin) executeScanCommand(args *model.CommandArgs, parts []string) (*model.CommandResponse, *model.AppError) {
+	if !p.API.HasPermissionTo(args.UserId, model.PermissionManageSystem) {
+		return &model.CommandResponse{
+			Text: "You must be a system admin to scan existing posts.",
+		}, nil
+	}
+
+	switch parts[0] {
+	case "start":
+		return p.executeScanStartCommand(args, parts[1:])
+	case "list":
+		return p.executeScanListCommand()
+	case "show", "pause", "resume", "cancel":
+		if len(parts) < 2 {
+			return &model.CommandResponse{
+				Text: fmt.Sprintf("Error: missing job ID. Usage: `/moderation scan %s [job_id]`", parts[0]),
+			}, nil
+		}
+		if parts[0] == "show" {
+			return p.executeScanShowCommand(parts[1])
+		}
+		return p.executeScanUpdateCommand(args, parts[0], parts[1])
+	default:
+		return &model.CommandResponse{
+			Text: "Error: invalid moderation command",
+		}, nil
+	}
+}
+
+func (p *Plugin) executeScanStartCommand(args *model.CommandArgs, parts []string) (*model.CommandResponse, *model.AppError) {
+	request, err := parseScanJobRequest(args, parts)
+	if err != nil {
+		return &model.CommandResponse{
+			Text: fmt.Sprintf("Error: %s. %s", err.Error(), scanStartUsage),
+		}, nil
+	}
+
+	auditRecord := plugin.MakeAuditRecord(auditEventTypeManageScanJob, model.AuditStatusAttempt)
+	auditRecord.AddMeta(auditMetaKeyUserID, args.UserId)
+	auditRecord.AddMeta(auditMetaKeyAction, "start")
+	auditRecord.AddMeta(auditMetaKeyPolicyScope, string(request.Scope))
+
+	if p.getConfiguration().AuditLoggingEnabled {
+		defer p.API.LogAuditRec(auditRecord)
+	}
+
+	job, err := p.startScanJob(args.UserId, request)
+	if err != nil {
+		p.API.LogError("Failed to start scan job", "scope", string(request.Scope), "scope_id", request.ScopeID, "user_id", args.UserId, "err", err)
+		auditRecord.AddErrorDesc(err.Error())
+		auditRecord.Fail()
+		return &model.CommandResponse{
+			Text: "Failed to start scan job.",
+		}, nil
+	}
+
+	p.API.LogInfo("Scan job started", "job_id", job.ID, "scope", string(job.Scope), "scope_id", job.ScopeID, "mode", string(job.Mode), "user_id", args.UserId)
+	auditRecord.AddMeta(auditMetaKeyScanJobID, job.ID)
+	auditRecord.Success()
+
+	return &model.CommandResponse{
+		Text: fmt.Sprintf("Started scan job `%s` for %d channels. You will get a message when it is finished.", job.ID, len(job.ChannelIDs)),
+	}, nil
+}
+
+func (p *Plugin) executeScanListCommand() (*model.CommandResponse, *model.AppError) {
+	jobs, err := p.scanJobsStore.ListJobs()
+	if err != nil {
+		p.API.LogError("Failed to list scan jobs", "err", err)
+		return &model.CommandResponse{
+			Text: "Failed to list scan jobs.",
+		}, nil
+	}
+
+	if len(jobs) == 0 {
+		return &model.CommandResponse{
+			Text: "There are no scan jobs.",
+		}, nil
+	}
+
+	var lines []string
+	for _, job := range jobs {
+		lines = append(lines, fmt.Sprintf("- `%s`: %s", job.ID, describeScanJob(p.API, job)))
+	}
+
+	response := fmt.Sprintf("Scan jobs:\n%s", strings.Join(lines, "\n"))
+	return &model.CommandResponse{Text: response}, nil
+}
+
+func (p *Plugin) executeScanShowCommand(jobID string) (*model.CommandResponse, *model.AppError) {
+	job, err := p.scanJobsStore.GetJob(jobID)
+	if err != nil {
+		p.API.LogError("Failed to get scan job", "job_id", jobID, "err", err)
+		return &model.CommandResponse{
+			Text: "Failed to get scan job.",
+		}, nil
+	}
+	if job == nil {
+		return &model.CommandResponse{
+			Text: "That scan job does not exist.",
+		}, nil
+	}
+
+	response := fmt.Sprintf("Scan job `%s`: %s\n- Channels scanned: %d of %d",
+		job.ID, describeScanJob(p.API, job), min(job.ChannelIndex, len(job.ChannelIDs)), len(job.ChannelIDs))
+	if len(job.Findings) == 0 {
+		return &model.CommandResponse{Text: response}, nil
+	}
+
+	response += "\n\nFlagged posts:"
+	for i, finding := range job.Findings {
+		if i == maxScanFindingsListed {
+			response += fmt.Sprintf("\n- and %d more", len(job.Findings)-maxScanFindingsListed)
+			break
+		}
+		username, channelName := getDisplayNames(p.API, finding.UserID, finding.ChannelID)
+		action := finding.Action
+		if action == "" {
+			action = "none"
+		}
+		response += fmt.Sprintf("\n- `%s` by @%s in ~%s on %s (action: %s)", finding.PostID, username, channelName,
+			time.UnixMilli(finding.CreateAt).UTC().Format(scanDateLayout), action)
+	}
+
+	return &model.CommandResponse{Text: response}, nil
+}
+
+func (p *Plugin) executeScanUpdateCommand(args *model.CommandArgs, action, jobID string) (*model.CommandResponse, *model.AppError) {
+	auditRecord := plugin.MakeAuditRecord(auditEventTypeManageScanJob, model.AuditStatusAttempt)
+	auditRecord.AddMeta(auditMetaKeyUserID, args.UserId)
+	auditRecord.AddMeta(auditMetaKeyAction, action)
+	auditRecord.AddMeta(auditMetaKeyScanJobID, jobID)
+
+	if p.getConfiguration().AuditLoggingEnabled {
+		defer p.API.LogAuditRec(auditRecord)
+	}
+
+	job, err := p.updateScanJob(action, jobID)
+	if err != nil {
+		auditRecord.AddErrorDesc(err.Error())
+		auditRecord.Fail()
+		if text, ok := scanJobUpdateErrorText(err); ok {
+			return &model.CommandResponse{Text: text}, nil
+		}
+		p.API.LogError("Failed to update scan job", "job_id", jobID, "action", action, "user_id", args.UserId, "err", err)
+		return &model.CommandResponse{
+			Text: "Failed to update scan job.",
+		}, nil
+	}
+
+	p.API.LogInfo("Scan job updated", "job_id", jobID, "action", action, "user_id", args.UserId)
+	auditRecord.Success()
+
+	return &model.CommandResponse{
+		Text: fmt.Sprintf("Scan job `%s` is %s.", job.ID, job.Status),
+	}, nil
+}
+
+// updateScanJob pauses, resumes or cancels a scan job
+func (p *Plugin) updateScanJob(action, jobID string) (*ScanJob, error) {
+	if p.scanJobRunner == nil {
+		return nil, errors.New("scan jobs are not available")
+	}
+
+	switch action {
+	case "pause":
+		return p.scanJobRunner.pauseJob(jobID)
+	case "resume":
+		return p.scanJobRunner.resumeJob(jobID)
+	case "cancel":
+		return p.scanJobRunner.cancelJob(jobID)
+	default:
+		return nil, errors.Errorf("unknown action %s", action)
+	}
+}
+
+// scanJobUpdateErrorText returns the message shown to the user for errors caused by the request
+func scanJobUpdateErrorText(err error) (string, bool) {
+	switch {
+	case errors.Is(err, ErrScanJobNotFound):
+		return "That scan job does not exist.", true
+	case errors.Is(err, ErrScanJobNotRunning):
+		return "That scan job is not running.", true
+	case errors.Is(err, ErrScanJobNotPaused):
+		return "That scan job is not paused.", true
+	case errors.Is(err, ErrScanJobFinished):
+		return "That scan job is already finished.", true
+	default:
+		return "", false
+	}
+}
+
+// parseScanJobRequest parses the arguments of the start command. The scope is
+// the current channel or team, and the optional dates are inclusive.
+func parseScanJobRequest(args *model.CommandArgs, parts []string) (ScanJobRequest, error) {
+	var request ScanJobRequest
+	if len(parts) < 2 || len(parts) > 4 {
+		return request, errors.New("invalid arguments")
+	}
+
+	request.Scope = PolicyScope(parts[0])
+	switch request.Scope {
+	case PolicyScopeChannel:
+		request.ScopeID = args.ChannelId
+	case PolicyScopeTeam:
+		request.ScopeID = args.TeamId
+	}
+	request.Mode = ScanJobMode(parts[1])
+
+	if len(parts) > 2 {
+		since, err := time.Parse(scanDateLayout, parts[2])
+		if err != nil {
+			return request, errors.Errorf("invalid date '%s'", parts[2])
+		}
+		request.Since = since.UnixMilli()
+	}
+	if len(parts) > 3 {
+		until, err := time.Parse(scanDateLayout, parts[3])
+		if err != nil {
+			return request, errors.Errorf("invalid date '%s'", parts[3])
+		}
+		request.Until = until.Add(24*time.Hour).UnixMilli() - 1
+	}
+
+	if err := request.Validate(); err != nil {
+		return request, err
+	}
+	return request, nil
+}
+
+func describeScanJob(api plugin.API, job *ScanJob) string {
+	return fmt.Sprintf("%s %s in %s mode, %d scanned, %d flagged, %d skipped, %d failed",
+		job.Status, job.describeScope(api), job.Mode, job.Scanned, job.Flagged, job.Skipped, job.Failed)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+func (p *Plugin) executeStatsCommand(args *model.CommandArgs, parts []string) (*model.CommandResponse, *model.AppError) {
+	if !p.API.HasPermissionTo(args.UserId, model.PermissionManageSystem) {
+		return &model.CommandResponse{
+			Text: "You must be a system admin to see moderation statistics.",
+		}, nil
+	}
+
+	days := defaultStatsReportDays
+	if len(parts) > 0 {
+		var err error
+		if days, err = parseStatsDays(parts[0]); err != nil {
+			return &model.CommandResponse{
+				Text: fmt.Sprintf("Error: %s. Usage: `/moderation stats [days]`", err.Error()),
+			}, nil
+		}
+	}
+
+	report, err := p.statsReport(days)
+	if err != nil {
+		p.API.LogError("Failed to build moderation statistics", "err", err)
+		return &model.CommandResponse{
+			Text: "Failed to build moderation statistics.",
+		}, nil
+	}
+
+	totals := report.Totals
+	response := fmt.Sprintf("#### Content moderation from %s to %s\n\n", report.From, report.To) +
+		"| Posts checked | Flagged | Errors | Timeouts | Excluded | Max queue depth | Average latency | Max latency |\n" +
+		"|---|---|---|---|---|---|---|---|\n" +
+		fmt.Sprintf("| %d | %d | %d | %d | %d | %d | %d ms | %d ms |",
+			totals.Checked, totals.Flagged, totals.Errors, totals.Timeouts, sumCounts(totals.Excluded),
+			totals.MaxQueueDepth, report.AverageLatencyMs, totals.ProviderLatencyMaxMs)
+
+	response += formatStatsCounts("Flagged category", totals.FlaggedCategories)
+	response += formatStatsCounts("Action", totals.Actions)
+	response += formatStatsCounts("Excluded reason", totals.Excluded)
+
+	return &model.CommandResponse{Text: response}, nil
+}
+
+// formatStatsCounts formats counts as a markdown table sorted by name, or returns
+// an empty string if there are none
+func formatStatsCounts(title string, counts map[string]int) string {
+	if len(counts) == 0 {
+		return ""
+	}
+
+	var names []string
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	table := fmt.Sprintf("\n\n| %s | Posts |\n|---|---|", title)
+	for _, name := range names {
+		table += fmt.Sprintf("\n| %s | %d |", name, counts[name])
+	}
+	return table
+}
+
+func sumCounts(counts map[string]int) int {
+	var total int
+	for _, count := range counts {
+		total += count
+	}
+	return total
+}
+
+func parseStatsDays(value string) (int, error) {
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 1 || days > maxStatsReportDays {
+		return 0, errors.Errorf("days must be a number between 1 and %d", maxStatsReportDays)
+	}
+	return days, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+func (p *Plugin) executeStrikesCommand(args *model.CommandArgs, parts []string) (*model.CommandResponse, *model.AppError) {
+	if !p.API.HasPermissionTo(args.UserId, model.PermissionManageSystem) {
+		return &model.CommandResponse{
+			Text: "You must be a system admin to manage strikes.",
+		}, nil
+	}
+
+	switch parts[0] {
+	case "list":
+		return p.executeStrikesListCommand()
+	case "show", "reset":
+		if len(parts) < 2 {
+			return &model.CommandResponse{
+				Text: fmt.Sprintf("Error: missing user. Usage: `/moderation strikes %s [@username]`", parts[0]),
+			}, nil
+		}
+		user, appErr := p.findUser(parts[1])
+		if appErr != nil {
+			return &model.CommandResponse{
+				Text: fmt.Sprintf("Error: could not find user %s.", parts[1]),
+			}, nil
+		}
+		if parts[0] == "show" {
+			return p.executeStrikesShowCommand(user)
+		}
+		return p.executeStrikesResetCommand(args, user)
+	default:
+		return &model.CommandResponse{
+			Text: "Error: invalid moderation command",
+		}, nil
+	}
+}
+
+func (p *Plugin) executeStrikesListCommand() (*model.CommandResponse, *model.AppError) {
+	list, err := p.strikesStore.ListStrikes()
+	if err != nil {
+		p.API.LogError("Failed to list strikes", "err", err)
+		return &model.CommandResponse{
+			Text: "Failed to list strikes.",
+		}, nil
+	}
+	if len(list) == 0 {
+		return &model.CommandResponse{
+			Text: "No users have strikes.",
+		}, nil
+	}
+
+	now := model.GetMillis()
+	var lines []string
+	for _, strikes := range list {
+		lines = append(lines, fmt.Sprintf("- @%s: %d strike(s), %s",
+			getUsername(p.API, strikes.UserID), len(strikes.Strikes), describeSanctions(strikes, now)))
+	}
+
+	response := fmt.Sprintf("The following users have strikes:\n%s", strings.Join(lines, "\n"))
+	return &model.CommandResponse{Text: response}, nil
+}
+
+func (p *Plugin) executeStrikesShowCommand(user *model.User) (*model.CommandResponse, *model.AppError) {
+	strikes, err := p.strikesStore.GetStrikes(user.Id)
+	if err != nil {
+		p.API.LogError("Failed to get strikes", "user_id", user.Id, "err", err)
+		return &model.CommandResponse{
+			Text: "Failed to get strikes.",
+		}, nil
+	}
+	if strikes == nil {
+		return &model.CommandResponse{
+			Text: fmt.Sprintf("@%s has no strikes.", user.Username),
+		}, nil
+	}
+
+	lines := []string{fmt.Sprintf("@%s has %d strike(s), %s:",
+		user.Username, len(strikes.Strikes), describeSanctions(strikes, model.GetMillis()))}
+	for _, strike := range strikes.Strikes {
+		createdAt := time.UnixMilli(strike.CreatedAt).UTC().Format(time.RFC1123)
+		lines = append(lines, fmt.Sprintf("- `%s` in ~%s, %s (%s)",
+			strike.PostID, p.getChannelName(strike.ChannelID), createdAt, formatModerationScores(strike.Result)))
+	}
+
+	return &model.CommandResponse{Text: strings.Join(lines, "\n")}, nil
+}
+
+func (p *Plugin) executeStrikesResetCommand(args *model.CommandArgs, user *model.User) (*model.CommandResponse, *model.AppError) {
+	auditRecord := plugin.MakeAuditRecord(auditEventTypeManageStrikes, model.AuditStatusAttempt)
+	auditRecord.AddMeta(auditMetaKeyUserID, args.UserId)
+	auditRecord.AddMeta(auditMetaKeyAction, "reset")
+
+	if p.getConfiguration().AuditLoggingEnabled {
+		defer p.API.LogAuditRec(auditRecord)
+	}
+
+	if err := p.resetStrikes(user.Id, auditRecord); err != nil {
+		auditRecord.AddErrorDesc(err.Error())
+		auditRecord.Fail()
+		if errors.Is(err, ErrUserHasNoStrikes) {
+			return &model.CommandResponse{
+				Text: fmt.Sprintf("@%s has no strikes.", user.Username),
+			}, nil
+		}
+		p.API.LogError("Failed to reset strikes", "target_user_id", user.Id, "user_id", args.UserId, "err", err)
+		return &model.CommandResponse{
+			Text: "Failed to reset strikes.",
+		}, nil
+	}
+
+	p.API.LogInfo("Strikes reset", "target_user_id", user.Id, "user_id", args.UserId)
+	auditRecord.Success()
+
+	return &model.CommandResponse{
+		Text: fmt.Sprintf("All strikes and sanctions of @%s have been removed.", user.Username),
+	}, nil
+}
+
+// describeSanctions summarizes the sanctions currently applied to a user
+func describeSanctions(strikes *UserStrikes, now int64) string {
+	switch {
+	case strikes.Deactivated:
+		return "deactivated"
+	case strikes.isRestricted(now):
+		return "restricted until " + time.UnixMilli(strikes.RestrictedUntil).UTC().Format(time.RFC1123)
+	case strikes.Level != sanctionNone:
+		return "sanction level " + strikes.Level.String()
+	default:
+		return "no sanctions"
+	}
+}
+
+// findUser looks up a user by @username, username or ID
+func (p *Plugin) findUser(nameOrID string) (*model.User, *model.AppError) {
+	user, appErr := p.API.GetUserByUsername(strings.TrimPrefix(nameOrID, "@"))
+	if appErr == nil {
+		return user, nil
+	}
+	if model.IsValidId(nameOrID) {
+		return p.API.GetUser(nameOrID)
+	}
+	return nil, appErr
+}
+
+func (p *Plugin) getChannelName(channelID string) string {
+	if channel, appErr := p.API.GetChannel(channelID); appErr == nil {
+		return channel.Name
+	}
+	return channelID
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation/blocklist"
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation/composite"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeModerator struct {
+	result moderation.Result
+	err    error
+	calls  int
+}
+
+func (m *fakeModerator) ModerateText(_ context.Context, _ string) (moderation.Result, error) {
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.result, nil
+}
+
+func TestInitCompositeModerator(t *testing.T) {
+	blocklistModerator, err := blocklist.New([]blocklist.Entry{{ID: "entry1", Pattern: "badword", Category: "Hate", Severity: 6}})
+	require.NoError(t, err)
+
+	t.Run("creates stages from the listed moderator types", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("LogInfo", "Blocklist moderator initialized").Return()
+		api.On("LogInfo", "Composite moderator initialized", "moderators", "blocklist", "strategy", "first_flag").Return()
+
+		config := &configuration{
+			ModeratorConfig: ModeratorConfig{
+				Type:                "composite",
+				CompositeModerators: "blocklist",
+				CompositeStrategy:   "first_flag",
+				CompositeThreshold:  "4",
+			},
+		}
+		mod, err := initModerator(api, config, "bot123", blocklistModerator, nil, nil)
+		require.NoError(t, err)
+
+		result, err := mod.ModerateText(context.Background(), "this has a badword")
+		require.NoError(t, err)
+		assert.Equal(t, 6, result["Hate"])
+	})
+
+	t.Run("rejects nested composite moderators", func(t *testing.T) {
+		api := &plugintest.API{}
+		config := &configuration{
+			ModeratorConfig: ModeratorConfig{
+				Type:                "composite",
+				CompositeModerators: "blocklist,composite",
+				CompositeStrategy:   "first_flag",
+				CompositeThreshold:  "4",
+			},
+		}
+		api.On("LogInfo", "Blocklist moderator initialized").Return()
+
+		_, err := initModerator(api, config, "bot123", blocklistModerator, nil, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestCompositeThresholds(t *testing.T) {
+	categoryThresholds := map[string]categoryThreshold{
+		"hate":     {value: 2},
+		"selfharm": {disabled: true},
+	}
+	policies := []*ModerationPolicy{
+		{Threshold: "5"},
+		{CategoryThresholds: map[string]string{"Hate": "6", "Sexual": "disabled"}},
+		{Threshold: "3", CategoryThresholds: map[string]string{"Violence": "1"}},
+	}
+
+	thresholds := compositeThresholds(4, categoryThresholds, policies)
+	assert.Equal(t, composite.Range{Lowest: 3, Highest: 5}, thresholds.Default)
+	assert.Equal(t, map[string]composite.Range{
+		"hate":     {Lowest: 2, Highest: 6},
+		"selfharm": {Lowest: composite.Disabled, Highest: composite.Disabled},
+		"sexual":   {Lowest: 3, Highest: composite.Disabled},
+		"violence": {Lowest: 1, Highest: 5},
+	}, thresholds.Categories)
+
+	assert.Equal(t, composite.Thresholds{
+		Default:    composite.Range{Lowest: 4, Highest: 4},
+		Categories: map[string]composite.Range{},
+	}, compositeThresholds(4, nil, nil))
+}
@@ -4,19 +4,32 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation/failover"
 	"github.com/pkg/errors"
 )
 
 // ModeratorConfig represents the configuration for content moderation providers
 type ModeratorConfig struct {
-	Type               string `json:"type"`
-	AzureEndpoint      string `json:"azure_endpoint"`
-	AzureAPIKey        string `json:"azure_apiKey"`
-	AzureThreshold     string `json:"azure_threshold"`
-	AgentsSystemPrompt string `json:"agents_system_prompt"`
-	AgentsThreshold    string `json:"agents_threshold"`
-	AgentsBotUsername  string `json:"agents_bot_username"`
+	Type                string            `json:"type"`
+	AzureEndpoint       string            `json:"azure_endpoint"`
+	AzureAPIKey         string            `json:"azure_apiKey"`
+	AzureThreshold      string            `json:"azure_threshold"`
+	AgentsSystemPrompt  string            `json:"agents_system_prompt"`
+	AgentsThreshold     string            `json:"agents_threshold"`
+	AgentsBotUsername   string            `json:"agents_bot_username"`
+	OpenAIEndpoint      string            `json:"openai_endpoint"`
+	OpenAIAPIKey        string            `json:"openai_apiKey"`
+	OpenAIModel         string            `json:"openai_model"`
+	OpenAIThreshold     string            `json:"openai_threshold"`
+	BlocklistThreshold  string            `json:"blocklist_threshold"`
+	CompositeModerators string            `json:"composite_moderators"`
+	CompositeStrategy   string            `json:"composite_strategy"`
+	CompositeThreshold  string            `json:"composite_threshold"`
+	CompositeMargin     string            `json:"composite_escalation_margin"`
+	FallbackType        string            `json:"fallback_type"`
+	CategoryThresholds  map[string]string `json:"category_thresholds"`
 }
 
 // configuration captures the plugin's external configuration as exposed in the Mattermost server
@@ -31,29 +44,87 @@ type ModeratorConfig struct {
 // If you add non-reference types to your configuration struct, be sure to rewrite Clone as a deep
 // copy appropriate for your types.
 type configuration struct {
-	Enabled                bool   `json:"enabled"`
-	ExcludedUsers          string `json:"excludedUsers"`
-	ExcludeDirectMessages  bool   `json:"excludeDirectMessages"`
-	ExcludePrivateChannels bool   `json:"excludePrivateChannels"`
-	BotUsername            string `json:"botUsername"`
-	BotDisplayName         string `json:"botDisplayName"`
-	AuditLoggingEnabled    bool   `json:"auditLoggingEnabled"`
-	RateLimitPerMinute     int    `json:"rateLimitPerMinute"`
-	ModeratorConfig        `json:"moderatorConfig"`
+	Enabled                   bool   `json:"enabled"`
+	ExcludedUsers             string `json:"excludedUsers"`
+	ExcludeDirectMessages     bool   `json:"excludeDirectMessages"`
+	ExcludePrivateChannels    bool   `json:"excludePrivateChannels"`
+	ModerateAttachments       bool   `json:"moderateAttachments"`
+	ModerateImages            bool   `json:"moderateImages"`
+	ModerateChannels          bool   `json:"moderateChannels"`
+	ModerateProfiles          bool   `json:"moderateProfiles"`
+	BotUsername               string `json:"botUsername"`
+	BotDisplayName            string `json:"botDisplayName"`
+	AuditLoggingEnabled       bool   `json:"auditLoggingEnabled"`
+	RateLimitPerMinute        int    `json:"rateLimitPerMinute"`
+	RateLimitBurst            int    `json:"rateLimitBurst"`
+	ModerationWorkers         int    `json:"moderationWorkers"`
+	LargeChannelMemberCount   int    `json:"largeChannelMemberCount"`
+	CatchUpAgeMinutes         int    `json:"catchUpAgeMinutes"`
+	BlockBeforePublish        bool   `json:"blockBeforePublish"`
+	BlockTimeoutSeconds       int    `json:"blockTimeoutSeconds"`
+	BlockFailurePolicy        string `json:"blockFailurePolicy"`
+	EnforcementAction         string `json:"enforcementAction"`
+	Reviewers                 string `json:"reviewers"`
+	AppealsChannelID          string `json:"appealsChannelId"`
+	StrikesEnabled            bool   `json:"strikesEnabled"`
+	StrikeDecayDays           int    `json:"strikeDecayDays"`
+	StrikeWarnThreshold       int    `json:"strikeWarnThreshold"`
+	StrikeNotifyThreshold     int    `json:"strikeNotifyThreshold"`
+	StrikeRestrictThreshold   int    `json:"strikeRestrictThreshold"`
+	StrikeRestrictHours       int    `json:"strikeRestrictHours"`
+	StrikeDeactivateThreshold int    `json:"strikeDeactivateThreshold"`
+	FailoverTimeoutSeconds    int    `json:"failoverTimeoutSeconds"`
+	FailoverFailureThreshold  int    `json:"failoverFailureThreshold"`
+	FailoverProbeSeconds      int    `json:"failoverProbeSeconds"`
+	MetricsToken              string `json:"metricsToken"`
+	ModeratorConfig           `json:"moderatorConfig"`
 }
 
+const (
+	blockFailurePolicyOpen   = "open"
+	blockFailurePolicyClosed = "closed"
+
+	defaultBlockTimeout = 5 * time.Second
+
+	defaultRateLimitBurst    = 10
+	defaultModerationWorkers = 4
+	maxModerationWorkers     = 64
+
+	defaultLargeChannelMemberCount = 100
+
+	defaultCatchUpAge = 10 * time.Minute
+
+	defaultStrikeDecayDays     = 30
+	defaultStrikeRestrictHours = 24
+
+	defaultFailoverTimeout          = 5 * time.Second
+	defaultFailoverFailureThreshold = 3
+	defaultFailoverProbeInterval    = 60 * time.Second
+)
+
 func (c *configuration) ExcludedUserSet() map[string]struct{} {
-	excludedMap := make(map[string]struct{})
-	if strings.TrimSpace(c.ExcludedUsers) == "" {
-		return excludedMap
+	return userIDSet(c.ExcludedUsers)
+}
+
+// ReviewerSet returns the users that are notified of flagged posts and allowed to
+// review posts held for review
+func (c *configuration) ReviewerSet() map[string]struct{} {
+	return userIDSet(c.Reviewers)
+}
+
+// userIDSet parses a comma separated list of user IDs
+func userIDSet(userIDs string) map[string]struct{} {
+	userMap := make(map[string]struct{})
+	if strings.TrimSpace(userIDs) == "" {
+		return userMap
 	}
-	for _, userID := range strings.Split(c.ExcludedUsers, ",") {
+	for _, userID := range strings.Split(userIDs, ",") {
 		trimmedID := strings.TrimSpace(userID)
 		if trimmedID != "" {
-			excludedMap[trimmedID] = struct{}{}
+			userMap[trimmedID] = struct{}{}
 		}
 	}
-	return excludedMap
+	return userMap
 }
 
 // ThresholdValue returns the threshold as an integer based on moderator type
@@ -64,6 +135,12 @@ func (c *configuration) ThresholdValue() (int, error) {
 		threshold = c.ModeratorConfig.AzureThreshold
 	case "agents":
 		threshold = c.ModeratorConfig.AgentsThreshold
+	case "openai":
+		threshold = c.ModeratorConfig.OpenAIThreshold
+	case "blocklist":
+		threshold = c.ModeratorConfig.BlocklistThreshold
+	case "composite":
+		threshold = c.ModeratorConfig.CompositeThreshold
 	default:
 		return 0, errors.Errorf("unknown moderator type: %s", c.ModeratorConfig.Type)
 	}
@@ -78,6 +155,74 @@ func (c *configuration) ThresholdValue() (int, error) {
 	return val, nil
 }
 
+// CompositeModeratorTypes returns the moderator types chained by the composite moderator, in order
+func (c *configuration) CompositeModeratorTypes() []string {
+	var types []string
+	for _, moderatorType := range strings.Split(c.ModeratorConfig.CompositeModerators, ",") {
+		if trimmed := strings.TrimSpace(moderatorType); trimmed != "" {
+			types = append(types, trimmed)
+		}
+	}
+	return types
+}
+
+// CompositeMarginValue returns how far below the threshold a severity has to be
+// for the composite moderator to ask the next moderator for a second opinion
+func (c *configuration) CompositeMarginValue() (int, error) {
+	if c.ModeratorConfig.CompositeMargin == "" {
+		return 0, nil
+	}
+	margin, err := strconv.Atoi(c.ModeratorConfig.CompositeMargin)
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not parse escalation margin: '%s'", c.ModeratorConfig.CompositeMargin)
+	}
+	return margin, nil
+}
+
+// FailoverSettingsValue returns when requests are sent to the fallback moderator.
+// The primary timeout leaves the fallback time to answer within the moderation timeout.
+func (c *configuration) FailoverSettingsValue() failover.Settings {
+	timeout := defaultFailoverTimeout
+	if c.FailoverTimeoutSeconds > 0 {
+		timeout = time.Duration(c.FailoverTimeoutSeconds) * time.Second
+	}
+	if timeout >= moderationAPITimeout {
+		timeout = moderationAPITimeout / 2
+	}
+	failureThreshold := c.FailoverFailureThreshold
+	if failureThreshold <= 0 {
+		failureThreshold = defaultFailoverFailureThreshold
+	}
+	probeInterval := defaultFailoverProbeInterval
+	if c.FailoverProbeSeconds > 0 {
+		probeInterval = time.Duration(c.FailoverProbeSeconds) * time.Second
+	}
+
+	return failover.Settings{
+		PrimaryTimeout:   timeout,
+		FailureThreshold: failureThreshold,
+		ProbeInterval:    probeInterval,
+	}
+}
+
+// CategoryThresholdValues returns the per-category threshold overrides, which apply
+// on top of the threshold returned by ThresholdValue
+func (c *configuration) CategoryThresholdValues() (map[string]categoryThreshold, error) {
+	return parseCategoryThresholds(c.ModeratorConfig.CategoryThresholds)
+}
+
+// EnforcementActionValue returns the action taken on flagged posts when no team or
+// channel policy overrides it
+func (c *configuration) EnforcementActionValue() (enforcementAction, error) {
+	if c.EnforcementAction == "" {
+		return enforcementActionDelete, nil
+	}
+	if !isValidEnforcementAction(c.EnforcementAction) {
+		return "", errors.Errorf("unknown enforcement action: %s", c.EnforcementAction)
+	}
+	return enforcementAction(c.EnforcementAction), nil
+}
+
 // RateLimitValue returns the rate limit per minute as an integer
 func (c *configuration) RateLimitValue() int {
 	if c.RateLimitPerMinute <= 0 {
@@ -86,6 +231,86 @@ func (c *configuration) RateLimitValue() int {
 	return c.RateLimitPerMinute
 }
 
+// RateLimitBurstValue returns how many requests may be sent at once before the
+// rate limit applies
+func (c *configuration) RateLimitBurstValue() int {
+	if c.RateLimitBurst <= 0 {
+		return defaultRateLimitBurst
+	}
+	return c.RateLimitBurst
+}
+
+// CatchUpAge returns how long a post can have been queued before it is resumed
+// through the catch-up path after the plugin is restarted
+func (c *configuration) CatchUpAge() time.Duration {
+	if c.CatchUpAgeMinutes <= 0 {
+		return defaultCatchUpAge
+	}
+	return time.Duration(c.CatchUpAgeMinutes) * time.Minute
+}
+
+// LargeChannelMemberCountValue returns the number of members from which posts in a
+// public channel are moderated before other posts
+func (c *configuration) LargeChannelMemberCountValue() int64 {
+	if c.LargeChannelMemberCount <= 0 {
+		return defaultLargeChannelMemberCount
+	}
+	return int64(c.LargeChannelMemberCount)
+}
+
+// ModerationWorkersValue returns the number of concurrent moderation requests
+func (c *configuration) ModerationWorkersValue() int {
+	if c.ModerationWorkers <= 0 {
+		return defaultModerationWorkers
+	}
+	if c.ModerationWorkers > maxModerationWorkers {
+		return maxModerationWorkers
+	}
+	return c.ModerationWorkers
+}
+
+// BlockTimeout returns how long MessageWillBePosted waits for a moderation result
+// before applying the failure policy. The value is bounded by the moderation API timeout.
+func (c *configuration) BlockTimeout() time.Duration {
+	if c.BlockTimeoutSeconds <= 0 {
+		return defaultBlockTimeout
+	}
+	timeout := time.Duration(c.BlockTimeoutSeconds) * time.Second
+	if timeout > moderationAPITimeout {
+		return moderationAPITimeout
+	}
+	return timeout
+}
+
+// BlockFailClosed returns true when posts should be rejected if moderation times out or fails
+func (c *configuration) BlockFailClosed() bool {
+	return c.BlockFailurePolicy == blockFailurePolicyClosed
+}
+
+// StrikePolicyValue returns the strike escalation settings. A threshold of zero
+// or less disables the corresponding sanction.
+func (c *configuration) StrikePolicyValue() strikePolicy {
+	decayDays := c.StrikeDecayDays
+	if decayDays <= 0 {
+		decayDays = defaultStrikeDecayDays
+	}
+	restrictHours := c.StrikeRestrictHours
+	if restrictHours <= 0 {
+		restrictHours = defaultStrikeRestrictHours
+	}
+
+	return strikePolicy{
+		decay:            time.Duration(decayDays) * 24 * time.Hour,
+		restrictDuration: time.Duration(restrictHours) * time.Hour,
+		thresholds: map[sanctionLevel]int{
+			sanctionWarning:    c.StrikeWarnThreshold,
+			sanctionNotify:     c.StrikeNotifyThreshold,
+			sanctionRestrict:   c.StrikeRestrictThreshold,
+			sanctionDeactivate: c.StrikeDeactivateThreshold,
+		},
+	}
+}
+
 // Clone shallow copies the configuration. Your implementation may require a deep copy if
 // your configuration has reference types.
 func (c *configuration) Clone() *configuration {
@@ -136,6 +361,10 @@ func (p *Plugin) setConfiguration(configuration *configuration) {
 		"excludedUsers", configuration.ExcludedUsers,
 		"excludeDirectMessages", configuration.ExcludeDirectMessages,
 		"excludePrivateChannels", configuration.ExcludePrivateChannels,
+		"moderateAttachments", configuration.ModerateAttachments,
+		"moderateImages", configuration.ModerateImages,
+		"moderateChannels", configuration.ModerateChannels,
+		"moderateProfiles", configuration.ModerateProfiles,
 		"moderationType", configuration.ModeratorConfig.Type,
 		"azureEndpointSet", configuration.ModeratorConfig.AzureEndpoint != "",
 		"azureAPIKeySet", configuration.ModeratorConfig.AzureAPIKey != "",
@@ -143,10 +372,42 @@ func (p *Plugin) setConfiguration(configuration *configuration) {
 		"agentsSystemPromptSet", configuration.ModeratorConfig.AgentsSystemPrompt != "",
 		"agentsThreshold", configuration.ModeratorConfig.AgentsThreshold,
 		"agentsBotUsername", configuration.ModeratorConfig.AgentsBotUsername,
+		"openaiEndpoint", configuration.ModeratorConfig.OpenAIEndpoint,
+		"openaiAPIKeySet", configuration.ModeratorConfig.OpenAIAPIKey != "",
+		"openaiModel", configuration.ModeratorConfig.OpenAIModel,
+		"openaiThreshold", configuration.ModeratorConfig.OpenAIThreshold,
+		"blocklistThreshold", configuration.ModeratorConfig.BlocklistThreshold,
+		"compositeModerators", configuration.ModeratorConfig.CompositeModerators,
+		"compositeStrategy", configuration.ModeratorConfig.CompositeStrategy,
+		"compositeThreshold", configuration.ModeratorConfig.CompositeThreshold,
+		"compositeEscalationMargin", configuration.ModeratorConfig.CompositeMargin,
+		"fallbackType", configuration.ModeratorConfig.FallbackType,
+		"categoryThresholds", configuration.ModeratorConfig.CategoryThresholds,
 		"auditLoggingEnabled", configuration.AuditLoggingEnabled,
 		"botUsername", configuration.BotUsername,
 		"botDisplayName", configuration.BotDisplayName,
-		"rateLimitPerMinute", configuration.RateLimitPerMinute)
+		"rateLimitPerMinute", configuration.RateLimitPerMinute,
+		"rateLimitBurst", configuration.RateLimitBurst,
+		"moderationWorkers", configuration.ModerationWorkers,
+		"largeChannelMemberCount", configuration.LargeChannelMemberCount,
+		"catchUpAgeMinutes", configuration.CatchUpAgeMinutes,
+		"blockBeforePublish", configuration.BlockBeforePublish,
+		"blockTimeoutSeconds", configuration.BlockTimeoutSeconds,
+		"blockFailurePolicy", configuration.BlockFailurePolicy,
+		"enforcementAction", configuration.EnforcementAction,
+		"reviewers", configuration.Reviewers,
+		"appealsChannelId", configuration.AppealsChannelID,
+		"strikesEnabled", configuration.StrikesEnabled,
+		"strikeDecayDays", configuration.StrikeDecayDays,
+		"strikeWarnThreshold", configuration.StrikeWarnThreshold,
+		"strikeNotifyThreshold", configuration.StrikeNotifyThreshold,
+		"strikeRestrictThreshold", configuration.StrikeRestrictThreshold,
+		"strikeRestrictHours", configuration.StrikeRestrictHours,
+		"strikeDeactivateThreshold", configuration.StrikeDeactivateThreshold,
+		"failoverTimeoutSeconds", configuration.FailoverTimeoutSeconds,
+		"failoverFailureThreshold", configuration.FailoverFailureThreshold,
+		"failoverProbeSeconds", configuration.FailoverProbeSeconds,
+		"metricsTokenSet", configuration.MetricsToken != "")
 	p.configuration = configuration
 }
 
@@ -162,7 +423,9 @@ func (p *Plugin) OnConfigurationChange() error {
 	p.setConfiguration(config)
 
 	// Initialize or reinitialize the moderator with the new configuration
-	if err := p.initialize(config); err != nil {
+	err := p.initialize(config)
+	p.setInitializeError(err)
+	if err != nil {
 		p.API.LogError("Failed to reinitialize after configuration change", "err", err)
 		return nil
 	}
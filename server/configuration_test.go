@@ -3,6 +3,7 @@ package main
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestConfiguration_ExcludedUserSet(t *testing.T) {
@@ -62,9 +63,18 @@ func TestConfiguration_ThresholdValue(t *testing.T) {
 		moderatorType   string
 		azureThreshold  string
 		agentsThreshold string
+		openaiThreshold string
+		compThreshold   string
 		expected        int
 		wantError       bool
 	}{
+		{
+			name:          "valid composite threshold",
+			moderatorType: "composite",
+			compThreshold: "4",
+			expected:      4,
+			wantError:     false,
+		},
 		{
 			name:           "valid azure threshold",
 			moderatorType:  "azure",
@@ -79,6 +89,20 @@ func TestConfiguration_ThresholdValue(t *testing.T) {
 			expected:        4,
 			wantError:       false,
 		},
+		{
+			name:            "valid openai threshold",
+			moderatorType:   "openai",
+			openaiThreshold: "6",
+			expected:        6,
+			wantError:       false,
+		},
+		{
+			name:            "empty openai threshold",
+			moderatorType:   "openai",
+			openaiThreshold: "",
+			expected:        0,
+			wantError:       true,
+		},
 		{
 			name:           "zero azure threshold",
 			moderatorType:  "azure",
@@ -136,6 +160,9 @@ func TestConfiguration_ThresholdValue(t *testing.T) {
 					Type:            tt.moderatorType,
 					AzureThreshold:  tt.azureThreshold,
 					AgentsThreshold: tt.agentsThreshold,
+					OpenAIThreshold: tt.openaiThreshold,
+
+					CompositeThreshold: tt.compThreshold,
 				},
 			}
 			result, err := c.ThresholdValue()
@@ -154,3 +181,331 @@ func TestConfiguration_ThresholdValue(t *testing.T) {
 		})
 	}
 }
+
+func TestConfiguration_CompositeModeratorTypes(t *testing.T) {
+	c := &configuration{
+		ModeratorConfig: ModeratorConfig{CompositeModerators: " blocklist, ,azure,agents "},
+	}
+	types := c.CompositeModeratorTypes()
+	expected := []string{"blocklist", "azure", "agents"}
+	if len(types) != len(expected) {
+		t.Fatalf("CompositeModeratorTypes() = %v, want %v", types, expected)
+	}
+	for i := range expected {
+		if types[i] != expected[i] {
+			t.Errorf("CompositeModeratorTypes() = %v, want %v", types, expected)
+		}
+	}
+}
+
+func TestConfiguration_CompositeMarginValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		margin    string
+		expected  int
+		wantError bool
+	}{
+		{name: "empty margin", margin: "", expected: 0},
+		{name: "valid margin", margin: "2", expected: 2},
+		{name: "invalid margin", margin: "abc", wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &configuration{
+				ModeratorConfig: ModeratorConfig{CompositeMargin: tt.margin},
+			}
+			result, err := c.CompositeMarginValue()
+			if tt.wantError {
+				if err == nil {
+					t.Errorf("CompositeMarginValue() expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("CompositeMarginValue() unexpected error: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("CompositeMarginValue() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConfiguration_BlockTimeout(t *testing.T) {
+	tests := []struct {
+		name           string
+		timeoutSeconds int
+		expected       time.Duration
+	}{
+		{
+			name:           "unset uses default",
+			timeoutSeconds: 0,
+			expected:       defaultBlockTimeout,
+		},
+		{
+			name:           "negative uses default",
+			timeoutSeconds: -3,
+			expected:       defaultBlockTimeout,
+		},
+		{
+			name:           "configured value",
+			timeoutSeconds: 3,
+			expected:       3 * time.Second,
+		},
+		{
+			name:           "capped at moderation API timeout",
+			timeoutSeconds: 60,
+			expected:       moderationAPITimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &configuration{
+				BlockTimeoutSeconds: tt.timeoutSeconds,
+			}
+			if result := c.BlockTimeout(); result != tt.expected {
+				t.Errorf("BlockTimeout() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConfiguration_BlockFailClosed(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   string
+		expected bool
+	}{
+		{
+			name:     "unset fails open",
+			policy:   "",
+			expected: false,
+		},
+		{
+			name:     "open policy",
+			policy:   blockFailurePolicyOpen,
+			expected: false,
+		},
+		{
+			name:     "closed policy",
+			policy:   blockFailurePolicyClosed,
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &configuration{
+				BlockFailurePolicy: tt.policy,
+			}
+			if result := c.BlockFailClosed(); result != tt.expected {
+				t.Errorf("BlockFailClosed() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConfiguration_CategoryThresholdValues(t *testing.T) {
+	tests := []struct {
+		name       string
+		thresholds map[string]string
+		expected   map[string]categoryThreshold
+		wantError  bool
+	}{
+		{
+			name:       "unset",
+			thresholds: nil,
+			expected:   map[string]categoryThreshold{},
+		},
+		{
+			name: "numeric and disabled values",
+			thresholds: map[string]string{
+				"Hate":     "2",
+				"Violence": "disabled",
+			},
+			expected: map[string]categoryThreshold{
+				"hate":     {value: 2},
+				"violence": {disabled: true},
+			},
+		},
+		{
+			name: "empty values use default threshold",
+			thresholds: map[string]string{
+				"Sexual":   "",
+				"SelfHarm": " 4 ",
+			},
+			expected: map[string]categoryThreshold{
+				"selfharm": {value: 4},
+			},
+		},
+		{
+			name: "invalid value",
+			thresholds: map[string]string{
+				"Hate": "high",
+			},
+			wantError: true,
+		},
+		{
+			name: "value above severity range",
+			thresholds: map[string]string{
+				"Hate": "60",
+			},
+			wantError: true,
+		},
+		{
+			name: "value below severity range",
+			thresholds: map[string]string{
+				"Hate": "-1",
+			},
+			wantError: true,
+		},
+		{
+			name: "values at bounds of severity range",
+			thresholds: map[string]string{
+				"Hate":     "0",
+				"Violence": "6",
+			},
+			expected: map[string]categoryThreshold{
+				"hate":     {value: 0},
+				"violence": {value: 6},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &configuration{
+				ModeratorConfig: ModeratorConfig{
+					CategoryThresholds: tt.thresholds,
+				},
+			}
+			result, err := c.CategoryThresholdValues()
+			if tt.wantError {
+				if err == nil {
+					t.Errorf("CategoryThresholdValues() expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("CategoryThresholdValues() unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("CategoryThresholdValues() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConfiguration_StrikePolicyValue(t *testing.T) {
+	tests := []struct {
+		name             string
+		decayDays        int
+		restrictHours    int
+		expectedDecay    time.Duration
+		expectedRestrict time.Duration
+	}{
+		{
+			name:             "unset values use defaults",
+			expectedDecay:    defaultStrikeDecayDays * 24 * time.Hour,
+			expectedRestrict: defaultStrikeRestrictHours * time.Hour,
+		},
+		{
+			name:             "configured values",
+			decayDays:        7,
+			restrictHours:    2,
+			expectedDecay:    7 * 24 * time.Hour,
+			expectedRestrict: 2 * time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &configuration{
+				StrikeDecayDays:     tt.decayDays,
+				StrikeRestrictHours: tt.restrictHours,
+				StrikeWarnThreshold: 1,
+			}
+			policy := c.StrikePolicyValue()
+			if policy.decay != tt.expectedDecay {
+				t.Errorf("StrikePolicyValue().decay = %v, want %v", policy.decay, tt.expectedDecay)
+			}
+			if policy.restrictDuration != tt.expectedRestrict {
+				t.Errorf("StrikePolicyValue().restrictDuration = %v, want %v", policy.restrictDuration, tt.expectedRestrict)
+			}
+			if policy.thresholds[sanctionWarning] != 1 {
+				t.Errorf("StrikePolicyValue().thresholds[warning] = %v, want 1", policy.thresholds[sanctionWarning])
+			}
+		})
+	}
+}
+
+func TestConfiguration_FailoverSettingsValue(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		settings := (&configuration{}).FailoverSettingsValue()
+		if settings.PrimaryTimeout != defaultFailoverTimeout {
+			t.Errorf("PrimaryTimeout = %v, want %v", settings.PrimaryTimeout, defaultFailoverTimeout)
+		}
+		if settings.FailureThreshold != defaultFailoverFailureThreshold {
+			t.Errorf("FailureThreshold = %v, want %v", settings.FailureThreshold, defaultFailoverFailureThreshold)
+		}
+		if settings.ProbeInterval != defaultFailoverProbeInterval {
+			t.Errorf("ProbeInterval = %v, want %v", settings.ProbeInterval, defaultFailoverProbeInterval)
+		}
+	})
+
+	t.Run("configured values", func(t *testing.T) {
+		settings := (&configuration{
+			FailoverTimeoutSeconds:   3,
+			FailoverFailureThreshold: 5,
+			FailoverProbeSeconds:     30,
+		}).FailoverSettingsValue()
+		if settings.PrimaryTimeout != 3*time.Second {
+			t.Errorf("PrimaryTimeout = %v, want %v", settings.PrimaryTimeout, 3*time.Second)
+		}
+		if settings.FailureThreshold != 5 {
+			t.Errorf("FailureThreshold = %v, want %v", settings.FailureThreshold, 5)
+		}
+		if settings.ProbeInterval != 30*time.Second {
+			t.Errorf("ProbeInterval = %v, want %v", settings.ProbeInterval, 30*time.Second)
+		}
+	})
+
+	t.Run("timeout leaves time for the fallback", func(t *testing.T) {
+		settings := (&configuration{FailoverTimeoutSeconds: 60}).FailoverSettingsValue()
+		if settings.PrimaryTimeout >= moderationAPITimeout {
+			t.Errorf("PrimaryTimeout = %v, want less than %v", settings.PrimaryTimeout, moderationAPITimeout)
+		}
+	})
+}
+
+func TestConfiguration_ModerationWorkersValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		workers  int
+		expected int
+	}{
+		{name: "default", workers: 0, expected: defaultModerationWorkers},
+		{name: "configured", workers: 8, expected: 8},
+		{name: "capped", workers: 1000, expected: maxModerationWorkers},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &configuration{ModerationWorkers: tt.workers}
+			if result := c.ModerationWorkersValue(); result != tt.expected {
+				t.Errorf("ModerationWorkersValue() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConfiguration_RateLimitBurstValue(t *testing.T) {
+	if result := (&configuration{}).RateLimitBurstValue(); result != defaultRateLimitBurst {
+		t.Errorf("RateLimitBurstValue() = %v, want %v", result, defaultRateLimitBurst)
+	}
+	if result := (&configuration{RateLimitBurst: 25}).RateLimitBurstValue(); result != 25 {
+		t.Errorf("RateLimitBurstValue() = %v, want %v", result, 25)
+	}
+}
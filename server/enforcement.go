@@ -0,0 +1,65 @@
+package main
+
+type enforcementAction string
+
+const (
+	enforcementActionDelete enforcementAction = "delete"
+	enforcementActionHide   enforcementAction = "hide"
+	enforcementActionFlag   enforcementAction = "flag"
+	enforcementActionReview enforcementAction = "review"
+
+	// enforcementActionMonitor records what would have been flagged for monitor
+	// reports without acting on the post or its author
+	enforcementActionMonitor enforcementAction = "monitor"
+)
+
+func isValidEnforcementAction(action string) bool {
+	switch enforcementAction(action) {
+	case enforcementActionDelete, enforcementActionHide, enforcementActionFlag, enforcementActionReview, enforcementActionMonitor:
+		return true
+	default:
+		return false
+	}
+}
+
+// effectivePolicy is the moderation policy that applies to a post once team and
+// channel policies have been applied on top of the global configuration
+type effectivePolicy struct {
+	threshold            int
+	categoryThresholds   map[string]categoryThreshold
+	thresholdsOverridden bool
+	action               enforcementAction
+}
+
+// isFlagged determines whether a moderation result violates this policy. Results are
+// only re-evaluated when a team or channel policy changed the thresholds, otherwise
+// the decision made by the moderation processor is used.
+func (ep effectivePolicy) isFlagged(result *moderationResult) bool {
+	if !ep.thresholdsOverridden || result.result == nil {
+		return result.code == moderationResultFlagged
+	}
+	return severityExceedsThresholds(result.result, ep.threshold, ep.categoryThresholds)
+}
+
+// postResult returns the result that decides what happens to a post whose content
+// was moderated in parts. Moderated content that violates the policy flags the
+// post even if other content of the post could not be moderated, so content that
+// fails moderation can't be used to get flagged content past it.
+func (ep effectivePolicy) postResult(result *moderationResult) *moderationResult {
+	if result.code != moderationResultError || len(result.result) == 0 ||
+		!severityExceedsThresholds(result.result, ep.threshold, ep.categoryThresholds) {
+		return result
+	}
+
+	flagged := *result
+	flagged.code = moderationResultFlagged
+	return &flagged
+}
+
+// enforcementAction returns the configured action, defaulting to deleting the post
+func (ep effectivePolicy) enforcementAction() enforcementAction {
+	if ep.action == "" {
+		return enforcementActionDelete
+	}
+	return ep.action
+}
@@ -19,6 +19,8 @@ type ExcludedChannelsStore interface {
 	SetExcluded(channelID string, excluded bool) error
 	IsExcluded(channelID string) (bool, error)
 	ListExcluded() []ExcludedChannelInfo
+	// Reload replaces the cached channels with the stored ones, after another node changed them
+	Reload() error
 }
 
 type excludedChannelsStore struct {
@@ -82,6 +84,21 @@ func (s *excludedChannelsStore) ListExcluded() []ExcludedChannelInfo {
 	return excludedChannels
 }
 
+func (s *excludedChannelsStore) Reload() error {
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+
+	previous := s.cache
+	s.cache = make(map[string]ExcludedChannelInfo)
+	s.loaded = false
+	if err := s.loadCacheWithoutLock(); err != nil {
+		s.cache = previous
+		s.loaded = true
+		return err
+	}
+	return nil
+}
+
 func (s *excludedChannelsStore) loadCacheWithoutLock() error {
 	if s.loaded {
 		return nil
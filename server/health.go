@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+// connectionTestMessage is the harmless text sent to providers to test them
+const connectionTestMessage = "This is a connection test of the content moderation plugin."
+
+// HealthReport describes whether content moderation is running on this server
+type HealthReport struct {
+	Enabled               bool   `json:"enabled"`
+	Healthy               bool   `json:"healthy"`
+	Moderator             string `json:"moderator"`
+	FallbackModerator     string `json:"fallback_moderator,omitempty"`
+	Error                 string `json:"error,omitempty"`
+	ModerationQueueLength int    `json:"moderation_queue_length"`
+	PostQueueLength       int    `json:"post_queue_length"`
+}
+
+// ConnectionTestReport is the outcome of testing a moderation configuration. Each
+// provider the configuration uses, including composite and fallback providers, is
+// tested on its own.
+type ConnectionTestReport struct {
+	Success     bool                  `json:"success"`
+	ConfigError string                `json:"config_error,omitempty"`
+	Providers   []*ProviderTestResult `json:"providers"`
+}
+
+// ProviderTestResult is the outcome of sending the test message to a provider
+type ProviderTestResult struct {
+	Moderator string            `json:"moderator"`
+	Success   bool              `json:"success"`
+	LatencyMs int64             `json:"latency_ms"`
+	Result    moderation.Result `json:"result,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	ErrorKind string            `json:"error_kind,omitempty"`
+}
+
+// setInitializeError records the outcome of the last initialization, so
+// misconfiguration shows up in the health report
+func (p *Plugin) setInitializeError(err error) {
+	p.initializeLock.Lock()
+	defer p.initializeLock.Unlock()
+	p.initializeErr = err
+}
+
+func (p *Plugin) getInitializeError() error {
+	p.initializeLock.RLock()
+	defer p.initializeLock.RUnlock()
+	return p.initializeErr
+}
+
+// healthReport describes whether moderation is enabled and running on this server
+func (p *Plugin) healthReport() *HealthReport {
+	config := p.getConfiguration()
+	report := &HealthReport{
+		Enabled:               config.Enabled,
+		Moderator:             config.ModeratorConfig.Type,
+		FallbackModerator:     config.ModeratorConfig.FallbackType,
+		ModerationQueueLength: p.moderationQueueLength(),
+		PostQueueLength:       p.postQueueLength(),
+	}
+
+	switch {
+	case !config.Enabled:
+		report.Error = "content moderation is disabled"
+	case p.getInitializeError() != nil:
+		report.Error = p.getInitializeError().Error()
+	case p.moderationProcessor == nil || p.postProcessor == nil:
+		report.Error = "content moderation is not running"
+	default:
+		report.Healthy = true
+	}
+	return report
+}
+
+// testConnection validates a moderation configuration and sends a test message to
+// every provider it uses. The providers are called once, without retries, so
+// errors are reported as the provider returned them.
+func (p *Plugin) testConnection(config *configuration) *ConnectionTestReport {
+	report := &ConnectionTestReport{Providers: []*ProviderTestResult{}}
+
+	if err := validateModeratorConfig(config); err != nil {
+		report.ConfigError = err.Error()
+		return report
+	}
+
+	// Agent based providers need the bot to talk to the Agents plugin
+	botID, err := p.API.EnsureBotUser(&model.Bot{
+		Username:    config.BotUsername,
+		DisplayName: config.BotDisplayName,
+	})
+	if err != nil {
+		report.ConfigError = errors.Wrap(err, "could not initialize bot user").Error()
+		return report
+	}
+
+	moderatorTypes := []string{config.ModeratorConfig.Type}
+	if config.ModeratorConfig.Type == "composite" {
+		moderatorTypes = config.CompositeModeratorTypes()
+	}
+	if config.ModeratorConfig.FallbackType != "" {
+		moderatorTypes = append(moderatorTypes, config.ModeratorConfig.FallbackType)
+	}
+
+	report.Success = true
+	for _, moderatorType := range moderatorTypes {
+		result := p.testModerator(config, moderatorType, botID)
+		report.Success = report.Success && result.Success
+		report.Providers = append(report.Providers, result)
+	}
+	return report
+}
+
+func (p *Plugin) testModerator(config *configuration, moderatorType, botID string) *ProviderTestResult {
+	result := &ProviderTestResult{Moderator: moderatorType}
+
+	providerConfig := config.Clone()
+	providerConfig.ModeratorConfig.Type = moderatorType
+	providerConfig.ModeratorConfig.FallbackType = ""
+	mod, err := initModerator(p.API, providerConfig, botID, p.blocklistModerator, p.policiesStore, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), moderationAPITimeout)
+	defer cancel()
+
+	start := time.Now()
+	result.Result, err = mod.ModerateText(ctx, connectionTestMessage)
+	result.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		result.ErrorKind = moderation.ClassifyError(err).String()
+		return result
+	}
+	result.Success = true
+	return result
+}
+
+// validateModeratorConfig checks the settings that initialize needs before it creates the moderator
+func validateModeratorConfig(config *configuration) error {
+	if _, err := config.ThresholdValue(); err != nil {
+		return errors.Wrap(err, "failed to load moderation threshold")
+	}
+	if _, err := config.CategoryThresholdValues(); err != nil {
+		return errors.Wrap(err, "failed to load category moderation thresholds")
+	}
+	if _, err := config.EnforcementActionValue(); err != nil {
+		return errors.Wrap(err, "failed to load enforcement action")
+	}
+	if config.ModeratorConfig.Type == "composite" {
+		if len(config.CompositeModeratorTypes()) == 0 {
+			return errors.New("composite moderator has no moderators")
+		}
+		if _, err := config.CompositeMarginValue(); err != nil {
+			return err
+		}
+	}
+	if config.ModeratorConfig.FallbackType != "" && config.ModeratorConfig.FallbackType == config.ModeratorConfig.Type {
+		return errors.New("fallback moderator must differ from the primary moderator")
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation/blocklist"
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation/openai"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlugin_testConnection(t *testing.T) {
+	blocklistModerator, err := blocklist.New([]blocklist.Entry{{ID: "entry1", Pattern: "badword", Category: "Hate", Severity: 6}})
+	require.NoError(t, err)
+
+	// The Azure provider is rejected with an invalid key
+	azureServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, `{"error":{"code":"401","message":"Access denied due to invalid subscription key."}}`, http.StatusUnauthorized)
+	}))
+	defer azureServer.Close()
+
+	newPlugin := func() *Plugin {
+		api := &plugintest.API{}
+		api.On("EnsureBotUser", mock.Anything).Return("bot123", nil)
+		api.On("LogInfo", mock.Anything).Return()
+		p := &Plugin{blocklistModerator: blocklistModerator}
+		p.SetAPI(api)
+		return p
+	}
+
+	t.Run("tests the primary and fallback providers", func(t *testing.T) {
+		config := &configuration{
+			ModeratorConfig: ModeratorConfig{
+				Type:               "blocklist",
+				BlocklistThreshold: "4",
+				FallbackType:       "azure",
+				AzureEndpoint:      azureServer.URL,
+				AzureAPIKey:        "invalid",
+			},
+		}
+
+		report := newPlugin().testConnection(config)
+		assert.False(t, report.Success)
+		assert.Empty(t, report.ConfigError)
+		require.Len(t, report.Providers, 2)
+
+		assert.Equal(t, "blocklist", report.Providers[0].Moderator)
+		assert.True(t, report.Providers[0].Success)
+		assert.NotNil(t, report.Providers[0].Result)
+
+		assert.Equal(t, "azure", report.Providers[1].Moderator)
+		assert.False(t, report.Providers[1].Success)
+		assert.Contains(t, report.Providers[1].Error, "401")
+		assert.Equal(t, "permanent", report.Providers[1].ErrorKind)
+	})
+
+	t.Run("tests every provider of a composite moderator", func(t *testing.T) {
+		config := &configuration{
+			ModeratorConfig: ModeratorConfig{
+				Type:                "composite",
+				CompositeModerators: "blocklist,azure",
+				CompositeThreshold:  "4",
+			},
+		}
+
+		report := newPlugin().testConnection(config)
+		assert.False(t, report.Success)
+		require.Len(t, report.Providers, 2)
+		assert.True(t, report.Providers[0].Success)
+		assert.Contains(t, report.Providers[1].Error, "endpoint URL is required")
+	})
+
+	t.Run("reports invalid configuration", func(t *testing.T) {
+		config := &configuration{
+			ModeratorConfig: ModeratorConfig{Type: "azure"},
+		}
+
+		report := newPlugin().testConnection(config)
+		assert.False(t, report.Success)
+		assert.Contains(t, report.ConfigError, "threshold")
+		assert.Empty(t, report.Providers)
+	})
+}
+
+func TestPlugin_healthReport(t *testing.T) {
+	t.Run("reports initialization errors", func(t *testing.T) {
+		p := &Plugin{configuration: &configuration{Enabled: true, ModeratorConfig: ModeratorConfig{Type: "azure"}}}
+		p.setInitializeError(errors.New("failed to initialize moderator: endpoint URL is required"))
+
+		report := p.healthReport()
+		assert.True(t, report.Enabled)
+		assert.False(t, report.Healthy)
+		assert.Equal(t, "azure", report.Moderator)
+		assert.Contains(t, report.Error, "endpoint URL is required")
+	})
+
+	t.Run("healthy while the processors run", func(t *testing.T) {
+		p := &Plugin{
+			configuration:       &configuration{Enabled: true, ModeratorConfig: ModeratorConfig{Type: "azure"}},
+			moderationProcessor: &ModerationProcessor{queue: newModerationQueue(10)},
+			postProcessor:       &PostProcessor{postsCh: make(chan *model.Post, 10)},
+		}
+		p.postProcessor.postsCh <- &model.Post{Id: "post1"}
+
+		report := p.healthReport()
+		assert.True(t, report.Healthy)
+		assert.Empty(t, report.Error)
+		assert.Equal(t, 1, report.PostQueueLength)
+	})
+}
+
+func TestWithSavedSecrets(t *testing.T) {
+	saved := ModeratorConfig{
+		AzureEndpoint: "https://example.cognitiveservices.azure.com",
+		AzureAPIKey:   "saved-azure",
+		OpenAIAPIKey:  "saved-openai",
+	}
+
+	tests := []struct {
+		name           string
+		config         ModeratorConfig
+		expectedAzure  string
+		expectedOpenAI string
+	}{
+		{
+			name:           "uses saved keys for masked keys",
+			config:         ModeratorConfig{AzureEndpoint: saved.AzureEndpoint, AzureAPIKey: model.FakeSetting, OpenAIAPIKey: "new-openai"},
+			expectedAzure:  "saved-azure",
+			expectedOpenAI: "new-openai",
+		},
+		{
+			name:           "ignores trailing slashes and the default endpoint",
+			config:         ModeratorConfig{AzureEndpoint: " https://Example.cognitiveservices.azure.com/ ", AzureAPIKey: model.FakeSetting, OpenAIEndpoint: openai.DefaultEndpoint + "/", OpenAIAPIKey: model.FakeSetting},
+			expectedAzure:  "saved-azure",
+			expectedOpenAI: "saved-openai",
+		},
+		{
+			name:           "does not send saved keys to another endpoint",
+			config:         ModeratorConfig{AzureEndpoint: "https://attacker.example.com", AzureAPIKey: model.FakeSetting, OpenAIEndpoint: "https://attacker.example.com/v1", OpenAIAPIKey: model.FakeSetting},
+			expectedAzure:  "",
+			expectedOpenAI: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := withSavedSecrets(tt.config, saved)
+			assert.Equal(t, tt.expectedAzure, merged.AzureAPIKey)
+			assert.Equal(t, tt.expectedOpenAI, merged.OpenAIAPIKey)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+const (
+	hiddenPostKVKeyPrefix = "hidden_post_"
+	hiddenPostMessage     = "_This post was hidden by content moderation._"
+)
+
+// HiddenPost keeps the original content of a post that was hidden by moderation
+type HiddenPost struct {
+	Post     *model.Post `json:"post"`
+	HiddenAt int64       `json:"hidden_at"`
+}
+
+// hidePost stores the original post in the KV store and replaces its visible
+// content, including the attachments and card added by integrations, with a
+// placeholder
+func hidePost(api plugin.API, post *model.Post) error {
+	data, err := json.Marshal(HiddenPost{
+		Post:     post,
+		HiddenAt: model.GetMillis(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal hidden post")
+	}
+
+	if appErr := api.KVSet(hiddenPostKVKeyPrefix+post.Id, data); appErr != nil {
+		return errors.Wrap(appErr, "failed to store hidden post")
+	}
+
+	hidden := post.Clone()
+	hidden.Message = hiddenPostMessage
+	hidden.FileIds = nil
+	hidden.DelProp(model.PostPropsAttachments)
+	hidden.DelProp(postPropsCard)
+	done := pluginPostWrites.beginUpdate(hidden)
+	defer done()
+	if _, appErr := api.UpdatePost(hidden); appErr != nil {
+		return errors.Wrap(appErr, "failed to update hidden post")
+	}
+
+	return nil
+}
+
+// getHiddenPost returns the original content of a hidden post, or nil if the post is not hidden
+func getHiddenPost(api plugin.API, postID string) (*HiddenPost, error) {
+	data, appErr := api.KVGet(hiddenPostKVKeyPrefix + postID)
+	if appErr != nil {
+		return nil, errors.Wrap(appErr, "failed to get hidden post")
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var hidden HiddenPost
+	if err := json.Unmarshal(data, &hidden); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal hidden post")
+	}
+	return &hidden, nil
+}
+
+// restoreHiddenPost puts the original content of a hidden post back in place
+func restoreHiddenPost(api plugin.API, postID string) (*model.Post, error) {
+	hidden, err := getHiddenPost(api, postID)
+	if err != nil {
+		return nil, err
+	}
+	if hidden == nil || hidden.Post == nil {
+		return nil, errors.New("hidden post not found")
+	}
+
+	current, appErr := api.GetPost(postID)
+	if appErr != nil {
+		return nil, errors.Wrap(appErr, "failed to get hidden post")
+	}
+
+	restored := current.Clone()
+	restored.Message = hidden.Post.Message
+	restored.FileIds = hidden.Post.FileIds
+	restored.SetProps(hidden.Post.GetProps())
+	done := pluginPostWrites.beginUpdate(restored)
+	updated, appErr := api.UpdatePost(restored)
+	done()
+	if appErr != nil {
+		return nil, errors.Wrap(appErr, "failed to restore hidden post")
+	}
+
+	if appErr := api.KVDelete(hiddenPostKVKeyPrefix + postID); appErr != nil {
+		return nil, errors.Wrap(appErr, "failed to delete hidden post")
+	}
+
+	return updated, nil
+}
+
+// deleteHiddenPost removes a hidden post along with its stored original content
+func deleteHiddenPost(api plugin.API, postID string) error {
+	if appErr := api.DeletePost(postID); appErr != nil {
+		return errors.Wrap(appErr, "failed to delete hidden post")
+	}
+	if appErr := api.KVDelete(hiddenPostKVKeyPrefix + postID); appErr != nil {
+		return errors.Wrap(appErr, "failed to delete hidden post content")
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHidePost(t *testing.T) {
+	post := &model.Post{Id: "post123", Message: "offensive message", FileIds: []string{"file1"}}
+	post.AddProp(postPropsCard, "offensive card")
+	post.AddProp(model.PostPropsAttachments, []*model.SlackAttachment{{Text: "offensive attachment"}})
+	post.AddProp("from_webhook", "true")
+
+	api := newKVTestAPI()
+	var updated *model.Post
+	api.On("UpdatePost", mock.Anything).Return(func(post *model.Post) (*model.Post, *model.AppError) {
+		updated = post
+		return post, nil
+	})
+
+	require.NoError(t, hidePost(api, post.Clone()))
+	require.NotNil(t, updated)
+	assert.Equal(t, hiddenPostMessage, updated.Message)
+	assert.Empty(t, updated.FileIds)
+	assert.Nil(t, updated.GetProp(postPropsCard))
+	assert.Nil(t, updated.GetProp(model.PostPropsAttachments))
+	assert.Equal(t, "true", updated.GetProp("from_webhook"))
+	assert.Equal(t, hiddenPostMessage, postText(updated), "no hidden text stays visible")
+
+	api.On("GetPost", "post123").Return(updated, nil)
+	restored, err := restoreHiddenPost(api, "post123")
+	require.NoError(t, err)
+	assert.Equal(t, "offensive message", restored.Message)
+	assert.Equal(t, "offensive card", restored.GetProp(postPropsCard))
+	assert.Equal(t, model.StringArray{"file1"}, restored.FileIds)
+}
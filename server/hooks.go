@@ -10,16 +10,35 @@ import (
 
 const emailNotificationWaitForResultTimeout = 15 * time.Second
 
+const (
+	blockedPostRejectionMessage    = "Your message was flagged by content moderation and was not posted."
+	unverifiedPostRejectionMessage = "Your message could not be checked by content moderation and was not posted. Please try again later."
+)
+
 func (p *Plugin) MessageWillBePosted(c *plugin.Context, post *model.Post) (*model.Post, string) {
-	if p.moderationProcessor != nil {
-		p.moderationProcessor.queueMessage(p.API, post.Message)
+	// Posts the plugin reinstates are not written by their restricted authors
+	if !pluginPostWrites.isCreating(post) {
+		if rejection := p.checkPostingRestriction(post); rejection != "" {
+			return nil, rejection
+		}
+	}
+	p.queueMessage(post)
+	if rejection := p.checkPostBeforePublish(post); rejection != "" {
+		return nil, rejection
 	}
 	return nil, ""
 }
 
 func (p *Plugin) MessageWillBeUpdated(c *plugin.Context, post, _ *model.Post) (*model.Post, string) {
-	if p.moderationProcessor != nil {
-		p.moderationProcessor.queueMessage(p.API, post.Message)
+	// Posts the plugin hides or restores are not written by their restricted authors
+	if !pluginPostWrites.isUpdating(post) {
+		if rejection := p.checkPostingRestriction(post); rejection != "" {
+			return nil, rejection
+		}
+	}
+	p.queueMessage(post)
+	if rejection := p.checkPostBeforePublish(post); rejection != "" {
+		return nil, rejection
 	}
 	return post, ""
 }
@@ -36,6 +55,20 @@ func (p *Plugin) MessageHasBeenUpdated(c *plugin.Context, post *model.Post) {
 	}
 }
 
+func (p *Plugin) ChannelHasBeenCreated(c *plugin.Context, channel *model.Channel) {
+	if p.postProcessor != nil && p.getConfiguration().ModerateChannels {
+		// Waiting for the moderation result must not hold up the hook
+		go p.postProcessor.moderateNewChannel(p.API, channel)
+	}
+}
+
+func (p *Plugin) UserHasBeenCreated(c *plugin.Context, user *model.User) {
+	if p.postProcessor != nil && p.getConfiguration().ModerateProfiles {
+		// Waiting for the moderation result must not hold up the hook
+		go p.postProcessor.moderateProfile(p.API, user)
+	}
+}
+
 func (p *Plugin) EmailNotificationWillBeSent(emailNotification *model.EmailNotification) (*model.EmailNotificationContent, string) {
 	if p.postProcessor == nil {
 		return nil, ""
@@ -48,8 +81,14 @@ func (p *Plugin) EmailNotificationWillBeSent(emailNotification *model.EmailNotif
 		return nil, ""
 	}
 
+	// The notification is held until the post is moderated, so moderate it first
+	text := postText(post)
+	if p.moderationProcessor != nil {
+		p.moderationProcessor.prioritizeMessage(text, moderationPriorityUrgent)
+	}
+
 	result := p.postProcessor.resultsCache.waitForResult(
-		post.Message, emailNotificationWaitForResultTimeout)
+		text, emailNotificationWaitForResultTimeout)
 	if result == nil {
 		p.API.LogError(
 			"Failed to complete content moderation before email notification timeout",
@@ -63,3 +102,86 @@ func (p *Plugin) EmailNotificationWillBeSent(emailNotification *model.EmailNotif
 
 	return nil, ""
 }
+
+// checkPostBeforePublish waits for the moderation result of a post when blocking
+// mode is enabled, and returns a rejection reason if the post must not be published.
+// An empty string means the post can be published.
+func (p *Plugin) checkPostBeforePublish(post *model.Post) string {
+	config := p.getConfiguration()
+	text := postText(post)
+	if !config.BlockBeforePublish || p.postProcessor == nil || text == "" {
+		return ""
+	}
+
+	record := plugin.MakeAuditRecord(auditEventTypeContentModeration, model.AuditStatusAttempt)
+	model.AddEventParameterAuditableToAuditRec(record, auditParamKeyPost, post)
+	record.AddMeta(auditMetaKeyAction, "block_before_publish")
+
+	if !p.postProcessor.shouldModerateUser(post.UserId, record) ||
+		!p.postProcessor.shouldModerateChannel(p.API, post.ChannelId, record) {
+		return ""
+	}
+
+	// Posts in channels that only flag or monitor content are never blocked
+	policy := p.postProcessor.resolvePolicy(p.API, post.ChannelId)
+	if action := policy.enforcementAction(); action == enforcementActionFlag || action == enforcementActionMonitor {
+		return ""
+	}
+
+	result := p.postProcessor.resultsCache.waitForResult(text, config.BlockTimeout())
+	if result == nil {
+		return p.handleBlockingFailure(config, record, post, context.DeadlineExceeded)
+	}
+
+	switch result.code {
+	case moderationResultProcessed, moderationResultFlagged:
+		if !policy.isFlagged(result) || p.postProcessor.isApproved(p.API, post) {
+			return ""
+		}
+		record.AddMeta(auditMetaKeyResult, result.result)
+		record.AddMeta(auditMetaKeyFlagged, true)
+		p.postProcessor.logAuditSuccess(p.API, record)
+		return blockedPostRejectionMessage
+	case moderationResultError:
+		return p.handleBlockingFailure(config, record, post, result.err)
+	default:
+		return ""
+	}
+}
+
+func (p *Plugin) handleBlockingFailure(config *configuration, record *model.AuditRecord, post *model.Post, err error) string {
+	if !config.BlockFailClosed() {
+		p.API.LogWarn("Content moderation did not complete before publishing, allowing post",
+			"post_id", post.Id, "user_id", post.UserId, "err", err)
+		return ""
+	}
+
+	errMsg := "Content moderation did not complete before publishing, rejecting post"
+	p.API.LogError(errMsg, "post_id", post.Id, "user_id", post.UserId, "err", err)
+	p.postProcessor.logAuditFail(p.API, record, errMsg, err)
+	return unverifiedPostRejectionMessage
+}
+
+// queueMessage queues the text of a post for moderation as soon as the post is
+// seen. Posts that are not moderated are skipped before their priority is looked up.
+func (p *Plugin) queueMessage(post *model.Post) {
+	queuePostMessage(p.API, p.moderationProcessor, p.postProcessor, post, p.getConfiguration().LargeChannelMemberCountValue())
+}
+
+// queuePostMessage queues the text of a post on the moderation processor, unless
+// it is empty or the post is excluded from moderation
+func queuePostMessage(api plugin.API, moderationProcessor *ModerationProcessor, postProcessor *PostProcessor, post *model.Post, largeChannelMemberCount int64) {
+	text := postText(post)
+	if moderationProcessor == nil || text == "" {
+		return
+	}
+
+	priority := moderationPriorityNormal
+	if postProcessor != nil {
+		if postProcessor.isExcluded(api, post.UserId, post.ChannelId) {
+			return
+		}
+		priority = postProcessor.messagePriority(api, post, largeChannelMemberCount)
+	}
+	moderationProcessor.queueMessage(api, text, priority)
+}
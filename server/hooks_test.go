@@ -0,0 +1,146 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newBlockingTestPlugin(api *plugintest.API, config *configuration) (*Plugin, *moderationResultsCache) {
+	cache := newModerationResultsCache()
+	p := &Plugin{
+		configuration: config,
+		postProcessor: &PostProcessor{
+			botID:                "bot123",
+			excludedUsers:        map[string]struct{}{},
+			excludedChannelStore: NewMockExcludedChannelsStore([]string{}),
+			resultsCache:         cache,
+			postCache:            newPostCache(),
+			cleanupTicker:        time.NewTicker(24 * time.Hour),
+		},
+	}
+	p.SetAPI(api)
+	return p, cache
+}
+
+func TestPlugin_checkPostBeforePublish(t *testing.T) {
+	post := &model.Post{
+		Id:        "post123",
+		UserId:    "user456",
+		ChannelId: "channel123",
+		Message:   "test message",
+	}
+
+	openChannel := &model.Channel{Id: "channel123", Type: model.ChannelTypeOpen}
+
+	t.Run("allows post when blocking is disabled", func(t *testing.T) {
+		api := &plugintest.API{}
+		p, cache := newBlockingTestPlugin(api, &configuration{BlockBeforePublish: false})
+		cache.setModerationResultFlagged("test message", moderation.Result{"hate": 6})
+
+		assert.Empty(t, p.checkPostBeforePublish(post))
+		api.AssertExpectations(t)
+	})
+
+	t.Run("rejects flagged post", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("GetChannel", "channel123").Return(openChannel, nil)
+		api.On("KVGet", approvedContentKey("user456", "channel123", "test message")).Return(nil, nil)
+		p, cache := newBlockingTestPlugin(api, &configuration{BlockBeforePublish: true})
+		cache.setModerationResultFlagged("test message", moderation.Result{"hate": 6})
+
+		assert.Equal(t, blockedPostRejectionMessage, p.checkPostBeforePublish(post))
+		api.AssertExpectations(t)
+	})
+
+	t.Run("allows flagged post approved by a reviewer", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("GetChannel", "channel123").Return(openChannel, nil)
+		api.On("KVGet", approvedContentKey("user456", "channel123", "test message")).Return([]byte("1"), nil)
+		p, cache := newBlockingTestPlugin(api, &configuration{BlockBeforePublish: true})
+		cache.setModerationResultFlagged("test message", moderation.Result{"hate": 6})
+
+		assert.Empty(t, p.checkPostBeforePublish(post))
+		api.AssertExpectations(t)
+	})
+
+	t.Run("allows post that was not flagged", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("GetChannel", "channel123").Return(openChannel, nil)
+		p, cache := newBlockingTestPlugin(api, &configuration{BlockBeforePublish: true})
+		cache.setModerationResultNotFlagged("test message", moderation.Result{"hate": 0})
+
+		assert.Empty(t, p.checkPostBeforePublish(post))
+		api.AssertExpectations(t)
+	})
+
+	t.Run("allows flagged post in monitor mode", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("GetChannel", "channel123").Return(openChannel, nil)
+		p, cache := newBlockingTestPlugin(api, &configuration{BlockBeforePublish: true})
+		p.postProcessor.defaultPolicy = effectivePolicy{threshold: 4, action: enforcementActionMonitor}
+		cache.setModerationResultFlagged("test message", moderation.Result{"hate": 6})
+
+		assert.Empty(t, p.checkPostBeforePublish(post))
+		api.AssertExpectations(t)
+	})
+
+	t.Run("allows flagged post from excluded user", func(t *testing.T) {
+		api := &plugintest.API{}
+		p, cache := newBlockingTestPlugin(api, &configuration{BlockBeforePublish: true})
+		p.postProcessor.excludedUsers = map[string]struct{}{"user456": {}}
+		cache.setModerationResultFlagged("test message", moderation.Result{"hate": 6})
+
+		assert.Empty(t, p.checkPostBeforePublish(post))
+		api.AssertExpectations(t)
+	})
+
+	t.Run("allows post on moderation error when failing open", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("GetChannel", "channel123").Return(openChannel, nil)
+		api.On("LogWarn", mock.AnythingOfType("string"), mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		p, cache := newBlockingTestPlugin(api, &configuration{
+			BlockBeforePublish: true,
+			BlockFailurePolicy: blockFailurePolicyOpen,
+		})
+		cache.setModerationResultError("test message", errors.New("moderation API error"))
+
+		assert.Empty(t, p.checkPostBeforePublish(post))
+		api.AssertExpectations(t)
+	})
+
+	t.Run("rejects post on moderation error when failing closed", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("GetChannel", "channel123").Return(openChannel, nil)
+		api.On("LogError", mock.AnythingOfType("string"), mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		p, cache := newBlockingTestPlugin(api, &configuration{
+			BlockBeforePublish: true,
+			BlockFailurePolicy: blockFailurePolicyClosed,
+		})
+		cache.setModerationResultError("test message", errors.New("moderation API error"))
+
+		assert.Equal(t, unverifiedPostRejectionMessage, p.checkPostBeforePublish(post))
+		api.AssertExpectations(t)
+	})
+
+	t.Run("rejects post on timeout when failing closed", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("GetChannel", "channel123").Return(openChannel, nil)
+		api.On("LogError", mock.AnythingOfType("string"), mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		p, cache := newBlockingTestPlugin(api, &configuration{
+			BlockBeforePublish:  true,
+			BlockTimeoutSeconds: 1,
+			BlockFailurePolicy:  blockFailurePolicyClosed,
+		})
+		cache.setResultPending("test message")
+
+		assert.Equal(t, unverifiedPostRejectionMessage, p.checkPostBeforePublish(post))
+		api.AssertExpectations(t)
+	})
+}
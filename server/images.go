@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation/azure"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+// Images are queued for moderation like messages, under a key that identifies the
+// image. The keys start with a NUL character, which can't be stored in a post
+// and is removed from attachment text, so they never collide with moderated text.
+const (
+	imageKeyPrefix     = "\x00image:"
+	imageFileKeyPrefix = imageKeyPrefix + "file:"
+	imageLinkKeyPrefix = imageKeyPrefix + "link:"
+)
+
+const (
+	// maxImageSize is the largest image sent to the moderation provider
+	maxImageSize = azure.MaxImageSize
+	// minImageDimension is the smallest width and height the moderation provider accepts
+	minImageDimension = 50
+	// maxPostImageLinks caps the linked images moderated per post
+	maxPostImageLinks = 5
+	// imageDownloadTimeout bounds downloading a linked image
+	imageDownloadTimeout = 10 * time.Second
+)
+
+var (
+	// errImageNotModerated is returned for linked images that can never be moderated,
+	// e.g. because they point to the internal network or are not images, so
+	// moderating them again would fail the same way
+	errImageNotModerated = errors.New("image is not moderated")
+
+	// errInternalAddress is returned when a linked image would be downloaded from
+	// an address that is not publicly routable
+	errInternalAddress = errors.New("internal address")
+)
+
+// markdownImageRegexp matches the URL of markdown images, e.g. ![alt](https://example.com/image.png "title")
+var markdownImageRegexp = regexp.MustCompile(`!\[[^\]]*\]\(\s*<?(https?://[^\s)>]+)>?(?:\s+"[^"]*")?\s*\)`)
+
+// imageHTTPClient downloads linked images. It refuses to connect to loopback,
+// private and link-local addresses, so links can't be used to reach services on
+// the internal network of the server.
+var imageHTTPClient = &http.Client{
+	Timeout: imageDownloadTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: rejectInternalAddress,
+		}).DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: imageDownloadTimeout,
+	},
+	CheckRedirect: func(_ *http.Request, via []*http.Request) error {
+		if len(via) >= 3 {
+			return errors.New("too many redirects")
+		}
+		return nil
+	},
+}
+
+func imageFileKey(fileID string) string {
+	return imageFileKeyPrefix + fileID
+}
+
+func imageLinkKey(link string) string {
+	return imageLinkKeyPrefix + link
+}
+
+// isImageKey returns true if a queued message is the key of an image
+func isImageKey(message string) bool {
+	return strings.HasPrefix(message, imageKeyPrefix)
+}
+
+// queueImages queues the images attached to or linked from a post for moderation
+// and returns their keys. Returns nil if images are not moderated or the
+// moderation provider can't check images.
+func (p *PostProcessor) queueImages(api plugin.API, post *model.Post, files []*model.FileInfo) []string {
+	if !p.moderateImages || p.moderationProcessor == nil || !p.moderationProcessor.supportsImages() {
+		return nil
+	}
+
+	var keys []string
+	for _, info := range files {
+		if isModeratedImage(info) {
+			keys = append(keys, imageFileKey(info.Id))
+		}
+	}
+	for _, link := range imageLinks(post) {
+		keys = append(keys, imageLinkKey(link))
+	}
+
+	for _, key := range keys {
+		p.moderationProcessor.queueMessage(api, key, moderationPriorityNormal)
+	}
+	return keys
+}
+
+// isModeratedImage returns true if an attachment is an image the moderation provider accepts
+func isModeratedImage(info *model.FileInfo) bool {
+	if !info.IsImage() || info.IsSvg() || info.Size > maxImageSize {
+		return false
+	}
+	// The dimensions are unknown for some formats, which are sent anyway
+	if info.Width > 0 && info.Height > 0 && (info.Width < minImageDimension || info.Height < minImageDimension) {
+		return false
+	}
+	return true
+}
+
+// imageLinks returns the images linked from a post, as found by the server when it
+// prepared the post metadata or as markdown images in the text of the post
+func imageLinks(post *model.Post) []string {
+	links := make(map[string]struct{})
+	if post.Metadata != nil {
+		for link, image := range post.Metadata.Images {
+			if image != nil && image.Width > 0 && image.Height > 0 &&
+				(image.Width < minImageDimension || image.Height < minImageDimension) {
+				continue
+			}
+			links[link] = struct{}{}
+		}
+	}
+	for _, match := range markdownImageRegexp.FindAllStringSubmatch(postText(post), -1) {
+		links[match[1]] = struct{}{}
+	}
+
+	var result []string
+	for link := range links {
+		if parsed, err := url.Parse(link); err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") {
+			result = append(result, link)
+		}
+	}
+	sort.Strings(result)
+	if len(result) > maxPostImageLinks {
+		result = result[:maxPostImageLinks]
+	}
+	return result
+}
+
+// supportsImages returns true if the moderator can check images
+func (p *ModerationProcessor) supportsImages() bool {
+	return moderation.SupportsImages(p.moderator)
+}
+
+// moderateImage loads the image identified by a key and sends it to the moderator
+func (p *ModerationProcessor) moderateImage(ctx context.Context, api plugin.API, key string) (moderation.Result, error) {
+	imageModerator, ok := p.moderator.(moderation.ImageModerator)
+	if !ok {
+		return nil, moderation.ErrImagesNotSupported
+	}
+
+	image, err := loadImage(ctx, api, key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load image for moderation")
+	}
+	return imageModerator.ModerateImage(ctx, image)
+}
+
+// loadImage returns the content of the attachment or linked image identified by a key
+func loadImage(ctx context.Context, api plugin.API, key string) ([]byte, error) {
+	if fileID, ok := strings.CutPrefix(key, imageFileKeyPrefix); ok {
+		data, appErr := api.GetFile(fileID)
+		if appErr != nil {
+			return nil, errors.Wrapf(appErr, "failed to get file %s", fileID)
+		}
+		return data, nil
+	}
+	if link, ok := strings.CutPrefix(key, imageLinkKeyPrefix); ok {
+		return downloadImage(ctx, link)
+	}
+	return nil, errors.New("unknown image key")
+}
+
+// downloadImage downloads a linked image of at most maxImageSize bytes
+func downloadImage(ctx context.Context, link string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create image request")
+	}
+
+	resp, err := imageHTTPClient.Do(req)
+	if err != nil {
+		// The refused connection is a network error, which is otherwise retried
+		if errors.Is(err, errInternalAddress) {
+			return nil, errors.Wrap(errImageNotModerated, "link points to an internal address")
+		}
+		return nil, errors.Wrap(err, "failed to download image")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("image download returned status %d", resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "image/") {
+		return nil, errors.Wrapf(errImageNotModerated, "link is not an image: %s", contentType)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageSize+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read image")
+	}
+	if len(data) > maxImageSize {
+		return nil, errors.Errorf("image exceeds the maximum size of %d bytes", maxImageSize)
+	}
+	return data, nil
+}
+
+// rejectInternalAddress refuses connections to addresses that are not publicly routable
+func rejectInternalAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return errors.Wrapf(errInternalAddress, "refusing to download image from %s", host)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation/azure"
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation/composite"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// explicitImageModerator flags text containing "offensive" and images containing "explicit"
+type explicitImageModerator struct {
+	keywordModerator
+}
+
+func (m *explicitImageModerator) ModerateImage(_ context.Context, image []byte) (moderation.Result, error) {
+	if bytes.Contains(image, []byte("explicit")) {
+		return moderation.Result{"Sexual": 6}, nil
+	}
+	return moderation.Result{"Sexual": 0}, nil
+}
+
+// uniformThresholds flags every category of a composite moderator at the same severity
+func uniformThresholds(threshold int) func() composite.Thresholds {
+	return func() composite.Thresholds {
+		return composite.Thresholds{Default: composite.Range{Lowest: threshold, Highest: threshold}}
+	}
+}
+
+func TestSupportsImages(t *testing.T) {
+	images := &explicitImageModerator{}
+	text := &fakeModerator{}
+
+	assert.True(t, moderation.SupportsImages(images))
+	assert.False(t, moderation.SupportsImages(text))
+	assert.True(t, moderation.SupportsImages(instrumentModerator(images, "azure", newMetrics(func() int { return 0 }, func() int { return 0 }))))
+	assert.False(t, moderation.SupportsImages(instrumentModerator(text, "agents", newMetrics(func() int { return 0 }, func() int { return 0 }))))
+
+	mixed, err := composite.New([]composite.Stage{{Name: "agents", Moderator: text}, {Name: "azure", Moderator: images}}, composite.StrategyMaxSeverity, uniformThresholds(4), 0)
+	require.NoError(t, err)
+	assert.True(t, moderation.SupportsImages(mixed))
+
+	// Text only stages are skipped for images
+	result, err := mixed.ModerateImage(context.Background(), []byte("explicit"))
+	require.NoError(t, err)
+	assert.Equal(t, moderation.Result{"Sexual": 6}, result)
+	assert.Zero(t, text.calls)
+
+	textOnly, err := composite.New([]composite.Stage{{Name: "agents", Moderator: text}}, composite.StrategyMaxSeverity, uniformThresholds(4), 0)
+	require.NoError(t, err)
+	assert.False(t, moderation.SupportsImages(textOnly))
+}
+
+func TestAzureModerator_ModerateImage(t *testing.T) {
+	var request azure.ImageAnalyzeRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/contentsafety/image:analyze", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		_, _ = w.Write([]byte(`{"categoriesAnalysis":[{"category":"Sexual","severity":6},{"category":"Hate","severity":0}]}`))
+	}))
+	defer server.Close()
+
+	api := &plugintest.API{}
+	api.On("LogInfo", mock.Anything).Return()
+	config := &configuration{ModeratorConfig: ModeratorConfig{Type: "azure", AzureEndpoint: server.URL, AzureAPIKey: "key"}}
+	mod, err := initModerator(api, config, "bot123", nil, nil, nil)
+	require.NoError(t, err)
+	require.True(t, moderation.SupportsImages(mod))
+
+	result, err := mod.(moderation.ImageModerator).ModerateImage(context.Background(), []byte("image data"))
+	require.NoError(t, err)
+	assert.Equal(t, moderation.Result{"Sexual": 6, "Hate": 0}, result)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("image data")), request.Image.Content)
+}
+
+func TestImageLinks(t *testing.T) {
+	post := &model.Post{
+		Message: "look ![cat](https://example.com/cat.png \"A cat\") and ![local](file:///etc/passwd) ![](http://example.com/dog.gif)",
+		Metadata: &model.PostMetadata{
+			Images: map[string]*model.PostImage{
+				"https://example.com/embed.jpg": {Width: 400, Height: 300},
+				"https://example.com/icon.png":  {Width: 16, Height: 16},
+			},
+		},
+	}
+
+	assert.Equal(t, []string{
+		"http://example.com/dog.gif",
+		"https://example.com/cat.png",
+		"https://example.com/embed.jpg",
+	}, imageLinks(post))
+}
+
+func TestIsModeratedImage(t *testing.T) {
+	assert.True(t, isModeratedImage(&model.FileInfo{MimeType: "image/png", Size: 1000, Width: 400, Height: 300}))
+	assert.False(t, isModeratedImage(&model.FileInfo{MimeType: "application/pdf", Size: 1000}))
+	assert.False(t, isModeratedImage(&model.FileInfo{MimeType: "image/svg+xml", Size: 1000}))
+	assert.False(t, isModeratedImage(&model.FileInfo{MimeType: "image/png", Size: maxImageSize + 1}))
+	assert.False(t, isModeratedImage(&model.FileInfo{MimeType: "image/png", Size: 1000, Width: 16, Height: 16}))
+}
+
+func TestDownloadImage_RejectsInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte("image data"))
+	}))
+	defer server.Close()
+
+	_, err := downloadImage(context.Background(), server.URL+"/image.png")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "internal address")
+	assert.ErrorIs(t, err, errImageNotModerated)
+	assert.Equal(t, moderation.ErrorKindPermanent, moderation.ClassifyError(err), "the link is not downloaded again")
+}
+
+func TestDownloadImage_RejectsOtherContent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+
+	// The test server listens on an internal address
+	client := imageHTTPClient
+	imageHTTPClient = server.Client()
+	defer func() { imageHTTPClient = client }()
+
+	_, err := downloadImage(context.Background(), server.URL+"/page")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, errImageNotModerated)
+	assert.Equal(t, moderation.ErrorKindPermanent, moderation.ClassifyError(err))
+}
+
+func TestPostProcessor_processPostImages(t *testing.T) {
+	newProcessor := func(t *testing.T, api *plugintest.API, moderator moderation.Moderator) *PostProcessor {
+		resultsCache := newModerationResultsCache()
+		moderationProcessor, err := newModerationProcessor(resultsCache, moderator, 4, nil, 6000, 10, 1, nil, nil, nil)
+		require.NoError(t, err)
+		moderationProcessor.start(api)
+		t.Cleanup(moderationProcessor.stop)
+
+		return &PostProcessor{
+			botID:                "bot123",
+			excludedUsers:        map[string]struct{}{},
+			excludedChannelStore: NewMockExcludedChannelsStore([]string{}),
+			defaultPolicy:        effectivePolicy{threshold: 4, action: enforcementActionDelete},
+			moderateImages:       true,
+			moderationProcessor:  moderationProcessor,
+			resultsCache:         resultsCache,
+			postCache:            newPostCache(),
+			postsCh:              make(chan *model.Post, 1),
+		}
+	}
+
+	newAPI := func(image string) *plugintest.API {
+		api := &plugintest.API{}
+		api.On("GetChannel", "channel123").Return(&model.Channel{Id: "channel123", Type: model.ChannelTypeOpen}, nil)
+		api.On("GetFileInfo", "file1").Return(&model.FileInfo{Id: "file1", MimeType: "image/png", Size: 100, Width: 400, Height: 300}, nil)
+		api.On("GetFile", "file1").Return([]byte(image), nil)
+		return api
+	}
+
+	t.Run("deletes a post with a flagged image", func(t *testing.T) {
+		api := newAPI("explicit image")
+		api.On("KVGet", approvedContentKey("user456", "channel123", "harmless caption")).Return(nil, nil)
+		api.On("DeletePost", "post123").Return(nil)
+		api.On("GetDirectChannel", "bot123", "user456").Return(&model.Channel{Id: "dm_channel"}, nil)
+		api.On("CreatePost", mock.Anything).Return(&model.Post{}, nil)
+
+		processor := newProcessor(t, api, &explicitImageModerator{})
+		post := &model.Post{Id: "post123", UserId: "user456", ChannelId: "channel123", Message: "harmless caption", FileIds: []string{"file1"}}
+		processor.moderationProcessor.queueMessage(api, post.Message, moderationPriorityNormal)
+
+		processor.queuePost(api, post)
+		assert.True(t, processor.processPost(api, <-processor.postsCh))
+		api.AssertCalled(t, "DeletePost", "post123")
+	})
+
+	t.Run("skips images if the moderator only checks text", func(t *testing.T) {
+		api := newAPI("explicit image")
+
+		processor := newProcessor(t, api, &keywordModerator{})
+		post := &model.Post{Id: "post123", UserId: "user456", ChannelId: "channel123", FileIds: []string{"file1"}}
+
+		processor.queuePost(api, post)
+		assert.True(t, processor.processPost(api, <-processor.postsCh))
+		api.AssertNotCalled(t, "GetFile", mock.Anything)
+		api.AssertNotCalled(t, "DeletePost", mock.Anything)
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+// maxKVIndexUpdateAttempts is how often an update of an index is retried when it
+// was changed concurrently
+const maxKVIndexUpdateAttempts = 5
+
+// kvIndex keeps the IDs of items stored under their own keys in a single value,
+// so the items can be listed without going through every key of the plugin.
+// Updates use compare-and-set, so nodes of a cluster can change it at the same time.
+type kvIndex struct {
+	api plugin.API
+	key string
+}
+
+func newKVIndex(api plugin.API, key string) kvIndex {
+	return kvIndex{
+		api: api,
+		key: key,
+	}
+}
+
+// list returns the IDs in the order they were added
+func (i kvIndex) list() ([]string, error) {
+	ids, _, err := i.get()
+	return ids, err
+}
+
+func (i kvIndex) add(id string) error {
+	return i.update(func(ids []string) []string {
+		if slices.Contains(ids, id) {
+			return nil
+		}
+		return append(ids, id)
+	})
+}
+
+func (i kvIndex) remove(removed ...string) error {
+	return i.update(func(ids []string) []string {
+		remaining := make([]string, 0, len(ids))
+		for _, existing := range ids {
+			if !slices.Contains(removed, existing) {
+				remaining = append(remaining, existing)
+			}
+		}
+		if len(remaining) == len(ids) {
+			return nil
+		}
+		return remaining
+	})
+}
+
+// update applies update to the index, retrying if it was changed concurrently.
+// A nil result leaves the index unchanged.
+func (i kvIndex) update(update func(ids []string) []string) error {
+	for attempt := 0; attempt < maxKVIndexUpdateAttempts; attempt++ {
+		ids, oldData, err := i.get()
+		if err != nil {
+			return err
+		}
+
+		updated := update(ids)
+		if updated == nil {
+			return nil
+		}
+		data, err := json.Marshal(updated)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal index")
+		}
+
+		saved, appErr := i.api.KVCompareAndSet(i.key, oldData, data)
+		if appErr != nil {
+			return errors.Wrap(appErr, "failed to store index")
+		}
+		if saved {
+			return nil
+		}
+	}
+	return errors.Errorf("index %s was changed concurrently too often", i.key)
+}
+
+func (i kvIndex) get() ([]string, []byte, error) {
+	data, appErr := i.api.KVGet(i.key)
+	if appErr != nil {
+		return nil, nil, errors.Wrap(appErr, "failed to get index")
+	}
+	if data == nil {
+		return []string{}, nil, nil
+	}
+
+	var ids []string
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to unmarshal index")
+	}
+	return ids, data, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/mock"
+)
+
+// newKVTestAPI backs the KV calls of the stores with a map. The KV calls are
+// optional, so tests can assert the expectations they add themselves.
+func newKVTestAPI() *plugintest.API {
+	var lock sync.Mutex
+	kv := make(map[string][]byte)
+
+	api := &plugintest.API{}
+	api.On("KVGet", mock.Anything).Return(func(key string) ([]byte, *model.AppError) {
+		lock.Lock()
+		defer lock.Unlock()
+		return kv[key], nil
+	}).Maybe()
+	api.On("KVSet", mock.Anything, mock.Anything).Return(func(key string, value []byte) *model.AppError {
+		lock.Lock()
+		defer lock.Unlock()
+		kv[key] = value
+		return nil
+	}).Maybe()
+	api.On("KVSetWithExpiry", mock.Anything, mock.Anything, mock.Anything).Return(func(key string, value []byte, _ int64) *model.AppError {
+		lock.Lock()
+		defer lock.Unlock()
+		kv[key] = value
+		return nil
+	}).Maybe()
+	api.On("KVDelete", mock.Anything).Return(func(key string) *model.AppError {
+		lock.Lock()
+		defer lock.Unlock()
+		delete(kv, key)
+		return nil
+	}).Maybe()
+	api.On("KVCompareAndSet", mock.Anything, mock.Anything, mock.Anything).Return(func(key string, oldValue, newValue []byte) (bool, *model.AppError) {
+		lock.Lock()
+		defer lock.Unlock()
+		if !bytes.Equal(kv[key], oldValue) {
+			return false, nil
+		}
+		kv[key] = newValue
+		return true, nil
+	}).Maybe()
+	api.On("KVSetWithOptions", mock.Anything, mock.Anything, mock.Anything).Return(func(key string, value []byte, options model.PluginKVSetOptions) (bool, *model.AppError) {
+		lock.Lock()
+		defer lock.Unlock()
+		if options.Atomic && !bytes.Equal(kv[key], options.OldValue) {
+			return false, nil
+		}
+		if value == nil {
+			delete(kv, key)
+		} else {
+			kv[key] = value
+		}
+		return true, nil
+	}).Maybe()
+	return api
+}
@@ -2,10 +2,14 @@ package main
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation/retry"
 	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
 )
 
 // The current Azure rate limit is 1000 posts per minute.
@@ -16,54 +20,155 @@ const (
 	maxModerationProcessingQueueSize = 10000
 )
 
+// Failed moderation requests are retried up to moderationRetryAttempts times
+// within moderationAPITimeout.
+const (
+	moderationRetryAttempts  = 3
+	moderationRetryBaseDelay = 500 * time.Millisecond
+	moderationRetryMaxDelay  = 4 * time.Second
+)
+
+// Messages that still fail with a retryable error after the moderator's own
+// retries are queued again with a backoff, as long as the attempt starts within
+// moderationRequeueWindow of the first failure. The first and the last attempt
+// take at most moderationAPITimeout each, so moderation gives up after at most
+// 2*moderationAPITimeout + moderationRequeueWindow, i.e. half of waitForResultTimeout
+// plus moderationAPITimeout, which leaves time for the message to wait in the queue
+// before the post processor stops waiting for the result.
+const (
+	maxModerationRequeues   = 3
+	moderationRequeueDelay  = 5 * time.Second
+	moderationRequeueWindow = waitForResultTimeout/2 - moderationAPITimeout
+)
+
+// messageRequeues counts how often a failed message was queued again
+type messageRequeues struct {
+	count         int
+	firstFailedAt time.Time
+}
+
 type ModerationProcessor struct {
 	moderator              moderation.Moderator
 	thresholdValue         int
+	categoryThresholds     map[string]categoryThreshold
 	moderationResultsCache *moderationResultsCache
-	messagesCh             chan string
+	queue                  *moderationQueue
 	done                   chan struct{}
 	cleanupTicker          *time.Ticker
-	rateLimitPerMinute     int
-	processingInterval     time.Duration
+
+	// limiter is shared by all workers so the provider rate limit is respected
+	// regardless of how many requests are in flight
+	limiter            *rate.Limiter
+	rateLimitPerMinute int
+	rateLimitBurst     int
+	workers            int
+
+	// cluster shares the rate limit and moderation results with the other nodes
+	// of a high availability cluster
+	cluster *clusterCoordinator
+
+	// stats counts moderation activity for the daily statistics, and metrics
+	// exports it to Prometheus
+	stats   *statsCollector
+	metrics *metrics
+
+	// requeues tracks the failed attempts by the hash of the message
+	requeuesLock sync.Mutex
+	requeues     map[string]*messageRequeues
 }
 
 func newModerationProcessor(
 	moderationResultsCache *moderationResultsCache,
 	moderator moderation.Moderator,
 	thresholdValue int,
+	categoryThresholds map[string]categoryThreshold,
 	rateLimitPerMinute int,
+	rateLimitBurst int,
+	workers int,
+	cluster *clusterCoordinator,
+	stats *statsCollector,
+	metrics *metrics,
 ) (*ModerationProcessor, error) {
 	if moderator == nil {
 		return nil, ErrModerationUnavailable
 	}
-	processingInterval := time.Duration(float64(time.Minute) / float64(rateLimitPerMinute))
+	if rateLimitPerMinute <= 0 || rateLimitBurst <= 0 || workers <= 0 {
+		return nil, errors.New("rate limit, burst and workers must be positive")
+	}
 	return &ModerationProcessor{
 		moderator:              moderator,
 		thresholdValue:         thresholdValue,
+		categoryThresholds:     categoryThresholds,
 		moderationResultsCache: moderationResultsCache,
-		messagesCh:             make(chan string, maxModerationProcessingQueueSize),
+		queue:                  newModerationQueue(maxModerationProcessingQueueSize),
 		done:                   make(chan struct{}),
 		cleanupTicker:          time.NewTicker(5 * time.Minute),
+		limiter:                rate.NewLimiter(rate.Limit(float64(rateLimitPerMinute)/60), rateLimitBurst),
 		rateLimitPerMinute:     rateLimitPerMinute,
-		processingInterval:     processingInterval,
+		rateLimitBurst:         rateLimitBurst,
+		workers:                workers,
+		cluster:                cluster,
+		stats:                  stats,
+		metrics:                metrics,
+		requeues:               make(map[string]*messageRequeues),
 	}, nil
 }
 
 func (p *ModerationProcessor) start(api plugin.API) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cleanupTicker := p.cleanupTicker
+
+	p.updateRateLimit()
+	rateLimitTicker := time.NewTicker(clusterHeartbeatInterval)
+
 	go func() {
+		defer cancel()
+		defer rateLimitTicker.Stop()
 		for {
 			select {
-			case message := <-p.messagesCh:
-				p.moderateMessage(message)
-				time.Sleep(p.processingInterval)
-			case <-p.cleanupTicker.C:
+			case <-cleanupTicker.C:
 				p.moderationResultsCache.cleanup()
-				continue
+			case <-rateLimitTicker.C:
+				p.updateRateLimit()
 			case <-p.done:
 				return
 			}
 		}
 	}()
+
+	for i := 0; i < p.workers; i++ {
+		go p.runWorker(ctx, api)
+	}
+}
+
+// runWorker moderates queued messages until the processor is stopped, waiting
+// for the rate limiter before every message. Retries of the moderator wait for the
+// rate limiter themselves.
+func (p *ModerationProcessor) runWorker(ctx context.Context, api plugin.API) {
+	for {
+		queued, ok := p.queue.pop(ctx)
+		if !ok {
+			return
+		}
+		if err := p.limiter.Wait(ctx); err != nil {
+			// The processor was stopped while waiting
+			return
+		}
+		p.moderateMessage(api, queued.message, queued.priority)
+	}
+}
+
+// retryFailedRequests makes the moderator retry failed requests. Retries wait for
+// the rate limiter like the first attempt, so they count against the provider rate
+// limit. Must be called before the processor is started.
+func (p *ModerationProcessor) retryFailedRequests(settings retry.Settings) error {
+	settings.Limiter = p.limiter
+	moderator, err := retry.New(p.moderator, settings)
+	if err != nil {
+		return err
+	}
+	p.moderator = moderator
+	return nil
 }
 
 func (p *ModerationProcessor) stop() {
@@ -74,48 +179,198 @@ func (p *ModerationProcessor) stop() {
 	close(p.done)
 }
 
-func (p *ModerationProcessor) queueMessage(api plugin.API, message string) {
+func (p *ModerationProcessor) queueMessage(api plugin.API, message string, priority moderationPriority) {
 	if message == "" {
 		return
 	}
 
 	shouldQueue := p.moderationResultsCache.setResultPending(message)
+	p.metrics.recordCacheLookup(!shouldQueue)
 	if !shouldQueue {
+		// The message may still be waiting in the queue at a lower priority
+		p.queue.promote(message, priority)
 		return
 	}
 
-	select {
-	case p.messagesCh <- message:
-		return
-	default:
+	if !p.queue.push(message, priority) {
 		api.LogError("Content moderation unable to analyze post: exceeded maximum post queue size")
-		return
 	}
+	p.stats.observeQueueDepth(p.queue.len())
+}
+
+// prioritizeMessage moves a message that is still queued to a higher priority
+func (p *ModerationProcessor) prioritizeMessage(message string, priority moderationPriority) {
+	p.queue.promote(message, priority)
 }
 
-func (p *ModerationProcessor) moderateMessage(message string) {
+func (p *ModerationProcessor) moderateMessage(api plugin.API, message string, priority moderationPriority) {
 	ctx, cancel := context.WithTimeout(context.Background(), moderationAPITimeout)
 	defer cancel()
 
-	result, err := p.moderator.ModerateText(ctx, message)
+	if !p.lockMessage(api, message, priority) {
+		return
+	}
+
+	start := time.Now()
+	var result moderation.Result
+	var err error
+	if isImageKey(message) {
+		result, err = p.moderateImage(ctx, api, message)
+	} else {
+		result, err = p.moderator.ModerateText(ctx, message)
+	}
+	p.stats.observeLatency(time.Since(start))
 	if err != nil {
+		if p.requeueMessage(api, message, priority, err) {
+			return
+		}
 		p.moderationResultsCache.setModerationResultError(message, err)
 		return
 	}
+	if p.cluster != nil {
+		p.cluster.publishResult(message, result)
+	}
+	p.applyResult(message, result)
+}
+
+// applyResult stores the result of a message moderated on this node
+func (p *ModerationProcessor) applyResult(message string, result moderation.Result) {
+	p.applyHashedResult(messageHash(message), result)
+}
 
+// applyHashedResult stores the result of the message with the given hash, which
+// was moderated on this or another node
+func (p *ModerationProcessor) applyHashedResult(key string, result moderation.Result) {
+	p.clearRequeues(key)
+
+	code := moderationResultProcessed
 	if p.resultSeverityAboveThreshold(result) {
-		p.moderationResultsCache.setModerationResultFlagged(message, result)
-		return
+		code = moderationResultFlagged
 	}
-
-	p.moderationResultsCache.setModerationResultNotFlagged(message, result)
+	p.moderationResultsCache.setHashedResult(key, code, result)
 }
 
 func (p *ModerationProcessor) resultSeverityAboveThreshold(result moderation.Result) bool {
-	for _, severity := range result {
-		if severity >= p.thresholdValue {
-			return true
+	return severityExceedsThresholds(result, p.thresholdValue, p.categoryThresholds)
+}
+
+// requeueMessage schedules another moderation attempt for a message that failed
+// with a retryable error. The message stays pending in the results cache until then.
+// Returns false if the message should not be retried.
+func (p *ModerationProcessor) requeueMessage(api plugin.API, message string, priority moderationPriority, err error) bool {
+	kind := moderation.ClassifyError(err)
+	if kind == moderation.ErrorKindPermanent {
+		p.clearRequeues(messageHash(message))
+		return false
+	}
+
+	delay, attempt, ok := p.nextRequeue(message, err, time.Now())
+	if !ok {
+		return false
+	}
+
+	api.LogWarn("Content moderation failed, queueing message again",
+		"err", err, "error_kind", kind.String(), "attempt", attempt, "delay", delay.String())
+
+	time.AfterFunc(delay, func() {
+		select {
+		case <-p.done:
+			return
+		default:
 		}
+
+		if !p.queue.push(message, priority) {
+			p.clearRequeues(messageHash(message))
+			p.moderationResultsCache.setModerationResultError(message, errors.Wrap(err, "exceeded maximum post queue size"))
+		}
+	})
+	return true
+}
+
+// nextRequeue returns how long to wait before moderating a failed message again
+// and the number of the requeue. Returns false if the message was queued again
+// too often, or the attempt would not start within moderationRequeueWindow of
+// the first failure.
+func (p *ModerationProcessor) nextRequeue(message string, err error, now time.Time) (time.Duration, int, bool) {
+	p.requeuesLock.Lock()
+	defer p.requeuesLock.Unlock()
+
+	if p.requeues == nil {
+		p.requeues = make(map[string]*messageRequeues)
+	}
+	key := messageHash(message)
+	requeues, ok := p.requeues[key]
+	if !ok {
+		requeues = &messageRequeues{firstFailedAt: now}
+		p.requeues[key] = requeues
+	}
+
+	delay := moderation.RetryAfter(err)
+	if delay == 0 {
+		delay = moderationRequeueDelay << requeues.count
+	}
+	if requeues.count >= maxModerationRequeues || now.Add(delay).Sub(requeues.firstFailedAt) > moderationRequeueWindow {
+		delete(p.requeues, key)
+		return 0, 0, false
 	}
+
+	requeues.count++
+	return delay, requeues.count, true
+}
+
+// clearRequeues forgets the failures of the message with the given hash
+func (p *ModerationProcessor) clearRequeues(key string) {
+	p.requeuesLock.Lock()
+	defer p.requeuesLock.Unlock()
+	delete(p.requeues, key)
+}
+
+// lockMessage returns true if this node should moderate the message. When several
+// nodes are active, only the node that locks the message moderates it and shares
+// the result. The other nodes queue the message again in case the result never
+// arrives, for example because the node that locked it went away.
+func (p *ModerationProcessor) lockMessage(api plugin.API, message string, priority moderationPriority) bool {
+	if p.cluster == nil || p.cluster.activeNodes() <= 1 {
+		return true
+	}
+	if !p.moderationResultsCache.isPending(message) {
+		// The result was shared by another node while the message was queued
+		return false
+	}
+
+	locked, err := p.cluster.lockMessage(message)
+	if err != nil {
+		api.LogWarn("Failed to lock message for moderation, moderating it anyway", "err", err)
+		return true
+	}
+	if locked {
+		return true
+	}
+
+	time.AfterFunc(messageLockTTL, func() {
+		select {
+		case <-p.done:
+			return
+		default:
+		}
+		if p.moderationResultsCache.isPending(message) {
+			p.queue.push(message, priority)
+		}
+	})
 	return false
 }
+
+// updateRateLimit shares the configured rate limit between the active nodes of the cluster
+func (p *ModerationProcessor) updateRateLimit() {
+	nodes := 1
+	if p.cluster != nil {
+		nodes = p.cluster.activeNodes()
+	}
+
+	burst := p.rateLimitBurst / nodes
+	if burst < 1 {
+		burst = 1
+	}
+	p.limiter.SetLimit(rate.Limit(float64(p.rateLimitPerMinute) / 60 / float64(nodes)))
+	p.limiter.SetBurst(burst)
+}
@@ -1,10 +1,18 @@
 package main
 
 import (
+	"context"
+	"net/http"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation/retry"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestModerationProcessor_resultSeverityAboveThreshold(t *testing.T) {
@@ -131,3 +139,271 @@ func TestModerationProcessor_resultSeverityAboveThreshold(t *testing.T) {
 		assert.True(t, above)
 	})
 }
+
+func TestModerationProcessor_resultSeverityAboveCategoryThresholds(t *testing.T) {
+	t.Run("uses category threshold instead of default", func(t *testing.T) {
+		processor := &ModerationProcessor{
+			thresholdValue: 2,
+			categoryThresholds: map[string]categoryThreshold{
+				"violence": {value: 6},
+			},
+		}
+
+		result := moderation.Result{
+			"Hate":     0,
+			"Violence": 4,
+		}
+
+		above := processor.resultSeverityAboveThreshold(result)
+		assert.False(t, above)
+	})
+
+	t.Run("flags category meeting a stricter threshold", func(t *testing.T) {
+		processor := &ModerationProcessor{
+			thresholdValue: 6,
+			categoryThresholds: map[string]categoryThreshold{
+				"selfharm": {value: 2},
+			},
+		}
+
+		result := moderation.Result{
+			"Violence": 4,
+			"SelfHarm": 2,
+		}
+
+		above := processor.resultSeverityAboveThreshold(result)
+		assert.True(t, above)
+	})
+
+	t.Run("ignores disabled category", func(t *testing.T) {
+		processor := &ModerationProcessor{
+			thresholdValue: 2,
+			categoryThresholds: map[string]categoryThreshold{
+				"violence": {disabled: true},
+			},
+		}
+
+		result := moderation.Result{
+			"Hate":     0,
+			"Violence": 6,
+		}
+
+		above := processor.resultSeverityAboveThreshold(result)
+		assert.False(t, above)
+	})
+
+	t.Run("uses default threshold for categories without override", func(t *testing.T) {
+		processor := &ModerationProcessor{
+			thresholdValue: 4,
+			categoryThresholds: map[string]categoryThreshold{
+				"violence": {disabled: true},
+			},
+		}
+
+		result := moderation.Result{
+			"Hate":     4,
+			"Violence": 6,
+		}
+
+		above := processor.resultSeverityAboveThreshold(result)
+		assert.True(t, above)
+	})
+}
+
+func TestModerationProcessor_moderateMessage(t *testing.T) {
+	newProcessor := func(moderator moderation.Moderator) *ModerationProcessor {
+		processor, err := newModerationProcessor(newModerationResultsCache(), moderator, 4, nil, 500, 10, 1, nil, nil, nil)
+		assert.NoError(t, err)
+		processor.moderationResultsCache.setResultPending("message")
+		return processor
+	}
+
+	t.Run("requeues message after retryable error", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("LogWarn", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+		processor := newProcessor(&fakeModerator{err: &moderation.APIError{StatusCode: http.StatusTooManyRequests}})
+		defer processor.stop()
+
+		processor.moderateMessage(api, "message", moderationPriorityNormal)
+
+		assert.Equal(t, moderationResultPending, processor.moderationResultsCache.cache[messageHash("message")].code)
+		assert.Equal(t, 1, processor.requeues[messageHash("message")].count)
+	})
+
+	t.Run("stores error after last requeue", func(t *testing.T) {
+		api := &plugintest.API{}
+		processor := newProcessor(&fakeModerator{err: &moderation.APIError{StatusCode: http.StatusServiceUnavailable}})
+		defer processor.stop()
+		processor.requeues[messageHash("message")] = &messageRequeues{count: maxModerationRequeues, firstFailedAt: time.Now()}
+
+		processor.moderateMessage(api, "message", moderationPriorityNormal)
+
+		assert.Equal(t, moderationResultError, processor.moderationResultsCache.cache[messageHash("message")].code)
+		assert.NotContains(t, processor.requeues, messageHash("message"))
+	})
+
+	t.Run("stores permanent error without requeueing", func(t *testing.T) {
+		api := &plugintest.API{}
+		processor := newProcessor(&fakeModerator{err: errors.New("invalid request")})
+		defer processor.stop()
+
+		processor.moderateMessage(api, "message", moderationPriorityNormal)
+
+		assert.Equal(t, moderationResultError, processor.moderationResultsCache.cache[messageHash("message")].code)
+	})
+}
+
+func TestModerationProcessor_nextRequeue(t *testing.T) {
+	transient := &moderation.APIError{StatusCode: http.StatusServiceUnavailable}
+
+	t.Run("gives up before the post processor stops waiting in the worst case", func(t *testing.T) {
+		processor := &ModerationProcessor{}
+
+		// Every attempt takes the full API timeout and fails with a retryable error
+		elapsed := moderationAPITimeout
+		attempts := 1
+		for {
+			delay, _, ok := processor.nextRequeue("message", transient, time.Unix(0, 0).Add(elapsed))
+			if !ok {
+				break
+			}
+			elapsed += delay + moderationAPITimeout
+			attempts++
+		}
+
+		assert.Greater(t, attempts, 1, "the message is retried")
+		assert.LessOrEqual(t, elapsed, waitForResultTimeout/2+moderationAPITimeout)
+		assert.Less(t, elapsed, waitForResultTimeout)
+		assert.NotContains(t, processor.requeues, messageHash("message"))
+	})
+
+	t.Run("does not wait past the window for the rate limit to reset", func(t *testing.T) {
+		processor := &ModerationProcessor{}
+		rateLimited := &moderation.APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: waitForResultTimeout}
+
+		_, _, ok := processor.nextRequeue("message", rateLimited, time.Now())
+		assert.False(t, ok)
+	})
+}
+
+// blockingModerator blocks every request until it is released
+type blockingModerator struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (m *blockingModerator) ModerateText(_ context.Context, _ string) (moderation.Result, error) {
+	m.started <- struct{}{}
+	<-m.release
+	return moderation.Result{"Hate": 0}, nil
+}
+
+func TestModerationProcessor_workers(t *testing.T) {
+	t.Run("slow request does not block other workers", func(t *testing.T) {
+		moderator := &blockingModerator{started: make(chan struct{}, 2), release: make(chan struct{})}
+		processor, err := newModerationProcessor(newModerationResultsCache(), moderator, 4, nil, 6000, 2, 2, nil, nil, nil)
+		assert.NoError(t, err)
+		api := &plugintest.API{}
+		processor.start(api)
+		defer processor.stop()
+
+		processor.queueMessage(api, "first", moderationPriorityNormal)
+		processor.queueMessage(api, "second", moderationPriorityNormal)
+
+		for i := 0; i < 2; i++ {
+			select {
+			case <-moderator.started:
+			case <-time.After(time.Second):
+				t.Fatal("expected both messages to be moderated concurrently")
+			}
+		}
+		close(moderator.release)
+
+		result := processor.moderationResultsCache.waitForResult("second", time.Second)
+		if assert.NotNil(t, result) {
+			assert.Equal(t, moderationResultProcessed, result.code)
+		}
+	})
+
+	t.Run("rate limiter spaces requests after burst", func(t *testing.T) {
+		var lock sync.Mutex
+		var calls []time.Time
+		moderator := &recordingModerator{onCall: func() {
+			lock.Lock()
+			defer lock.Unlock()
+			calls = append(calls, time.Now())
+		}}
+		// 600 per minute is one request every 100ms after a burst of 1
+		processor, err := newModerationProcessor(newModerationResultsCache(), moderator, 4, nil, 600, 1, 4, nil, nil, nil)
+		assert.NoError(t, err)
+		api := &plugintest.API{}
+		processor.start(api)
+		defer processor.stop()
+
+		processor.queueMessage(api, "first", moderationPriorityNormal)
+		processor.queueMessage(api, "second", moderationPriorityNormal)
+		processor.moderationResultsCache.waitForResult("first", time.Second)
+		processor.moderationResultsCache.waitForResult("second", time.Second)
+
+		lock.Lock()
+		defer lock.Unlock()
+		if assert.Len(t, calls, 2) {
+			first, second := calls[0], calls[1]
+			if second.Before(first) {
+				first, second = second, first
+			}
+			assert.GreaterOrEqual(t, second.Sub(first), 80*time.Millisecond)
+		}
+	})
+
+	t.Run("retries wait for the rate limiter", func(t *testing.T) {
+		var lock sync.Mutex
+		var calls []time.Time
+		moderator := &recordingModerator{onCall: func() {
+			lock.Lock()
+			defer lock.Unlock()
+			calls = append(calls, time.Now())
+		}, errs: []error{&moderation.APIError{StatusCode: http.StatusServiceUnavailable}}}
+		// 600 per minute is one request every 100ms after a burst of 1
+		processor, err := newModerationProcessor(newModerationResultsCache(), moderator, 4, nil, 600, 1, 1, nil, nil, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, processor.retryFailedRequests(retry.Settings{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}))
+		api := &plugintest.API{}
+		processor.start(api)
+		defer processor.stop()
+
+		processor.queueMessage(api, "message", moderationPriorityNormal)
+		result := processor.moderationResultsCache.waitForResult("message", time.Second)
+		if assert.NotNil(t, result) {
+			assert.Equal(t, moderationResultProcessed, result.code)
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		if assert.Len(t, calls, 2) {
+			assert.GreaterOrEqual(t, calls[1].Sub(calls[0]), 80*time.Millisecond)
+		}
+	})
+
+	t.Run("rejects invalid settings", func(t *testing.T) {
+		_, err := newModerationProcessor(newModerationResultsCache(), &fakeModerator{}, 4, nil, 500, 10, 0, nil, nil, nil)
+		assert.Error(t, err)
+	})
+}
+
+// recordingModerator calls onCall for every request and fails with errs before succeeding
+type recordingModerator struct {
+	onCall func()
+	errs   []error
+}
+
+func (m *recordingModerator) ModerateText(_ context.Context, _ string) (moderation.Result, error) {
+	m.onCall()
+	if len(m.errs) > 0 {
+		err := m.errs[0]
+		m.errs = m.errs[1:]
+		return nil, err
+	}
+	return moderation.Result{"Hate": 0}, nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "content_moderation"
+
+// metrics exports moderation activity of this node as Prometheus metrics. It has
+// its own registry so the metrics survive the plugin being reconfigured without
+// being registered twice. All methods can be called on nil metrics.
+type metrics struct {
+	registry *prometheus.Registry
+
+	providerLatency *prometheus.HistogramVec
+	providerErrors  *prometheus.CounterVec
+	postsChecked    prometheus.Counter
+	postFailures    *prometheus.CounterVec
+	flagged         *prometheus.CounterVec
+	cacheHits       prometheus.Counter
+	cacheMisses     prometheus.Counter
+}
+
+// newMetrics creates the metrics. The queue length functions are called on every
+// scrape and return 0 while moderation is disabled.
+func newMetrics(moderationQueueLength, postQueueLength func() int) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		providerLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "provider_request_duration_seconds",
+			Help:      "Duration of requests to the moderation provider.",
+			Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 4, 8, 15},
+		}, []string{"backend"}),
+		providerErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "provider_errors_total",
+			Help:      "Failed requests to the moderation provider, by whether they can be retried.",
+		}, []string{"backend", "type"}),
+		postsChecked: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "posts_checked_total",
+			Help:      "Posts with a moderation result.",
+		}),
+		postFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "post_failures_total",
+			Help:      "Posts that could not be moderated, because no result arrived in time or moderation failed.",
+		}, []string{"type"}),
+		flagged: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "flagged_total",
+			Help:      "Flagged posts, by the categories that reached their threshold.",
+		}, []string{"category"}),
+		cacheHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "results_cache_hits_total",
+			Help:      "Messages whose moderation result was already cached or pending.",
+		}),
+		cacheMisses: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "results_cache_misses_total",
+			Help:      "Messages that had to be sent to the moderation provider.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.providerLatency,
+		m.providerErrors,
+		m.postsChecked,
+		m.postFailures,
+		m.flagged,
+		m.cacheHits,
+		m.cacheMisses,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "moderation_queue_length",
+			Help:      "Messages waiting to be sent to the moderation provider.",
+		}, func() float64 {
+			return float64(moderationQueueLength())
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "post_queue_length",
+			Help:      "Posts waiting for their moderation result.",
+		}, func() float64 {
+			return float64(postQueueLength())
+		}),
+	)
+	return m
+}
+
+func (m *metrics) observeProviderRequest(backend string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.providerLatency.WithLabelValues(backend).Observe(duration.Seconds())
+	if err != nil {
+		kind := strings.ReplaceAll(moderation.ClassifyError(err).String(), " ", "_")
+		m.providerErrors.WithLabelValues(backend, kind).Inc()
+	}
+}
+
+func (m *metrics) recordChecked() {
+	if m == nil {
+		return
+	}
+	m.postsChecked.Inc()
+}
+
+func (m *metrics) recordFlagged(categories []string) {
+	if m == nil {
+		return
+	}
+	for _, category := range categories {
+		m.flagged.WithLabelValues(category).Inc()
+	}
+}
+
+func (m *metrics) recordTimeout() {
+	if m == nil {
+		return
+	}
+	m.postFailures.WithLabelValues("timeout").Inc()
+}
+
+func (m *metrics) recordError() {
+	if m == nil {
+		return
+	}
+	m.postFailures.WithLabelValues("error").Inc()
+}
+
+func (m *metrics) recordCacheLookup(hit bool) {
+	if m == nil {
+		return
+	}
+	if hit {
+		m.cacheHits.Inc()
+		return
+	}
+	m.cacheMisses.Inc()
+}
+
+// instrumentedModerator measures the requests of a moderation provider
+type instrumentedModerator struct {
+	moderator moderation.Moderator
+	backend   string
+	metrics   *metrics
+}
+
+// instrumentModerator wraps moderator so its requests are measured, labeled with backend
+func instrumentModerator(moderator moderation.Moderator, backend string, m *metrics) moderation.Moderator {
+	if m == nil {
+		return moderator
+	}
+	return &instrumentedModerator{
+		moderator: moderator,
+		backend:   backend,
+		metrics:   m,
+	}
+}
+
+func (im *instrumentedModerator) ModerateText(ctx context.Context, text string) (moderation.Result, error) {
+	start := time.Now()
+	result, err := im.moderator.ModerateText(ctx, text)
+	im.metrics.observeProviderRequest(im.backend, time.Since(start), err)
+	return result, err
+}
+
+func (im *instrumentedModerator) ModerateImage(ctx context.Context, image []byte) (moderation.Result, error) {
+	images, ok := im.moderator.(moderation.ImageModerator)
+	if !ok {
+		return nil, moderation.ErrImagesNotSupported
+	}
+
+	start := time.Now()
+	result, err := images.ModerateImage(ctx, image)
+	im.metrics.observeProviderRequest(im.backend, time.Since(start), err)
+	return result, err
+}
+
+// SupportsImages returns true if the measured moderator can check images
+func (im *instrumentedModerator) SupportsImages() bool {
+	return moderation.SupportsImages(im.moderator)
+}
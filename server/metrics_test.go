@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentModerator(t *testing.T) {
+	m := newMetrics(func() int { return 0 }, func() int { return 0 })
+
+	mod := instrumentModerator(&fakeModerator{result: moderation.Result{"Hate": 0}}, "azure", m)
+	_, err := mod.ModerateText(context.Background(), "message")
+	require.NoError(t, err)
+
+	failing := instrumentModerator(&fakeModerator{err: &moderation.APIError{StatusCode: http.StatusTooManyRequests}}, "openai", m)
+	_, err = failing.ModerateText(context.Background(), "message")
+	require.Error(t, err)
+
+	unwrapped := &fakeModerator{}
+	assert.Same(t, unwrapped, instrumentModerator(unwrapped, "azure", nil), "moderators are not wrapped without metrics")
+
+	body := scrapeMetrics(t, m)
+	assert.Contains(t, body, `content_moderation_provider_request_duration_seconds_count{backend="azure"} 1`)
+	assert.Contains(t, body, `content_moderation_provider_request_duration_seconds_count{backend="openai"} 1`)
+	assert.Contains(t, body, `content_moderation_provider_errors_total{backend="openai",type="rate_limited"} 1`)
+}
+
+func TestMetrics(t *testing.T) {
+	m := newMetrics(func() int { return 3 }, func() int { return 5 })
+
+	m.recordChecked()
+	m.recordFlagged([]string{"Hate", "Violence"})
+	m.recordTimeout()
+	m.recordError()
+	m.recordCacheLookup(true)
+	m.recordCacheLookup(false)
+	m.recordCacheLookup(false)
+
+	body := scrapeMetrics(t, m)
+	assert.Contains(t, body, "content_moderation_moderation_queue_length 3")
+	assert.Contains(t, body, "content_moderation_post_queue_length 5")
+	assert.Contains(t, body, "content_moderation_posts_checked_total 1")
+	assert.Contains(t, body, `content_moderation_flagged_total{category="Hate"} 1`)
+	assert.Contains(t, body, `content_moderation_post_failures_total{type="timeout"} 1`)
+	assert.Contains(t, body, `content_moderation_post_failures_total{type="error"} 1`)
+	assert.Contains(t, body, "content_moderation_results_cache_hits_total 1")
+	assert.Contains(t, body, "content_moderation_results_cache_misses_total 2")
+
+	// Nil metrics record nothing
+	var disabled *metrics
+	disabled.recordChecked()
+	disabled.recordCacheLookup(true)
+}
+
+func TestPlugin_requireMetricsAccess(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("HasPermissionTo", "admin", model.PermissionManageSystem).Return(true)
+	api.On("HasPermissionTo", "user", model.PermissionManageSystem).Return(false)
+
+	p := &Plugin{configuration: &configuration{MetricsToken: "secret"}}
+	p.SetAPI(api)
+	p.metrics = newMetrics(p.moderationQueueLength, p.postQueueLength)
+
+	tests := []struct {
+		name          string
+		userID        string
+		authorization string
+		status        int
+	}{
+		{name: "metrics token", authorization: "Bearer secret", status: http.StatusOK},
+		{name: "wrong token", authorization: "Bearer wrong", status: http.StatusUnauthorized},
+		{name: "no credentials", status: http.StatusUnauthorized},
+		{name: "system admin", userID: "admin", status: http.StatusOK},
+		{name: "other user", userID: "user", status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.userID != "" {
+				r.Header.Set("Mattermost-User-ID", tt.userID)
+			}
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			p.ServeHTTP(&plugin.Context{}, w, r)
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func scrapeMetrics(t *testing.T, m *metrics) string {
+	t.Helper()
+
+	p := &Plugin{metrics: m}
+	w := httptest.NewRecorder()
+	p.handleGetMetrics(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	return w.Body.String()
+}
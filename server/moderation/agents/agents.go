@@ -22,6 +22,10 @@ const (
 
 var _ moderation.Moderator = (*Moderator)(nil)
 
+// Moderator asks an agent of the Agents plugin to moderate text. It does not
+// implement moderation.ImageModerator, as the inter-plugin completion API of the
+// Agents plugin only accepts text prompts, so vision models can't be given the
+// image. Image moderation needs another provider until the API accepts images.
 type Moderator struct {
 	client           *interpluginclient.Client
 	systemPrompt     string
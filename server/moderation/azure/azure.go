@@ -3,6 +3,7 @@ package azure
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -15,6 +16,12 @@ const (
 	// ContentSafetyTextAnalyzeEndpoint is the Azure AI Content Safety text analyze API path
 	ContentSafetyTextAnalyzeEndpoint = "/contentsafety/text:analyze?api-version=2024-09-01"
 
+	// ContentSafetyImageAnalyzeEndpoint is the Azure AI Content Safety image analyze API path
+	ContentSafetyImageAnalyzeEndpoint = "/contentsafety/image:analyze?api-version=2024-09-01"
+
+	// MaxImageSize is the largest image the image analyze API accepts
+	MaxImageSize = 4 * 1024 * 1024
+
 	// DefaultOutputType is used to determine the result format provided by the API
 	DefaultOutputType = "FourSeverityLevels"
 )
@@ -27,10 +34,13 @@ const (
 	CategorySelfHarm = "SelfHarm"
 )
 
-// Ensure Moderator implements the moderation.Moderator interface
-var _ moderation.Moderator = (*Moderator)(nil)
+// Ensure Moderator implements the moderation.Moderator and moderation.ImageModerator interfaces
+var (
+	_ moderation.Moderator      = (*Moderator)(nil)
+	_ moderation.ImageModerator = (*Moderator)(nil)
+)
 
-// Moderator implements Azure AI Content Safety for text moderation
+// Moderator implements Azure AI Content Safety for text and image moderation
 type Moderator struct {
 	// client is the HTTP client for API requests
 	client *http.Client
@@ -46,6 +56,18 @@ type TextAnalyzeRequest struct {
 	OutputType string   `json:"outputType,omitempty"`
 }
 
+// ImageAnalyzeRequest represents the request structure for Azure Content Safety image analysis
+type ImageAnalyzeRequest struct {
+	Image      ImageData `json:"image"`
+	Categories []string  `json:"categories,omitempty"`
+	OutputType string    `json:"outputType,omitempty"`
+}
+
+// ImageData holds the base64 encoded image to analyze
+type ImageData struct {
+	Content string `json:"content"`
+}
+
 // AnalyzeResponse represents the response from Azure Content Safety API
 type AnalyzeResponse struct {
 	CategoriesAnalysis []struct {
@@ -87,6 +109,26 @@ func (m *Moderator) ModerateText(ctx context.Context, text string) (moderation.R
 	return result, nil
 }
 
+// ModerateImage analyzes an image using Azure AI Content Safety API. Images may be
+// JPEG, PNG, GIF, BMP, TIFF or WEBP of at most MaxImageSize bytes.
+func (m *Moderator) ModerateImage(ctx context.Context, image []byte) (moderation.Result, error) {
+	if len(image) > MaxImageSize {
+		return nil, errors.Errorf("image of %d bytes exceeds the maximum size of %d bytes", len(image), MaxImageSize)
+	}
+
+	req, err := makeModerateImageRequest(ctx, m.config.Endpoint, image)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create moderation request")
+	}
+
+	result, err := sendRequest(m.client, m.config.APIKey, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to moderate image content")
+	}
+
+	return result, nil
+}
+
 func makeModerateTextRequest(ctx context.Context, apiEndpoint string, text string) (*http.Request, error) {
 	// Create the request body
 	reqBody := TextAnalyzeRequest{
@@ -110,6 +152,27 @@ func makeModerateTextRequest(ctx context.Context, apiEndpoint string, text strin
 	return req, nil
 }
 
+func makeModerateImageRequest(ctx context.Context, apiEndpoint string, image []byte) (*http.Request, error) {
+	reqBody := ImageAnalyzeRequest{
+		Image:      ImageData{Content: base64.StdEncoding.EncodeToString(image)},
+		Categories: []string{CategoryHate, CategorySexual, CategoryViolence, CategorySelfHarm},
+		OutputType: DefaultOutputType,
+	}
+
+	jsonBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling request")
+	}
+
+	endpoint := apiEndpoint + ContentSafetyImageAnalyzeEndpoint
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating request")
+	}
+
+	return req, nil
+}
+
 // addRequestHeaders adds the required headers to the request
 func addRequestHeaders(req *http.Request, apiKey string) {
 	req.Header.Set("Content-Type", "application/json")
@@ -152,7 +215,7 @@ func sendRequest(client *http.Client, apiKey string, req *http.Request) (moderat
 		if e != nil {
 			return nil, errors.Wrapf(e, "failed to read error response body (status code: %d)", resp.StatusCode)
 		}
-		return nil, errors.Errorf("Azure API returned status %d: %s", resp.StatusCode, string(body))
+		return nil, moderation.NewAPIError("Azure", resp, body)
 	}
 
 	// Parse the response
@@ -0,0 +1,144 @@
+package blocklist
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/pkg/errors"
+)
+
+const (
+	// MinSeverity and MaxSeverity bound the severity of an entry
+	MinSeverity = 0
+	MaxSeverity = 6
+)
+
+// Entry is an admin managed term or regular expression
+type Entry struct {
+	ID string `json:"id"`
+
+	// Pattern is the term or regular expression to look for
+	Pattern string `json:"pattern"`
+
+	// Regex treats Pattern as a regular expression instead of a literal term
+	Regex bool `json:"regex"`
+
+	// WholeWord only matches the pattern when it is not part of a larger word
+	WholeWord bool `json:"whole_word"`
+
+	// CaseSensitive disables case insensitive matching
+	CaseSensitive bool `json:"case_sensitive"`
+
+	// Category is the moderation category reported when the entry matches
+	Category string `json:"category"`
+
+	// Severity is the severity reported for the category when the entry matches
+	Severity int `json:"severity"`
+}
+
+// Validate checks that the entry is complete and its pattern compiles
+func (e *Entry) Validate() error {
+	if strings.TrimSpace(e.Pattern) == "" {
+		return errors.New("pattern is required")
+	}
+	if strings.TrimSpace(e.Category) == "" {
+		return errors.New("category is required")
+	}
+	if e.Severity < MinSeverity || e.Severity > MaxSeverity {
+		return errors.Errorf("severity must be between %d and %d", MinSeverity, MaxSeverity)
+	}
+	if _, err := e.compile(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// compile builds the regular expression used to match the entry
+func (e *Entry) compile() (*regexp.Regexp, error) {
+	pattern := e.Pattern
+	if !e.Regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if e.WholeWord {
+		pattern = `\b(?:` + pattern + `)\b`
+	}
+	if !e.CaseSensitive {
+		pattern = `(?i)` + pattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid pattern '%s'", e.Pattern)
+	}
+	return re, nil
+}
+
+type rule struct {
+	re       *regexp.Regexp
+	category string
+	severity int
+}
+
+// Ensure Moderator implements the moderation.Moderator interface
+var _ moderation.Moderator = (*Moderator)(nil)
+
+// Moderator matches text against a blocklist without calling any external service
+type Moderator struct {
+	rulesLock sync.RWMutex
+	rules     []rule
+}
+
+// New creates a new blocklist moderator with the given entries
+func New(entries []Entry) (*Moderator, error) {
+	m := &Moderator{}
+	if err := m.SetEntries(entries); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SetEntries replaces the entries used for moderation. The current entries are
+// kept if any of the new entries is invalid.
+func (m *Moderator) SetEntries(entries []Entry) error {
+	rules := make([]rule, 0, len(entries))
+	for i := range entries {
+		if err := entries[i].Validate(); err != nil {
+			return errors.Wrapf(err, "invalid blocklist entry %s", entries[i].ID)
+		}
+		re, err := entries[i].compile()
+		if err != nil {
+			return err
+		}
+		rules = append(rules, rule{
+			re:       re,
+			category: entries[i].Category,
+			severity: entries[i].Severity,
+		})
+	}
+
+	m.rulesLock.Lock()
+	defer m.rulesLock.Unlock()
+	m.rules = rules
+	return nil
+}
+
+// ModerateText reports, for every category of the blocklist, the highest
+// severity of the entries that match the text
+func (m *Moderator) ModerateText(_ context.Context, text string) (moderation.Result, error) {
+	m.rulesLock.RLock()
+	defer m.rulesLock.RUnlock()
+
+	result := make(moderation.Result)
+	for _, r := range m.rules {
+		if _, ok := result[r.category]; !ok {
+			result[r.category] = 0
+		}
+		if r.severity > result[r.category] && r.re.MatchString(text) {
+			result[r.category] = r.severity
+		}
+	}
+	return result, nil
+}
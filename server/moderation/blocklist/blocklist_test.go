@@ -0,0 +1,76 @@
+package blocklist
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModerator_regexEntries(t *testing.T) {
+	moderator, err := New([]Entry{
+		{ID: "1", Pattern: `k+i+l+l+ y+o+u`, Regex: true, Category: "Violence", Severity: 4},
+		{ID: "2", Pattern: "Slur", CaseSensitive: true, Category: "Hate", Severity: 6},
+		{ID: "3", Pattern: "murder", Category: "Violence", Severity: 6},
+	})
+	require.NoError(t, err)
+
+	result, err := moderator.ModerateText(context.Background(), "i will kiiill you, slur")
+	require.NoError(t, err)
+	assert.Equal(t, 4, result["Violence"])
+	assert.Equal(t, 0, result["Hate"], "case sensitive entries must match the case")
+
+	result, err = moderator.ModerateText(context.Background(), "murder, Slur")
+	require.NoError(t, err)
+	assert.Equal(t, 6, result["Violence"])
+	assert.Equal(t, 6, result["Hate"])
+}
+
+func TestEntry_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		entry     Entry
+		wantError bool
+	}{
+		{
+			name:  "valid term",
+			entry: Entry{Pattern: "term", Category: "Hate", Severity: 4},
+		},
+		{
+			name:      "missing pattern",
+			entry:     Entry{Category: "Hate", Severity: 4},
+			wantError: true,
+		},
+		{
+			name:      "missing category",
+			entry:     Entry{Pattern: "term", Severity: 4},
+			wantError: true,
+		},
+		{
+			name:      "severity out of range",
+			entry:     Entry{Pattern: "term", Category: "Hate", Severity: 7},
+			wantError: true,
+		},
+		{
+			name:      "invalid regex",
+			entry:     Entry{Pattern: "(unclosed", Regex: true, Category: "Hate", Severity: 4},
+			wantError: true,
+		},
+		{
+			name:  "regex characters in term",
+			entry: Entry{Pattern: "(unclosed", Category: "Hate", Severity: 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.entry.Validate()
+			if tt.wantError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package composite
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/pkg/errors"
+)
+
+// Strategy determines how the stages of a composite moderator are consulted
+type Strategy string
+
+const (
+	// StrategyFirstFlag runs the stages in order and stops at the first stage that flags the text
+	StrategyFirstFlag Strategy = "first_flag"
+
+	// StrategyMaxSeverity runs every stage and reports the highest severity of each category
+	StrategyMaxSeverity Strategy = "max_severity"
+
+	// StrategyEscalate runs the stages in order and only asks the next stage for a second
+	// opinion when the highest severity is within the escalation margin below the threshold.
+	// The result of the last stage consulted is reported, unless an earlier stage flagged the text.
+	StrategyEscalate Strategy = "escalate"
+)
+
+// IsValidStrategy returns true if the strategy is supported
+func IsValidStrategy(strategy Strategy) bool {
+	switch strategy {
+	case StrategyFirstFlag, StrategyMaxSeverity, StrategyEscalate:
+		return true
+	default:
+		return false
+	}
+}
+
+// Stage is a named moderator consulted by the composite moderator
+type Stage struct {
+	Name      string
+	Moderator moderation.Moderator
+}
+
+// Disabled is the threshold of a category that is never flagged
+const Disabled = math.MaxInt
+
+// Range spans the thresholds that apply to a category. Teams and channels can have
+// their own thresholds, so a category can be flagged at a different severity in
+// each of them.
+type Range struct {
+	Lowest  int
+	Highest int
+}
+
+// Thresholds are the severities at which the categories of a result are flagged.
+// A stage is only relied on to have flagged text if the text is flagged at the
+// highest threshold of a category, and a second opinion is asked for when a
+// severity is close to the lowest one.
+type Thresholds struct {
+	// Default applies to the categories without a range of their own
+	Default Range
+
+	// Categories holds the ranges of individual categories, keyed by lowercase name
+	Categories map[string]Range
+}
+
+// rangeFor returns the thresholds of a category
+func (t Thresholds) rangeFor(category string) Range {
+	if r, ok := t.Categories[strings.ToLower(category)]; ok {
+		return r
+	}
+	return t.Default
+}
+
+// flagged returns true if a category of the result is flagged at every threshold that applies to it
+func (t Thresholds) flagged(result moderation.Result) bool {
+	for category, severity := range result {
+		if highest := t.rangeFor(category).Highest; highest != Disabled && severity >= highest {
+			return true
+		}
+	}
+	return false
+}
+
+// closeCall returns true if a category of the result is within the margin below,
+// or above, the lowest threshold that applies to it
+func (t Thresholds) closeCall(result moderation.Result, margin int) bool {
+	for category, severity := range result {
+		if lowest := t.rangeFor(category).Lowest; lowest != Disabled && severity >= lowest-margin {
+			return true
+		}
+	}
+	return false
+}
+
+// Ensure Moderator implements the moderation.Moderator and moderation.ImageModerator interfaces
+var (
+	_ moderation.Moderator      = (*Moderator)(nil)
+	_ moderation.ImageModerator = (*Moderator)(nil)
+)
+
+// Moderator chains several moderators
+type Moderator struct {
+	stages   []Stage
+	strategy Strategy
+
+	// thresholds returns the thresholds in effect, which change with the configuration
+	// and with the team and channel policies
+	thresholds func() Thresholds
+
+	// margin is how far below the threshold a severity has to be to need a second opinion
+	margin int
+}
+
+// New creates a composite moderator that consults the stages in order
+func New(stages []Stage, strategy Strategy, thresholds func() Thresholds, margin int) (*Moderator, error) {
+	if len(stages) == 0 {
+		return nil, errors.New("at least one moderator is required")
+	}
+	if !IsValidStrategy(strategy) {
+		return nil, errors.Errorf("unknown strategy: %s", strategy)
+	}
+	if thresholds == nil {
+		return nil, errors.New("thresholds are required")
+	}
+	if margin < 0 {
+		return nil, errors.New("escalation margin must not be negative")
+	}
+
+	return &Moderator{
+		stages:     stages,
+		strategy:   strategy,
+		thresholds: thresholds,
+		margin:     margin,
+	}, nil
+}
+
+// ModerateText consults the stages according to the strategy. A stage that fails
+// is skipped, and moderation only fails if no stage could be consulted.
+func (m *Moderator) ModerateText(ctx context.Context, text string) (moderation.Result, error) {
+	return m.moderate(m.stages, func(stage Stage) (moderation.Result, error) {
+		return stage.Moderator.ModerateText(ctx, text)
+	})
+}
+
+// ModerateImage consults the stages that can check images according to the
+// strategy. Stages that only check text are skipped.
+func (m *Moderator) ModerateImage(ctx context.Context, image []byte) (moderation.Result, error) {
+	stages := m.imageStages()
+	if len(stages) == 0 {
+		return nil, moderation.ErrImagesNotSupported
+	}
+	return m.moderate(stages, func(stage Stage) (moderation.Result, error) {
+		return stage.Moderator.(moderation.ImageModerator).ModerateImage(ctx, image)
+	})
+}
+
+// SupportsImages returns true if any stage can check images
+func (m *Moderator) SupportsImages() bool {
+	return len(m.imageStages()) > 0
+}
+
+func (m *Moderator) imageStages() []Stage {
+	var stages []Stage
+	for _, stage := range m.stages {
+		if moderation.SupportsImages(stage.Moderator) {
+			stages = append(stages, stage)
+		}
+	}
+	return stages
+}
+
+func (m *Moderator) moderate(stages []Stage, request func(stage Stage) (moderation.Result, error)) (moderation.Result, error) {
+	thresholds := m.thresholds()
+	var merged moderation.Result
+	var stageErr error
+
+	for _, stage := range stages {
+		result, err := request(stage)
+		if err != nil {
+			// Later stages may still flag the text, and an escalated result stands
+			// without a second opinion
+			if stageErr == nil {
+				stageErr = errors.Wrapf(err, "moderator %s failed", stage.Name)
+			}
+			continue
+		}
+
+		switch m.strategy {
+		case StrategyFirstFlag:
+			merged = mergeMaxSeverity(merged, result)
+			if thresholds.flagged(result) {
+				return merged, nil
+			}
+		case StrategyMaxSeverity:
+			merged = mergeMaxSeverity(merged, result)
+		case StrategyEscalate:
+			if thresholds.flagged(result) {
+				return result, nil
+			}
+			merged = result
+			if !thresholds.closeCall(result, m.margin) {
+				return merged, nil
+			}
+		}
+	}
+
+	if merged == nil {
+		return nil, stageErr
+	}
+	return merged, nil
+}
+
+// mergeMaxSeverity keeps the highest severity of every category in merged
+func mergeMaxSeverity(merged, result moderation.Result) moderation.Result {
+	if merged == nil {
+		merged = make(moderation.Result, len(result))
+	}
+	for category, severity := range result {
+		if current, ok := merged[category]; !ok || severity > current {
+			merged[category] = severity
+		}
+	}
+	return merged
+}
@@ -0,0 +1,235 @@
+package composite
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubModerator returns a fixed result or error and counts its calls
+type stubModerator struct {
+	result moderation.Result
+	err    error
+	calls  int
+}
+
+func (m *stubModerator) ModerateText(_ context.Context, _ string) (moderation.Result, error) {
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.result, nil
+}
+
+// uniform flags every category at the same severity
+func uniform(threshold int) func() Thresholds {
+	return func() Thresholds {
+		return Thresholds{Default: Range{Lowest: threshold, Highest: threshold}}
+	}
+}
+
+func TestModerator_strategies(t *testing.T) {
+	newStages := func(first, second moderation.Result) (*stubModerator, *stubModerator, []Stage) {
+		a := &stubModerator{result: first}
+		b := &stubModerator{result: second}
+		return a, b, []Stage{{Name: "a", Moderator: a}, {Name: "b", Moderator: b}}
+	}
+
+	t.Run("first flag stops at flagging stage", func(t *testing.T) {
+		_, b, stages := newStages(moderation.Result{"Hate": 4}, moderation.Result{"Hate": 6})
+		mod, err := New(stages, StrategyFirstFlag, uniform(4), 0)
+		require.NoError(t, err)
+
+		result, err := mod.ModerateText(context.Background(), "text")
+		require.NoError(t, err)
+		assert.Equal(t, moderation.Result{"Hate": 4}, result)
+		assert.Equal(t, 0, b.calls)
+	})
+
+	t.Run("max severity merges every stage", func(t *testing.T) {
+		_, b, stages := newStages(moderation.Result{"Hate": 6, "Sexual": 0}, moderation.Result{"Hate": 2, "Sexual": 4})
+		mod, err := New(stages, StrategyMaxSeverity, uniform(2), 0)
+		require.NoError(t, err)
+
+		result, err := mod.ModerateText(context.Background(), "text")
+		require.NoError(t, err)
+		assert.Equal(t, moderation.Result{"Hate": 6, "Sexual": 4}, result)
+		assert.Equal(t, 1, b.calls)
+	})
+
+	t.Run("escalate asks for second opinion on close call", func(t *testing.T) {
+		_, b, stages := newStages(moderation.Result{"Hate": 3}, moderation.Result{"Hate": 0})
+		mod, err := New(stages, StrategyEscalate, uniform(4), 1)
+		require.NoError(t, err)
+
+		result, err := mod.ModerateText(context.Background(), "text")
+		require.NoError(t, err)
+		assert.Equal(t, moderation.Result{"Hate": 0}, result)
+		assert.Equal(t, 1, b.calls)
+	})
+
+	t.Run("escalate accepts clearly safe result", func(t *testing.T) {
+		_, b, stages := newStages(moderation.Result{"Hate": 2}, moderation.Result{"Hate": 6})
+		mod, err := New(stages, StrategyEscalate, uniform(4), 1)
+		require.NoError(t, err)
+
+		result, err := mod.ModerateText(context.Background(), "text")
+		require.NoError(t, err)
+		assert.Equal(t, moderation.Result{"Hate": 2}, result)
+		assert.Equal(t, 0, b.calls)
+	})
+
+	t.Run("rejects unknown strategy", func(t *testing.T) {
+		_, _, stages := newStages(nil, nil)
+		_, err := New(stages, "unknown", uniform(4), 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects missing thresholds", func(t *testing.T) {
+		_, _, stages := newStages(nil, nil)
+		_, err := New(stages, StrategyFirstFlag, nil, 0)
+		assert.Error(t, err)
+	})
+}
+
+func TestModerator_thresholds(t *testing.T) {
+	thresholds := func() Thresholds {
+		return Thresholds{
+			Default: Range{Lowest: 4, Highest: 4},
+			Categories: map[string]Range{
+				"hate":     {Lowest: 2, Highest: 5},
+				"selfharm": {Lowest: Disabled, Highest: Disabled},
+				"sexual":   {Lowest: 3, Highest: Disabled},
+			},
+		}
+	}
+
+	tests := []struct {
+		name           string
+		strategy       Strategy
+		first          moderation.Result
+		second         moderation.Result
+		expectedResult moderation.Result
+		expectedCalls  int
+	}{
+		{
+			name:           "first flag stops at the highest category threshold",
+			strategy:       StrategyFirstFlag,
+			first:          moderation.Result{"Hate": 5},
+			second:         moderation.Result{"Hate": 6},
+			expectedResult: moderation.Result{"Hate": 5},
+		},
+		{
+			name:           "first flag continues below the highest category threshold",
+			strategy:       StrategyFirstFlag,
+			first:          moderation.Result{"Hate": 4},
+			second:         moderation.Result{"Hate": 6},
+			expectedResult: moderation.Result{"Hate": 6},
+			expectedCalls:  1,
+		},
+		{
+			name:           "first flag ignores disabled categories",
+			strategy:       StrategyFirstFlag,
+			first:          moderation.Result{"SelfHarm": 6},
+			second:         moderation.Result{"Violence": 4},
+			expectedResult: moderation.Result{"SelfHarm": 6, "Violence": 4},
+			expectedCalls:  1,
+		},
+		{
+			name:           "first flag continues for categories disabled by a policy",
+			strategy:       StrategyFirstFlag,
+			first:          moderation.Result{"Sexual": 6},
+			second:         moderation.Result{"Sexual": 0},
+			expectedResult: moderation.Result{"Sexual": 6},
+			expectedCalls:  1,
+		},
+		{
+			name:           "escalate asks for a second opinion near the lowest category threshold",
+			strategy:       StrategyEscalate,
+			first:          moderation.Result{"Hate": 1},
+			second:         moderation.Result{"Hate": 0},
+			expectedResult: moderation.Result{"Hate": 0},
+			expectedCalls:  1,
+		},
+		{
+			name:           "escalate accepts results of disabled categories",
+			strategy:       StrategyEscalate,
+			first:          moderation.Result{"SelfHarm": 6, "Violence": 1},
+			second:         moderation.Result{"Violence": 6},
+			expectedResult: moderation.Result{"SelfHarm": 6, "Violence": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			second := &stubModerator{result: tt.second}
+			stages := []Stage{{Name: "a", Moderator: &stubModerator{result: tt.first}}, {Name: "b", Moderator: second}}
+			mod, err := New(stages, tt.strategy, thresholds, 1)
+			require.NoError(t, err)
+
+			result, err := mod.ModerateText(context.Background(), "text")
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedResult, result)
+			assert.Equal(t, tt.expectedCalls, second.calls)
+		})
+	}
+}
+
+func TestModerator_stageErrors(t *testing.T) {
+	outage := errors.New("outage")
+
+	t.Run("escalate keeps the earlier result when the second opinion fails", func(t *testing.T) {
+		stages := []Stage{
+			{Name: "a", Moderator: &stubModerator{result: moderation.Result{"Hate": 3}}},
+			{Name: "b", Moderator: &stubModerator{err: outage}},
+		}
+		mod, err := New(stages, StrategyEscalate, uniform(4), 1)
+		require.NoError(t, err)
+
+		result, err := mod.ModerateText(context.Background(), "text")
+		require.NoError(t, err)
+		assert.Equal(t, moderation.Result{"Hate": 3}, result)
+	})
+
+	t.Run("first flag skips a failed stage", func(t *testing.T) {
+		stages := []Stage{
+			{Name: "a", Moderator: &stubModerator{err: outage}},
+			{Name: "b", Moderator: &stubModerator{result: moderation.Result{"Hate": 6}}},
+		}
+		mod, err := New(stages, StrategyFirstFlag, uniform(4), 0)
+		require.NoError(t, err)
+
+		result, err := mod.ModerateText(context.Background(), "text")
+		require.NoError(t, err)
+		assert.Equal(t, moderation.Result{"Hate": 6}, result)
+	})
+
+	t.Run("max severity keeps the results of the other stages", func(t *testing.T) {
+		stages := []Stage{
+			{Name: "a", Moderator: &stubModerator{result: moderation.Result{"Hate": 2}}},
+			{Name: "b", Moderator: &stubModerator{err: outage}},
+		}
+		mod, err := New(stages, StrategyMaxSeverity, uniform(4), 0)
+		require.NoError(t, err)
+
+		result, err := mod.ModerateText(context.Background(), "text")
+		require.NoError(t, err)
+		assert.Equal(t, moderation.Result{"Hate": 2}, result)
+	})
+
+	t.Run("fails if every stage fails", func(t *testing.T) {
+		stages := []Stage{
+			{Name: "a", Moderator: &stubModerator{err: outage}},
+			{Name: "b", Moderator: &stubModerator{err: errors.New("also down")}},
+		}
+		mod, err := New(stages, StrategyMaxSeverity, uniform(4), 0)
+		require.NoError(t, err)
+
+		_, err = mod.ModerateText(context.Background(), "text")
+		assert.ErrorIs(t, err, outage)
+	})
+}
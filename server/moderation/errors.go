@@ -0,0 +1,121 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrorKind classifies moderation errors by whether and how the request can be retried
+type ErrorKind int
+
+const (
+	// ErrorKindPermanent errors fail the same way when retried
+	ErrorKindPermanent ErrorKind = iota
+
+	// ErrorKindTransient errors, such as server errors and network failures, may succeed when retried
+	ErrorKindTransient
+
+	// ErrorKindRateLimited errors succeed when retried after the provider's rate limit resets
+	ErrorKindRateLimited
+)
+
+// String returns a human readable name for the error kind
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorKindTransient:
+		return "transient"
+	case ErrorKindRateLimited:
+		return "rate limited"
+	default:
+		return "permanent"
+	}
+}
+
+// APIError is returned when a moderation API responds with an unexpected status code
+type APIError struct {
+	// Provider is the name of the moderation API, used in the error message
+	Provider string
+
+	StatusCode int
+	Body       string
+
+	// RetryAfter is how long the API asked to wait before retrying, or zero if it didn't say
+	RetryAfter time.Duration
+}
+
+// NewAPIError creates an APIError from a response with an unexpected status code.
+// body is the response body, which is included in the error message.
+func NewAPIError(provider string, resp *http.Response, body []byte) *APIError {
+	return &APIError{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RetryAfter: ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API returned status %d: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// ParseRetryAfter parses the value of a Retry-After header, which is either a
+// number of seconds or an HTTP date. It returns zero if the value is missing or invalid.
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// ClassifyError determines whether a moderation error can be retried
+func ClassifyError(err error) ErrorKind {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.StatusCode == http.StatusTooManyRequests:
+			return ErrorKindRateLimited
+		case apiErr.StatusCode == http.StatusRequestTimeout || apiErr.StatusCode >= http.StatusInternalServerError:
+			return ErrorKindTransient
+		default:
+			return ErrorKindPermanent
+		}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorKindTransient
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrorKindTransient
+	}
+	return ErrorKindPermanent
+}
+
+// RetryAfter returns how long the moderation API asked to wait before retrying,
+// or zero if the error doesn't say
+func RetryAfter(err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter
+	}
+	return 0
+}
@@ -0,0 +1,29 @@
+package moderation
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	assert.Equal(t, ErrorKindRateLimited, ClassifyError(errors.Wrap(&APIError{StatusCode: http.StatusTooManyRequests}, "wrapped")))
+	assert.Equal(t, ErrorKindTransient, ClassifyError(&APIError{StatusCode: http.StatusServiceUnavailable}))
+	assert.Equal(t, ErrorKindPermanent, ClassifyError(&APIError{StatusCode: http.StatusUnauthorized}))
+	assert.Equal(t, ErrorKindTransient, ClassifyError(errors.Wrap(context.DeadlineExceeded, "request failed")))
+	assert.Equal(t, ErrorKindPermanent, ClassifyError(errors.New("invalid response")))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 30*time.Second, ParseRetryAfter("30", now))
+	assert.Equal(t, 90*time.Second, ParseRetryAfter(now.Add(90*time.Second).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), ParseRetryAfter("", now))
+	assert.Equal(t, time.Duration(0), ParseRetryAfter("soon", now))
+	assert.Equal(t, time.Duration(0), ParseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now))
+}
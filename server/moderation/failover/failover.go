@@ -0,0 +1,206 @@
+package failover
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/pkg/errors"
+)
+
+// State is the state of the circuit breaker guarding the primary moderator
+type State string
+
+const (
+	// StateClosed sends requests to the primary moderator
+	StateClosed State = "closed"
+
+	// StateOpen sends all requests to the fallback moderator after repeated primary failures
+	StateOpen State = "open"
+
+	// StateHalfOpen lets a single probe request through to the primary moderator
+	StateHalfOpen State = "half_open"
+)
+
+// Settings configure when the primary moderator is bypassed
+type Settings struct {
+	// PrimaryTimeout bounds how long the primary moderator may take before the fallback is tried
+	PrimaryTimeout time.Duration
+
+	// FailureThreshold is the number of consecutive primary failures that opens the circuit
+	FailureThreshold int
+
+	// ProbeInterval is how long the circuit stays open before the primary is probed again
+	ProbeInterval time.Duration
+
+	// OnStateChange is called whenever the circuit changes state. It is called while
+	// the state is locked, so it must not call back into the moderator.
+	OnStateChange func(from, to State)
+}
+
+// Ensure Moderator implements the moderation.Moderator and moderation.ImageModerator interfaces
+var (
+	_ moderation.Moderator      = (*Moderator)(nil)
+	_ moderation.ImageModerator = (*Moderator)(nil)
+)
+
+// Moderator sends requests to a primary moderator and falls back to a secondary
+// moderator when the primary fails, times out, or has failed repeatedly
+type Moderator struct {
+	primary  moderation.Moderator
+	fallback moderation.Moderator
+	settings Settings
+
+	stateLock sync.Mutex
+	state     State
+	failures  int
+	openedAt  time.Time
+	probing   bool
+}
+
+// New creates a moderator that fails over from primary to fallback
+func New(primary, fallback moderation.Moderator, settings Settings) (*Moderator, error) {
+	if primary == nil || fallback == nil {
+		return nil, errors.New("primary and fallback moderators are required")
+	}
+	if settings.PrimaryTimeout <= 0 {
+		return nil, errors.New("primary timeout must be positive")
+	}
+	if settings.FailureThreshold <= 0 {
+		return nil, errors.New("failure threshold must be positive")
+	}
+	if settings.ProbeInterval <= 0 {
+		return nil, errors.New("probe interval must be positive")
+	}
+
+	return &Moderator{
+		primary:  primary,
+		fallback: fallback,
+		settings: settings,
+		state:    StateClosed,
+	}, nil
+}
+
+// State returns the current state of the circuit breaker
+func (m *Moderator) State() State {
+	m.stateLock.Lock()
+	defer m.stateLock.Unlock()
+	return m.state
+}
+
+// ModerateText moderates text with the primary moderator, unless the circuit is
+// open, and with the fallback moderator if the primary is bypassed or fails
+func (m *Moderator) ModerateText(ctx context.Context, text string) (moderation.Result, error) {
+	return m.moderate(ctx,
+		func(ctx context.Context) (moderation.Result, error) { return m.primary.ModerateText(ctx, text) },
+		func(ctx context.Context) (moderation.Result, error) { return m.fallback.ModerateText(ctx, text) })
+}
+
+// ModerateImage moderates an image like text if both moderators can check images.
+// If only one of them can, images are always sent to that one.
+func (m *Moderator) ModerateImage(ctx context.Context, image []byte) (moderation.Result, error) {
+	primarySupported := moderation.SupportsImages(m.primary)
+	fallbackSupported := moderation.SupportsImages(m.fallback)
+	switch {
+	case primarySupported && fallbackSupported:
+		return m.moderate(ctx,
+			func(ctx context.Context) (moderation.Result, error) {
+				return m.primary.(moderation.ImageModerator).ModerateImage(ctx, image)
+			},
+			func(ctx context.Context) (moderation.Result, error) {
+				return m.fallback.(moderation.ImageModerator).ModerateImage(ctx, image)
+			})
+	case primarySupported:
+		return m.primary.(moderation.ImageModerator).ModerateImage(ctx, image)
+	case fallbackSupported:
+		return m.fallback.(moderation.ImageModerator).ModerateImage(ctx, image)
+	default:
+		return nil, moderation.ErrImagesNotSupported
+	}
+}
+
+// SupportsImages returns true if the primary or the fallback moderator can check images
+func (m *Moderator) SupportsImages() bool {
+	return moderation.SupportsImages(m.primary) || moderation.SupportsImages(m.fallback)
+}
+
+func (m *Moderator) moderate(ctx context.Context, primary, fallback func(ctx context.Context) (moderation.Result, error)) (moderation.Result, error) {
+	var primaryErr error
+	if m.allowPrimary() {
+		primaryCtx, cancel := context.WithTimeout(ctx, m.settings.PrimaryTimeout)
+		result, err := primary(primaryCtx)
+		cancel()
+		m.recordPrimaryResult(err)
+		if err == nil {
+			return result, nil
+		}
+		if ctx.Err() != nil {
+			return nil, errors.Wrap(err, "primary moderator failed")
+		}
+		primaryErr = err
+	}
+
+	result, err := fallback(ctx)
+	if err != nil {
+		if primaryErr != nil {
+			return nil, errors.Wrapf(err, "fallback moderator failed after primary moderator failed: %v", primaryErr)
+		}
+		return nil, errors.Wrap(err, "fallback moderator failed")
+	}
+	return result, nil
+}
+
+// allowPrimary returns true if the request should be sent to the primary moderator.
+// Once the probe interval has passed, a single request probes an open circuit.
+func (m *Moderator) allowPrimary() bool {
+	m.stateLock.Lock()
+	defer m.stateLock.Unlock()
+
+	switch m.state {
+	case StateOpen:
+		if time.Now().Sub(m.openedAt) < m.settings.ProbeInterval {
+			return false
+		}
+		m.setStateWithoutLock(StateHalfOpen)
+		m.probing = true
+		return true
+	case StateHalfOpen:
+		if m.probing {
+			return false
+		}
+		m.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (m *Moderator) recordPrimaryResult(err error) {
+	m.stateLock.Lock()
+	defer m.stateLock.Unlock()
+
+	m.probing = false
+	if err == nil {
+		m.failures = 0
+		m.setStateWithoutLock(StateClosed)
+		return
+	}
+
+	m.failures++
+	if m.state == StateHalfOpen || m.failures >= m.settings.FailureThreshold {
+		m.openedAt = time.Now()
+		m.setStateWithoutLock(StateOpen)
+	}
+}
+
+func (m *Moderator) setStateWithoutLock(state State) {
+	if m.state == state {
+		return
+	}
+	previous := m.state
+	m.state = state
+	if m.settings.OnStateChange != nil {
+		m.settings.OnStateChange(previous, state)
+	}
+}
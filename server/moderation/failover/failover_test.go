@@ -0,0 +1,140 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubModerator returns a fixed result or error and counts its calls
+type stubModerator struct {
+	result moderation.Result
+	err    error
+	calls  int
+}
+
+func (m *stubModerator) ModerateText(_ context.Context, _ string) (moderation.Result, error) {
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.result, nil
+}
+
+type slowModerator struct {
+	calls int
+}
+
+func (m *slowModerator) ModerateText(ctx context.Context, _ string) (moderation.Result, error) {
+	m.calls++
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestModerator(t *testing.T) {
+	settings := Settings{
+		PrimaryTimeout:   time.Second,
+		FailureThreshold: 2,
+		ProbeInterval:    50 * time.Millisecond,
+	}
+
+	t.Run("uses primary while it succeeds", func(t *testing.T) {
+		primary := &stubModerator{result: moderation.Result{"Hate": 2}}
+		fallback := &stubModerator{result: moderation.Result{"Hate": 6}}
+		mod, err := New(primary, fallback, settings)
+		require.NoError(t, err)
+
+		result, err := mod.ModerateText(context.Background(), "text")
+		require.NoError(t, err)
+		assert.Equal(t, moderation.Result{"Hate": 2}, result)
+		assert.Equal(t, 0, fallback.calls)
+	})
+
+	t.Run("falls back when primary fails", func(t *testing.T) {
+		primary := &stubModerator{err: errors.New("outage")}
+		fallback := &stubModerator{result: moderation.Result{"Hate": 6}}
+		mod, err := New(primary, fallback, settings)
+		require.NoError(t, err)
+
+		result, err := mod.ModerateText(context.Background(), "text")
+		require.NoError(t, err)
+		assert.Equal(t, moderation.Result{"Hate": 6}, result)
+		assert.Equal(t, StateClosed, mod.State())
+	})
+
+	t.Run("falls back when primary times out", func(t *testing.T) {
+		primary := &slowModerator{}
+		fallback := &stubModerator{result: moderation.Result{"Hate": 0}}
+		timeoutSettings := settings
+		timeoutSettings.PrimaryTimeout = 10 * time.Millisecond
+		mod, err := New(primary, fallback, timeoutSettings)
+		require.NoError(t, err)
+
+		result, err := mod.ModerateText(context.Background(), "text")
+		require.NoError(t, err)
+		assert.Equal(t, moderation.Result{"Hate": 0}, result)
+	})
+
+	t.Run("returns error when both fail", func(t *testing.T) {
+		primary := &stubModerator{err: errors.New("outage")}
+		fallback := &stubModerator{err: errors.New("also down")}
+		mod, err := New(primary, fallback, settings)
+		require.NoError(t, err)
+
+		_, err = mod.ModerateText(context.Background(), "text")
+		assert.Error(t, err)
+	})
+
+	t.Run("opens circuit after repeated failures and probes primary", func(t *testing.T) {
+		primary := &stubModerator{err: errors.New("outage")}
+		fallback := &stubModerator{result: moderation.Result{"Hate": 0}}
+		var transitions []State
+		circuitSettings := settings
+		circuitSettings.OnStateChange = func(_, to State) {
+			transitions = append(transitions, to)
+		}
+		mod, err := New(primary, fallback, circuitSettings)
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			_, err = mod.ModerateText(context.Background(), "text")
+			require.NoError(t, err)
+		}
+		assert.Equal(t, StateOpen, mod.State())
+		assert.Equal(t, 2, primary.calls, "open circuit bypasses the primary")
+		assert.Equal(t, 3, fallback.calls)
+
+		time.Sleep(circuitSettings.ProbeInterval)
+		primary.err = nil
+		primary.result = moderation.Result{"Hate": 4}
+
+		result, err := mod.ModerateText(context.Background(), "text")
+		require.NoError(t, err)
+		assert.Equal(t, moderation.Result{"Hate": 4}, result)
+		assert.Equal(t, StateClosed, mod.State())
+		assert.Equal(t, []State{StateOpen, StateHalfOpen, StateClosed}, transitions)
+	})
+
+	t.Run("failed probe keeps circuit open", func(t *testing.T) {
+		primary := &stubModerator{err: errors.New("outage")}
+		fallback := &stubModerator{result: moderation.Result{"Hate": 0}}
+		mod, err := New(primary, fallback, settings)
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			_, err = mod.ModerateText(context.Background(), "text")
+			require.NoError(t, err)
+		}
+		time.Sleep(settings.ProbeInterval)
+
+		_, err = mod.ModerateText(context.Background(), "text")
+		require.NoError(t, err)
+		assert.Equal(t, 3, primary.calls)
+		assert.Equal(t, StateOpen, mod.State())
+	})
+}
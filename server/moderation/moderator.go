@@ -2,6 +2,8 @@ package moderation
 
 import (
 	"context"
+
+	"github.com/pkg/errors"
 )
 
 // Result contains the resulting severities from a moderation check
@@ -13,6 +15,34 @@ type Moderator interface {
 	ModerateText(ctx context.Context, text string) (Result, error)
 }
 
+// ErrImagesNotSupported is returned when a moderator is asked to check an image
+// but none of its providers can
+var ErrImagesNotSupported = errors.New("image moderation is not supported by the moderator")
+
+// ImageModerator is implemented by moderators that can also check images. It is
+// optional, so moderators that only check text don't have to implement it.
+type ImageModerator interface {
+	// ModerateImage checks if an image violates moderation rules
+	ModerateImage(ctx context.Context, image []byte) (Result, error)
+}
+
+// imageSupport is implemented by moderators that wrap other moderators, which can
+// only check images if the moderators they wrap can
+type imageSupport interface {
+	SupportsImages() bool
+}
+
+// SupportsImages returns true if the moderator can check images
+func SupportsImages(m Moderator) bool {
+	if _, ok := m.(ImageModerator); !ok {
+		return false
+	}
+	if s, ok := m.(imageSupport); ok {
+		return s.SupportsImages()
+	}
+	return true
+}
+
 // Config defines a common configuration for moderators
 type Config struct {
 	// Endpoint is the API endpoint URL
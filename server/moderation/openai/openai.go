@@ -0,0 +1,227 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultEndpoint is the base URL of the OpenAI API
+	DefaultEndpoint = "https://api.openai.com/v1"
+
+	// ModerationsEndpoint is the moderations API path relative to the base URL
+	ModerationsEndpoint = "/moderations"
+
+	// DefaultModel is the moderation model used when none is configured
+	DefaultModel = "omni-moderation-latest"
+
+	// maxSeverity is the highest severity of the moderation.Result scale
+	maxSeverity = 6
+)
+
+// These constants define the content categories reported by this moderator. They
+// match the categories of the other moderators so category thresholds apply to all of them.
+const (
+	CategoryHate     = "Hate"
+	CategorySexual   = "Sexual"
+	CategoryViolence = "Violence"
+	CategorySelfHarm = "SelfHarm"
+)
+
+// categoryMapping maps OpenAI moderation categories onto the plugin categories.
+// Categories that are not listed are ignored.
+var categoryMapping = map[string]string{
+	"hate":                   CategoryHate,
+	"hate/threatening":       CategoryHate,
+	"harassment":             CategoryHate,
+	"harassment/threatening": CategoryHate,
+	"sexual":                 CategorySexual,
+	"sexual/minors":          CategorySexual,
+	"violence":               CategoryViolence,
+	"violence/graphic":       CategoryViolence,
+	"illicit/violent":        CategoryViolence,
+	"self-harm":              CategorySelfHarm,
+	"self-harm/intent":       CategorySelfHarm,
+	"self-harm/instructions": CategorySelfHarm,
+}
+
+// Ensure Moderator implements the moderation.Moderator interface
+var _ moderation.Moderator = (*Moderator)(nil)
+
+// Moderator implements text moderation against the OpenAI moderations API, or
+// any server that exposes a compatible API
+type Moderator struct {
+	// client is the HTTP client for API requests
+	client *http.Client
+
+	// config holds the moderator configuration
+	config *moderation.Config
+
+	// model is the moderation model requested from the API
+	model string
+}
+
+// ModerationRequest represents the request structure for the moderations API
+type ModerationRequest struct {
+	Model string `json:"model,omitempty"`
+	Input string `json:"input"`
+}
+
+// ModerationResponse represents the response from the moderations API
+type ModerationResponse struct {
+	Results []struct {
+		Flagged        bool               `json:"flagged"`
+		CategoryScores map[string]float64 `json:"category_scores"`
+	} `json:"results"`
+}
+
+// New creates a new OpenAI moderator. An empty endpoint or model falls back to the
+// OpenAI defaults. The API key is optional so self-hosted servers without
+// authentication can be used.
+func New(config *moderation.Config, model string) (*Moderator, error) {
+	endpoint := strings.TrimRight(strings.TrimSpace(config.Endpoint), "/")
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	if endpoint == DefaultEndpoint && config.APIKey == "" {
+		return nil, errors.New("API key is required")
+	}
+
+	if model == "" {
+		model = DefaultModel
+	}
+
+	return &Moderator{
+		client: &http.Client{},
+		config: &moderation.Config{
+			Endpoint: endpoint,
+			APIKey:   config.APIKey,
+		},
+		model: model,
+	}, nil
+}
+
+// ModerateText analyzes text content using the moderations API
+func (m *Moderator) ModerateText(ctx context.Context, text string) (moderation.Result, error) {
+	req, err := makeModerateTextRequest(ctx, m.config.Endpoint, m.model, text)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create moderation request")
+	}
+
+	result, err := sendRequest(m.client, m.config.APIKey, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to moderate text content")
+	}
+
+	return result, nil
+}
+
+func makeModerateTextRequest(ctx context.Context, apiEndpoint, model, text string) (*http.Request, error) {
+	reqBody := ModerationRequest{
+		Model: model,
+		Input: text,
+	}
+
+	jsonBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling request")
+	}
+
+	endpoint := apiEndpoint + ModerationsEndpoint
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating request")
+	}
+
+	return req, nil
+}
+
+// addRequestHeaders adds the required headers to the request
+func addRequestHeaders(req *http.Request, apiKey string) {
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+}
+
+// parseResponseBody parses the response body into a structured ModerationResponse
+func parseResponseBody(responseBody io.Reader) (*ModerationResponse, error) {
+	var moderationResp ModerationResponse
+	if err := json.NewDecoder(responseBody).Decode(&moderationResp); err != nil {
+		return nil, errors.Wrap(err, "error decoding API response")
+	}
+	if len(moderationResp.Results) == 0 {
+		return nil, errors.New("API response contains no results")
+	}
+	return &moderationResp, nil
+}
+
+// convertToModerationResult converts the category scores, which range from 0 to 1,
+// to severities on the 0-6 scale. Each plugin category gets the highest severity of
+// the OpenAI categories mapped onto it.
+func convertToModerationResult(resp *ModerationResponse) moderation.Result {
+	result := moderation.Result{
+		CategoryHate:     0,
+		CategorySexual:   0,
+		CategoryViolence: 0,
+		CategorySelfHarm: 0,
+	}
+	for _, moderationResult := range resp.Results {
+		for category, score := range moderationResult.CategoryScores {
+			mapped, ok := categoryMapping[category]
+			if !ok {
+				continue
+			}
+			if severity := scoreToSeverity(score); severity > result[mapped] {
+				result[mapped] = severity
+			}
+		}
+	}
+	return result
+}
+
+// scoreToSeverity scales a 0-1 score to the 0-6 severity scale
+func scoreToSeverity(score float64) int {
+	severity := int(math.Round(score * maxSeverity))
+	if severity < 0 {
+		return 0
+	}
+	if severity > maxSeverity {
+		return maxSeverity
+	}
+	return severity
+}
+
+// sendRequest sends a request to the moderations API and processes the response
+func sendRequest(client *http.Client, apiKey string, req *http.Request) (moderation.Result, error) {
+	addRequestHeaders(req, apiKey)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "error calling OpenAI moderations API")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, e := io.ReadAll(resp.Body)
+		if e != nil {
+			return nil, errors.Wrapf(e, "failed to read error response body (status code: %d)", resp.StatusCode)
+		}
+		return nil, moderation.NewAPIError("OpenAI", resp, body)
+	}
+
+	moderationResp, err := parseResponseBody(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return convertToModerationResult(moderationResp), nil
+}
@@ -0,0 +1,200 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name             string
+		endpoint         string
+		apiKey           string
+		model            string
+		expectedEndpoint string
+		expectedModel    string
+		expectError      bool
+	}{
+		{
+			name:             "defaults to the OpenAI API",
+			apiKey:           "key",
+			expectedEndpoint: DefaultEndpoint,
+			expectedModel:    DefaultModel,
+		},
+		{
+			name:        "requires an API key for the OpenAI API",
+			expectError: true,
+		},
+		{
+			name:        "requires an API key for the OpenAI API when configured explicitly",
+			endpoint:    DefaultEndpoint + "/",
+			expectError: true,
+		},
+		{
+			name:             "allows a compatible server without an API key",
+			endpoint:         " http://localhost:8000/v1/ ",
+			model:            "text-moderation",
+			expectedEndpoint: "http://localhost:8000/v1",
+			expectedModel:    "text-moderation",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(&moderation.Config{Endpoint: tt.endpoint, APIKey: tt.apiKey}, tt.model)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedEndpoint, m.config.Endpoint)
+			assert.Equal(t, tt.expectedModel, m.model)
+		})
+	}
+}
+
+func TestScoreToSeverity(t *testing.T) {
+	tests := []struct {
+		score    float64
+		expected int
+	}{
+		{score: 0, expected: 0},
+		{score: 0.08, expected: 0},
+		{score: 0.09, expected: 1},
+		{score: 0.5, expected: 3},
+		{score: 0.75, expected: 5},
+		{score: 0.95, expected: 6},
+		{score: 1, expected: 6},
+		{score: -0.1, expected: 0},
+		{score: 1.5, expected: 6},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, scoreToSeverity(tt.score), "score %v", tt.score)
+	}
+}
+
+func TestModerator_ModerateText(t *testing.T) {
+	tests := []struct {
+		name           string
+		apiKey         string
+		status         int
+		scores         map[string]float64
+		expectedResult moderation.Result
+		expectError    bool
+	}{
+		{
+			name:   "maps categories onto the highest severity",
+			apiKey: "key",
+			status: http.StatusOK,
+			scores: map[string]float64{
+				"hate":                   0.1,
+				"harassment/threatening": 0.9,
+				"sexual/minors":          0.4,
+				"violence/graphic":       0.2,
+				"illicit/violent":        0.6,
+				"self-harm/instructions": 1,
+				"illicit":                1,
+			},
+			expectedResult: moderation.Result{
+				CategoryHate:     5,
+				CategorySexual:   2,
+				CategoryViolence: 4,
+				CategorySelfHarm: 6,
+			},
+		},
+		{
+			name:   "reports all categories of harmless content",
+			status: http.StatusOK,
+			scores: map[string]float64{},
+			expectedResult: moderation.Result{
+				CategoryHate:     0,
+				CategorySexual:   0,
+				CategoryViolence: 0,
+				CategorySelfHarm: 0,
+			},
+		},
+		{
+			name:        "returns an API error for unexpected status codes",
+			status:      http.StatusTooManyRequests,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, "/v1"+ModerationsEndpoint, r.URL.Path)
+				assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				if tt.apiKey != "" {
+					assert.Equal(t, "Bearer "+tt.apiKey, r.Header.Get("Authorization"))
+				} else {
+					assert.Empty(t, r.Header.Get("Authorization"))
+				}
+
+				var req ModerationRequest
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+				assert.Equal(t, DefaultModel, req.Model)
+				assert.Equal(t, "some text", req.Input)
+
+				if tt.status != http.StatusOK {
+					w.Header().Set("Retry-After", "3")
+					w.WriteHeader(tt.status)
+					_, _ = w.Write([]byte("slow down"))
+					return
+				}
+				_, _ = w.Write(moderationResponse(t, tt.scores))
+			}))
+			defer server.Close()
+
+			m, err := New(&moderation.Config{Endpoint: server.URL + "/v1/", APIKey: tt.apiKey}, "")
+			require.NoError(t, err)
+
+			result, err := m.ModerateText(context.Background(), "some text")
+			if tt.expectError {
+				require.Error(t, err)
+				assert.Equal(t, moderation.ErrorKindRateLimited, moderation.ClassifyError(err))
+				var apiErr *moderation.APIError
+				require.ErrorAs(t, err, &apiErr)
+				assert.Equal(t, 3*time.Second, apiErr.RetryAfter)
+				assert.Equal(t, "slow down", apiErr.Body)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedResult, result)
+		})
+	}
+}
+
+func TestModerator_ModerateText_emptyResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"results": []}`))
+	}))
+	defer server.Close()
+
+	m, err := New(&moderation.Config{Endpoint: server.URL}, "")
+	require.NoError(t, err)
+
+	_, err = m.ModerateText(context.Background(), "some text")
+	assert.Error(t, err)
+}
+
+func moderationResponse(t *testing.T, scores map[string]float64) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{
+		"results": []map[string]any{
+			{"flagged": false, "category_scores": scores},
+		},
+	})
+	require.NoError(t, err)
+	return data
+}
@@ -0,0 +1,139 @@
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/pkg/errors"
+)
+
+// Settings configure how failed moderation requests are retried
+type Settings struct {
+	// MaxAttempts is the maximum number of requests, including the first one
+	MaxAttempts int
+
+	// BaseDelay is the backoff before the first retry. It doubles with every retry.
+	BaseDelay time.Duration
+
+	// MaxDelay caps the backoff between retries
+	MaxDelay time.Duration
+
+	// Limiter, if set, is waited for before every retry, so retries count against
+	// the rate limit of the provider like the first attempt
+	Limiter Limiter
+}
+
+// Limiter delays requests to stay within the rate limit of the provider
+type Limiter interface {
+	Wait(ctx context.Context) error
+}
+
+// Ensure Moderator implements the moderation.Moderator and moderation.ImageModerator interfaces
+var (
+	_ moderation.Moderator      = (*Moderator)(nil)
+	_ moderation.ImageModerator = (*Moderator)(nil)
+)
+
+// Moderator retries rate limited and transient failures of another moderator
+// with jittered exponential backoff, honoring the Retry-After of the API
+type Moderator struct {
+	next     moderation.Moderator
+	settings Settings
+}
+
+// New creates a moderator that retries failed requests to next
+func New(next moderation.Moderator, settings Settings) (*Moderator, error) {
+	if next == nil {
+		return nil, errors.New("moderator is required")
+	}
+	if settings.MaxAttempts <= 0 {
+		return nil, errors.New("max attempts must be positive")
+	}
+	if settings.BaseDelay <= 0 || settings.MaxDelay < settings.BaseDelay {
+		return nil, errors.New("base delay must be positive and not exceed max delay")
+	}
+
+	return &Moderator{
+		next:     next,
+		settings: settings,
+	}, nil
+}
+
+// ModerateText moderates text, retrying as long as the error can be retried and
+// the next attempt can start before the deadline of the context
+func (m *Moderator) ModerateText(ctx context.Context, text string) (moderation.Result, error) {
+	return m.moderate(ctx, func() (moderation.Result, error) {
+		return m.next.ModerateText(ctx, text)
+	})
+}
+
+// ModerateImage moderates an image with the same retries as text
+func (m *Moderator) ModerateImage(ctx context.Context, image []byte) (moderation.Result, error) {
+	next, ok := m.next.(moderation.ImageModerator)
+	if !ok {
+		return nil, moderation.ErrImagesNotSupported
+	}
+	return m.moderate(ctx, func() (moderation.Result, error) {
+		return next.ModerateImage(ctx, image)
+	})
+}
+
+// SupportsImages returns true if the retried moderator can check images
+func (m *Moderator) SupportsImages() bool {
+	return moderation.SupportsImages(m.next)
+}
+
+func (m *Moderator) moderate(ctx context.Context, request func() (moderation.Result, error)) (moderation.Result, error) {
+	var err error
+	for attempt := 0; attempt < m.settings.MaxAttempts; attempt++ {
+		var result moderation.Result
+		result, err = request()
+		if err == nil {
+			return result, nil
+		}
+		if ctx.Err() != nil || moderation.ClassifyError(err) == moderation.ErrorKindPermanent {
+			return nil, err
+		}
+		if attempt == m.settings.MaxAttempts-1 {
+			break
+		}
+
+		delay := moderation.RetryAfter(err)
+		if delay == 0 {
+			delay = m.backoff(attempt)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return nil, errors.Wrapf(err, "not retrying, as the next attempt would start after the deadline (retry after %s)", delay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+
+		if m.settings.Limiter != nil {
+			if waitErr := m.settings.Limiter.Wait(ctx); waitErr != nil {
+				return nil, errors.Wrapf(err, "not retrying, as the rate limit allows no further attempt before the deadline (%s)", waitErr)
+			}
+		}
+	}
+
+	return nil, errors.Wrapf(err, "giving up after %d attempts", m.settings.MaxAttempts)
+}
+
+// backoff returns a random delay up to the exponential backoff of the attempt
+func (m *Moderator) backoff(attempt int) time.Duration {
+	backoff := m.settings.MaxDelay
+	if attempt < 32 {
+		if exp := m.settings.BaseDelay << attempt; exp > 0 && exp < backoff {
+			backoff = exp
+		}
+	}
+	// Half of the backoff is fixed so retries are never immediate
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
@@ -0,0 +1,134 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyModerator fails with the given errors before succeeding
+type flakyModerator struct {
+	errs  []error
+	calls int
+}
+
+func (m *flakyModerator) ModerateText(_ context.Context, _ string) (moderation.Result, error) {
+	m.calls++
+	if len(m.errs) > 0 {
+		err := m.errs[0]
+		m.errs = m.errs[1:]
+		return nil, err
+	}
+	return moderation.Result{"Hate": 0}, nil
+}
+
+func TestModerator(t *testing.T) {
+	settings := Settings{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    5 * time.Millisecond,
+	}
+
+	t.Run("retries transient errors", func(t *testing.T) {
+		next := &flakyModerator{errs: []error{
+			&moderation.APIError{StatusCode: http.StatusInternalServerError},
+			&moderation.APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Millisecond},
+		}}
+		mod, err := New(next, settings)
+		require.NoError(t, err)
+
+		result, err := mod.ModerateText(context.Background(), "text")
+		require.NoError(t, err)
+		assert.Equal(t, moderation.Result{"Hate": 0}, result)
+		assert.Equal(t, 3, next.calls)
+	})
+
+	t.Run("does not retry permanent errors", func(t *testing.T) {
+		next := &flakyModerator{errs: []error{&moderation.APIError{StatusCode: http.StatusBadRequest}}}
+		mod, err := New(next, settings)
+		require.NoError(t, err)
+
+		_, err = mod.ModerateText(context.Background(), "text")
+		assert.Error(t, err)
+		assert.Equal(t, 1, next.calls)
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		next := &flakyModerator{errs: []error{
+			&moderation.APIError{StatusCode: http.StatusBadGateway},
+			&moderation.APIError{StatusCode: http.StatusBadGateway},
+			&moderation.APIError{StatusCode: http.StatusBadGateway},
+		}}
+		mod, err := New(next, settings)
+		require.NoError(t, err)
+
+		_, err = mod.ModerateText(context.Background(), "text")
+		assert.Error(t, err)
+		assert.Equal(t, 3, next.calls)
+	})
+
+	t.Run("does not wait past the deadline", func(t *testing.T) {
+		next := &flakyModerator{errs: []error{
+			&moderation.APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Minute},
+		}}
+		mod, err := New(next, settings)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		start := time.Now()
+		_, err = mod.ModerateText(ctx, "text")
+		assert.Error(t, err)
+		assert.Equal(t, 1, next.calls)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("waits for the limiter before every retry", func(t *testing.T) {
+		next := &flakyModerator{errs: []error{
+			&moderation.APIError{StatusCode: http.StatusServiceUnavailable},
+			&moderation.APIError{StatusCode: http.StatusServiceUnavailable},
+		}}
+		limiter := &countingLimiter{}
+		limitedSettings := settings
+		limitedSettings.Limiter = limiter
+		mod, err := New(next, limitedSettings)
+		require.NoError(t, err)
+
+		_, err = mod.ModerateText(context.Background(), "text")
+		require.NoError(t, err)
+		assert.Equal(t, 3, next.calls)
+		assert.Equal(t, 2, limiter.waits, "the first attempt is rate limited by the caller")
+	})
+
+	t.Run("stops when the limiter allows no further attempt", func(t *testing.T) {
+		next := &flakyModerator{errs: []error{
+			&moderation.APIError{StatusCode: http.StatusServiceUnavailable},
+		}}
+		limitedSettings := settings
+		limitedSettings.Limiter = &countingLimiter{err: errors.New("would exceed deadline")}
+		mod, err := New(next, limitedSettings)
+		require.NoError(t, err)
+
+		_, err = mod.ModerateText(context.Background(), "text")
+		assert.Error(t, err)
+		assert.Equal(t, moderation.ErrorKindTransient, moderation.ClassifyError(err))
+		assert.Equal(t, 1, next.calls)
+	})
+}
+
+// countingLimiter counts how often it was waited for
+type countingLimiter struct {
+	err   error
+	waits int
+}
+
+func (l *countingLimiter) Wait(_ context.Context) error {
+	l.waits++
+	return l.err
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// moderationPriority determines how soon a message is moderated when the queue is deep
+type moderationPriority int
+
+const (
+	moderationPriorityNormal moderationPriority = iota
+	// moderationPriorityHigh is used for high exposure content, such as posts in
+	// large public channels and posts from guest accounts
+	moderationPriorityHigh
+	// moderationPriorityUrgent is used for messages that something is waiting on,
+	// such as a pending email notification
+	moderationPriorityUrgent
+
+	moderationPriorityCount
+)
+
+func (p moderationPriority) String() string {
+	switch p {
+	case moderationPriorityHigh:
+		return "high"
+	case moderationPriorityUrgent:
+		return "urgent"
+	default:
+		return "normal"
+	}
+}
+
+// moderationQueueSchedule is the order in which priorities are served while
+// all of them have messages queued. Every priority appears at least once so
+// lower priorities keep being served when higher ones are busy.
+var moderationQueueSchedule = []moderationPriority{
+	moderationPriorityUrgent,
+	moderationPriorityHigh,
+	moderationPriorityUrgent,
+	moderationPriorityNormal,
+	moderationPriorityUrgent,
+	moderationPriorityHigh,
+	moderationPriorityUrgent,
+}
+
+type queuedMessage struct {
+	message  string
+	priority moderationPriority
+}
+
+// moderationQueue is a bounded queue of messages with one FIFO per priority
+type moderationQueue struct {
+	lock     sync.Mutex
+	queues   [moderationPriorityCount]*list.List
+	elements map[string]*list.Element
+	next     int
+	maxSize  int
+
+	// ready holds one token for every queued message so pop can wait for messages
+	ready chan struct{}
+}
+
+func newModerationQueue(maxSize int) *moderationQueue {
+	q := &moderationQueue{
+		elements: make(map[string]*list.Element),
+		maxSize:  maxSize,
+		ready:    make(chan struct{}, maxSize),
+	}
+	for i := range q.queues {
+		q.queues[i] = list.New()
+	}
+	return q
+}
+
+// push adds a message to the queue. Returns false if the queue is full.
+func (q *moderationQueue) push(message string, priority moderationPriority) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if element, ok := q.elements[message]; ok {
+		q.promoteWithoutLock(element, priority)
+		return true
+	}
+	if len(q.elements) >= q.maxSize {
+		return false
+	}
+
+	q.elements[message] = q.queues[priority].PushBack(&queuedMessage{message: message, priority: priority})
+	q.ready <- struct{}{}
+	return true
+}
+
+// promote moves a queued message to a higher priority. Messages that aren't
+// queued, or are already queued at the same or a higher priority, are left alone.
+func (q *moderationQueue) promote(message string, priority moderationPriority) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if element, ok := q.elements[message]; ok {
+		q.promoteWithoutLock(element, priority)
+	}
+}
+
+func (q *moderationQueue) promoteWithoutLock(element *list.Element, priority moderationPriority) {
+	queued := element.Value.(*queuedMessage)
+	if priority <= queued.priority {
+		return
+	}
+	q.queues[queued.priority].Remove(element)
+	queued.priority = priority
+	q.elements[queued.message] = q.queues[priority].PushBack(queued)
+}
+
+// pop waits for a message and returns the next one according to the schedule.
+// Returns false if the context is done first.
+func (q *moderationQueue) pop(ctx context.Context) (queuedMessage, bool) {
+	select {
+	case <-q.ready:
+	case <-ctx.Done():
+		return queuedMessage{}, false
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	// Every priority is part of the schedule, so a full round always finds the
+	// message the ready token was taken for
+	for i := 0; i < len(moderationQueueSchedule); i++ {
+		priority := moderationQueueSchedule[q.next]
+		q.next = (q.next + 1) % len(moderationQueueSchedule)
+		if element := q.queues[priority].Front(); element != nil {
+			queued := element.Value.(*queuedMessage)
+			q.queues[priority].Remove(element)
+			delete(q.elements, queued.message)
+			return *queued, true
+		}
+	}
+	return queuedMessage{}, false
+}
+
+// len returns the number of queued messages
+func (q *moderationQueue) len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.elements)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func popMessages(t *testing.T, q *moderationQueue, count int) []string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var messages []string
+	for i := 0; i < count; i++ {
+		queued, ok := q.pop(ctx)
+		require.True(t, ok)
+		messages = append(messages, queued.message)
+	}
+	return messages
+}
+
+func TestModerationQueue(t *testing.T) {
+	t.Run("serves higher priorities first", func(t *testing.T) {
+		q := newModerationQueue(10)
+		require.True(t, q.push("normal", moderationPriorityNormal))
+		require.True(t, q.push("high", moderationPriorityHigh))
+		require.True(t, q.push("urgent", moderationPriorityUrgent))
+
+		assert.Equal(t, []string{"urgent", "high", "normal"}, popMessages(t, q, 3))
+	})
+
+	t.Run("keeps serving lower priorities when higher ones are busy", func(t *testing.T) {
+		q := newModerationQueue(100)
+		require.True(t, q.push("normal", moderationPriorityNormal))
+		for i := 0; i < 20; i++ {
+			require.True(t, q.push(model.NewId(), moderationPriorityUrgent))
+		}
+
+		assert.Contains(t, popMessages(t, q, len(moderationQueueSchedule)), "normal")
+	})
+
+	t.Run("promotes queued message", func(t *testing.T) {
+		q := newModerationQueue(10)
+		require.True(t, q.push("first", moderationPriorityNormal))
+		require.True(t, q.push("second", moderationPriorityNormal))
+		q.promote("second", moderationPriorityUrgent)
+		q.promote("first", moderationPriorityNormal)
+
+		assert.Equal(t, []string{"second", "first"}, popMessages(t, q, 2))
+	})
+
+	t.Run("does not queue a message twice", func(t *testing.T) {
+		q := newModerationQueue(10)
+		require.True(t, q.push("message", moderationPriorityNormal))
+		require.True(t, q.push("message", moderationPriorityHigh))
+
+		queued := popMessages(t, q, 1)
+		assert.Equal(t, []string{"message"}, queued)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, ok := q.pop(ctx)
+		assert.False(t, ok)
+	})
+
+	t.Run("rejects messages when full", func(t *testing.T) {
+		q := newModerationQueue(1)
+		require.True(t, q.push("first", moderationPriorityNormal))
+		assert.False(t, q.push("second", moderationPriorityUrgent))
+	})
+}
+
+func TestPostProcessor_messagePriority(t *testing.T) {
+	post := &model.Post{UserId: "user123", ChannelId: "channel123"}
+
+	t.Run("guest posts are high priority", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("GetUser", "user123").Return(&model.User{Id: "user123", Roles: model.SystemGuestRoleId}, nil).Once()
+		p := &PostProcessor{}
+
+		assert.Equal(t, moderationPriorityHigh, p.messagePriority(api, post, 100))
+		assert.Equal(t, moderationPriorityHigh, p.messagePriority(api, post, 100))
+		api.AssertExpectations(t)
+	})
+
+	t.Run("posts in large public channels are high priority", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("GetUser", "user123").Return(&model.User{Id: "user123", Roles: model.SystemUserRoleId}, nil).Once()
+		api.On("GetChannel", "channel123").Return(&model.Channel{Id: "channel123", Type: model.ChannelTypeOpen}, nil).Once()
+		api.On("GetChannelStats", "channel123").Return(&model.ChannelStats{MemberCount: 150}, nil).Once()
+		p := &PostProcessor{}
+
+		assert.Equal(t, moderationPriorityHigh, p.messagePriority(api, post, 100))
+		assert.Equal(t, moderationPriorityNormal, p.messagePriority(api, post, 200), "the member count is cached, not the priority")
+		api.AssertExpectations(t)
+	})
+
+	t.Run("posts in private channels are normal priority", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("GetUser", "user123").Return(&model.User{Id: "user123", Roles: model.SystemUserRoleId}, nil)
+		api.On("GetChannel", "channel123").Return(&model.Channel{Id: "channel123", Type: model.ChannelTypePrivate}, nil)
+		p := &PostProcessor{}
+
+		assert.Equal(t, moderationPriorityNormal, p.messagePriority(api, post, 100))
+		api.AssertNotCalled(t, "GetChannelStats", mock.Anything)
+	})
+}
+
+func TestQueuePostMessage(t *testing.T) {
+	newProcessors := func(t *testing.T) (*ModerationProcessor, *PostProcessor) {
+		moderationProcessor, err := newModerationProcessor(newModerationResultsCache(), &fakeModerator{}, 4, nil, 6000, 10, 1, nil, nil, nil)
+		require.NoError(t, err)
+		postProcessor := &PostProcessor{
+			botID:                "bot123",
+			excludedUsers:        map[string]struct{}{"excluded456": {}},
+			excludedChannelStore: NewMockExcludedChannelsStore([]string{"excluded123"}),
+		}
+		return moderationProcessor, postProcessor
+	}
+
+	t.Run("skips excluded posts and empty messages without looking up the priority", func(t *testing.T) {
+		api := &plugintest.API{}
+		moderationProcessor, postProcessor := newProcessors(t)
+
+		queuePostMessage(api, moderationProcessor, postProcessor, &model.Post{UserId: "user123", ChannelId: "excluded123", Message: "hello"}, 100)
+		queuePostMessage(api, moderationProcessor, postProcessor, &model.Post{UserId: "excluded456", ChannelId: "channel123", Message: "hello"}, 100)
+		queuePostMessage(api, moderationProcessor, postProcessor, &model.Post{UserId: "user123", ChannelId: "channel123"}, 100)
+
+		assert.Zero(t, moderationProcessor.queue.len())
+		api.AssertExpectations(t)
+	})
+
+	t.Run("queues moderated posts", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("GetUser", "user123").Return(&model.User{Id: "user123", Roles: model.SystemGuestRoleId}, nil)
+		api.On("GetChannel", "channel123").Return(&model.Channel{Id: "channel123", Type: model.ChannelTypeOpen}, nil)
+		moderationProcessor, postProcessor := newProcessors(t)
+
+		queuePostMessage(api, moderationProcessor, postProcessor, &model.Post{UserId: "user123", ChannelId: "channel123", Message: "hello"}, 100)
+		assert.Equal(t, 1, moderationProcessor.queue.len())
+	})
+}
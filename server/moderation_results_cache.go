@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"sync"
 	"time"
 
@@ -18,6 +20,8 @@ const (
 	moderationResultError
 )
 
+// moderationResultsCache keeps the moderation results of messages by the hash of
+// the message, so results can be shared with other nodes without the message itself
 type moderationResultsCache struct {
 	cache     map[string]*moderationResult
 	listeners map[string][]chan *moderationResult
@@ -50,17 +54,18 @@ func (pc *moderationResultsCache) setResultPending(message string) bool {
 		return false
 	}
 
+	key := messageHash(message)
 	pc.cacheLock.Lock()
 	defer pc.cacheLock.Unlock()
 
 	// We may already have a result in the cache
 	// so let's just refresh the timestamp
-	if result, ok := pc.cache[message]; ok {
+	if result, ok := pc.cache[key]; ok {
 		result.timestamp = time.Now()
 		return false
 	}
 
-	pc.cache[message] = &moderationResult{
+	pc.cache[key] = &moderationResult{
 		code:      moderationResultPending,
 		timestamp: time.Now(),
 	}
@@ -68,65 +73,68 @@ func (pc *moderationResultsCache) setResultPending(message string) bool {
 	return true
 }
 
+// isPending returns true if the message is waiting for a moderation result
+func (pc *moderationResultsCache) isPending(message string) bool {
+	pc.cacheLock.Lock()
+	defer pc.cacheLock.Unlock()
+
+	result, ok := pc.cache[messageHash(message)]
+	return ok && result.code == moderationResultPending
+}
+
 func (pc *moderationResultsCache) setModerationResultError(message string, err error) {
 	if message == "" {
 		return
 	}
 
-	pc.cacheLock.Lock()
-	defer pc.cacheLock.Unlock()
-
-	result := &moderationResult{
+	pc.setResult(messageHash(message), &moderationResult{
 		code:      moderationResultError,
 		timestamp: time.Now(),
 		err:       err,
-	}
-	pc.cache[message] = result
-	pc.notifyListeners(message, result)
+	})
 }
 
 func (pc *moderationResultsCache) setModerationResultNotFlagged(message string, result moderation.Result) {
 	if message == "" {
 		return
 	}
-
-	pc.cacheLock.Lock()
-	defer pc.cacheLock.Unlock()
-
-	moderationResult := &moderationResult{
-		code:      moderationResultProcessed,
-		result:    result,
-		timestamp: time.Now(),
-	}
-	pc.cache[message] = moderationResult
-	pc.notifyListeners(message, moderationResult)
+	pc.setHashedResult(messageHash(message), moderationResultProcessed, result)
 }
 
 func (pc *moderationResultsCache) setModerationResultFlagged(message string, result moderation.Result) {
 	if message == "" {
 		return
 	}
+	pc.setHashedResult(messageHash(message), moderationResultFlagged, result)
+}
 
+// setHashedResult stores the result of the message with the given hash, e.g. one
+// shared by another node
+func (pc *moderationResultsCache) setHashedResult(key string, code moderationResultCode, result moderation.Result) {
+	pc.setResult(key, &moderationResult{
+		code:      code,
+		result:    result,
+		timestamp: time.Now(),
+	})
+}
+
+func (pc *moderationResultsCache) setResult(key string, result *moderationResult) {
 	pc.cacheLock.Lock()
 	defer pc.cacheLock.Unlock()
 
-	moderationResult := &moderationResult{
-		code:      moderationResultFlagged,
-		result:    result,
-		timestamp: time.Now(),
-	}
-	pc.cache[message] = moderationResult
-	pc.notifyListeners(message, moderationResult)
+	pc.cache[key] = result
+	pc.notifyListeners(key, result)
 }
 
 func (pc *moderationResultsCache) waitForResult(message string, timeout time.Duration) *moderationResult {
+	key := messageHash(message)
 	pc.cacheLock.Lock()
-	if cached, ok := pc.cache[message]; ok && cached.code != moderationResultPending {
+	if cached, ok := pc.cache[key]; ok && cached.code != moderationResultPending {
 		pc.cacheLock.Unlock()
 		return cached
 	}
 	ch := make(chan *moderationResult, 1)
-	pc.listeners[message] = append(pc.listeners[message], ch)
+	pc.listeners[key] = append(pc.listeners[key], ch)
 	pc.cacheLock.Unlock()
 
 	select {
@@ -139,8 +147,8 @@ func (pc *moderationResultsCache) waitForResult(message string, timeout time.Dur
 
 // notifyListeners notifies all registered listeners for a message and cleans them up.
 // IMPORTANT: This method assumes the caller already holds pc.cacheLock.
-func (pc *moderationResultsCache) notifyListeners(message string, result *moderationResult) {
-	listeners, ok := pc.listeners[message]
+func (pc *moderationResultsCache) notifyListeners(key string, result *moderationResult) {
+	listeners, ok := pc.listeners[key]
 	if !ok {
 		return
 	}
@@ -153,7 +161,7 @@ func (pc *moderationResultsCache) notifyListeners(message string, result *modera
 		close(ch)
 	}
 
-	delete(pc.listeners, message)
+	delete(pc.listeners, key)
 }
 
 func (pc *moderationResultsCache) cleanup() {
@@ -161,13 +169,19 @@ func (pc *moderationResultsCache) cleanup() {
 	defer pc.cacheLock.Unlock()
 
 	now := time.Now()
-	for message, result := range pc.cache {
+	for key, result := range pc.cache {
 		if now.Sub(result.timestamp) > pc.cacheTTL {
-			delete(pc.cache, message)
-			for _, ch := range pc.listeners[message] {
+			delete(pc.cache, key)
+			for _, ch := range pc.listeners[key] {
 				close(ch)
 			}
-			delete(pc.listeners, message)
+			delete(pc.listeners, key)
 		}
 	}
 }
+
+// messageHash returns the key of a message in the results cache
+func messageHash(message string) string {
+	hash := sha256.Sum256([]byte(message))
+	return hex.EncodeToString(hash[:])
+}
@@ -58,11 +58,11 @@ func TestModerationResultsCache_cleanup(t *testing.T) {
 			t.Errorf("Expected 1 cache entry after cleanup, got %d", len(cache.cache))
 		}
 
-		if _, ok := cache.cache["fresh_message"]; !ok {
+		if _, ok := cache.cache[messageHash("fresh_message")]; !ok {
 			t.Error("Expected fresh_message to remain in cache")
 		}
 
-		if _, ok := cache.cache["expired_message"]; ok {
+		if _, ok := cache.cache[messageHash("expired_message")]; ok {
 			t.Error("Expected expired_message to be removed from cache")
 		}
 	})
@@ -75,7 +75,7 @@ func TestModerationResultsCache_cleanup(t *testing.T) {
 		cache.setResultPending("message1")
 		ch := make(chan *moderationResult, 1)
 		cache.cacheLock.Lock()
-		cache.listeners["message1"] = []chan *moderationResult{ch}
+		cache.listeners[messageHash("message1")] = []chan *moderationResult{ch}
 		cache.cacheLock.Unlock()
 
 		// Wait for entry to expire
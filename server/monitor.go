@@ -0,0 +1,173 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultMonitorReportDays = 7
+	maxMonitorReportDays     = int(monitorRetention / (24 * time.Hour))
+
+	// maxMonitorRecordsListed is the number of unreviewed flags returned at once
+	maxMonitorRecordsListed = 20
+)
+
+// MonitorReport summarizes the monitored flags of a range of days
+type MonitorReport struct {
+	From       string                            `json:"from"`
+	To         string                            `json:"to"`
+	Flagged    int                               `json:"flagged"`
+	Categories map[string]*MonitorCategoryReport `json:"categories"`
+}
+
+// MonitorCategoryReport summarizes the monitored flags of a category. The false
+// positive rate is the share of reviewed flags that reviewers marked as false positives.
+type MonitorCategoryReport struct {
+	MonitorCategoryStats
+	Reviewed          int     `json:"reviewed"`
+	FalsePositiveRate float64 `json:"false_positive_rate"`
+}
+
+// recordMonitoredFlag stores what would have been flagged so it shows up in monitor
+// reports, and returns the ID of the record. The post and its author are left alone.
+func (p *PostProcessor) recordMonitoredFlag(api plugin.API, post *model.Post, policy effectivePolicy, result moderation.Result) (string, error) {
+	if p.monitorStore == nil {
+		return "", errors.New("monitoring is not available")
+	}
+
+	id := model.NewId()
+	return id, p.monitorStore.AddRecord(&MonitorRecord{
+		ID:                id,
+		PostID:            post.Id,
+		UserID:            post.UserId,
+		ChannelID:         post.ChannelId,
+		TeamID:            p.getChannelInfo(api, post.ChannelId).teamID,
+		Message:           postText(post),
+		Result:            result,
+		Thresholds:        policy.thresholdsFor(result),
+		FlaggedCategories: policy.flaggedCategories(result),
+		FlaggedAt:         model.GetMillis(),
+	})
+}
+
+// thresholdsFor returns the threshold the policy applies to each category of the
+// result. Disabled categories are left out.
+func (ep effectivePolicy) thresholdsFor(result moderation.Result) map[string]int {
+	thresholds := make(map[string]int, len(result))
+	for category := range result {
+		threshold := ep.threshold
+		if override, ok := ep.categoryThresholds[strings.ToLower(category)]; ok {
+			if override.disabled {
+				continue
+			}
+			threshold = override.value
+		}
+		thresholds[category] = threshold
+	}
+	return thresholds
+}
+
+// flaggedCategories returns the categories of the result that reach their threshold, sorted by name
+func (ep effectivePolicy) flaggedCategories(result moderation.Result) []string {
+	var categories []string
+	for category, threshold := range ep.thresholdsFor(result) {
+		if result[category] >= threshold {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// monitorReport summarizes the monitored flags of the last days, including today
+func (p *Plugin) monitorReport(days int) (*MonitorReport, error) {
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, 1-days)
+
+	monitorDays, err := p.monitorStore.GetDays(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &MonitorReport{
+		From:       from.Format(monitorDayLayout),
+		To:         to.Format(monitorDayLayout),
+		Categories: make(map[string]*MonitorCategoryReport),
+	}
+	for _, day := range monitorDays {
+		report.Flagged += day.Flagged
+		for name, stats := range day.Categories {
+			category, ok := report.Categories[name]
+			if !ok {
+				category = &MonitorCategoryReport{}
+				report.Categories[name] = category
+			}
+			category.Flagged += stats.Flagged
+			category.Confirmed += stats.Confirmed
+			category.FalsePositives += stats.FalsePositives
+		}
+	}
+
+	for _, category := range report.Categories {
+		category.Reviewed = category.Confirmed + category.FalsePositives
+		if category.Reviewed > 0 {
+			category.FalsePositiveRate = float64(category.FalsePositives) / float64(category.Reviewed)
+		}
+	}
+	return report, nil
+}
+
+// unreviewedMonitorRecords returns the most recent monitored flags of the last
+// days that no reviewer has given a verdict on yet
+func (p *Plugin) unreviewedMonitorRecords(days int) ([]*MonitorRecord, error) {
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, 1-days)
+
+	monitorDays, err := p.monitorStore.GetDays(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	records := []*MonitorRecord{}
+	for i := len(monitorDays) - 1; i >= 0; i-- {
+		recordIDs := monitorDays[i].RecordIDs
+		for j := len(recordIDs) - 1; j >= 0; j-- {
+			record, err := p.monitorStore.GetRecord(recordIDs[j])
+			if err != nil {
+				return nil, err
+			}
+			if record == nil || record.Verdict != "" {
+				continue
+			}
+			records = append(records, record)
+			if len(records) == maxMonitorRecordsListed {
+				return records, nil
+			}
+		}
+	}
+	return records, nil
+}
+
+// setMonitorVerdict records a reviewer's verdict on a monitored flag
+func (p *Plugin) setMonitorVerdict(id string, verdict MonitorVerdict, reviewerID string, auditRecord *model.AuditRecord) (*MonitorRecord, error) {
+	if !isValidMonitorVerdict(verdict) {
+		return nil, errors.Errorf("verdict must be %s or %s", MonitorVerdictConfirmed, MonitorVerdictFalsePositive)
+	}
+	auditRecord.AddMeta(auditMetaKeyMonitorRecordID, id)
+	auditRecord.AddMeta(auditMetaKeyVerdict, string(verdict))
+
+	record, err := p.monitorStore.SetVerdict(id, verdict, reviewerID)
+	if err != nil {
+		return nil, err
+	}
+	auditRecord.AddMeta(auditMetaKeyPostID, record.PostID)
+	auditRecord.AddMeta(auditMetaKeyResult, record.Result)
+	return record, nil
+}
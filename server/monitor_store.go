@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+const (
+	monitorRecordKVKeyPrefix = "monitor_record_"
+	monitorDayKVKeyPrefix    = "monitor_day_"
+	monitorDayLayout         = "2006-01-02"
+
+	// monitorRetention is how long monitored flags are kept for reports
+	monitorRetention = 90 * 24 * time.Hour
+
+	// maxMonitorDayRecords is the number of records of a day that can be listed for review.
+	// Records beyond it are still counted in reports.
+	maxMonitorDayRecords = 1000
+
+	// maxMonitorUpdateAttempts is how often an update is retried when it was changed concurrently
+	maxMonitorUpdateAttempts = 5
+)
+
+var ErrMonitorRecordNotFound = errors.New("monitored flag not found")
+
+type MonitorVerdict string
+
+const (
+	MonitorVerdictConfirmed     MonitorVerdict = "confirmed"
+	MonitorVerdictFalsePositive MonitorVerdict = "false_positive"
+)
+
+func isValidMonitorVerdict(verdict MonitorVerdict) bool {
+	return verdict == MonitorVerdictConfirmed || verdict == MonitorVerdictFalsePositive
+}
+
+// MonitorRecord is a post that would have been flagged if the policy enforced an action
+type MonitorRecord struct {
+	ID        string            `json:"id"`
+	PostID    string            `json:"post_id"`
+	UserID    string            `json:"user_id"`
+	ChannelID string            `json:"channel_id"`
+	TeamID    string            `json:"team_id,omitempty"`
+	Message   string            `json:"message"`
+	Result    moderation.Result `json:"result"`
+
+	// Thresholds is the threshold applied to each category of the result, and
+	// FlaggedCategories the categories that reached it
+	Thresholds        map[string]int `json:"thresholds"`
+	FlaggedCategories []string       `json:"flagged_categories"`
+
+	FlaggedAt  int64          `json:"flagged_at"`
+	Verdict    MonitorVerdict `json:"verdict,omitempty"`
+	ReviewedBy string         `json:"reviewed_by,omitempty"`
+	ReviewedAt int64          `json:"reviewed_at,omitempty"`
+}
+
+// MonitorCategoryStats counts the monitored flags of a category
+type MonitorCategoryStats struct {
+	Flagged        int `json:"flagged"`
+	Confirmed      int `json:"confirmed"`
+	FalsePositives int `json:"false_positives"`
+}
+
+// MonitorDay aggregates the monitored flags of a day, in UTC
+type MonitorDay struct {
+	Date       string                           `json:"date"`
+	Flagged    int                              `json:"flagged"`
+	Categories map[string]*MonitorCategoryStats `json:"categories"`
+	RecordIDs  []string                         `json:"record_ids"`
+}
+
+func (d *MonitorDay) category(name string) *MonitorCategoryStats {
+	if d.Categories == nil {
+		d.Categories = make(map[string]*MonitorCategoryStats)
+	}
+	stats, ok := d.Categories[name]
+	if !ok {
+		stats = &MonitorCategoryStats{}
+		d.Categories[name] = stats
+	}
+	return stats
+}
+
+// countVerdict adds delta to the verdict counts of the flagged categories
+func (d *MonitorDay) countVerdict(record *MonitorRecord, verdict MonitorVerdict, delta int) {
+	for _, name := range record.FlaggedCategories {
+		stats := d.category(name)
+		switch verdict {
+		case MonitorVerdictConfirmed:
+			stats.Confirmed += delta
+		case MonitorVerdictFalsePositive:
+			stats.FalsePositives += delta
+		}
+	}
+}
+
+type MonitorStore interface {
+	AddRecord(record *MonitorRecord) error
+	GetRecord(id string) (*MonitorRecord, error)
+	// SetVerdict records whether a reviewer agrees with a monitored flag
+	SetVerdict(id string, verdict MonitorVerdict, reviewerID string) (*MonitorRecord, error)
+	// GetDays returns the aggregates of the days from from to to, skipping days without flags
+	GetDays(from, to time.Time) ([]*MonitorDay, error)
+}
+
+type monitorStore struct {
+	api plugin.API
+}
+
+func newMonitorStore(api plugin.API) *monitorStore {
+	return &monitorStore{
+		api: api,
+	}
+}
+
+func monitorDayKey(t time.Time) string {
+	return monitorDayKVKeyPrefix + t.UTC().Format(monitorDayLayout)
+}
+
+func (s *monitorStore) AddRecord(record *MonitorRecord) error {
+	if err := s.saveRecord(record); err != nil {
+		return err
+	}
+
+	return s.updateDay(time.UnixMilli(record.FlaggedAt), func(day *MonitorDay) {
+		day.Flagged++
+		for _, name := range record.FlaggedCategories {
+			day.category(name).Flagged++
+		}
+		if len(day.RecordIDs) < maxMonitorDayRecords {
+			day.RecordIDs = append(day.RecordIDs, record.ID)
+		}
+	})
+}
+
+func (s *monitorStore) GetRecord(id string) (*MonitorRecord, error) {
+	data, appErr := s.api.KVGet(monitorRecordKVKeyPrefix + id)
+	if appErr != nil {
+		return nil, errors.Wrap(appErr, "failed to get monitored flag")
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var record MonitorRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal monitored flag")
+	}
+	return &record, nil
+}
+
+func (s *monitorStore) SetVerdict(id string, verdict MonitorVerdict, reviewerID string) (*MonitorRecord, error) {
+	record, err := s.GetRecord(id)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, ErrMonitorRecordNotFound
+	}
+
+	previous := record.Verdict
+	if previous == verdict {
+		return record, nil
+	}
+	record.Verdict = verdict
+	record.ReviewedBy = reviewerID
+	record.ReviewedAt = model.GetMillis()
+	if err := s.saveRecord(record); err != nil {
+		return nil, err
+	}
+
+	if err := s.updateDay(time.UnixMilli(record.FlaggedAt), func(day *MonitorDay) {
+		day.countVerdict(record, previous, -1)
+		day.countVerdict(record, verdict, 1)
+	}); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (s *monitorStore) GetDays(from, to time.Time) ([]*MonitorDay, error) {
+	var days []*MonitorDay
+	for t := from.UTC(); !t.After(to.UTC()); t = t.AddDate(0, 0, 1) {
+		day, _, err := s.getDayWithData(monitorDayKey(t))
+		if err != nil {
+			return nil, err
+		}
+		if day != nil {
+			days = append(days, day)
+		}
+	}
+	return days, nil
+}
+
+func (s *monitorStore) saveRecord(record *MonitorRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal monitored flag")
+	}
+
+	if appErr := s.api.KVSetWithExpiry(monitorRecordKVKeyPrefix+record.ID, data, int64(monitorRetention/time.Second)); appErr != nil {
+		return errors.Wrap(appErr, "failed to store monitored flag")
+	}
+	return nil
+}
+
+// updateDay applies update to the aggregate of a day, retrying if it was changed concurrently
+func (s *monitorStore) updateDay(t time.Time, update func(day *MonitorDay)) error {
+	key := monitorDayKey(t)
+	for attempt := 0; attempt < maxMonitorUpdateAttempts; attempt++ {
+		day, oldData, err := s.getDayWithData(key)
+		if err != nil {
+			return err
+		}
+		if day == nil {
+			day = &MonitorDay{Date: t.UTC().Format(monitorDayLayout)}
+		}
+
+		update(day)
+
+		data, err := json.Marshal(day)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal monitored day")
+		}
+
+		saved, appErr := s.api.KVSetWithOptions(key, data, model.PluginKVSetOptions{
+			Atomic:          true,
+			OldValue:        oldData,
+			ExpireInSeconds: int64(monitorRetention / time.Second),
+		})
+		if appErr != nil {
+			return errors.Wrap(appErr, "failed to store monitored day")
+		}
+		if saved {
+			return nil
+		}
+	}
+	return errors.New("monitored day was changed concurrently too often")
+}
+
+func (s *monitorStore) getDayWithData(key string) (*MonitorDay, []byte, error) {
+	data, appErr := s.api.KVGet(key)
+	if appErr != nil {
+		return nil, nil, errors.Wrap(appErr, "failed to get monitored day")
+	}
+	if data == nil {
+		return nil, nil, nil
+	}
+
+	var day MonitorDay
+	if err := json.Unmarshal(data, &day); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to unmarshal monitored day")
+	}
+	return &day, data, nil
+}
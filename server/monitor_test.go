@@ -0,0 +1,179 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newMonitorTestAPI backs the KV calls of the monitor store with a map
+func newMonitorTestAPI() *plugintest.API {
+	return newKVTestAPI()
+}
+
+func newMonitorTestRecord(categories ...string) *MonitorRecord {
+	result := moderation.Result{"Hate": 0, "Violence": 0}
+	thresholds := map[string]int{"Hate": 4, "Violence": 4}
+	for _, category := range categories {
+		result[category] = 5
+	}
+	return &MonitorRecord{
+		ID:                model.NewId(),
+		PostID:            model.NewId(),
+		UserID:            "user1",
+		ChannelID:         "channel1",
+		Message:           "message",
+		Result:            result,
+		Thresholds:        thresholds,
+		FlaggedCategories: categories,
+		FlaggedAt:         model.GetMillis(),
+	}
+}
+
+func TestMonitorStore(t *testing.T) {
+	store := newMonitorStore(newMonitorTestAPI())
+
+	hate := newMonitorTestRecord("Hate")
+	both := newMonitorTestRecord("Hate", "Violence")
+	require.NoError(t, store.AddRecord(hate))
+	require.NoError(t, store.AddRecord(both))
+
+	_, err := store.SetVerdict(hate.ID, MonitorVerdictFalsePositive, "reviewer1")
+	require.NoError(t, err)
+	_, err = store.SetVerdict(both.ID, MonitorVerdictFalsePositive, "reviewer1")
+	require.NoError(t, err)
+	// Changing a verdict moves the flag from one count to the other
+	record, err := store.SetVerdict(both.ID, MonitorVerdictConfirmed, "reviewer2")
+	require.NoError(t, err)
+	assert.Equal(t, "reviewer2", record.ReviewedBy)
+
+	_, err = store.SetVerdict(model.NewId(), MonitorVerdictConfirmed, "reviewer1")
+	assert.ErrorIs(t, err, ErrMonitorRecordNotFound)
+
+	days, err := store.GetDays(time.Now().AddDate(0, 0, -1), time.Now())
+	require.NoError(t, err)
+	require.Len(t, days, 1)
+	assert.Equal(t, 2, days[0].Flagged)
+	assert.Equal(t, []string{hate.ID, both.ID}, days[0].RecordIDs)
+	assert.Equal(t, MonitorCategoryStats{Flagged: 2, Confirmed: 1, FalsePositives: 1}, *days[0].Categories["Hate"])
+	assert.Equal(t, MonitorCategoryStats{Flagged: 1, Confirmed: 1}, *days[0].Categories["Violence"])
+}
+
+func TestPlugin_monitorReport(t *testing.T) {
+	api := newMonitorTestAPI()
+	p := &Plugin{monitorStore: newMonitorStore(api)}
+	p.SetAPI(api)
+
+	records := []*MonitorRecord{
+		newMonitorTestRecord("Hate"),
+		newMonitorTestRecord("Hate"),
+		newMonitorTestRecord("Hate"),
+		newMonitorTestRecord("Violence"),
+	}
+	for _, record := range records {
+		require.NoError(t, p.monitorStore.AddRecord(record))
+	}
+	for _, id := range []string{records[0].ID, records[1].ID} {
+		_, err := p.monitorStore.SetVerdict(id, MonitorVerdictFalsePositive, "reviewer1")
+		require.NoError(t, err)
+	}
+
+	report, err := p.monitorReport(defaultMonitorReportDays)
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Flagged)
+	assert.Equal(t, 3, report.Categories["Hate"].Flagged)
+	assert.Equal(t, 2, report.Categories["Hate"].Reviewed)
+	assert.InDelta(t, 1.0, report.Categories["Hate"].FalsePositiveRate, 0.001)
+	assert.Equal(t, 0, report.Categories["Violence"].Reviewed)
+	assert.Zero(t, report.Categories["Violence"].FalsePositiveRate)
+
+	unreviewed, err := p.unreviewedMonitorRecords(defaultMonitorReportDays)
+	require.NoError(t, err)
+	require.Len(t, unreviewed, 2)
+	assert.Equal(t, records[3].ID, unreviewed[0].ID, "most recent flags come first")
+	assert.Equal(t, records[2].ID, unreviewed[1].ID)
+}
+
+func TestPostProcessor_enforcePolicy_monitor(t *testing.T) {
+	api := newMonitorTestAPI()
+	api.On("GetChannel", "channel1").Return(&model.Channel{Id: "channel1", TeamId: "team1", Type: model.ChannelTypeOpen}, nil)
+	store := newMonitorStore(api)
+	processor := &PostProcessor{
+		botID:        "bot123",
+		monitorStore: store,
+		postCache:    newPostCache(),
+	}
+
+	policy := effectivePolicy{
+		threshold: 4,
+		categoryThresholds: map[string]categoryThreshold{
+			"violence": {value: 2},
+			"sexual":   {disabled: true},
+		},
+		action: enforcementActionMonitor,
+	}
+	post := &model.Post{Id: "post1", UserId: "user1", ChannelId: "channel1", Message: "message"}
+	result := moderation.Result{"Hate": 3, "Violence": 2, "Sexual": 6}
+
+	record := plugin.MakeAuditRecord(auditEventTypeContentModeration, model.AuditStatusAttempt)
+	assert.True(t, processor.enforcePolicy(api, post, policy, result, record))
+	assert.Equal(t, string(enforcementActionMonitor), record.Meta[auditMetaKeyAction])
+	api.AssertNotCalled(t, "DeletePost", mock.Anything)
+	api.AssertNotCalled(t, "CreatePost", mock.Anything)
+
+	days, err := store.GetDays(time.Now(), time.Now())
+	require.NoError(t, err)
+	require.Len(t, days, 1)
+	require.Len(t, days[0].RecordIDs, 1)
+
+	stored, err := store.GetRecord(days[0].RecordIDs[0])
+	require.NoError(t, err)
+	assert.Equal(t, "post1", stored.PostID)
+	assert.Equal(t, "team1", stored.TeamID)
+	assert.Equal(t, map[string]int{"Hate": 4, "Violence": 2}, stored.Thresholds)
+	assert.Equal(t, []string{"Violence"}, stored.FlaggedCategories)
+}
+
+func TestPostProcessor_enforcePolicy_flag(t *testing.T) {
+	api := newMonitorTestAPI()
+	api.On("GetChannel", "channel1").Return(&model.Channel{Id: "channel1", Name: "town-square", TeamId: "team1", Type: model.ChannelTypeOpen}, nil)
+	api.On("GetUser", "user1").Return(&model.User{Id: "user1", Username: "someone"}, nil)
+	api.On("GetDirectChannel", "bot123", "reviewer1").Return(&model.Channel{Id: "dm_channel"}, nil)
+	var notification *model.Post
+	api.On("CreatePost", mock.Anything).Run(func(args mock.Arguments) {
+		notification = args.Get(0).(*model.Post)
+	}).Return(&model.Post{}, nil)
+
+	store := newMonitorStore(api)
+	processor := &PostProcessor{
+		botID:        "bot123",
+		monitorStore: store,
+		reviewers:    map[string]struct{}{"reviewer1": {}},
+		postCache:    newPostCache(),
+	}
+
+	policy := effectivePolicy{threshold: 4, action: enforcementActionFlag}
+	post := &model.Post{Id: "post1", UserId: "user1", ChannelId: "channel1", Message: "message"}
+	record := plugin.MakeAuditRecord(auditEventTypeContentModeration, model.AuditStatusAttempt)
+	assert.True(t, processor.enforcePolicy(api, post, policy, moderation.Result{"Hate": 5}, record))
+	api.AssertNotCalled(t, "DeletePost", mock.Anything)
+
+	days, err := store.GetDays(time.Now(), time.Now())
+	require.NoError(t, err)
+	require.Len(t, days, 1)
+	require.Len(t, days[0].RecordIDs, 1)
+	recordID := days[0].RecordIDs[0]
+	assert.Equal(t, recordID, record.Meta[auditMetaKeyMonitorRecordID])
+
+	require.NotNil(t, notification)
+	assert.Equal(t, "dm_channel", notification.ChannelId)
+	assert.Contains(t, notification.Message, "@someone in ~town-square")
+	assert.Contains(t, notification.Message, "/moderation monitor confirm "+recordID)
+}
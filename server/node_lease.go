@@ -0,0 +1,77 @@
+package main
+
+import (
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+const (
+	// nodeLeaseKVKeyPrefix is the prefix of the KV keys that show which nodes are
+	// running. A node renews its lease periodically and removes it when it stops,
+	// and the lease expires if the node goes away without removing it.
+	nodeLeaseKVKeyPrefix = "node_lease_"
+
+	nodeLeaseRenewInterval = 30 * time.Second
+	nodeLeaseTTL           = 3 * nodeLeaseRenewInterval
+)
+
+// nodeLease lets the nodes of a cluster find out which nodes are still running,
+// so the work of a node that went away can be taken over
+type nodeLease struct {
+	api    plugin.API
+	nodeID string
+	done   chan struct{}
+}
+
+func newNodeLease(api plugin.API) *nodeLease {
+	return &nodeLease{
+		api:    api,
+		nodeID: model.NewId(),
+		done:   make(chan struct{}),
+	}
+}
+
+func (l *nodeLease) start() {
+	l.renew()
+
+	go func() {
+		ticker := time.NewTicker(nodeLeaseRenewInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				l.renew()
+			case <-l.done:
+				return
+			}
+		}
+	}()
+}
+
+func (l *nodeLease) stop() {
+	close(l.done)
+	if appErr := l.api.KVDelete(nodeLeaseKVKeyPrefix + l.nodeID); appErr != nil {
+		l.api.LogWarn("Failed to remove node lease", "err", appErr)
+	}
+}
+
+func (l *nodeLease) renew() {
+	if appErr := l.api.KVSetWithExpiry(nodeLeaseKVKeyPrefix+l.nodeID, []byte{1}, int64(nodeLeaseTTL/time.Second)); appErr != nil {
+		l.api.LogWarn("Failed to renew node lease", "err", appErr)
+	}
+}
+
+// isNodeRunning returns true if a node, including this one, holds a lease
+func (l *nodeLease) isNodeRunning(nodeID string) (bool, error) {
+	if nodeID == l.nodeID {
+		return true, nil
+	}
+	data, appErr := l.api.KVGet(nodeLeaseKVKeyPrefix + nodeID)
+	if appErr != nil {
+		return false, errors.Wrap(appErr, "failed to get node lease")
+	}
+	return data != nil, nil
+}
@@ -7,6 +7,11 @@ import (
 	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
 	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation/agents"
 	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation/azure"
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation/blocklist"
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation/composite"
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation/failover"
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation/openai"
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation/retry"
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/plugin"
 	"github.com/mattermost/mattermost/server/public/pluginapi"
@@ -19,9 +24,28 @@ type Plugin struct {
 	configurationLock sync.RWMutex
 	configuration     *configuration
 
+	// initializeErr is the error of the last initialization, reported by the health endpoint
+	initializeLock sync.RWMutex
+	initializeErr  error
+
 	postProcessor        *PostProcessor
 	moderationProcessor  *ModerationProcessor
 	excludedChannelStore ExcludedChannelsStore
+	policiesStore        PoliciesStore
+	reviewStore          ReviewStore
+	appealsStore         AppealsStore
+	strikesStore         StrikesStore
+	blocklistStore       BlocklistStore
+	blocklistModerator   *blocklist.Moderator
+	queueJournal         QueueJournal
+	nodeLease            *nodeLease
+	monitorStore         MonitorStore
+	scanJobsStore        ScanJobsStore
+	scanJobRunner        *scanJobRunner
+	statsStore           StatsStore
+	stats                *statsCollector
+	metrics              *metrics
+	cluster              *clusterCoordinator
 }
 
 func (p *Plugin) OnActivate() error {
@@ -41,25 +65,112 @@ func (p *Plugin) OnActivate() error {
 		return err
 	}
 
+	p.policiesStore, err = newPoliciesStore(p.API)
+	if err != nil {
+		p.API.LogError("Failed to create moderation policies store", "err", err)
+		return err
+	}
+
+	p.reviewStore, err = newReviewStore(p.API)
+	if err != nil {
+		p.API.LogError("Failed to create review store", "err", err)
+		return err
+	}
+
+	p.appealsStore = newAppealsStore(p.API)
+	p.monitorStore = newMonitorStore(p.API)
+	p.scanJobsStore = newScanJobsStore(p.API)
+	p.statsStore = newStatsStore(p.API)
+
+	p.strikesStore, err = newStrikesStore(p.API)
+	if err != nil {
+		p.API.LogError("Failed to create strikes store", "err", err)
+		return err
+	}
+
+	p.blocklistStore, err = newBlocklistStore(p.API)
+	if err != nil {
+		p.API.LogError("Failed to create blocklist store", "err", err)
+		return err
+	}
+
+	p.blocklistModerator, err = blocklist.New(p.blocklistStore.ListEntries())
+	if err != nil {
+		p.API.LogError("Failed to create blocklist moderator", "err", err)
+		return err
+	}
+
+	p.nodeLease = newNodeLease(p.API)
+	p.nodeLease.start()
+	p.queueJournal = newQueueJournal(p.API, p.nodeLease.nodeID)
+
+	p.cluster = newClusterCoordinator(p.API)
+	p.cluster.start()
+
+	p.stats = newStatsCollector(p.API, p.statsStore)
+	p.stats.start()
+	p.metrics = newMetrics(p.moderationQueueLength, p.postQueueLength)
+
 	if err := p.registerSlashCommands(); err != nil {
 		p.API.LogError("Failed to register slash commands", "err", err)
 		return err
 	}
 
+	p.scanJobRunner = newScanJobRunner(p.API, p.scanJobsStore, p.scanProcessors)
+
 	config := p.getConfiguration()
-	if err := p.initialize(config); err != nil {
+	err = p.initialize(config)
+	p.setInitializeError(err)
+	if err != nil {
 		p.API.LogError("Cannot initialize plugin", "err", err)
 		return nil
 	}
 
+	p.scanJobRunner.start()
+
+	return nil
+}
+
+// OnDeactivate stops the scan jobs and processors, stores the remaining statistics,
+// leaves the cluster and releases the lease of this node, so its queued posts can
+// be resumed by the other nodes
+func (p *Plugin) OnDeactivate() error {
+	if p.scanJobRunner != nil {
+		p.scanJobRunner.stop()
+		p.scanJobRunner = nil
+	}
+	if p.postProcessor != nil {
+		p.postProcessor.stop()
+		p.postProcessor = nil
+	}
+	if p.moderationProcessor != nil {
+		p.moderationProcessor.stop()
+		p.moderationProcessor = nil
+	}
+	if p.stats != nil {
+		p.stats.stop()
+		p.stats = nil
+	}
+	if p.cluster != nil {
+		p.cluster.stop()
+		p.cluster = nil
+	}
+	if p.nodeLease != nil {
+		p.nodeLease.stop()
+		p.nodeLease = nil
+	}
 	return nil
 }
 
 func (p *Plugin) initialize(config *configuration) error {
+	// The previous post processor finishes the post it is processing before its
+	// remaining posts are resumed
+	previousPostProcessor := p.postProcessor
 	if p.postProcessor != nil {
 		p.postProcessor.stop()
 		p.postProcessor = nil
 	}
+	journaledBefore := model.GetMillis()
 
 	if p.moderationProcessor != nil {
 		p.moderationProcessor.stop()
@@ -85,40 +196,90 @@ func (p *Plugin) initialize(config *configuration) error {
 	// we use the bot ID instead of user ID to ensure consistent access control.
 	// The bot account only needs to be granted agent access once, rather than
 	// requiring every user whose content is moderated to have agent permissions.
-	moderator, err := initModerator(p.API, config, pluginBotID)
+	moderator, err := initModerator(p.API, config, pluginBotID, p.blocklistModerator, p.policiesStore, p.metrics)
 	if err != nil {
 		return errors.Wrap(err, "failed to initialize moderator")
 	}
 
+	if config.ModerateImages && !moderation.SupportsImages(moderator) {
+		p.API.LogWarn("Image moderation is enabled, but the moderation provider can't check images",
+			"moderator", config.ModeratorConfig.Type)
+	}
+
 	thresholdValue, err := config.ThresholdValue()
 	if err != nil {
 		return errors.Wrap(err, "failed to load moderation threshold")
 	}
 
+	categoryThresholds, err := config.CategoryThresholdValues()
+	if err != nil {
+		return errors.Wrap(err, "failed to load category moderation thresholds")
+	}
+
+	action, err := config.EnforcementActionValue()
+	if err != nil {
+		return errors.Wrap(err, "failed to load enforcement action")
+	}
+
 	moderationResultsCache := newModerationResultsCache()
-	rateLimitPerMinute := config.RateLimitValue()
-	moderationProcessor, err := newModerationProcessor(moderationResultsCache, moderator, thresholdValue, rateLimitPerMinute)
+	moderationProcessor, err := newModerationProcessor(
+		moderationResultsCache, moderator, thresholdValue, categoryThresholds,
+		config.RateLimitValue(), config.RateLimitBurstValue(), config.ModerationWorkersValue(), p.cluster, p.stats, p.metrics)
 	if err != nil {
 		return errors.Wrap(err, "failed to create post moderation processor")
 	}
+
+	// Rate limited and transient provider errors are retried within the moderation timeout
+	if err := moderationProcessor.retryFailedRequests(retry.Settings{
+		MaxAttempts: moderationRetryAttempts,
+		BaseDelay:   moderationRetryBaseDelay,
+		MaxDelay:    moderationRetryMaxDelay,
+	}); err != nil {
+		return errors.Wrap(err, "failed to initialize moderator retries")
+	}
 	p.moderationProcessor = moderationProcessor
 	p.moderationProcessor.start(p.API)
 
+	// Appeals are only offered when there is a channel to route them to
+	var appealsStore AppealsStore
+	if config.AppealsChannelID != "" {
+		appealsStore = p.appealsStore
+	}
+
+	var strikesStore StrikesStore
+	if config.StrikesEnabled {
+		strikesStore = p.strikesStore
+	}
+
 	postCache := newPostCache()
 	processor, err := newPostProcessor(
 		pluginBotID, config.AuditLoggingEnabled, moderationResultsCache,
 		postCache, excludedUsers, p.excludedChannelStore,
-		config.ExcludeDirectMessages, config.ExcludePrivateChannels)
+		config.ExcludeDirectMessages, config.ExcludePrivateChannels,
+		effectivePolicy{
+			threshold:          thresholdValue,
+			categoryThresholds: categoryThresholds,
+			action:             action,
+		},
+		p.policiesStore, p.reviewStore, config.ReviewerSet(), appealsStore,
+		strikesStore, config.StrikePolicyValue(), p.monitorStore, config.ModerateAttachments, config.ModerateImages, moderationProcessor, config.ModerateChannels, config.ModerateProfiles,
+		p.queueJournal, p.stats, p.metrics)
 	if err != nil {
 		return errors.Wrap(err, "failed to create post processor")
 	}
 	p.postProcessor = processor
 	p.postProcessor.start(p.API)
 
+	go p.resumeQueuedPosts(moderationProcessor, processor, previousPostProcessor, journaledBefore, config.CatchUpAge())
+
 	return nil
 }
 
-func initModerator(api plugin.API, config *configuration, pluginBotID string) (moderation.Moderator, error) {
+func initModerator(api plugin.API, config *configuration, pluginBotID string, blocklistModerator *blocklist.Moderator, policiesStore PoliciesStore, metrics *metrics) (moderation.Moderator, error) {
+	if config.ModeratorConfig.FallbackType != "" {
+		return initFailoverModerator(api, config, pluginBotID, blocklistModerator, policiesStore, metrics)
+	}
+
 	switch config.ModeratorConfig.Type {
 	case "azure":
 		azureConfig := &moderation.Config{
@@ -132,7 +293,7 @@ func initModerator(api plugin.API, config *configuration, pluginBotID string) (m
 		}
 
 		api.LogInfo("Azure AI Content Safety moderator initialized")
-		return mod, nil
+		return instrumentModerator(mod, "azure", metrics), nil
 	case "agents":
 		mod, err := agents.New(api, config.ModeratorConfig.AgentsSystemPrompt, pluginBotID, config.ModeratorConfig.AgentsBotUsername)
 		if err != nil {
@@ -140,8 +301,143 @@ func initModerator(api plugin.API, config *configuration, pluginBotID string) (m
 		}
 
 		api.LogInfo("Agents plugin moderator initialized")
-		return mod, nil
+		return instrumentModerator(mod, "agents", metrics), nil
+	case "openai":
+		openaiConfig := &moderation.Config{
+			Endpoint: config.ModeratorConfig.OpenAIEndpoint,
+			APIKey:   config.ModeratorConfig.OpenAIAPIKey,
+		}
+
+		mod, err := openai.New(openaiConfig, config.ModeratorConfig.OpenAIModel)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create OpenAI moderator")
+		}
+
+		api.LogInfo("OpenAI moderator initialized")
+		return instrumentModerator(mod, "openai", metrics), nil
+	case "blocklist":
+		if blocklistModerator == nil {
+			return nil, errors.New("blocklist is not available")
+		}
+
+		api.LogInfo("Blocklist moderator initialized")
+		return instrumentModerator(blocklistModerator, "blocklist", metrics), nil
+	case "composite":
+		return initCompositeModerator(api, config, pluginBotID, blocklistModerator, policiesStore, metrics)
 	default:
 		return nil, errors.Errorf("unknown moderator type: %s", config.ModeratorConfig.Type)
 	}
 }
+
+// initCompositeModerator creates the moderators chained by the composite moderator,
+// using the settings of each moderator type
+func initCompositeModerator(api plugin.API, config *configuration, pluginBotID string, blocklistModerator *blocklist.Moderator, policiesStore PoliciesStore, metrics *metrics) (moderation.Moderator, error) {
+	threshold, err := config.ThresholdValue()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load composite moderation threshold")
+	}
+	categoryThresholds, err := config.CategoryThresholdValues()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load composite category thresholds")
+	}
+	margin, err := config.CompositeMarginValue()
+	if err != nil {
+		return nil, err
+	}
+
+	// Team and channel policies can change while the moderator is in use
+	thresholds := func() composite.Thresholds {
+		if policiesStore == nil {
+			return compositeThresholds(threshold, categoryThresholds, nil)
+		}
+		policies, listErr := policiesStore.ListPolicies()
+		if listErr != nil {
+			api.LogWarn("Failed to list moderation policies for composite moderator", "err", listErr)
+		}
+		return compositeThresholds(threshold, categoryThresholds, policies)
+	}
+
+	var stages []composite.Stage
+	for _, moderatorType := range config.CompositeModeratorTypes() {
+		if moderatorType == "composite" {
+			return nil, errors.New("composite moderators can't be nested")
+		}
+
+		stageConfig := config.Clone()
+		stageConfig.ModeratorConfig.Type = moderatorType
+		stageConfig.ModeratorConfig.FallbackType = ""
+		mod, err := initModerator(api, stageConfig, pluginBotID, blocklistModerator, policiesStore, metrics)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create %s moderator for composite moderator", moderatorType)
+		}
+		stages = append(stages, composite.Stage{Name: moderatorType, Moderator: mod})
+	}
+
+	mod, err := composite.New(stages, composite.Strategy(config.ModeratorConfig.CompositeStrategy), thresholds, margin)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create composite moderator")
+	}
+
+	api.LogInfo("Composite moderator initialized", "moderators", config.ModeratorConfig.CompositeModerators, "strategy", config.ModeratorConfig.CompositeStrategy)
+	return mod, nil
+}
+
+// initFailoverModerator wraps the configured moderator so requests fail over to the
+// fallback moderator when it fails or is unavailable
+func initFailoverModerator(api plugin.API, config *configuration, pluginBotID string, blocklistModerator *blocklist.Moderator, policiesStore PoliciesStore, metrics *metrics) (moderation.Moderator, error) {
+	primaryType := config.ModeratorConfig.Type
+	fallbackType := config.ModeratorConfig.FallbackType
+	if fallbackType == primaryType {
+		return nil, errors.New("fallback moderator must differ from the primary moderator")
+	}
+
+	primaryConfig := config.Clone()
+	primaryConfig.ModeratorConfig.FallbackType = ""
+	primary, err := initModerator(api, primaryConfig, pluginBotID, blocklistModerator, policiesStore, metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	fallbackConfig := config.Clone()
+	fallbackConfig.ModeratorConfig.Type = fallbackType
+	fallbackConfig.ModeratorConfig.FallbackType = ""
+	fallback, err := initModerator(api, fallbackConfig, pluginBotID, blocklistModerator, policiesStore, metrics)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create %s fallback moderator", fallbackType)
+	}
+
+	settings := config.FailoverSettingsValue()
+	settings.OnStateChange = func(from, to failover.State) {
+		switch to {
+		case failover.StateOpen:
+			api.LogWarn("Moderation provider is failing, sending posts to the fallback provider",
+				"moderator", primaryType, "fallback", fallbackType, "probe_interval", settings.ProbeInterval.String())
+		case failover.StateClosed:
+			api.LogInfo("Moderation provider recovered", "moderator", primaryType)
+		}
+	}
+
+	mod, err := failover.New(primary, fallback, settings)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create failover moderator")
+	}
+
+	api.LogInfo("Fallback moderator initialized", "moderator", primaryType, "fallback", fallbackType)
+	return mod, nil
+}
+
+// moderationQueueLength returns the number of messages waiting for the moderation provider
+func (p *Plugin) moderationQueueLength() int {
+	if moderationProcessor := p.moderationProcessor; moderationProcessor != nil {
+		return moderationProcessor.queue.len()
+	}
+	return 0
+}
+
+// postQueueLength returns the number of posts waiting for their moderation result
+func (p *Plugin) postQueueLength() int {
+	if postProcessor := p.postProcessor; postProcessor != nil {
+		return len(postProcessor.postsCh)
+	}
+	return 0
+}
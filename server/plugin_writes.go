@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// pluginPostWrites tracks the posts the plugin is creating or updating on behalf of
+// their authors, e.g. to hide, restore or reinstate them. Writes through the plugin
+// API run the hooks of this plugin as well, on the same server, and these must not
+// be rejected like posts of the authors themselves.
+var pluginPostWrites = newPostWrites()
+
+type postWrites struct {
+	lock sync.Mutex
+	keys map[string]int
+}
+
+func newPostWrites() *postWrites {
+	return &postWrites{
+		keys: make(map[string]int),
+	}
+}
+
+// beginCreate marks a post the plugin is about to create until the returned function is called
+func (w *postWrites) beginCreate(post *model.Post) func() {
+	return w.begin(createdPostKey(post))
+}
+
+// beginUpdate marks a post the plugin is about to update until the returned function is called
+func (w *postWrites) beginUpdate(post *model.Post) func() {
+	return w.begin(updatedPostKey(post))
+}
+
+func (w *postWrites) isCreating(post *model.Post) bool {
+	return w.has(createdPostKey(post))
+}
+
+func (w *postWrites) isUpdating(post *model.Post) bool {
+	return w.has(updatedPostKey(post))
+}
+
+func (w *postWrites) begin(key string) func() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.keys[key]++
+
+	return func() {
+		w.lock.Lock()
+		defer w.lock.Unlock()
+		if w.keys[key]--; w.keys[key] <= 0 {
+			delete(w.keys, key)
+		}
+	}
+}
+
+func (w *postWrites) has(key string) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.keys[key] > 0
+}
+
+// createdPostKey identifies a post that is created, which has no ID yet. The plugin
+// only creates posts of users with the creation time of an earlier post.
+func createdPostKey(post *model.Post) string {
+	return "create:" + post.UserId + ":" + post.ChannelId + ":" + strconv.FormatInt(post.CreateAt, 10)
+}
+
+func updatedPostKey(post *model.Post) string {
+	return "update:" + post.Id
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+const moderationPoliciesKVKey = "moderation_policies"
+
+type PolicyScope string
+
+const (
+	PolicyScopeTeam    PolicyScope = "team"
+	PolicyScopeChannel PolicyScope = "channel"
+)
+
+// ModerationPolicy overrides the global moderation configuration for a team or channel.
+// Empty fields are inherited from the enclosing scope.
+type ModerationPolicy struct {
+	Threshold          string            `json:"threshold,omitempty"`
+	CategoryThresholds map[string]string `json:"category_thresholds,omitempty"`
+	Action             string            `json:"action,omitempty"`
+}
+
+func (mp *ModerationPolicy) validate() error {
+	if mp.Threshold != "" {
+		if _, err := strconv.Atoi(mp.Threshold); err != nil {
+			return errors.Wrapf(err, "could not parse threshold value: '%s'", mp.Threshold)
+		}
+	}
+	if _, err := parseCategoryThresholds(mp.CategoryThresholds); err != nil {
+		return err
+	}
+	if mp.Action != "" && !isValidEnforcementAction(mp.Action) {
+		return errors.Errorf("unknown enforcement action: %s", mp.Action)
+	}
+	return nil
+}
+
+// applyTo overrides the fields of the effective policy that are set in this policy
+func (mp *ModerationPolicy) applyTo(effective *effectivePolicy) error {
+	if mp.Threshold != "" {
+		val, err := strconv.Atoi(mp.Threshold)
+		if err != nil {
+			return errors.Wrapf(err, "could not parse threshold value: '%s'", mp.Threshold)
+		}
+		effective.threshold = val
+		effective.thresholdsOverridden = true
+	}
+
+	categoryThresholds, err := parseCategoryThresholds(mp.CategoryThresholds)
+	if err != nil {
+		return err
+	}
+	if len(categoryThresholds) > 0 {
+		merged := make(map[string]categoryThreshold, len(effective.categoryThresholds)+len(categoryThresholds))
+		for category, threshold := range effective.categoryThresholds {
+			merged[category] = threshold
+		}
+		for category, threshold := range categoryThresholds {
+			merged[category] = threshold
+		}
+		effective.categoryThresholds = merged
+		effective.thresholdsOverridden = true
+	}
+
+	if mp.Action != "" {
+		effective.action = enforcementAction(mp.Action)
+	}
+	return nil
+}
+
+type PoliciesStore interface {
+	GetPolicy(scope PolicyScope, id string) (*ModerationPolicy, error)
+	SetPolicy(scope PolicyScope, id string, policy *ModerationPolicy) error
+	DeletePolicy(scope PolicyScope, id string) error
+	// ListPolicies returns the policies of all teams and channels
+	ListPolicies() ([]*ModerationPolicy, error)
+	// Reload replaces the cached policies with the stored ones, after another node changed them
+	Reload() error
+}
+
+type policiesStore struct {
+	cacheLock sync.Mutex
+	cache     map[string]*ModerationPolicy
+	loaded    bool
+	api       plugin.API
+}
+
+func newPoliciesStore(api plugin.API) (*policiesStore, error) {
+	s := &policiesStore{
+		cache: make(map[string]*ModerationPolicy),
+		api:   api,
+	}
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+	if err := s.loadCacheWithoutLock(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func policyKey(scope PolicyScope, id string) string {
+	return string(scope) + ":" + id
+}
+
+func (s *policiesStore) GetPolicy(scope PolicyScope, id string) (*ModerationPolicy, error) {
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+	return s.cache[policyKey(scope, id)], nil
+}
+
+func (s *policiesStore) SetPolicy(scope PolicyScope, id string, policy *ModerationPolicy) error {
+	if err := policy.validate(); err != nil {
+		return err
+	}
+
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+
+	s.cache[policyKey(scope, id)] = policy
+	return s.saveCacheWithoutLock()
+}
+
+func (s *policiesStore) DeletePolicy(scope PolicyScope, id string) error {
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+
+	delete(s.cache, policyKey(scope, id))
+	return s.saveCacheWithoutLock()
+}
+
+func (s *policiesStore) ListPolicies() ([]*ModerationPolicy, error) {
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+
+	policies := make([]*ModerationPolicy, 0, len(s.cache))
+	for _, policy := range s.cache {
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+func (s *policiesStore) Reload() error {
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+
+	previous := s.cache
+	s.cache = make(map[string]*ModerationPolicy)
+	s.loaded = false
+	if err := s.loadCacheWithoutLock(); err != nil {
+		s.cache = previous
+		s.loaded = true
+		return err
+	}
+	return nil
+}
+
+func (s *policiesStore) loadCacheWithoutLock() error {
+	if s.loaded {
+		return nil
+	}
+
+	var appErr *model.AppError
+	data, appErr := s.api.KVGet(moderationPoliciesKVKey)
+	if appErr != nil {
+		return appErr
+	}
+	if data == nil {
+		s.loaded = true
+		return nil
+	}
+
+	var policies map[string]*ModerationPolicy
+	if err := json.Unmarshal(data, &policies); err != nil {
+		return err
+	}
+
+	for key, policy := range policies {
+		if !strings.Contains(key, ":") || policy == nil {
+			continue
+		}
+		s.cache[key] = policy
+	}
+
+	s.loaded = true
+	return nil
+}
+
+func (s *policiesStore) saveCacheWithoutLock() error {
+	data, err := json.Marshal(s.cache)
+	if err != nil {
+		return err
+	}
+
+	if appErr := s.api.KVSet(moderationPoliciesKVKey, data); appErr != nil {
+		return appErr
+	}
+
+	return nil
+}
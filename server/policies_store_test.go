@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModerationPolicy_validate(t *testing.T) {
+	t.Run("accepts empty policy", func(t *testing.T) {
+		policy := &ModerationPolicy{}
+		assert.NoError(t, policy.validate())
+	})
+
+	t.Run("accepts complete policy", func(t *testing.T) {
+		policy := &ModerationPolicy{
+			Threshold:          "4",
+			CategoryThresholds: map[string]string{"Hate": "2", "Violence": "disabled"},
+			Action:             "hide",
+		}
+		assert.NoError(t, policy.validate())
+	})
+
+	t.Run("rejects invalid threshold", func(t *testing.T) {
+		policy := &ModerationPolicy{Threshold: "high"}
+		assert.Error(t, policy.validate())
+	})
+
+	t.Run("rejects invalid category threshold", func(t *testing.T) {
+		policy := &ModerationPolicy{CategoryThresholds: map[string]string{"Hate": "low"}}
+		assert.Error(t, policy.validate())
+	})
+
+	t.Run("rejects unknown action", func(t *testing.T) {
+		policy := &ModerationPolicy{Action: "ban"}
+		assert.Error(t, policy.validate())
+	})
+}
+
+func TestPoliciesStore(t *testing.T) {
+	t.Run("loads policies from the KV store", func(t *testing.T) {
+		data, err := json.Marshal(map[string]*ModerationPolicy{
+			"channel:channel123": {Action: "flag"},
+		})
+		require.NoError(t, err)
+
+		api := &plugintest.API{}
+		api.On("KVGet", moderationPoliciesKVKey).Return(data, nil)
+
+		store, err := newPoliciesStore(api)
+		require.NoError(t, err)
+
+		policy, err := store.GetPolicy(PolicyScopeChannel, "channel123")
+		require.NoError(t, err)
+		assert.Equal(t, "flag", policy.Action)
+
+		policy, err = store.GetPolicy(PolicyScopeTeam, "channel123")
+		require.NoError(t, err)
+		assert.Nil(t, policy)
+		api.AssertExpectations(t)
+	})
+
+	t.Run("saves and deletes policies", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("KVGet", moderationPoliciesKVKey).Return(nil, nil)
+		api.On("KVSet", moderationPoliciesKVKey, mock.Anything).Return(nil)
+
+		store, err := newPoliciesStore(api)
+		require.NoError(t, err)
+
+		require.NoError(t, store.SetPolicy(PolicyScopeTeam, "team123", &ModerationPolicy{Threshold: "6"}))
+		policy, err := store.GetPolicy(PolicyScopeTeam, "team123")
+		require.NoError(t, err)
+		assert.Equal(t, "6", policy.Threshold)
+
+		require.NoError(t, store.DeletePolicy(PolicyScopeTeam, "team123"))
+		policy, err = store.GetPolicy(PolicyScopeTeam, "team123")
+		require.NoError(t, err)
+		assert.Nil(t, policy)
+		api.AssertNumberOfCalls(t, "KVSet", 2)
+	})
+
+	t.Run("rejects invalid policy", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("KVGet", moderationPoliciesKVKey).Return(nil, nil)
+
+		store, err := newPoliciesStore(api)
+		require.NoError(t, err)
+
+		err = store.SetPolicy(PolicyScopeChannel, "channel123", &ModerationPolicy{Action: "ban"})
+		assert.Error(t, err)
+		api.AssertNotCalled(t, "KVSet", mock.Anything, mock.Anything)
+	})
+}
+
+func TestPostProcessor_resolvePolicy(t *testing.T) {
+	newProcessor := func(store PoliciesStore) *PostProcessor {
+		return &PostProcessor{
+			defaultPolicy: effectivePolicy{
+				threshold: 4,
+				categoryThresholds: map[string]categoryThreshold{
+					"sexual": {value: 2},
+				},
+				action: enforcementActionDelete,
+			},
+			policiesStore: store,
+		}
+	}
+
+	newAPI := func() *plugintest.API {
+		api := &plugintest.API{}
+		api.On("GetChannel", "channel123").Return(&model.Channel{
+			Id:     "channel123",
+			TeamId: "team123",
+			Type:   model.ChannelTypeOpen,
+		}, nil)
+		return api
+	}
+
+	t.Run("uses global configuration without policies", func(t *testing.T) {
+		api := newAPI()
+		processor := newProcessor(NewMockPoliciesStore())
+
+		policy := processor.resolvePolicy(api, "channel123")
+		assert.Equal(t, 4, policy.threshold)
+		assert.Equal(t, enforcementActionDelete, policy.enforcementAction())
+		assert.False(t, policy.thresholdsOverridden)
+	})
+
+	t.Run("channel policy overrides team policy", func(t *testing.T) {
+		api := newAPI()
+		store := NewMockPoliciesStore()
+		store.policies[policyKey(PolicyScopeTeam, "team123")] = &ModerationPolicy{
+			Threshold: "6",
+			Action:    "hide",
+		}
+		store.policies[policyKey(PolicyScopeChannel, "channel123")] = &ModerationPolicy{
+			CategoryThresholds: map[string]string{"Hate": "2"},
+			Action:             "flag",
+		}
+		processor := newProcessor(store)
+
+		policy := processor.resolvePolicy(api, "channel123")
+		assert.Equal(t, 6, policy.threshold)
+		assert.Equal(t, enforcementActionFlag, policy.enforcementAction())
+		assert.True(t, policy.thresholdsOverridden)
+		assert.Equal(t, map[string]categoryThreshold{
+			"sexual": {value: 2},
+			"hate":   {value: 2},
+		}, policy.categoryThresholds)
+	})
+
+	t.Run("overridden thresholds re-evaluate results", func(t *testing.T) {
+		api := newAPI()
+		store := NewMockPoliciesStore()
+		store.policies[policyKey(PolicyScopeChannel, "channel123")] = &ModerationPolicy{
+			CategoryThresholds: map[string]string{"Violence": "disabled"},
+		}
+		processor := newProcessor(store)
+
+		policy := processor.resolvePolicy(api, "channel123")
+		flagged := policy.isFlagged(&moderationResult{
+			code:   moderationResultFlagged,
+			result: map[string]int{"Violence": 6, "Hate": 2},
+		})
+		assert.False(t, flagged)
+	})
+}
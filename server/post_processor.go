@@ -3,9 +3,11 @@ package main
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/plugin"
 	"github.com/pkg/errors"
@@ -14,8 +16,16 @@ import (
 const channelCacheTTL = 1 * time.Minute
 
 const (
-	channelNotificationTemplate = "_A post with potentially offensive content was flagged and removed._"
-	dmNotificationTemplate      = "_Your post with the following content was flagged and removed:_\n\n%s"
+	channelNotificationTemplate       = "_A post with potentially offensive content was flagged and removed._"
+	dmNotificationTemplate            = "_Your post with the following content was flagged and removed:_\n\n%s"
+	hiddenChannelNotificationTemplate = "_A post with potentially offensive content was flagged and hidden._"
+	hiddenDMNotificationTemplate      = "_Your post with the following content was flagged and hidden:_\n\n%s"
+	reviewChannelNotificationTemplate = "_A post with potentially offensive content was flagged and is hidden until a moderator reviews it._"
+	reviewDMNotificationTemplate      = "_Your post with the following content was flagged and is hidden until a moderator reviews it:_\n\n%s"
+	reviewerNotificationTemplate      = "A post by @%s in ~%s was flagged by content moderation and is waiting for review:\n\n%s\n\n" +
+		"Use `/moderation review approve %s` to restore it or `/moderation review remove %s` to delete it."
+	flaggedReviewerNotificationTemplate = "A post by @%s in ~%s was flagged by content moderation and left in place:\n\n%s"
+	flaggedReviewerVerdictTemplate      = "\n\nUse `/moderation monitor confirm %s` or `/moderation monitor false-positive %s` to record whether it should have been flagged."
 )
 
 const (
@@ -35,18 +45,82 @@ type PostProcessor struct {
 	excludedUsers          map[string]struct{}
 	excludedChannelStore   ExcludedChannelsStore
 	channelInfoCache       sync.Map // channel ID -> channelInfoCacheEntry
+	channelSizeCache       sync.Map // channel ID -> channelSizeCacheEntry
+	guestCache             sync.Map // user ID -> guestCacheEntry
 	excludeDirectMessages  bool
 	excludePrivateChannels bool
 
+	defaultPolicy effectivePolicy
+	policiesStore PoliciesStore
+
+	reviewStore ReviewStore
+	reviewers   map[string]struct{}
+
+	appealsStore AppealsStore
+
+	strikesStore StrikesStore
+	strikePolicy strikePolicy
+
+	monitorStore MonitorStore
+
+	// moderateAttachments and moderateImages enable moderating the text of
+	// attachments and images, which are queued on the moderation processor
+	moderateAttachments bool
+	moderateImages      bool
+	moderationProcessor *ModerationProcessor
+
+	// moderateChannels enables moderating the display name, header and purpose of channels
+	moderateChannels bool
+
+	// moderateProfiles enables moderating user profiles, profileFingerprints
+	// keeps what was last moderated of each profile, profileReports the flagged
+	// values that were reported and profileFailures how often moderating each
+	// profile failed
+	moderateProfiles    bool
+	profileFingerprints sync.Map // user ID -> profile fingerprint
+	profileReports      sync.Map // profileReportKey -> struct{}
+	profileFailuresLock sync.Mutex
+	profileFailures     map[string]int // user ID -> failed attempts
+
+	// journal keeps queued posts so they can be resumed after a restart
+	journal QueueJournal
+
+	// stats counts moderation activity for the daily statistics, and metrics
+	// exports it to Prometheus
+	stats   *statsCollector
+	metrics *metrics
+
 	resultsCache  *moderationResultsCache
 	postCache     *postCache
 	postsCh       chan *model.Post
 	done          chan struct{}
 	cleanupTicker *time.Ticker
+
+	// attachedKeys are the keys of the attached content queued for moderation
+	// along with each post in postsCh, so processing a post only waits for them
+	attachedKeysLock sync.Mutex
+	attachedKeys     map[*model.Post][]string
+
+	// running is done once the processor stopped and finished the post it was processing
+	running sync.WaitGroup
 }
 
 type channelInfoCacheEntry struct {
 	channelType  model.ChannelType
+	teamID       string
+	creationTime time.Time
+}
+
+// channelSizeCacheEntry is the member count of a public channel, which determines
+// the priority of its posts
+type channelSizeCacheEntry struct {
+	memberCount  int64
+	creationTime time.Time
+}
+
+// guestCacheEntry is whether a user is a guest, which determines the priority of their posts
+type guestCacheEntry struct {
+	guest        bool
 	creationTime time.Time
 }
 
@@ -59,6 +133,22 @@ func newPostProcessor(
 	excludedChannelStore ExcludedChannelsStore,
 	excludeDirectMessages bool,
 	excludePrivateChannels bool,
+	defaultPolicy effectivePolicy,
+	policiesStore PoliciesStore,
+	reviewStore ReviewStore,
+	reviewers map[string]struct{},
+	appealsStore AppealsStore,
+	strikesStore StrikesStore,
+	strikePolicy strikePolicy,
+	monitorStore MonitorStore,
+	moderateAttachments bool,
+	moderateImages bool,
+	moderationProcessor *ModerationProcessor,
+	moderateChannels bool,
+	moderateProfiles bool,
+	journal QueueJournal,
+	stats *statsCollector,
+	metrics *metrics,
 ) (*PostProcessor, error) {
 	return &PostProcessor{
 		botID:                  botID,
@@ -69,6 +159,22 @@ func newPostProcessor(
 		excludedChannelStore:   excludedChannelStore,
 		excludeDirectMessages:  excludeDirectMessages,
 		excludePrivateChannels: excludePrivateChannels,
+		defaultPolicy:          defaultPolicy,
+		policiesStore:          policiesStore,
+		reviewStore:            reviewStore,
+		reviewers:              reviewers,
+		appealsStore:           appealsStore,
+		strikesStore:           strikesStore,
+		strikePolicy:           strikePolicy,
+		monitorStore:           monitorStore,
+		moderateAttachments:    moderateAttachments,
+		moderateImages:         moderateImages,
+		moderationProcessor:    moderationProcessor,
+		moderateChannels:       moderateChannels,
+		moderateProfiles:       moderateProfiles,
+		journal:                journal,
+		stats:                  stats,
+		metrics:                metrics,
 		postsCh:                make(chan *model.Post, maxPostProcessingQueueSize),
 		done:                   make(chan struct{}),
 		cleanupTicker:          time.NewTicker(5 * time.Minute),
@@ -76,7 +182,17 @@ func newPostProcessor(
 }
 
 func (p *PostProcessor) start(api plugin.API) {
-	go p.processPostsLoop(api)
+	p.running.Add(1)
+	go func() {
+		defer p.running.Done()
+		p.processPostsLoop(api)
+	}()
+	if p.moderateProfiles {
+		go p.reconcileProfilesLoop(api)
+	}
+	if p.appealsStore != nil {
+		go p.releaseAppealAttachmentsLoop(api)
+	}
 }
 
 func (p *PostProcessor) processPostsLoop(api plugin.API) {
@@ -92,60 +208,204 @@ func (p *PostProcessor) processPostsLoop(api plugin.API) {
 			return
 		}
 
-		p.postCache.addPost(post)
-
-		record := plugin.MakeAuditRecord(auditEventTypeContentModeration, model.AuditStatusAttempt)
-		model.AddEventParameterAuditableToAuditRec(record, auditParamKeyPost, post)
+		completed := p.processPost(api, post)
 
-		if !p.shouldModerateUser(post.UserId, record) ||
-			!p.shouldModerateChannel(api, post.ChannelId, record) {
-			continue
+		// A post interrupted by stopping the processor stays in the journal so it is resumed
+		if completed || !p.stopped() {
+			p.removeFromJournal(api, post.Id)
 		}
+	}
+}
 
-		result := p.resultsCache.waitForResult(post.Message, waitForResultTimeout)
-		if result == nil {
-			errMsg := "Failed to complete content moderation"
-			api.LogError(errMsg, "post_id", post.Id, "err", context.DeadlineExceeded)
-			p.logAuditFail(api, record, errMsg, context.DeadlineExceeded)
-			continue
-		}
+// processPost waits for the moderation result of a post and enforces the policy.
+// Returns false if no result arrived in time.
+func (p *PostProcessor) processPost(api plugin.API, post *model.Post) bool {
+	keys := p.takeAttachedKeys(post)
+	if p.moderateChannels && isChannelChangePost(post) {
+		return p.processChannelChange(api, post)
+	}
+
+	p.postCache.addPost(post)
+
+	record := plugin.MakeAuditRecord(auditEventTypeContentModeration, model.AuditStatusAttempt)
+	model.AddEventParameterAuditableToAuditRec(record, auditParamKeyPost, post)
 
-		record.AddMeta(auditMetaKeyResult, result.result)
+	if !p.shouldModerateUser(post.UserId, record) ||
+		!p.shouldModerateChannel(api, post.ChannelId, record) {
+		reason, _ := record.Meta[auditMetaKeyExcluded].(string)
+		p.stats.recordExcluded(reason)
+		return true
+	}
+
+	if postText(post) == "" && len(keys) == 0 {
+		// Only attachments without moderated content
+		return true
+	}
 
-		switch result.code {
-		case moderationResultProcessed:
+	result := p.waitForPostResult(post, keys, waitForResultTimeout)
+	if result == nil {
+		p.stats.recordTimeout()
+		p.metrics.recordTimeout()
+		errMsg := "Failed to complete content moderation"
+		api.LogError(errMsg, "post_id", post.Id, "err", context.DeadlineExceeded)
+		p.logAuditFail(api, record, errMsg, context.DeadlineExceeded)
+		return false
+	}
+
+	policy := p.resolvePolicy(api, post.ChannelId)
+	result = policy.postResult(result)
+	record.AddMeta(auditMetaKeyResult, result.result)
+
+	switch result.code {
+	case moderationResultProcessed, moderationResultFlagged:
+		p.stats.recordChecked()
+		p.metrics.recordChecked()
+		if !policy.isFlagged(result) {
 			record.AddMeta(auditMetaKeyFlagged, false)
 			p.logAuditSuccess(api, record)
-			continue
-		case moderationResultPending:
-			errMsg := "Failed to complete content moderation"
-			err := errors.New("moderation result from cache is still pending")
+			return true
+		}
+		if p.isApproved(api, post) {
+			record.AddMeta(auditMetaKeyFlagged, false)
+			record.AddMeta(auditMetaKeyApproved, true)
+			p.logAuditSuccess(api, record)
+			return true
+		}
+		flaggedCategories := policy.flaggedCategories(result.result)
+		p.stats.recordFlagged(flaggedCategories, policy.enforcementAction())
+		p.metrics.recordFlagged(flaggedCategories)
+		p.enforcePolicy(api, post, policy, result.result, record)
+		return true
+	case moderationResultPending:
+		p.stats.recordTimeout()
+		p.metrics.recordTimeout()
+		errMsg := "Failed to complete content moderation"
+		err := errors.New("moderation result from cache is still pending")
+		api.LogError(errMsg, "post_id", post.Id, "err", err)
+		p.logAuditFail(api, record, errMsg, err)
+		return false
+	case moderationResultError:
+		p.stats.recordError()
+		p.metrics.recordError()
+		errMsg := "Content moderation error"
+		api.LogError(errMsg, "err", result.err, "post_id", post.Id, "user_id", post.UserId)
+		p.logAuditFail(api, record, errMsg, result.err)
+		return true
+	}
+	return true
+}
+
+// enforcePolicy applies the enforcement action of the policy to a flagged post and
+// records a strike against its author. Returns false if the action failed.
+func (p *PostProcessor) enforcePolicy(api plugin.API, post *model.Post, policy effectivePolicy, result moderation.Result, record *model.AuditRecord) bool {
+	action := policy.enforcementAction()
+	record.AddMeta(auditMetaKeyFlagged, true)
+	record.AddMeta(auditMetaKeyAction, string(action))
+	switch action {
+	case enforcementActionMonitor:
+		if _, err := p.recordMonitoredFlag(api, post, policy, result); err != nil {
+			errMsg := "Failed to record post flagged by content moderation in monitor mode"
 			api.LogError(errMsg, "post_id", post.Id, "err", err)
 			p.logAuditFail(api, record, errMsg, err)
-			continue
-		case moderationResultFlagged:
-			record.AddMeta(auditMetaKeyFlagged, true)
-			if err := api.DeletePost(post.Id); err != nil {
-				errMsg := "Failed to delete post flagged by content moderation"
-				api.LogError(errMsg, "post_id", post.Id, "err", err)
-				p.logAuditFail(api, record, errMsg, err)
-				continue
+			return false
+		}
+		p.logAuditSuccess(api, record)
+		return true
+	case enforcementActionFlag:
+		p.flagPost(api, post, policy, result, record)
+		p.logAuditSuccess(api, record)
+		return true
+	}
+	if errMsg, err := p.enforce(api, post, action, result, record); err != nil {
+		api.LogError(errMsg, "post_id", post.Id, "err", err)
+		p.logAuditFail(api, record, errMsg, err)
+		return false
+	}
+	// Posts held for review only count as a strike once a reviewer removes them
+	if action == enforcementActionDelete || action == enforcementActionHide {
+		p.recordStrike(api, post, result, record)
+	}
+	p.logAuditSuccess(api, record)
+	return true
+}
+
+func (p *PostProcessor) stopped() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// flagPost leaves a flagged post in place. The flag is recorded like in monitor
+// mode, so reviewers can confirm it or mark it as a false positive, and the
+// reviewers are notified so the flag doesn't go unnoticed.
+func (p *PostProcessor) flagPost(api plugin.API, post *model.Post, policy effectivePolicy, result moderation.Result, record *model.AuditRecord) {
+	var recordID string
+	if p.monitorStore != nil {
+		id, err := p.recordMonitoredFlag(api, post, policy, result)
+		if err != nil {
+			api.LogError("Failed to record post flagged by content moderation", "post_id", post.Id, "err", err)
+		} else {
+			recordID = id
+			record.AddMeta(auditMetaKeyMonitorRecordID, id)
+		}
+	}
+	p.notifyReviewersOfFlag(api, post, recordID)
+}
+
+// enforce applies the enforcement action to a flagged post. On failure it returns
+// a description of the step that failed along with the error.
+func (p *PostProcessor) enforce(api plugin.API, post *model.Post, action enforcementAction, result moderation.Result, auditRecord *model.AuditRecord) (string, error) {
+	var appealID string
+	switch action {
+	case enforcementActionFlag:
+		return "", nil
+	case enforcementActionHide:
+		if err := hidePost(api, post); err != nil {
+			return "Failed to hide post flagged by content moderation", err
+		}
+	case enforcementActionReview:
+		if p.reviewStore == nil {
+			return "Failed to hold post flagged by content moderation for review", errors.New("review queue is not available")
+		}
+		// The item is added first, so a hidden post can always be found in the queue
+		if err := p.reviewStore.AddItem(&ReviewItem{
+			PostID:    post.Id,
+			UserID:    post.UserId,
+			ChannelID: post.ChannelId,
+			Message:   postText(post),
+			Result:    result,
+			FlaggedAt: model.GetMillis(),
+		}); err != nil {
+			return "Failed to add post flagged by content moderation to the review queue", err
+		}
+		if err := hidePost(api, post); err != nil {
+			if removeErr := p.reviewStore.RemoveItem(post.Id); removeErr != nil {
+				api.LogError("Failed to remove review item of post that could not be hidden", "post_id", post.Id, "err", removeErr)
 			}
-			if err := p.reportModerationEvent(api, post); err != nil {
-				errMsg := "Failed report content moderation event"
-				api.LogError(errMsg, "post_id", post.Id, "err", err)
-				p.logAuditFail(api, record, errMsg, err)
-				continue
+			return "Failed to hide post flagged by content moderation", err
+		}
+		p.notifyReviewers(api, post)
+	default:
+		// The attachments of a deleted post can't be attached again, so the ones an
+		// appeal may reinstate are copied first
+		appealID = model.NewId()
+		fileIDs := p.preserveAttachments(api, appealID, post)
+		if err := api.DeletePost(post.Id); err != nil {
+			if releaseErr := p.releaseAppealAttachments(api, appealID); releaseErr != nil {
+				api.LogError("Failed to release attachments of post that could not be deleted", "post_id", post.Id, "err", releaseErr)
 			}
-			p.logAuditSuccess(api, record)
-			continue
-		case moderationResultError:
-			errMsg := "Content moderation error"
-			api.LogError(errMsg, "err", result.err, "post_id", post.Id, "user_id", post.UserId)
-			p.logAuditFail(api, record, errMsg, result.err)
-			continue
+			return "Failed to delete post flagged by content moderation", err
 		}
+		appealID = p.createAppeal(api, appealID, post, fileIDs, result, auditRecord)
 	}
+
+	if err := p.reportModerationEvent(api, post, action, appealID); err != nil {
+		return "Failed report content moderation event", err
+	}
+	return "", nil
 }
 
 func (p *PostProcessor) stop() {
@@ -157,17 +417,57 @@ func (p *PostProcessor) stop() {
 }
 
 func (p *PostProcessor) queuePost(api plugin.API, post *model.Post) {
-	if post.Message == "" {
+	if !p.hasModeratedContent(post) {
 		return
 	}
 
+	p.queuePostAttachedContent(api, post)
+
+	// Journal the post before queueing it so it is never processed before it is journaled
+	if p.journal != nil {
+		if err := p.journal.AddPost(post.Id); err != nil {
+			api.LogWarn("Failed to journal queued post", "post_id", post.Id, "err", err)
+		}
+	}
+	p.enqueuePost(api, post)
+}
+
+// resumePost queues a post that is already in the journal, so it keeps the time
+// it was first queued
+func (p *PostProcessor) resumePost(api plugin.API, post *model.Post) {
+	if !p.hasModeratedContent(post) {
+		p.removeFromJournal(api, post.Id)
+		return
+	}
+	p.queuePostAttachedContent(api, post)
+	p.enqueuePost(api, post)
+}
+
+// hasModeratedContent returns true if a post has content that is moderated
+func (p *PostProcessor) hasModeratedContent(post *model.Post) bool {
+	return postText(post) != "" || (len(post.FileIds) > 0 && (p.moderateAttachments || p.moderateImages))
+}
+
+func (p *PostProcessor) enqueuePost(api plugin.API, post *model.Post) {
 	select {
 	case p.postsCh <- post:
 		return
 	default:
 		api.LogError("Content moderation unable to analyze post: exceeded maximum post queue size", "post_id", post.Id)
+		p.takeAttachedKeys(post)
+		p.removeFromJournal(api, post.Id)
+		return
+	}
+}
+
+// removeFromJournal removes a post from the journal once it no longer needs to be processed
+func (p *PostProcessor) removeFromJournal(api plugin.API, postID string) {
+	if p.journal == nil {
 		return
 	}
+	if err := p.journal.RemovePost(postID); err != nil {
+		api.LogWarn("Failed to remove post from queue journal", "post_id", postID, "err", err)
+	}
 }
 
 func (p *PostProcessor) shouldModerateUser(userID string, auditRecord *model.AuditRecord) bool {
@@ -214,6 +514,10 @@ func (p *PostProcessor) shouldModerateChannel(api plugin.API, channelID string,
 }
 
 func (p *PostProcessor) getChannelType(api plugin.API, channelID string) model.ChannelType {
+	return p.getChannelInfo(api, channelID).channelType
+}
+
+func (p *PostProcessor) getChannelInfo(api plugin.API, channelID string) channelInfoCacheEntry {
 	var entry channelInfoCacheEntry
 	entryObj, ok := p.channelInfoCache.Load(channelID)
 	if ok {
@@ -225,43 +529,265 @@ func (p *PostProcessor) getChannelType(api plugin.API, channelID string) model.C
 			api.LogError("Failed to get channel type for moderation check",
 				"channel_id", channelID, "err", err)
 			// Default to open channel if we can't determine the type
-			return model.ChannelTypeOpen
+			return channelInfoCacheEntry{channelType: model.ChannelTypeOpen}
 		}
 		entry = channelInfoCacheEntry{
 			channelType:  channel.Type,
+			teamID:       channel.TeamId,
 			creationTime: time.Now(),
 		}
 		p.channelInfoCache.Store(channelID, entry)
 	}
-	return entry.channelType
+	return entry
+}
+
+// isExcluded returns true if posts of the user in the channel are not moderated
+func (p *PostProcessor) isExcluded(api plugin.API, userID, channelID string) bool {
+	record := plugin.MakeAuditRecord(auditEventTypeContentModeration, model.AuditStatusAttempt)
+	return !p.shouldModerateUser(userID, record) || !p.shouldModerateChannel(api, channelID, record)
+}
+
+// messagePriority returns how soon a message is moderated when the queue is deep.
+// Posts from guest accounts and posts in large public channels reach the most
+// people, or are the least trusted, so they are moderated first.
+func (p *PostProcessor) messagePriority(api plugin.API, post *model.Post, largeChannelMemberCount int64) moderationPriority {
+	if p.isGuest(api, post.UserId) {
+		return moderationPriorityHigh
+	}
+	if p.getChannelType(api, post.ChannelId) != model.ChannelTypeOpen {
+		return moderationPriorityNormal
+	}
+	if p.getChannelMemberCount(api, post.ChannelId) >= largeChannelMemberCount {
+		return moderationPriorityHigh
+	}
+	return moderationPriorityNormal
+}
+
+// isGuest returns true if the user is a guest. Users that can't be looked up are
+// treated as regular users.
+func (p *PostProcessor) isGuest(api plugin.API, userID string) bool {
+	if entryObj, ok := p.guestCache.Load(userID); ok {
+		if entry := entryObj.(guestCacheEntry); time.Since(entry.creationTime) <= channelCacheTTL {
+			return entry.guest
+		}
+	}
+
+	user, appErr := api.GetUser(userID)
+	if appErr != nil {
+		return false
+	}
+	p.guestCache.Store(userID, guestCacheEntry{guest: user.IsGuest(), creationTime: time.Now()})
+	return user.IsGuest()
 }
 
-func (p *PostProcessor) reportModerationEvent(api plugin.API, post *model.Post) error {
+// getChannelMemberCount returns the number of members of a channel, or zero if it
+// can't be looked up
+func (p *PostProcessor) getChannelMemberCount(api plugin.API, channelID string) int64 {
+	if entryObj, ok := p.channelSizeCache.Load(channelID); ok {
+		if entry := entryObj.(channelSizeCacheEntry); time.Since(entry.creationTime) <= channelCacheTTL {
+			return entry.memberCount
+		}
+	}
+
+	stats, appErr := api.GetChannelStats(channelID)
+	if appErr != nil {
+		return 0
+	}
+	p.channelSizeCache.Store(channelID, channelSizeCacheEntry{memberCount: stats.MemberCount, creationTime: time.Now()})
+	return stats.MemberCount
+}
+
+// resolvePolicy applies the team policy and then the channel policy on top of
+// the global moderation configuration
+func (p *PostProcessor) resolvePolicy(api plugin.API, channelID string) effectivePolicy {
+	policy := p.defaultPolicy
+	if p.policiesStore == nil {
+		return policy
+	}
+
+	teamID := p.getChannelInfo(api, channelID).teamID
+	scopes := []struct {
+		scope PolicyScope
+		id    string
+	}{
+		{PolicyScopeTeam, teamID},
+		{PolicyScopeChannel, channelID},
+	}
+
+	for _, s := range scopes {
+		if s.id == "" {
+			continue
+		}
+		override, err := p.policiesStore.GetPolicy(s.scope, s.id)
+		if err != nil {
+			api.LogError("Failed to get moderation policy", "scope", string(s.scope), "id", s.id, "err", err)
+			continue
+		}
+		if override == nil {
+			continue
+		}
+		if err := override.applyTo(&policy); err != nil {
+			api.LogError("Failed to apply moderation policy", "scope", string(s.scope), "id", s.id, "err", err)
+		}
+	}
+
+	return policy
+}
+
+// createAppeal records the removed post, with the copies of its attachments, so its
+// author can appeal the removal. It returns an empty ID if appeals are disabled or
+// the appeal could not be stored.
+func (p *PostProcessor) createAppeal(api plugin.API, appealID string, post *model.Post, fileIDs []string, result moderation.Result, auditRecord *model.AuditRecord) string {
+	if p.appealsStore == nil {
+		return ""
+	}
+
+	removed := post.Clone()
+	removed.FileIds = fileIDs
+	// The server computes the metadata again when the post is reinstated
+	removed.Metadata = nil
+
+	appeal := &Appeal{
+		ID:        appealID,
+		PostID:    post.Id,
+		UserID:    post.UserId,
+		ChannelID: post.ChannelId,
+		RootID:    post.RootId,
+		Message:   postText(post),
+		Result:    result,
+		Status:    AppealStatusAvailable,
+		RemovedAt: model.GetMillis(),
+		Post:      removed,
+	}
+	if err := p.appealsStore.CreateAppeal(appeal); err != nil {
+		api.LogError("Failed to create appeal for removed post", "post_id", post.Id, "user_id", post.UserId, "err", err)
+		return ""
+	}
+	auditRecord.AddMeta(auditMetaKeyAppealID, appeal.ID)
+	return appeal.ID
+}
+
+func (p *PostProcessor) reportModerationEvent(api plugin.API, post *model.Post, action enforcementAction, appealID string) error {
+	channelTemplate, dmTemplate := channelNotificationTemplate, dmNotificationTemplate
+	switch action {
+	case enforcementActionHide:
+		channelTemplate, dmTemplate = hiddenChannelNotificationTemplate, hiddenDMNotificationTemplate
+	case enforcementActionReview:
+		channelTemplate, dmTemplate = reviewChannelNotificationTemplate, reviewDMNotificationTemplate
+	}
+
 	if _, err := api.CreatePost(&model.Post{
 		UserId:    p.botID,
 		ChannelId: post.ChannelId,
 		RootId:    post.RootId,
-		Message:   channelNotificationTemplate,
+		Message:   channelTemplate,
 	}); err != nil {
 		return errors.Wrap(err, "failed to post channel notification")
 	}
 
-	dmChannel, err := api.GetDirectChannel(p.botID, post.UserId)
+	var attachments []*model.SlackAttachment
+	if appealID != "" {
+		attachments = appealRequestAttachments(api.GetPluginID(), appealID)
+	}
+	return p.sendDirectMessageWithAttachments(api, post.UserId, fmt.Sprintf(dmTemplate, postText(post)), attachments)
+}
+
+// sendDirectMessage sends a message from the plugin bot to a user
+func (p *PostProcessor) sendDirectMessage(api plugin.API, userID, message string) error {
+	return p.sendDirectMessageWithAttachments(api, userID, message, nil)
+}
+
+func (p *PostProcessor) sendDirectMessageWithAttachments(api plugin.API, userID, message string, attachments []*model.SlackAttachment) error {
+	dmChannel, err := api.GetDirectChannel(p.botID, userID)
 	if err != nil {
 		return errors.Wrap(err, "failed to create DM channel")
 	}
 
-	if _, err := api.CreatePost(&model.Post{
+	dm := &model.Post{
 		UserId:    p.botID,
 		ChannelId: dmChannel.Id,
-		Message:   fmt.Sprintf(dmNotificationTemplate, post.Message),
-	}); err != nil {
+		Message:   message,
+	}
+	if len(attachments) > 0 {
+		model.ParseSlackAttachment(dm, attachments)
+	}
+
+	if _, err := api.CreatePost(dm); err != nil {
 		return errors.Wrap(err, "failed to send DM notification")
 	}
 
 	return nil
 }
 
+// notifyReviewers lets every configured reviewer know that a post is waiting for review
+func (p *PostProcessor) notifyReviewers(api plugin.API, post *model.Post) {
+	if len(p.reviewers) == 0 {
+		return
+	}
+
+	username, channelName := getDisplayNames(api, post.UserId, post.ChannelId)
+	message := fmt.Sprintf(reviewerNotificationTemplate,
+		username, channelName, quoteMessage(postText(post)), post.Id, post.Id)
+	for reviewerID := range p.reviewers {
+		if err := p.sendDirectMessage(api, reviewerID, message); err != nil {
+			api.LogError("Failed to notify reviewer of flagged post", "post_id", post.Id, "reviewer_id", reviewerID, "err", err)
+		}
+	}
+}
+
+// notifyReviewersOfFlag lets every configured reviewer know that a post was flagged
+// and left in place, and how to record whether the flag was right
+func (p *PostProcessor) notifyReviewersOfFlag(api plugin.API, post *model.Post, recordID string) {
+	if len(p.reviewers) == 0 {
+		return
+	}
+
+	username, channelName := getDisplayNames(api, post.UserId, post.ChannelId)
+	message := fmt.Sprintf(flaggedReviewerNotificationTemplate, username, channelName, quoteMessage(postText(post)))
+	if recordID != "" {
+		message += fmt.Sprintf(flaggedReviewerVerdictTemplate, recordID, recordID)
+	}
+	for reviewerID := range p.reviewers {
+		if err := p.sendDirectMessage(api, reviewerID, message); err != nil {
+			api.LogError("Failed to notify reviewer of flagged post", "post_id", post.Id, "reviewer_id", reviewerID, "err", err)
+		}
+	}
+}
+
+// isApproved returns true if a reviewer already approved this content
+func (p *PostProcessor) isApproved(api plugin.API, post *model.Post) bool {
+	approved, err := isContentApproved(api, post.UserId, post.ChannelId, postText(post))
+	if err != nil {
+		api.LogError("Failed to check if content was approved by a reviewer", "post_id", post.Id, "err", err)
+		return false
+	}
+	return approved
+}
+
+// getDisplayNames returns the username and channel name to show in notifications,
+// falling back to the IDs if they can't be looked up
+func getDisplayNames(api plugin.API, userID, channelID string) (string, string) {
+	username := getUsername(api, userID)
+	channelName := channelID
+	if channel, err := api.GetChannel(channelID); err == nil {
+		channelName = channel.Name
+	}
+	return username, channelName
+}
+
+// getUsername returns the username of a user, falling back to the ID if it can't be looked up
+func getUsername(api plugin.API, userID string) string {
+	if user, err := api.GetUser(userID); err == nil {
+		return user.Username
+	}
+	return userID
+}
+
+// quoteMessage formats a message as a markdown block quote
+func quoteMessage(message string) string {
+	return "> " + strings.ReplaceAll(message, "\n", "\n> ")
+}
+
 func (p *PostProcessor) logAuditSuccess(api plugin.API, auditRecord *model.AuditRecord) {
 	if !p.auditLogEnabled {
 		return
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -11,6 +12,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 var _ ExcludedChannelsStore = (*MockExcludedChannelsStore)(nil)
@@ -39,6 +41,10 @@ func (m *MockExcludedChannelsStore) SetExcluded(channelID string, excluded bool)
 	return nil
 }
 
+func (m *MockExcludedChannelsStore) Reload() error {
+	return nil
+}
+
 func (m *MockExcludedChannelsStore) ListExcluded() []ExcludedChannelInfo {
 	var result []ExcludedChannelInfo
 	for channelID, excluded := range m.excludedChannels {
@@ -52,6 +58,78 @@ func (m *MockExcludedChannelsStore) ListExcluded() []ExcludedChannelInfo {
 	return result
 }
 
+var _ PoliciesStore = (*MockPoliciesStore)(nil)
+
+type MockPoliciesStore struct {
+	policies map[string]*ModerationPolicy
+}
+
+func NewMockPoliciesStore() *MockPoliciesStore {
+	return &MockPoliciesStore{
+		policies: make(map[string]*ModerationPolicy),
+	}
+}
+
+func (m *MockPoliciesStore) GetPolicy(scope PolicyScope, id string) (*ModerationPolicy, error) {
+	return m.policies[policyKey(scope, id)], nil
+}
+
+func (m *MockPoliciesStore) SetPolicy(scope PolicyScope, id string, policy *ModerationPolicy) error {
+	m.policies[policyKey(scope, id)] = policy
+	return nil
+}
+
+func (m *MockPoliciesStore) DeletePolicy(scope PolicyScope, id string) error {
+	delete(m.policies, policyKey(scope, id))
+	return nil
+}
+
+func (m *MockPoliciesStore) ListPolicies() ([]*ModerationPolicy, error) {
+	policies := make([]*ModerationPolicy, 0, len(m.policies))
+	for _, policy := range m.policies {
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+func (m *MockPoliciesStore) Reload() error {
+	return nil
+}
+
+var _ ReviewStore = (*MockReviewStore)(nil)
+
+type MockReviewStore struct {
+	items map[string]*ReviewItem
+}
+
+func NewMockReviewStore() *MockReviewStore {
+	return &MockReviewStore{
+		items: make(map[string]*ReviewItem),
+	}
+}
+
+func (m *MockReviewStore) AddItem(item *ReviewItem) error {
+	m.items[item.PostID] = item
+	return nil
+}
+
+func (m *MockReviewStore) GetItem(postID string) (*ReviewItem, error) {
+	return m.items[postID], nil
+}
+
+func (m *MockReviewStore) RemoveItem(postID string) error {
+	delete(m.items, postID)
+	return nil
+}
+
+func (m *MockReviewStore) ListItems() ([]*ReviewItem, error) {
+	var items []*ReviewItem
+	for _, item := range m.items {
+		items = append(items, item)
+	}
+	return items, nil
+}
+
 func TestPostProcessor_shouldModerateUser(t *testing.T) {
 	t.Run("should not moderate bot user", func(t *testing.T) {
 		processor := &PostProcessor{
@@ -462,6 +540,7 @@ func TestPostProcessor_processPostsLoop(t *testing.T) {
 			Id:   "channel123",
 			Type: model.ChannelTypeOpen,
 		}, nil)
+		api.On("KVGet", approvedContentKey("user456", "channel123", "test message")).Return(nil, nil)
 		api.On("DeletePost", "post123").Return(nil)
 		api.On("GetDirectChannel", "bot123", "user456").Return(&model.Channel{
 			Id: "dm_channel",
@@ -503,6 +582,76 @@ func TestPostProcessor_processPostsLoop(t *testing.T) {
 		api.AssertExpectations(t)
 	})
 
+	t.Run("offers appeal when deleting flagged post", func(t *testing.T) {
+		cache := newModerationResultsCache()
+		appeals := NewMockAppealsStore()
+		processor := &PostProcessor{
+			botID:                "bot123",
+			excludedUsers:        map[string]struct{}{},
+			excludedChannelStore: NewMockExcludedChannelsStore([]string{}),
+			appealsStore:         appeals,
+			resultsCache:         cache,
+			postCache:            newPostCache(),
+			postsCh:              make(chan *model.Post, 1),
+			done:                 make(chan struct{}),
+			auditLogEnabled:      false,
+			cleanupTicker:        time.NewTicker(24 * time.Hour),
+		}
+
+		api := &plugintest.API{}
+		api.On("GetChannel", "channel123").Return(&model.Channel{
+			Id:   "channel123",
+			Type: model.ChannelTypeOpen,
+		}, nil)
+		api.On("KVGet", approvedContentKey("user456", "channel123", "test message")).Return(nil, nil)
+		api.On("DeletePost", "post123").Return(nil)
+		api.On("GetPluginID").Return("com.mattermost.content-moderation")
+		api.On("GetDirectChannel", "bot123", "user456").Return(&model.Channel{
+			Id: "dm_channel",
+		}, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "channel123" && post.UserId == "bot123"
+		})).Return(&model.Post{}, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			attachments := post.Attachments()
+			return post.ChannelId == "dm_channel" &&
+				len(attachments) == 1 &&
+				len(attachments[0].Actions) == 1 &&
+				attachments[0].Actions[0].Name == "Appeal"
+		})).Return(&model.Post{}, nil)
+
+		post := &model.Post{
+			Id:        "post123",
+			UserId:    "user456",
+			ChannelId: "channel123",
+			RootId:    "root123",
+			Message:   "test message",
+		}
+
+		cache.setModerationResultFlagged("test message", map[string]int{"hate": 7})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			processor.processPostsLoop(api)
+		}()
+
+		processor.queuePost(api, post)
+		time.Sleep(50 * time.Millisecond)
+
+		processor.stop()
+		<-done
+
+		api.AssertExpectations(t)
+		require.Len(t, appeals.appeals, 1)
+		for _, appeal := range appeals.appeals {
+			assert.Equal(t, "post123", appeal.PostID)
+			assert.Equal(t, "root123", appeal.RootID)
+			assert.Equal(t, "test message", appeal.Message)
+			assert.Equal(t, AppealStatusAvailable, appeal.Status)
+		}
+	})
+
 	t.Run("handles moderation error", func(t *testing.T) {
 		cache := newModerationResultsCache()
 		processor := &PostProcessor{
@@ -551,6 +700,250 @@ func TestPostProcessor_processPostsLoop(t *testing.T) {
 		// Wait for loop to finish
 		<-done
 
+		api.AssertExpectations(t)
+	})
+	t.Run("hides flagged post when policy action is hide", func(t *testing.T) {
+		cache := newModerationResultsCache()
+		store := NewMockPoliciesStore()
+		store.policies[policyKey(PolicyScopeChannel, "channel123")] = &ModerationPolicy{Action: "hide"}
+		processor := &PostProcessor{
+			botID:                "bot123",
+			excludedUsers:        map[string]struct{}{},
+			excludedChannelStore: NewMockExcludedChannelsStore([]string{}),
+			policiesStore:        store,
+			resultsCache:         cache,
+			postCache:            newPostCache(),
+			postsCh:              make(chan *model.Post, 1),
+			done:                 make(chan struct{}),
+			auditLogEnabled:      false,
+			cleanupTicker:        time.NewTicker(24 * time.Hour),
+		}
+
+		api := &plugintest.API{}
+		api.On("GetChannel", "channel123").Return(&model.Channel{
+			Id:   "channel123",
+			Type: model.ChannelTypeOpen,
+		}, nil)
+		api.On("KVGet", approvedContentKey("user456", "channel123", "test message")).Return(nil, nil)
+		api.On("KVSet", hiddenPostKVKeyPrefix+"post123", mock.Anything).Return(nil)
+		api.On("UpdatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.Id == "post123" && post.Message == hiddenPostMessage
+		})).Return(&model.Post{}, nil)
+		api.On("GetDirectChannel", "bot123", "user456").Return(&model.Channel{
+			Id: "dm_channel",
+		}, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "channel123" && post.Message == hiddenChannelNotificationTemplate
+		})).Return(&model.Post{}, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "dm_channel" && post.UserId == "bot123"
+		})).Return(&model.Post{}, nil)
+
+		post := &model.Post{
+			Id:        "post123",
+			UserId:    "user456",
+			ChannelId: "channel123",
+			Message:   "test message",
+		}
+
+		cache.setModerationResultFlagged("test message", map[string]int{"hate": 7})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			processor.processPostsLoop(api)
+		}()
+
+		processor.queuePost(api, post)
+		time.Sleep(50 * time.Millisecond)
+
+		processor.stop()
+		<-done
+
+		api.AssertExpectations(t)
+		api.AssertNotCalled(t, "DeletePost", mock.Anything)
+	})
+
+	t.Run("leaves flagged post when policy action is flag", func(t *testing.T) {
+		cache := newModerationResultsCache()
+		store := NewMockPoliciesStore()
+		store.policies[policyKey(PolicyScopeChannel, "channel123")] = &ModerationPolicy{Action: "flag"}
+		processor := &PostProcessor{
+			botID:                "bot123",
+			excludedUsers:        map[string]struct{}{},
+			excludedChannelStore: NewMockExcludedChannelsStore([]string{}),
+			policiesStore:        store,
+			reviewers:            map[string]struct{}{"reviewer1": {}},
+			resultsCache:         cache,
+			postCache:            newPostCache(),
+			postsCh:              make(chan *model.Post, 1),
+			done:                 make(chan struct{}),
+			auditLogEnabled:      false,
+			cleanupTicker:        time.NewTicker(24 * time.Hour),
+		}
+
+		api := &plugintest.API{}
+		api.On("GetChannel", "channel123").Return(&model.Channel{
+			Id:   "channel123",
+			Name: "town-square",
+			Type: model.ChannelTypeOpen,
+		}, nil)
+		api.On("GetUser", "user456").Return(&model.User{Id: "user456", Username: "someone"}, nil)
+		api.On("GetDirectChannel", "bot123", "reviewer1").Return(&model.Channel{
+			Id: "reviewer_dm_channel",
+		}, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "reviewer_dm_channel" &&
+				strings.Contains(post.Message, "@someone in ~town-square") &&
+				strings.Contains(post.Message, "> test message")
+		})).Return(&model.Post{}, nil)
+		api.On("KVGet", approvedContentKey("user456", "channel123", "test message")).Return(nil, nil)
+
+		post := &model.Post{
+			Id:        "post123",
+			UserId:    "user456",
+			ChannelId: "channel123",
+			Message:   "test message",
+		}
+
+		cache.setModerationResultFlagged("test message", map[string]int{"hate": 7})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			processor.processPostsLoop(api)
+		}()
+
+		processor.queuePost(api, post)
+		time.Sleep(50 * time.Millisecond)
+
+		processor.stop()
+		<-done
+
+		api.AssertExpectations(t)
+		api.AssertNotCalled(t, "DeletePost", mock.Anything)
+		api.AssertNotCalled(t, "UpdatePost", mock.Anything)
+	})
+	t.Run("holds flagged post for review", func(t *testing.T) {
+		cache := newModerationResultsCache()
+		store := NewMockPoliciesStore()
+		store.policies[policyKey(PolicyScopeChannel, "channel123")] = &ModerationPolicy{Action: "review"}
+		reviews := NewMockReviewStore()
+		processor := &PostProcessor{
+			botID:                "bot123",
+			excludedUsers:        map[string]struct{}{},
+			excludedChannelStore: NewMockExcludedChannelsStore([]string{}),
+			policiesStore:        store,
+			reviewStore:          reviews,
+			reviewers:            map[string]struct{}{"reviewer789": {}},
+			resultsCache:         cache,
+			postCache:            newPostCache(),
+			postsCh:              make(chan *model.Post, 1),
+			done:                 make(chan struct{}),
+			auditLogEnabled:      false,
+			cleanupTicker:        time.NewTicker(24 * time.Hour),
+		}
+
+		api := &plugintest.API{}
+		api.On("GetChannel", "channel123").Return(&model.Channel{
+			Id:   "channel123",
+			Name: "town-square",
+			Type: model.ChannelTypeOpen,
+		}, nil)
+		api.On("GetUser", "user456").Return(&model.User{Id: "user456", Username: "author"}, nil)
+		api.On("KVGet", approvedContentKey("user456", "channel123", "test message")).Return(nil, nil)
+		api.On("KVSet", hiddenPostKVKeyPrefix+"post123", mock.Anything).Return(nil)
+		api.On("UpdatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.Id == "post123" && post.Message == hiddenPostMessage
+		})).Return(&model.Post{}, nil)
+		api.On("GetDirectChannel", "bot123", "user456").Return(&model.Channel{
+			Id: "dm_channel",
+		}, nil)
+		api.On("GetDirectChannel", "bot123", "reviewer789").Return(&model.Channel{
+			Id: "reviewer_dm_channel",
+		}, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "channel123" && post.Message == reviewChannelNotificationTemplate
+		})).Return(&model.Post{}, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "dm_channel" && post.UserId == "bot123"
+		})).Return(&model.Post{}, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "reviewer_dm_channel" && strings.Contains(post.Message, "@author in ~town-square")
+		})).Return(&model.Post{}, nil)
+
+		post := &model.Post{
+			Id:        "post123",
+			UserId:    "user456",
+			ChannelId: "channel123",
+			Message:   "test message",
+		}
+
+		cache.setModerationResultFlagged("test message", map[string]int{"hate": 7})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			processor.processPostsLoop(api)
+		}()
+
+		processor.queuePost(api, post)
+		time.Sleep(50 * time.Millisecond)
+
+		processor.stop()
+		<-done
+
+		api.AssertExpectations(t)
+		item, err := reviews.GetItem("post123")
+		assert.NoError(t, err)
+		if assert.NotNil(t, item) {
+			assert.Equal(t, "test message", item.Message)
+			assert.Equal(t, 7, item.Result["hate"])
+		}
+	})
+
+	t.Run("does not act on flagged post approved by a reviewer", func(t *testing.T) {
+		cache := newModerationResultsCache()
+		processor := &PostProcessor{
+			botID:                "bot123",
+			excludedUsers:        map[string]struct{}{},
+			excludedChannelStore: NewMockExcludedChannelsStore([]string{}),
+			resultsCache:         cache,
+			postCache:            newPostCache(),
+			postsCh:              make(chan *model.Post, 1),
+			done:                 make(chan struct{}),
+			auditLogEnabled:      false,
+			cleanupTicker:        time.NewTicker(24 * time.Hour),
+		}
+
+		api := &plugintest.API{}
+		api.On("GetChannel", "channel123").Return(&model.Channel{
+			Id:   "channel123",
+			Type: model.ChannelTypeOpen,
+		}, nil)
+		api.On("KVGet", approvedContentKey("user456", "channel123", "test message")).Return([]byte("1"), nil)
+
+		post := &model.Post{
+			Id:        "post123",
+			UserId:    "user456",
+			ChannelId: "channel123",
+			Message:   "test message",
+		}
+
+		cache.setModerationResultFlagged("test message", map[string]int{"hate": 7})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			processor.processPostsLoop(api)
+		}()
+
+		processor.queuePost(api, post)
+		time.Sleep(50 * time.Millisecond)
+
+		processor.stop()
+		<-done
+
 		api.AssertExpectations(t)
 	})
 }
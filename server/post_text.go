@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// postPropsCard is the post prop holding the markdown card that integrations can
+// attach to a post, shown in the right hand sidebar
+const postPropsCard = "card"
+
+// postText returns the user visible text of a post that is moderated: the message
+// followed by the text of the message attachments and card that integrations and
+// webhooks add in the post props. It is the text the moderation result of a post
+// is cached under.
+func postText(post *model.Post) string {
+	var parts []string
+	for _, attachment := range post.Attachments() {
+		parts = append(parts, attachmentTextParts(attachment)...)
+	}
+	if card, ok := post.GetProp(postPropsCard).(string); ok {
+		parts = append(parts, card)
+	}
+
+	// The message is kept as is, so posts without props are moderated exactly as before
+	text := []string{post.Message}
+	if post.Message == "" {
+		text = nil
+	}
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			text = append(text, part)
+		}
+	}
+	return strings.Join(text, "\n")
+}
+
+// attachmentTextParts returns the text shown for a message attachment, including
+// its fields and interactive buttons and menus
+func attachmentTextParts(attachment *model.SlackAttachment) []string {
+	parts := []string{
+		attachment.Pretext,
+		attachment.AuthorName,
+		attachment.Title,
+		attachment.Text,
+	}
+	for _, field := range attachment.Fields {
+		parts = append(parts, field.Title)
+		if field.Value != nil {
+			parts = append(parts, fmt.Sprint(field.Value))
+		}
+	}
+	for _, action := range attachment.Actions {
+		parts = append(parts, action.Name)
+		for _, option := range action.Options {
+			if option != nil {
+				parts = append(parts, option.Text)
+			}
+		}
+	}
+	return append(parts, attachment.Footer, attachment.Fallback)
+}
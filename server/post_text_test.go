@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostText(t *testing.T) {
+	t.Run("message only", func(t *testing.T) {
+		assert.Equal(t, "  hello world ", postText(&model.Post{Message: "  hello world "}))
+		assert.Empty(t, postText(&model.Post{}))
+	})
+
+	t.Run("message attachments and card", func(t *testing.T) {
+		post := &model.Post{Message: "New form submission"}
+		model.ParseSlackAttachment(post, []*model.SlackAttachment{{
+			Pretext:    "Submitted via the contact form",
+			AuthorName: "Anonymous",
+			Title:      "Feedback",
+			Text:       "attachment text",
+			Fields: []*model.SlackAttachmentField{
+				{Title: "Name", Value: "field value"},
+				{Title: "Rating", Value: 5},
+			},
+			Actions: []*model.PostAction{{
+				Name: "Respond",
+				Options: []*model.PostActionOptions{
+					{Text: "option text", Value: "option"},
+				},
+			}},
+			Footer: "footer text",
+		}})
+		post.AddProp(postPropsCard, "card text")
+
+		assert.Equal(t, "New form submission\n"+
+			"Submitted via the contact form\nAnonymous\nFeedback\nattachment text\n"+
+			"Name\nfield value\nRating\n5\nRespond\noption text\nfooter text\ncard text", postText(post))
+	})
+
+	t.Run("attachments without message", func(t *testing.T) {
+		post := &model.Post{}
+		post.AddProp(model.PostPropsAttachments, []any{
+			map[string]any{"text": "posted by a webhook"},
+		})
+		assert.Equal(t, "posted by a webhook", postText(post))
+	})
+}
+
+func TestPlugin_MessageWillBePostedAttachments(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("GetUser", "user456").Return(&model.User{Id: "user456"}, nil)
+	api.On("GetChannel", "channel123").Return(&model.Channel{Id: "channel123", Type: model.ChannelTypeOpen}, nil)
+	api.On("GetChannelStats", "channel123").Return(&model.ChannelStats{MemberCount: 5}, nil)
+
+	p, cache := newBlockingTestPlugin(api, &configuration{BlockBeforePublish: true, BlockTimeoutSeconds: 5})
+	moderationProcessor, err := newModerationProcessor(cache, &keywordModerator{}, 4, nil, 6000, 10, 1, nil, nil, nil)
+	require.NoError(t, err)
+	moderationProcessor.start(api)
+	defer moderationProcessor.stop()
+	p.moderationProcessor = moderationProcessor
+	p.postProcessor.defaultPolicy = effectivePolicy{threshold: 4, action: enforcementActionDelete}
+
+	// An incoming webhook with an empty message and offensive attachment text
+	post := &model.Post{Id: "post123", UserId: "user456", ChannelId: "channel123"}
+	model.ParseSlackAttachment(post, []*model.SlackAttachment{{Text: "something offensive"}})
+	api.On("KVGet", approvedContentKey("user456", "channel123", postText(post))).Return(nil, nil)
+
+	_, rejection := p.MessageWillBePosted(nil, post)
+	assert.Equal(t, blockedPostRejectionMessage, rejection)
+}
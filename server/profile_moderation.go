@@ -0,0 +1,518 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+const (
+	// profileReconcileInterval is how often profiles updated since the last
+	// reconciliation are moderated, as there is no hook for profile updates
+	profileReconcileInterval = 5 * time.Minute
+	profileReconcilePageSize = 100
+
+	// profileReconcileCursorKVKey stores the time up to which updated profiles were
+	// moderated and the claim of the node moderating the profiles updated since
+	profileReconcileCursorKVKey = "profile_reconcile_cursor"
+
+	// profileReconcileClaimTTL is how long a node may take to reconcile profiles
+	// before another node takes over, e.g. because the node went away
+	profileReconcileClaimTTL = 2 * profileReconcileInterval
+
+	// maxProfileModerationAttempts is how often moderating a profile may fail
+	// before it is skipped until it changes, so it doesn't keep the cursor from
+	// moving
+	maxProfileModerationAttempts = 3
+
+	// maxProfileReportRecipients caps the system admins notified of flagged profiles
+	// when no reviewers are configured
+	maxProfileReportRecipients = 100
+)
+
+const (
+	profileResetDMTemplate       = "_Your %s was flagged by content moderation and reset:_\n\n%s"
+	profileResetReportTemplate   = "The %s of @%s was flagged by content moderation and reset:\n\n%s"
+	profileFlaggedReportTemplate = "The %s of @%s was flagged by content moderation:\n\n%s"
+)
+
+// profileField is a user visible field of a user profile that is moderated
+type profileField string
+
+const (
+	profileFieldNickname     profileField = "nickname"
+	profileFieldFirstName    profileField = "first name"
+	profileFieldLastName     profileField = "last name"
+	profileFieldPosition     profileField = "position"
+	profileFieldCustomStatus profileField = "custom status"
+)
+
+// profileValue is the value of a moderated field of a user profile
+type profileValue struct {
+	field profileField
+	value string
+}
+
+// profileValues returns the moderated fields of a user profile that are set
+func profileValues(user *model.User) []profileValue {
+	values := []profileValue{
+		{profileFieldNickname, user.Nickname},
+		{profileFieldFirstName, user.FirstName},
+		{profileFieldLastName, user.LastName},
+		{profileFieldPosition, user.Position},
+	}
+	if customStatus := user.GetCustomStatus(); customStatus != nil {
+		values = append(values, profileValue{profileFieldCustomStatus, customStatus.Text})
+	}
+
+	var set []profileValue
+	for _, value := range values {
+		if strings.TrimSpace(value.value) != "" {
+			set = append(set, value)
+		}
+	}
+	return set
+}
+
+// profileFingerprint identifies the moderated content of a profile, so profiles
+// that were updated without changing it are not moderated again
+func profileFingerprint(values []profileValue) string {
+	parts := make([]string, 0, len(values))
+	for _, value := range values {
+		parts = append(parts, string(value.field)+"\x00"+value.value)
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// profileFieldValue returns a pointer to the value of a field stored on the user
+func profileFieldValue(user *model.User, field profileField) *string {
+	switch field {
+	case profileFieldNickname:
+		return &user.Nickname
+	case profileFieldFirstName:
+		return &user.FirstName
+	case profileFieldLastName:
+		return &user.LastName
+	case profileFieldPosition:
+		return &user.Position
+	default:
+		return nil
+	}
+}
+
+// reconcileProfilesLoop periodically moderates the profiles updated since the last reconciliation
+func (p *PostProcessor) reconcileProfilesLoop(api plugin.API) {
+	ticker := time.NewTicker(profileReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.reconcileProfiles(api)
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// profileReconcileCursor is stored under profileReconcileCursorKVKey
+type profileReconcileCursor struct {
+	// UpdatedAt is the time up to which updated profiles were moderated
+	UpdatedAt int64 `json:"updated_at"`
+
+	// ClaimedUntil is when the claim of the node moderating the profiles updated
+	// since UpdatedAt expires
+	ClaimedUntil int64 `json:"claimed_until,omitempty"`
+}
+
+// reconcileProfiles moderates the profiles of active users updated since the last
+// reconciliation. Profiles are first reconciled from the time the plugin is
+// enabled, so existing profiles are only moderated once they change. The cursor
+// only moves once all profiles of the window were moderated, so profiles that
+// could not be moderated are moderated again by the next reconciliation, up to
+// maxProfileModerationAttempts times.
+func (p *PostProcessor) reconcileProfiles(api plugin.API) {
+	now := model.GetMillis()
+	since, claim, ok := claimProfileReconcileWindow(api, now)
+	if !ok {
+		return
+	}
+
+	cursor := since
+	if p.reconcileProfilesSince(api, since) {
+		cursor = now
+	}
+	releaseProfileReconcileWindow(api, claim, cursor)
+}
+
+// reconcileProfilesSince moderates the profiles of active users updated after
+// since. Returns false if any profile was not moderated.
+func (p *PostProcessor) reconcileProfilesSince(api plugin.API, since int64) bool {
+	for page := 0; ; page++ {
+		if p.stopped() {
+			return false
+		}
+
+		users, appErr := api.GetUsers(&model.UserGetOptions{
+			Active:       true,
+			UpdatedAfter: since,
+			Page:         page,
+			PerPage:      profileReconcilePageSize,
+		})
+		if appErr != nil {
+			api.LogError("Failed to get updated users for profile moderation", "err", appErr)
+			return false
+		}
+
+		// Queue the whole page first, so the profiles are moderated concurrently
+		for _, user := range users {
+			p.queueProfile(api, user)
+		}
+		completed := true
+		for _, user := range users {
+			if !p.moderateProfile(api, user) {
+				completed = false
+			}
+		}
+		if !completed {
+			return false
+		}
+
+		if len(users) < profileReconcilePageSize {
+			return true
+		}
+	}
+}
+
+// claimProfileReconcileWindow claims the reconciliation of the profiles updated
+// since the stored cursor and returns the cursor along with the stored claim.
+// Returns false if another node of the cluster holds the claim, in which case
+// that node reconciles the profiles, and on the first run, which only starts the
+// cursor.
+func claimProfileReconcileWindow(api plugin.API, now int64) (int64, []byte, bool) {
+	oldData, appErr := api.KVGet(profileReconcileCursorKVKey)
+	if appErr != nil {
+		api.LogError("Failed to get profile moderation cursor", "err", appErr)
+		return 0, nil, false
+	}
+
+	cursor := parseProfileReconcileCursor(api, oldData)
+	if cursor.ClaimedUntil > now {
+		return 0, nil, false
+	}
+
+	claimed := profileReconcileCursor{UpdatedAt: cursor.UpdatedAt, ClaimedUntil: now + profileReconcileClaimTTL.Milliseconds()}
+	if cursor.UpdatedAt == 0 {
+		claimed = profileReconcileCursor{UpdatedAt: now}
+	}
+	data, err := json.Marshal(claimed)
+	if err != nil {
+		api.LogError("Failed to marshal profile moderation cursor", "err", err)
+		return 0, nil, false
+	}
+
+	saved, appErr := api.KVCompareAndSet(profileReconcileCursorKVKey, oldData, data)
+	if appErr != nil {
+		api.LogError("Failed to store profile moderation cursor", "err", appErr)
+		return 0, nil, false
+	}
+	if !saved || cursor.UpdatedAt == 0 {
+		return 0, nil, false
+	}
+	return cursor.UpdatedAt, data, true
+}
+
+// releaseProfileReconcileWindow moves the cursor to updatedAt and releases the
+// claim, unless the claim expired and was taken over by another node
+func releaseProfileReconcileWindow(api plugin.API, claim []byte, updatedAt int64) {
+	data, err := json.Marshal(profileReconcileCursor{UpdatedAt: updatedAt})
+	if err != nil {
+		api.LogError("Failed to marshal profile moderation cursor", "err", err)
+		return
+	}
+
+	saved, appErr := api.KVCompareAndSet(profileReconcileCursorKVKey, claim, data)
+	if appErr != nil {
+		api.LogError("Failed to store profile moderation cursor", "err", appErr)
+		return
+	}
+	if !saved {
+		api.LogWarn("Profile moderation was taken over by another node before it completed")
+	}
+}
+
+// parseProfileReconcileCursor reads a stored cursor
+func parseProfileReconcileCursor(api plugin.API, data []byte) profileReconcileCursor {
+	var cursor profileReconcileCursor
+	if data == nil {
+		return cursor
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		api.LogWarn("Ignoring invalid profile moderation cursor", "err", err)
+		return profileReconcileCursor{}
+	}
+	return cursor
+}
+
+// queueProfile queues the fields of a profile for moderation. Returns false if the
+// profile does not need to be moderated: bots and excluded users are skipped, as
+// are profiles whose moderated fields did not change since they were moderated.
+func (p *PostProcessor) queueProfile(api plugin.API, user *model.User) bool {
+	if user.IsBot || user.Id == p.botID {
+		return false
+	}
+	if _, excluded := p.excludedUsers[user.Id]; excluded {
+		return false
+	}
+	values := profileValues(user)
+	if len(values) == 0 {
+		return false
+	}
+	if fingerprint, ok := p.profileFingerprints.Load(user.Id); ok && fingerprint == profileFingerprint(values) {
+		return false
+	}
+
+	if p.moderationProcessor != nil {
+		for _, value := range values {
+			p.moderationProcessor.queueMessage(api, value.value, moderationPriorityNormal)
+		}
+	}
+	return true
+}
+
+// moderateProfile moderates the nickname, full name, position and custom status
+// of a user and enforces the global policy: flagged fields are only reported if
+// the policy flags or monitors content, and reset otherwise. The reviewers, or
+// the system admins if there are none, are notified of flagged fields, and the
+// user is notified of reset fields. Returns false if the profile could not be
+// moderated, so it is moderated again, see retryProfile.
+func (p *PostProcessor) moderateProfile(api plugin.API, user *model.User) bool {
+	if !p.queueProfile(api, user) {
+		return true
+	}
+
+	record := plugin.MakeAuditRecord(auditEventTypeProfileModeration, model.AuditStatusAttempt)
+	record.AddMeta(auditMetaKeyTargetUserID, user.Id)
+
+	values := profileValues(user)
+	policy := p.defaultPolicy
+	deadline := time.Now().Add(waitForResultTimeout)
+	var flagged []profileValue
+	var flaggedCategories []string
+	completed := true
+	for _, value := range values {
+		result := p.resultsCache.waitForResult(value.value, time.Until(deadline))
+		if result == nil || result.code == moderationResultPending {
+			p.stats.recordTimeout()
+			p.metrics.recordTimeout()
+			errMsg := "Failed to complete content moderation of profile"
+			api.LogError(errMsg, "user_id", user.Id, "field", string(value.field), "err", context.DeadlineExceeded)
+			p.logAuditFail(api, record, errMsg, context.DeadlineExceeded)
+			return p.retryProfile(api, user, values)
+		}
+		if result.code == moderationResultError {
+			p.stats.recordError()
+			p.metrics.recordError()
+			api.LogError("Content moderation error", "err", result.err, "user_id", user.Id, "field", string(value.field))
+			completed = false
+			continue
+		}
+
+		p.stats.recordChecked()
+		p.metrics.recordChecked()
+		if policy.isFlagged(result) {
+			flagged = append(flagged, value)
+			flaggedCategories = append(flaggedCategories, policy.flaggedCategories(result.result)...)
+		}
+	}
+	// Profiles with fields that failed to be moderated are moderated again
+	if completed {
+		p.profileFingerprints.Store(user.Id, profileFingerprint(values))
+		p.clearProfileFailures(user.Id)
+	}
+
+	if len(flagged) == 0 {
+		record.AddMeta(auditMetaKeyFlagged, false)
+		p.logAuditSuccess(api, record)
+		if !completed {
+			return p.retryProfile(api, user, values)
+		}
+		return true
+	}
+
+	action := policy.enforcementAction()
+	p.stats.recordFlagged(flaggedCategories, action)
+	p.metrics.recordFlagged(flaggedCategories)
+	record.AddMeta(auditMetaKeyFlagged, true)
+	record.AddMeta(auditMetaKeyProfileFields, profileFieldNames(flagged))
+
+	reset := action != enforcementActionFlag && action != enforcementActionMonitor
+	if reset {
+		record.AddMeta(auditMetaKeyAction, "reset")
+		if err := resetProfileFields(api, user.Id, flagged); err != nil {
+			errMsg := "Failed to reset profile field flagged by content moderation"
+			api.LogError(errMsg, "user_id", user.Id, "err", err)
+			p.logAuditFail(api, record, errMsg, err)
+			return p.retryProfile(api, user, values)
+		}
+	} else {
+		record.AddMeta(auditMetaKeyAction, string(enforcementActionFlag))
+	}
+
+	// Fields that are left in place are reported once, even if the profile is
+	// moderated again because another field failed
+	if !reset {
+		flagged = p.unreportedProfileValues(user.Id, flagged)
+	}
+	if len(flagged) > 0 {
+		p.notifyProfileFlagged(api, user, flagged, reset)
+	}
+	p.logAuditSuccess(api, record)
+	if !completed {
+		return p.retryProfile(api, user, values)
+	}
+	return true
+}
+
+// retryProfile records that a profile could not be moderated and returns false,
+// so it is moderated again. Once it failed maxProfileModerationAttempts times,
+// the profile is skipped until it changes and true is returned.
+func (p *PostProcessor) retryProfile(api plugin.API, user *model.User, values []profileValue) bool {
+	p.profileFailuresLock.Lock()
+	if p.profileFailures == nil {
+		p.profileFailures = make(map[string]int)
+	}
+	p.profileFailures[user.Id]++
+	failures := p.profileFailures[user.Id]
+	if failures < maxProfileModerationAttempts {
+		p.profileFailuresLock.Unlock()
+		return false
+	}
+	delete(p.profileFailures, user.Id)
+	p.profileFailuresLock.Unlock()
+
+	api.LogWarn("Skipping profile moderation until the profile changes", "user_id", user.Id, "attempts", failures)
+	p.profileFingerprints.Store(user.Id, profileFingerprint(values))
+	return true
+}
+
+func (p *PostProcessor) clearProfileFailures(userID string) {
+	p.profileFailuresLock.Lock()
+	defer p.profileFailuresLock.Unlock()
+	delete(p.profileFailures, userID)
+}
+
+// unreportedProfileValues returns the flagged values of a profile that were not
+// reported yet, and marks them as reported
+func (p *PostProcessor) unreportedProfileValues(userID string, values []profileValue) []profileValue {
+	var unreported []profileValue
+	for _, value := range values {
+		key := userID + "\x00" + string(value.field) + "\x00" + value.value
+		if _, reported := p.profileReports.LoadOrStore(key, struct{}{}); !reported {
+			unreported = append(unreported, value)
+		}
+	}
+	return unreported
+}
+
+// resetProfileFields clears flagged fields of a profile, unless they changed again in the meantime
+func resetProfileFields(api plugin.API, userID string, values []profileValue) error {
+	user, appErr := api.GetUser(userID)
+	if appErr != nil {
+		return errors.Wrap(appErr, "failed to get user")
+	}
+
+	changed, removeCustomStatus := false, false
+	for _, value := range values {
+		if value.field == profileFieldCustomStatus {
+			if customStatus := user.GetCustomStatus(); customStatus != nil && customStatus.Text == value.value {
+				removeCustomStatus = true
+			}
+			continue
+		}
+		if field := profileFieldValue(user, value.field); field != nil && *field == value.value {
+			*field = ""
+			changed = true
+		}
+	}
+
+	if changed {
+		if _, appErr := api.UpdateUser(user); appErr != nil {
+			return errors.Wrap(appErr, "failed to update user")
+		}
+	}
+	if removeCustomStatus {
+		if appErr := api.RemoveUserCustomStatus(userID); appErr != nil {
+			return errors.Wrap(appErr, "failed to remove custom status")
+		}
+	}
+	return nil
+}
+
+// notifyProfileFlagged reports flagged profile fields and lets the user know which ones were reset
+func (p *PostProcessor) notifyProfileFlagged(api plugin.API, user *model.User, values []profileValue, reset bool) {
+	reportTemplate := profileFlaggedReportTemplate
+	if reset {
+		reportTemplate = profileResetReportTemplate
+	}
+
+	recipients := p.profileReportRecipients(api)
+	for _, value := range values {
+		quoted := quoteMessage(value.value)
+		if reset {
+			if err := p.sendDirectMessage(api, user.Id, fmt.Sprintf(profileResetDMTemplate, value.field, quoted)); err != nil {
+				api.LogError("Failed to notify user of flagged profile", "user_id", user.Id, "err", err)
+			}
+		}
+
+		message := fmt.Sprintf(reportTemplate, value.field, user.Username, quoted)
+		for _, recipientID := range recipients {
+			if err := p.sendDirectMessage(api, recipientID, message); err != nil {
+				api.LogError("Failed to report flagged profile", "user_id", user.Id, "recipient_id", recipientID, "err", err)
+			}
+		}
+	}
+}
+
+// profileReportRecipients returns the reviewers, or the system admins if no
+// reviewers are configured
+func (p *PostProcessor) profileReportRecipients(api plugin.API) []string {
+	var recipients []string
+	for reviewerID := range p.reviewers {
+		recipients = append(recipients, reviewerID)
+	}
+	if len(recipients) > 0 {
+		return recipients
+	}
+
+	admins, appErr := api.GetUsers(&model.UserGetOptions{
+		Role:    model.SystemAdminRoleId,
+		Active:  true,
+		PerPage: maxProfileReportRecipients,
+	})
+	if appErr != nil {
+		api.LogError("Failed to get system admins to report flagged profile", "err", appErr)
+		return nil
+	}
+	for _, admin := range admins {
+		if !admin.IsBot {
+			recipients = append(recipients, admin.Id)
+		}
+	}
+	return recipients
+}
+
+// profileFieldNames returns the names of the fields of profile values for the audit log
+func profileFieldNames(values []profileValue) []string {
+	names := make([]string, 0, len(values))
+	for _, value := range values {
+		names = append(names, string(value.field))
+	}
+	return names
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileValues(t *testing.T) {
+	user := &model.User{Nickname: "nick", FirstName: "Jane", LastName: " ", Position: "Engineer"}
+	require.NoError(t, user.SetCustomStatus(&model.CustomStatus{Emoji: "palm_tree", Text: "on vacation"}))
+
+	assert.Equal(t, []profileValue{
+		{profileFieldNickname, "nick"},
+		{profileFieldFirstName, "Jane"},
+		{profileFieldPosition, "Engineer"},
+		{profileFieldCustomStatus, "on vacation"},
+	}, profileValues(user))
+	assert.Empty(t, profileValues(&model.User{}))
+}
+
+func TestClaimProfileReconcileWindow(t *testing.T) {
+	claimedUntil := 2000 + profileReconcileClaimTTL.Milliseconds()
+
+	tests := []struct {
+		name           string
+		stored         []byte
+		expectedClaim  bool
+		expectedSince  int64
+		expectedStored profileReconcileCursor
+	}{
+		{
+			name:           "starts the cursor from now on the first run",
+			expectedStored: profileReconcileCursor{UpdatedAt: 2000},
+		},
+		{
+			name:           "claims the window since the cursor",
+			stored:         []byte(`{"updated_at":1000}`),
+			expectedClaim:  true,
+			expectedSince:  1000,
+			expectedStored: profileReconcileCursor{UpdatedAt: 1000, ClaimedUntil: claimedUntil},
+		},
+		{
+			name:           "skips the window claimed by another node",
+			stored:         []byte(`{"updated_at":1000,"claimed_until":3000}`),
+			expectedStored: profileReconcileCursor{UpdatedAt: 1000, ClaimedUntil: 3000},
+		},
+		{
+			name:           "takes over an expired claim",
+			stored:         []byte(`{"updated_at":1000,"claimed_until":1500}`),
+			expectedClaim:  true,
+			expectedSince:  1000,
+			expectedStored: profileReconcileCursor{UpdatedAt: 1000, ClaimedUntil: claimedUntil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newKVTestAPI()
+			if tt.stored != nil {
+				require.Nil(t, api.KVSet(profileReconcileCursorKVKey, tt.stored))
+			}
+
+			since, claim, ok := claimProfileReconcileWindow(api, 2000)
+			assert.Equal(t, tt.expectedClaim, ok)
+			assert.Equal(t, tt.expectedSince, since)
+
+			data, appErr := api.KVGet(profileReconcileCursorKVKey)
+			require.Nil(t, appErr)
+			assert.Equal(t, tt.expectedStored, parseProfileReconcileCursor(api, data))
+			if ok {
+				assert.Equal(t, data, claim)
+			}
+		})
+	}
+}
+
+func TestPostProcessor_reconcileProfiles(t *testing.T) {
+	newProcessor := func(t *testing.T, api *plugintest.API, moderator moderation.Moderator) *PostProcessor {
+		resultsCache := newModerationResultsCache()
+		moderationProcessor, err := newModerationProcessor(resultsCache, moderator, 4, nil, 6000, 10, 1, nil, nil, nil)
+		require.NoError(t, err)
+		moderationProcessor.start(api)
+		t.Cleanup(moderationProcessor.stop)
+
+		return &PostProcessor{
+			botID:               "bot123",
+			excludedUsers:       map[string]struct{}{},
+			defaultPolicy:       effectivePolicy{threshold: 4, action: enforcementActionFlag},
+			moderationProcessor: moderationProcessor,
+			moderateProfiles:    true,
+			resultsCache:        resultsCache,
+			done:                make(chan struct{}),
+		}
+	}
+
+	storedCursor := func(t *testing.T, api *plugintest.API) profileReconcileCursor {
+		data, appErr := api.KVGet(profileReconcileCursorKVKey)
+		require.Nil(t, appErr)
+		return parseProfileReconcileCursor(api, data)
+	}
+
+	t.Run("moves the cursor once all profiles were moderated", func(t *testing.T) {
+		api := newKVTestAPI()
+		require.Nil(t, api.KVSet(profileReconcileCursorKVKey, []byte(`{"updated_at":1000}`)))
+		api.On("GetUsers", mock.MatchedBy(func(options *model.UserGetOptions) bool {
+			return options.UpdatedAfter == 1000
+		})).Return([]*model.User{{Id: "user456", Position: "Engineer"}}, nil)
+
+		start := model.GetMillis()
+		newProcessor(t, api, &keywordModerator{}).reconcileProfiles(api)
+
+		cursor := storedCursor(t, api)
+		assert.GreaterOrEqual(t, cursor.UpdatedAt, start)
+		assert.Zero(t, cursor.ClaimedUntil, "the claim is released")
+	})
+
+	t.Run("keeps the cursor and releases the claim if profiles could not be listed", func(t *testing.T) {
+		api := newKVTestAPI()
+		require.Nil(t, api.KVSet(profileReconcileCursorKVKey, []byte(`{"updated_at":1000}`)))
+		api.On("GetUsers", mock.Anything).Return(nil, model.NewAppError("GetUsers", "error", nil, "", 500))
+		api.On("LogError", mock.Anything, mock.Anything, mock.Anything).Return()
+
+		newProcessor(t, api, &keywordModerator{}).reconcileProfiles(api)
+
+		assert.Equal(t, profileReconcileCursor{UpdatedAt: 1000}, storedCursor(t, api))
+	})
+
+	t.Run("keeps the cursor if a profile could not be moderated", func(t *testing.T) {
+		api := newKVTestAPI()
+		require.Nil(t, api.KVSet(profileReconcileCursorKVKey, []byte(`{"updated_at":1000}`)))
+		api.On("GetUsers", mock.Anything).Return([]*model.User{{Id: "user456", Position: "Engineer"}}, nil)
+		api.On("LogError", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+
+		newProcessor(t, api, &fakeModerator{err: errors.New("provider unavailable")}).reconcileProfiles(api)
+
+		assert.Equal(t, profileReconcileCursor{UpdatedAt: 1000}, storedCursor(t, api))
+	})
+
+	t.Run("reports a flagged field once and moves on from a field that keeps failing", func(t *testing.T) {
+		api := newKVTestAPI()
+		require.Nil(t, api.KVSet(profileReconcileCursorKVKey, []byte(`{"updated_at":1000}`)))
+		user := &model.User{Id: "user456", Username: "someone", Nickname: "offensive nick", Position: "broken position"}
+		api.On("GetUsers", mock.Anything).Return([]*model.User{user}, nil)
+		api.On("GetDirectChannel", "bot123", "reviewer789").Return(&model.Channel{Id: "dm_channel"}, nil)
+		api.On("CreatePost", mock.Anything).Return(&model.Post{}, nil)
+		api.On("LogError", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+		api.On("LogWarn", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+
+		processor := newProcessor(t, api, &failingFieldModerator{})
+		processor.reviewers = map[string]struct{}{"reviewer789": {}}
+		for attempt := 1; attempt < maxProfileModerationAttempts; attempt++ {
+			processor.reconcileProfiles(api)
+			assert.Equal(t, profileReconcileCursor{UpdatedAt: 1000}, storedCursor(t, api))
+		}
+
+		start := model.GetMillis()
+		processor.reconcileProfiles(api)
+		assert.GreaterOrEqual(t, storedCursor(t, api).UpdatedAt, start, "the cursor moves once the profile is skipped")
+		api.AssertNumberOfCalls(t, "CreatePost", 1)
+
+		processor.reconcileProfiles(api)
+		api.AssertNumberOfCalls(t, "CreatePost", 1)
+	})
+}
+
+// failingFieldModerator flags text containing "offensive" and fails to moderate text containing "broken"
+type failingFieldModerator struct {
+	keywordModerator
+}
+
+func (m *failingFieldModerator) ModerateText(ctx context.Context, text string) (moderation.Result, error) {
+	if strings.Contains(text, "broken") {
+		return nil, errors.New("invalid request")
+	}
+	return m.keywordModerator.ModerateText(ctx, text)
+}
+
+func TestPostProcessor_moderateProfile(t *testing.T) {
+	newProcessor := func(t *testing.T, api *plugintest.API, action enforcementAction) *PostProcessor {
+		resultsCache := newModerationResultsCache()
+		moderationProcessor, err := newModerationProcessor(resultsCache, &keywordModerator{}, 4, nil, 6000, 10, 1, nil, nil, nil)
+		require.NoError(t, err)
+		moderationProcessor.start(api)
+		t.Cleanup(moderationProcessor.stop)
+
+		return &PostProcessor{
+			botID:               "bot123",
+			excludedUsers:       map[string]struct{}{},
+			defaultPolicy:       effectivePolicy{threshold: 4, action: action},
+			reviewers:           map[string]struct{}{"reviewer789": {}},
+			moderationProcessor: moderationProcessor,
+			moderateProfiles:    true,
+			resultsCache:        resultsCache,
+		}
+	}
+
+	newAPI := func(user *model.User) *plugintest.API {
+		api := &plugintest.API{}
+		api.On("GetUser", user.Id).Return(user, nil)
+		api.On("GetDirectChannel", "bot123", mock.Anything).Return(&model.Channel{Id: "dm_channel"}, nil)
+		api.On("CreatePost", mock.Anything).Return(&model.Post{}, nil)
+		return api
+	}
+
+	t.Run("resets a flagged nickname", func(t *testing.T) {
+		user := &model.User{Id: "user456", Username: "someone", Nickname: "offensive nick", FirstName: "Jane"}
+		api := newAPI(user.DeepCopy())
+		api.On("UpdateUser", mock.MatchedBy(func(updated *model.User) bool {
+			return updated.Nickname == "" && updated.FirstName == "Jane"
+		})).Return(&model.User{}, nil)
+
+		processor := newProcessor(t, api, enforcementActionDelete)
+		processor.moderateProfile(api, user)
+
+		api.AssertCalled(t, "UpdateUser", mock.Anything)
+		api.AssertCalled(t, "GetDirectChannel", "bot123", "user456")
+		api.AssertCalled(t, "GetDirectChannel", "bot123", "reviewer789")
+	})
+
+	t.Run("removes a flagged custom status", func(t *testing.T) {
+		user := &model.User{Id: "user456", Username: "someone", FirstName: "Jane"}
+		require.NoError(t, user.SetCustomStatus(&model.CustomStatus{Text: "feeling offensive"}))
+		api := newAPI(user.DeepCopy())
+		api.On("RemoveUserCustomStatus", "user456").Return(nil)
+
+		processor := newProcessor(t, api, enforcementActionDelete)
+		processor.moderateProfile(api, user)
+
+		api.AssertCalled(t, "RemoveUserCustomStatus", "user456")
+		api.AssertNotCalled(t, "UpdateUser", mock.Anything)
+	})
+
+	t.Run("only reports if the policy flags content", func(t *testing.T) {
+		user := &model.User{Id: "user456", Username: "someone", Position: "offensive position"}
+		api := newAPI(user)
+
+		processor := newProcessor(t, api, enforcementActionFlag)
+		processor.moderateProfile(api, user)
+
+		api.AssertNotCalled(t, "UpdateUser", mock.Anything)
+		api.AssertNotCalled(t, "GetDirectChannel", "bot123", "user456")
+		api.AssertCalled(t, "GetDirectChannel", "bot123", "reviewer789")
+	})
+
+	t.Run("skips unchanged profiles", func(t *testing.T) {
+		user := &model.User{Id: "user456", Username: "someone", Position: "offensive position"}
+		api := newAPI(user)
+
+		processor := newProcessor(t, api, enforcementActionFlag)
+		processor.moderateProfile(api, user)
+		processor.moderateProfile(api, user)
+
+		api.AssertNumberOfCalls(t, "GetDirectChannel", 1)
+	})
+
+	t.Run("skips bots and excluded users", func(t *testing.T) {
+		api := &plugintest.API{}
+		processor := newProcessor(t, api, enforcementActionDelete)
+		processor.excludedUsers["user456"] = struct{}{}
+
+		processor.moderateProfile(api, &model.User{Id: "user456", Nickname: "offensive nick"})
+		processor.moderateProfile(api, &model.User{Id: "bot000", IsBot: true, Nickname: "offensive nick"})
+		api.AssertExpectations(t)
+	})
+}
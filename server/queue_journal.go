@@ -0,0 +1,115 @@
+package main
+
+import (
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+)
+
+// resumeQueuedPosts queues the posts that were still waiting to be moderated when
+// the processors were last stopped. Posts journaled on this node are resumed once
+// the previous post processor finished the post it was processing, and posts of
+// nodes that are no longer running are claimed from them, so no post is processed
+// twice. Posts journaled after journaledBefore were queued on the new processor.
+// Posts that have been waiting longer than catchUpAge are checked one at a time so
+// they don't hold up new posts.
+func (p *Plugin) resumeQueuedPosts(moderationProcessor *ModerationProcessor, postProcessor, previous *PostProcessor, journaledBefore int64, catchUpAge time.Duration) {
+	if p.queueJournal == nil {
+		return
+	}
+	if previous != nil {
+		previous.running.Wait()
+	}
+
+	queued, err := p.queueJournal.ListPosts()
+	if err != nil {
+		p.API.LogError("Failed to resume moderation of queued posts", "err", err)
+		return
+	}
+	if len(queued) == 0 {
+		return
+	}
+
+	nodeID := p.queueJournal.NodeID()
+	runningNodes := make(map[string]bool)
+	catchUpBefore := time.Now().Add(-catchUpAge).UnixMilli()
+	var resumed int
+	var catchUp []*model.Post
+	for _, entry := range queued {
+		if entry.NodeID == nodeID {
+			if entry.QueuedAt > journaledBefore {
+				continue
+			}
+		} else if !p.claimQueuedPost(entry, runningNodes) {
+			continue
+		}
+
+		post, appErr := p.API.GetPost(entry.PostID)
+		if appErr != nil || post.DeleteAt != 0 {
+			// The post was deleted in the meantime, so there is nothing left to moderate
+			postProcessor.removeFromJournal(p.API, entry.PostID)
+			continue
+		}
+
+		if entry.QueuedAt < catchUpBefore {
+			catchUp = append(catchUp, post)
+			continue
+		}
+		queuePostMessage(p.API, moderationProcessor, postProcessor, post, p.getConfiguration().LargeChannelMemberCountValue())
+		postProcessor.resumePost(p.API, post)
+		resumed++
+	}
+
+	p.API.LogInfo("Resumed moderation of queued posts", "resumed", resumed, "catch_up", len(catchUp))
+	if len(catchUp) > 0 {
+		catchUpQueuedPosts(p.API, moderationProcessor, postProcessor, catchUp)
+	}
+}
+
+// claimQueuedPost returns true if this node took over a post journaled by a node
+// that is no longer running. runningNodes caches which nodes are running.
+func (p *Plugin) claimQueuedPost(entry QueuedPost, runningNodes map[string]bool) bool {
+	if p.nodeLease == nil {
+		return false
+	}
+
+	running, ok := runningNodes[entry.NodeID]
+	if !ok {
+		var err error
+		if running, err = p.nodeLease.isNodeRunning(entry.NodeID); err != nil {
+			p.API.LogWarn("Failed to check whether the node of a queued post is running", "node_id", entry.NodeID, "err", err)
+			running = true
+		}
+		runningNodes[entry.NodeID] = running
+	}
+	if running {
+		return false
+	}
+
+	claimed, err := p.queueJournal.ClaimPost(entry)
+	if err != nil {
+		p.API.LogWarn("Failed to claim queued post", "post_id", entry.PostID, "err", err)
+		return false
+	}
+	return claimed
+}
+
+// catchUpQueuedPosts queues each post once the previous one has been moderated,
+// until all posts are queued or the post processor is stopped
+func catchUpQueuedPosts(api plugin.API, moderationProcessor *ModerationProcessor, postProcessor *PostProcessor, posts []*model.Post) {
+	for _, post := range posts {
+		select {
+		case <-postProcessor.done:
+			return
+		default:
+		}
+
+		text := postText(post)
+		moderationProcessor.queueMessage(api, text, moderationPriorityNormal)
+		postProcessor.resumePost(api, post)
+		if text != "" {
+			postProcessor.resultsCache.waitForResult(text, waitForResultTimeout)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+const (
+	queueJournalKVKeyPrefix = "queued_post_"
+
+	// queueJournalRetention is how long a post stays in the journal if it is never processed
+	queueJournalRetention = 24 * time.Hour
+
+	queueJournalListPageSize = 1000
+)
+
+// QueuedPost records a post that was queued for moderation but not processed yet
+type QueuedPost struct {
+	PostID   string `json:"post_id"`
+	QueuedAt int64  `json:"queued_at"`
+
+	// NodeID is the node processing the post
+	NodeID string `json:"node_id"`
+}
+
+type QueueJournal interface {
+	AddPost(postID string) error
+	RemovePost(postID string) error
+	ListPosts() ([]QueuedPost, error)
+	// ClaimPost makes this node process a post journaled by another node, keeping
+	// the time it was queued. Returns false if another node claimed it first.
+	ClaimPost(post QueuedPost) (bool, error)
+	// NodeID returns the node that journals posts
+	NodeID() string
+}
+
+type queueJournal struct {
+	api    plugin.API
+	nodeID string
+}
+
+func newQueueJournal(api plugin.API, nodeID string) *queueJournal {
+	return &queueJournal{
+		api:    api,
+		nodeID: nodeID,
+	}
+}
+
+func (j *queueJournal) NodeID() string {
+	return j.nodeID
+}
+
+func (j *queueJournal) AddPost(postID string) error {
+	data, err := json.Marshal(QueuedPost{PostID: postID, QueuedAt: model.GetMillis(), NodeID: j.nodeID})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal queued post")
+	}
+
+	if appErr := j.api.KVSetWithExpiry(queueJournalKVKeyPrefix+postID, data, int64(queueJournalRetention/time.Second)); appErr != nil {
+		return errors.Wrap(appErr, "failed to store queued post")
+	}
+	return nil
+}
+
+func (j *queueJournal) ClaimPost(post QueuedPost) (bool, error) {
+	key := queueJournalKVKeyPrefix + post.PostID
+	oldData, appErr := j.api.KVGet(key)
+	if appErr != nil {
+		return false, errors.Wrap(appErr, "failed to get queued post")
+	}
+	if oldData == nil {
+		return false, nil
+	}
+
+	var current QueuedPost
+	if err := json.Unmarshal(oldData, &current); err != nil {
+		return false, errors.Wrap(err, "failed to unmarshal queued post")
+	}
+	if current.NodeID != post.NodeID {
+		return false, nil
+	}
+
+	current.NodeID = j.nodeID
+	data, err := json.Marshal(current)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to marshal queued post")
+	}
+
+	// The post keeps the expiry it got when it was first queued
+	expiry := queueJournalRetention - time.Since(time.UnixMilli(current.QueuedAt))
+	if expiry < time.Second {
+		expiry = time.Second
+	}
+	saved, appErr := j.api.KVSetWithOptions(key, data, model.PluginKVSetOptions{
+		Atomic:          true,
+		OldValue:        oldData,
+		ExpireInSeconds: int64(expiry / time.Second),
+	})
+	if appErr != nil {
+		return false, errors.Wrap(appErr, "failed to claim queued post")
+	}
+	return saved, nil
+}
+
+func (j *queueJournal) RemovePost(postID string) error {
+	if appErr := j.api.KVDelete(queueJournalKVKeyPrefix + postID); appErr != nil {
+		return errors.Wrap(appErr, "failed to remove queued post")
+	}
+	return nil
+}
+
+// ListPosts returns the posts in the journal. It goes through every key of the
+// plugin, so it is only meant to be called when the plugin is initialized.
+func (j *queueJournal) ListPosts() ([]QueuedPost, error) {
+	var posts []QueuedPost
+	for page := 0; ; page++ {
+		keys, appErr := j.api.KVList(page, queueJournalListPageSize)
+		if appErr != nil {
+			return nil, errors.Wrap(appErr, "failed to list queued posts")
+		}
+
+		for _, key := range keys {
+			if !strings.HasPrefix(key, queueJournalKVKeyPrefix) {
+				continue
+			}
+			data, appErr := j.api.KVGet(key)
+			if appErr != nil {
+				return nil, errors.Wrap(appErr, "failed to get queued post")
+			}
+			if data == nil {
+				continue
+			}
+
+			var post QueuedPost
+			if err := json.Unmarshal(data, &post); err != nil {
+				return nil, errors.Wrap(err, "failed to unmarshal queued post")
+			}
+			posts = append(posts, post)
+		}
+
+		if len(keys) < queueJournalListPageSize {
+			return posts, nil
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var _ QueueJournal = (*MockQueueJournal)(nil)
+
+type MockQueueJournal struct {
+	lock   sync.Mutex
+	posts  map[string]QueuedPost
+	nodeID string
+}
+
+func NewMockQueueJournal(posts ...QueuedPost) *MockQueueJournal {
+	m := &MockQueueJournal{posts: make(map[string]QueuedPost), nodeID: "node1"}
+	for _, post := range posts {
+		m.posts[post.PostID] = post
+	}
+	return m
+}
+
+func (m *MockQueueJournal) NodeID() string {
+	return m.nodeID
+}
+
+func (m *MockQueueJournal) AddPost(postID string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.posts[postID] = QueuedPost{PostID: postID, QueuedAt: model.GetMillis(), NodeID: m.nodeID}
+	return nil
+}
+
+func (m *MockQueueJournal) ClaimPost(post QueuedPost) (bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	current, ok := m.posts[post.PostID]
+	if !ok || current.NodeID != post.NodeID {
+		return false, nil
+	}
+	current.NodeID = m.nodeID
+	m.posts[post.PostID] = current
+	return true, nil
+}
+
+func (m *MockQueueJournal) get(postID string) QueuedPost {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.posts[postID]
+}
+
+func (m *MockQueueJournal) RemovePost(postID string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.posts, postID)
+	return nil
+}
+
+func (m *MockQueueJournal) ListPosts() ([]QueuedPost, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var posts []QueuedPost
+	for _, post := range m.posts {
+		posts = append(posts, post)
+	}
+	return posts, nil
+}
+
+func (m *MockQueueJournal) contains(postID string) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	_, ok := m.posts[postID]
+	return ok
+}
+
+func TestQueueJournal_ListPosts(t *testing.T) {
+	api := &plugintest.API{}
+	data, err := json.Marshal(QueuedPost{PostID: "post123", QueuedAt: 1000, NodeID: "node2"})
+	require.NoError(t, err)
+	api.On("KVList", 0, queueJournalListPageSize).Return([]string{strikesIndexKVKey, queueJournalKVKeyPrefix + "post123"}, nil)
+	api.On("KVGet", queueJournalKVKeyPrefix+"post123").Return(data, nil)
+
+	posts, err := newQueueJournal(api, "node1").ListPosts()
+	require.NoError(t, err)
+	assert.Equal(t, []QueuedPost{{PostID: "post123", QueuedAt: 1000, NodeID: "node2"}}, posts)
+	api.AssertExpectations(t)
+}
+
+func TestPostProcessor_queuePostJournal(t *testing.T) {
+	journal := NewMockQueueJournal()
+	processor := &PostProcessor{
+		journal: journal,
+		postsCh: make(chan *model.Post, 1),
+	}
+	api := &plugintest.API{}
+	api.On("LogError", mock.Anything, mock.Anything, mock.Anything).Return()
+
+	processor.queuePost(api, &model.Post{Id: "post1", Message: "hello"})
+	assert.True(t, journal.contains("post1"))
+
+	// The queue is full, so the post is not kept in the journal
+	processor.queuePost(api, &model.Post{Id: "post2", Message: "hello"})
+	assert.False(t, journal.contains("post2"))
+}
+
+func TestPlugin_resumeQueuedPosts(t *testing.T) {
+	now := model.GetMillis()
+	journal := NewMockQueueJournal(
+		QueuedPost{PostID: "recent", QueuedAt: now, NodeID: "node1"},
+		QueuedPost{PostID: "deleted", QueuedAt: now, NodeID: "node1"},
+		QueuedPost{PostID: "old", QueuedAt: now - time.Hour.Milliseconds(), NodeID: "node1"},
+	)
+
+	api := &plugintest.API{}
+	api.On("GetPost", "recent").Return(&model.Post{Id: "recent", UserId: "user1", ChannelId: "channel1", Message: "recent message"}, nil)
+	api.On("GetPost", "deleted").Return(nil, model.NewAppError("GetPost", "not_found", nil, "", 404))
+	api.On("GetPost", "old").Return(&model.Post{Id: "old", UserId: "user1", ChannelId: "channel1", Message: "old message"}, nil)
+	api.On("GetUser", "user1").Return(&model.User{Id: "user1", Roles: model.SystemUserRoleId}, nil)
+	api.On("GetChannel", "channel1").Return(&model.Channel{Id: "channel1", Type: model.ChannelTypePrivate}, nil)
+	api.On("LogInfo", "Resumed moderation of queued posts", "resumed", 1, "catch_up", 1).Return()
+
+	resultsCache := newModerationResultsCache()
+	moderationProcessor, err := newModerationProcessor(resultsCache, &fakeModerator{}, 4, nil, 500, 10, 1, nil, nil, nil)
+	require.NoError(t, err)
+	postProcessor := &PostProcessor{
+		excludedChannelStore: NewMockExcludedChannelsStore([]string{}),
+		journal:              journal,
+		resultsCache:         resultsCache,
+		postsCh:              make(chan *model.Post, 10),
+		done:                 make(chan struct{}),
+	}
+
+	// Complete the old post's catch-up without a running moderation processor
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		resultsCache.setModerationResultNotFlagged("old message", nil)
+	}()
+
+	p := &Plugin{configuration: &configuration{}, queueJournal: journal}
+	p.SetAPI(api)
+	p.resumeQueuedPosts(moderationProcessor, postProcessor, nil, now, 10*time.Minute)
+
+	assert.False(t, journal.contains("deleted"))
+	require.Len(t, postProcessor.postsCh, 2)
+	assert.Equal(t, "recent", (<-postProcessor.postsCh).Id)
+	assert.Equal(t, "old", (<-postProcessor.postsCh).Id)
+	api.AssertExpectations(t)
+}
+
+func TestQueueJournal_ClaimPost(t *testing.T) {
+	api := newKVTestAPI()
+	require.NoError(t, newQueueJournal(api, "node2").AddPost("post123"))
+	data, appErr := api.KVGet(queueJournalKVKeyPrefix + "post123")
+	require.Nil(t, appErr)
+	var entry QueuedPost
+	require.NoError(t, json.Unmarshal(data, &entry))
+
+	journal := newQueueJournal(api, "node1")
+	claimed, err := journal.ClaimPost(entry)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	data, appErr = api.KVGet(queueJournalKVKeyPrefix + "post123")
+	require.Nil(t, appErr)
+	var claimedEntry QueuedPost
+	require.NoError(t, json.Unmarshal(data, &claimedEntry))
+	assert.Equal(t, QueuedPost{PostID: "post123", QueuedAt: entry.QueuedAt, NodeID: "node1"}, claimedEntry)
+
+	// Another node that saw the same entry lost the race
+	claimed, err = newQueueJournal(api, "node3").ClaimPost(entry)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+}
+
+func TestPlugin_resumeQueuedPosts_owners(t *testing.T) {
+	now := model.GetMillis()
+	journal := NewMockQueueJournal(
+		QueuedPost{PostID: "own", QueuedAt: now - 2000, NodeID: "node1"},
+		QueuedPost{PostID: "new", QueuedAt: now + 1000, NodeID: "node1"},
+		QueuedPost{PostID: "running", QueuedAt: now - 2000, NodeID: "node2"},
+		QueuedPost{PostID: "stopped", QueuedAt: now - 2000, NodeID: "node3"},
+	)
+
+	api := newKVTestAPI()
+	for _, postID := range []string{"own", "new", "running", "stopped"} {
+		api.On("GetPost", postID).Return(&model.Post{Id: postID, UserId: "user1", ChannelId: "channel1", Message: postID + " message"}, nil).Maybe()
+	}
+	api.On("GetUser", "user1").Return(&model.User{Id: "user1", Roles: model.SystemUserRoleId}, nil)
+	api.On("GetChannel", "channel1").Return(&model.Channel{Id: "channel1", Type: model.ChannelTypePrivate}, nil)
+	api.On("LogInfo", "Resumed moderation of queued posts", "resumed", 2, "catch_up", 0).Return()
+
+	lease := newNodeLease(api)
+	lease.nodeID = "node1"
+	require.Nil(t, api.KVSetWithExpiry(nodeLeaseKVKeyPrefix+"node2", []byte{1}, 60))
+
+	moderationProcessor, err := newModerationProcessor(newModerationResultsCache(), &fakeModerator{}, 4, nil, 500, 10, 1, nil, nil, nil)
+	require.NoError(t, err)
+	postProcessor := &PostProcessor{
+		excludedChannelStore: NewMockExcludedChannelsStore([]string{}),
+		journal:              journal,
+		resultsCache:         newModerationResultsCache(),
+		postsCh:              make(chan *model.Post, 10),
+		done:                 make(chan struct{}),
+	}
+
+	p := &Plugin{configuration: &configuration{}, queueJournal: journal, nodeLease: lease}
+	p.SetAPI(api)
+	p.resumeQueuedPosts(moderationProcessor, postProcessor, nil, now, 10*time.Minute)
+
+	var resumed []string
+	for len(postProcessor.postsCh) > 0 {
+		resumed = append(resumed, (<-postProcessor.postsCh).Id)
+	}
+	assert.ElementsMatch(t, []string{"own", "stopped"}, resumed)
+	assert.Equal(t, QueuedPost{PostID: "stopped", QueuedAt: now - 2000, NodeID: "node1"}, journal.get("stopped"))
+	assert.Equal(t, "node2", journal.get("running").NodeID)
+	api.AssertNotCalled(t, "GetPost", "running")
+	api.AssertNotCalled(t, "GetPost", "new")
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	reviewApprovedDMTemplate = "_Your post with the following content was reviewed by a moderator and has been restored:_\n\n%s"
+	reviewRemovedDMTemplate  = "_Your post with the following content was reviewed by a moderator and removed:_\n\n%s"
+)
+
+var ErrReviewItemNotFound = errors.New("post is not waiting for review")
+
+// isReviewer returns true if the user may approve or remove posts held for review
+func (p *Plugin) isReviewer(userID string) bool {
+	if p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+		return true
+	}
+	_, ok := p.getConfiguration().ReviewerSet()[userID]
+	return ok
+}
+
+// approveReviewItem restores a post held for review and lets the author know
+func (p *Plugin) approveReviewItem(postID string, auditRecord *model.AuditRecord) error {
+	item, err := p.getReviewItem(postID, auditRecord)
+	if err != nil {
+		return err
+	}
+	auditRecord.AddMeta(auditMetaKeyResult, item.Result)
+
+	// Restoring the post triggers the update hooks, so the content needs to be
+	// approved first to prevent it from being flagged again
+	if err := approveContent(p.API, item.UserID, item.ChannelID, item.Message); err != nil {
+		return err
+	}
+	if _, err := restoreHiddenPost(p.API, postID); err != nil {
+		return err
+	}
+	if err := p.reviewStore.RemoveItem(postID); err != nil {
+		return errors.Wrap(err, "failed to remove post from the review queue")
+	}
+
+	p.notifyReviewedAuthor(item, reviewApprovedDMTemplate)
+	return nil
+}
+
+// removeReviewItem deletes a post held for review and lets the author know
+func (p *Plugin) removeReviewItem(postID string, auditRecord *model.AuditRecord) error {
+	item, err := p.getReviewItem(postID, auditRecord)
+	if err != nil {
+		return err
+	}
+	auditRecord.AddMeta(auditMetaKeyResult, item.Result)
+
+	if err := deleteHiddenPost(p.API, postID); err != nil {
+		return err
+	}
+	if err := p.reviewStore.RemoveItem(postID); err != nil {
+		return errors.Wrap(err, "failed to remove post from the review queue")
+	}
+	p.postProcessor.recordStrike(p.API, &model.Post{
+		Id:        item.PostID,
+		UserId:    item.UserID,
+		ChannelId: item.ChannelID,
+	}, item.Result, auditRecord)
+
+	p.notifyReviewedAuthor(item, reviewRemovedDMTemplate)
+	return nil
+}
+
+func (p *Plugin) getReviewItem(postID string, auditRecord *model.AuditRecord) (*ReviewItem, error) {
+	auditRecord.AddMeta(auditMetaKeyPostID, postID)
+
+	if p.postProcessor == nil {
+		return nil, ErrModerationUnavailable
+	}
+
+	item, err := p.reviewStore.GetItem(postID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get review item")
+	}
+	if item == nil {
+		return nil, ErrReviewItemNotFound
+	}
+	auditRecord.AddMeta(auditMetaKeyChannelID, item.ChannelID)
+	return item, nil
+}
+
+func (p *Plugin) notifyReviewedAuthor(item *ReviewItem, template string) {
+	if err := p.postProcessor.sendDirectMessage(p.API, item.UserID, fmt.Sprintf(template, item.Message)); err != nil {
+		p.API.LogError("Failed to notify author of review decision", "post_id", item.PostID, "user_id", item.UserID, "err", err)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+const (
+	reviewItemKVKeyPrefix = "review_item_"
+
+	// reviewIndexKVKey lists the post IDs of the items waiting for review
+	reviewIndexKVKey = "review_index"
+)
+
+// ReviewItem is a flagged post that is hidden until a reviewer approves or removes it
+type ReviewItem struct {
+	PostID    string            `json:"post_id"`
+	UserID    string            `json:"user_id"`
+	ChannelID string            `json:"channel_id"`
+	Message   string            `json:"message"`
+	Result    moderation.Result `json:"result"`
+	FlaggedAt int64             `json:"flagged_at"`
+}
+
+type ReviewStore interface {
+	AddItem(item *ReviewItem) error
+	GetItem(postID string) (*ReviewItem, error)
+	RemoveItem(postID string) error
+	ListItems() ([]*ReviewItem, error)
+}
+
+// reviewStore keeps each item under its own key and the post IDs of all items in
+// an index, so the queue can grow without rewriting it as a whole and every node
+// of a cluster sees the same items
+type reviewStore struct {
+	api   plugin.API
+	index kvIndex
+}
+
+func newReviewStore(api plugin.API) (*reviewStore, error) {
+	return &reviewStore{
+		api:   api,
+		index: newKVIndex(api, reviewIndexKVKey),
+	}, nil
+}
+
+func (s *reviewStore) AddItem(item *ReviewItem) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal review item")
+	}
+	if appErr := s.api.KVSet(reviewItemKVKeyPrefix+item.PostID, data); appErr != nil {
+		return errors.Wrap(appErr, "failed to store review item")
+	}
+
+	if err := s.index.add(item.PostID); err != nil {
+		return errors.Wrap(err, "failed to add review item to index")
+	}
+	return nil
+}
+
+func (s *reviewStore) GetItem(postID string) (*ReviewItem, error) {
+	data, appErr := s.api.KVGet(reviewItemKVKeyPrefix + postID)
+	if appErr != nil {
+		return nil, errors.Wrap(appErr, "failed to get review item")
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var item ReviewItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal review item")
+	}
+	return &item, nil
+}
+
+func (s *reviewStore) RemoveItem(postID string) error {
+	if appErr := s.api.KVDelete(reviewItemKVKeyPrefix + postID); appErr != nil {
+		return errors.Wrap(appErr, "failed to delete review item")
+	}
+
+	if err := s.index.remove(postID); err != nil {
+		return errors.Wrap(err, "failed to remove review item from index")
+	}
+	return nil
+}
+
+// ListItems returns the items waiting for review, oldest first
+func (s *reviewStore) ListItems() ([]*ReviewItem, error) {
+	postIDs, err := s.index.list()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list review items")
+	}
+
+	items := make([]*ReviewItem, 0, len(postIDs))
+	for _, postID := range postIDs {
+		item, err := s.GetItem(postID)
+		if err != nil {
+			return nil, err
+		}
+		// Removed by another node between reading the index and the item
+		if item == nil {
+			continue
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].FlaggedAt < items[j].FlaggedAt
+	})
+	return items, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReviewStore(t *testing.T) {
+	api := newKVTestAPI()
+	store, err := newReviewStore(api)
+	require.NoError(t, err)
+
+	require.NoError(t, store.AddItem(&ReviewItem{PostID: "post2", Message: "second", FlaggedAt: 2}))
+	require.NoError(t, store.AddItem(&ReviewItem{PostID: "post1", Message: "first", FlaggedAt: 1}))
+	require.NoError(t, store.AddItem(&ReviewItem{PostID: "post1", Message: "first", FlaggedAt: 1}))
+
+	// Another node sees the items through the KV store
+	other, err := newReviewStore(api)
+	require.NoError(t, err)
+	item, err := other.GetItem("post2")
+	require.NoError(t, err)
+	require.NotNil(t, item)
+	assert.Equal(t, "second", item.Message)
+
+	items, err := other.ListItems()
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, "post1", items[0].PostID, "oldest items come first")
+	assert.Equal(t, "post2", items[1].PostID)
+
+	require.NoError(t, other.RemoveItem("post1"))
+	item, err = store.GetItem("post1")
+	require.NoError(t, err)
+	assert.Nil(t, item)
+	items, err = store.ListItems()
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "post2", items[0].PostID)
+}
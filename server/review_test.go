@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newReviewTestPlugin(api *plugintest.API, reviews *MockReviewStore) *Plugin {
+	p := &Plugin{
+		configuration: &configuration{},
+		reviewStore:   reviews,
+		postProcessor: &PostProcessor{
+			botID:         "bot123",
+			postCache:     newPostCache(),
+			cleanupTicker: time.NewTicker(24 * time.Hour),
+		},
+	}
+	p.SetAPI(api)
+	return p
+}
+
+func TestPlugin_approveReviewItem(t *testing.T) {
+	t.Run("restores hidden post and approves its content", func(t *testing.T) {
+		reviews := NewMockReviewStore()
+		reviews.items["post123"] = &ReviewItem{
+			PostID:    "post123",
+			UserID:    "user456",
+			ChannelID: "channel123",
+			Message:   "original message",
+		}
+
+		original := &model.Post{Id: "post123", UserId: "user456", ChannelId: "channel123", Message: "original message"}
+		hiddenData, err := json.Marshal(HiddenPost{Post: original})
+		require.NoError(t, err)
+
+		api := &plugintest.API{}
+		api.On("KVSetWithExpiry", approvedContentKey("user456", "channel123", "original message"), []byte("1"), mock.Anything).Return(nil)
+		api.On("KVGet", hiddenPostKVKeyPrefix+"post123").Return(hiddenData, nil)
+		api.On("GetPost", "post123").Return(&model.Post{Id: "post123", Message: hiddenPostMessage}, nil)
+		api.On("UpdatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.Id == "post123" && post.Message == "original message"
+		})).Return(original, nil)
+		api.On("KVDelete", hiddenPostKVKeyPrefix+"post123").Return(nil)
+		api.On("GetDirectChannel", "bot123", "user456").Return(&model.Channel{Id: "dm_channel"}, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "dm_channel"
+		})).Return(&model.Post{}, nil)
+
+		p := newReviewTestPlugin(api, reviews)
+		record := plugin.MakeAuditRecord("test", model.AuditStatusAttempt)
+
+		require.NoError(t, p.approveReviewItem("post123", record))
+		assert.Empty(t, reviews.items)
+		api.AssertExpectations(t)
+	})
+
+	t.Run("returns not found for unknown post", func(t *testing.T) {
+		api := &plugintest.API{}
+		p := newReviewTestPlugin(api, NewMockReviewStore())
+		record := plugin.MakeAuditRecord("test", model.AuditStatusAttempt)
+
+		err := p.approveReviewItem("post123", record)
+		assert.ErrorIs(t, err, ErrReviewItemNotFound)
+		api.AssertExpectations(t)
+	})
+}
+
+func TestPlugin_removeReviewItem(t *testing.T) {
+	t.Run("deletes hidden post", func(t *testing.T) {
+		reviews := NewMockReviewStore()
+		reviews.items["post123"] = &ReviewItem{
+			PostID:    "post123",
+			UserID:    "user456",
+			ChannelID: "channel123",
+			Message:   "original message",
+		}
+
+		api := &plugintest.API{}
+		api.On("DeletePost", "post123").Return(nil)
+		api.On("KVDelete", hiddenPostKVKeyPrefix+"post123").Return(nil)
+		api.On("GetDirectChannel", "bot123", "user456").Return(&model.Channel{Id: "dm_channel"}, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "dm_channel"
+		})).Return(&model.Post{}, nil)
+
+		p := newReviewTestPlugin(api, reviews)
+		record := plugin.MakeAuditRecord("test", model.AuditStatusAttempt)
+
+		require.NoError(t, p.removeReviewItem("post123", record))
+		assert.Empty(t, reviews.items)
+		api.AssertExpectations(t)
+	})
+}
+
+// failingReviewStore fails to add items
+type failingReviewStore struct {
+	*MockReviewStore
+}
+
+func (s *failingReviewStore) AddItem(*ReviewItem) error {
+	return errors.New("store unavailable")
+}
+
+func TestPostProcessor_enforce_review(t *testing.T) {
+	post := &model.Post{Id: "post123", UserId: "user456", ChannelId: "channel123", Message: "test message"}
+	record := plugin.MakeAuditRecord(auditEventTypeContentModeration, model.AuditStatusAttempt)
+
+	t.Run("leaves the post visible if it can't be queued", func(t *testing.T) {
+		api := &plugintest.API{}
+		processor := &PostProcessor{reviewStore: &failingReviewStore{NewMockReviewStore()}}
+
+		_, err := processor.enforce(api, post.Clone(), enforcementActionReview, nil, record)
+		require.Error(t, err)
+		api.AssertNotCalled(t, "UpdatePost", mock.Anything)
+	})
+
+	t.Run("removes the item if the post can't be hidden", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("KVSet", hiddenPostKVKeyPrefix+"post123", mock.Anything).Return(model.NewAppError("KVSet", "error", nil, "", 500))
+		reviews := NewMockReviewStore()
+		processor := &PostProcessor{reviewStore: reviews}
+
+		_, err := processor.enforce(api, post.Clone(), enforcementActionReview, nil, record)
+		require.Error(t, err)
+		assert.Empty(t, reviews.items)
+	})
+}
@@ -0,0 +1,551 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+const (
+	// scanJobPageSize is the number of posts scanned between two progress updates
+	scanJobPageSize = 20
+
+	// scanJobStaleAfter is how long a running job may go without progress before
+	// another runner takes it over
+	scanJobStaleAfter = 10 * time.Minute
+
+	// scanJobCheckInterval is how often jobs without a runner are looked for
+	scanJobCheckInterval = time.Minute
+
+	scanJobChannelsPageSize = 200
+)
+
+const scanJobCompletedTemplate = "The content moderation scan `%s` of %s finished: %d posts scanned, %d flagged, %d skipped and %d failed. " +
+	"Use `/moderation scan show %s` to see the flagged posts."
+
+var (
+	ErrScanJobNotRunning = errors.New("scan job is not running")
+	ErrScanJobNotPaused  = errors.New("scan job is not paused")
+	ErrScanJobFinished   = errors.New("scan job is already finished")
+
+	errScanJobTakenOver = errors.New("scan job was taken over by another runner")
+)
+
+// ScanJobRequest describes the posts a new scan job should go through
+type ScanJobRequest struct {
+	Scope   PolicyScope `json:"scope"`
+	ScopeID string      `json:"scope_id"`
+	Mode    ScanJobMode `json:"mode"`
+
+	// Since and Until bound the creation time of the scanned posts, in milliseconds.
+	// Until defaults to the time the job is started.
+	Since int64 `json:"since"`
+	Until int64 `json:"until"`
+}
+
+func (r *ScanJobRequest) Validate() error {
+	if r.Scope != PolicyScopeChannel && r.Scope != PolicyScopeTeam {
+		return errors.Errorf("scope must be %s or %s", PolicyScopeChannel, PolicyScopeTeam)
+	}
+	if !model.IsValidId(r.ScopeID) {
+		return errors.Errorf("invalid %s ID", r.Scope)
+	}
+	if r.Mode != ScanJobModeReport && r.Mode != ScanJobModeEnforce {
+		return errors.Errorf("mode must be %s or %s", ScanJobModeReport, ScanJobModeEnforce)
+	}
+	if r.Since < 0 || r.Until < 0 {
+		return errors.New("dates must not be negative")
+	}
+	if r.Until != 0 && r.Until < r.Since {
+		return errors.New("end of the date range must not be before its start")
+	}
+	return nil
+}
+
+// startScanJob resolves the channels covered by the request and starts a job scanning them
+func (p *Plugin) startScanJob(userID string, request ScanJobRequest) (*ScanJob, error) {
+	if p.scanJobRunner == nil {
+		return nil, errors.New("scan jobs are not available")
+	}
+
+	channelIDs, err := p.scanJobChannels(request.Scope, request.ScopeID)
+	if err != nil {
+		return nil, err
+	}
+
+	until := request.Until
+	if until == 0 {
+		until = model.GetMillis()
+	}
+
+	return p.scanJobRunner.startJob(&ScanJob{
+		Scope:      request.Scope,
+		ScopeID:    request.ScopeID,
+		Mode:       request.Mode,
+		Since:      request.Since,
+		Until:      until,
+		CreatedBy:  userID,
+		ChannelIDs: channelIDs,
+	})
+}
+
+// scanJobChannels returns the channels to scan. A team scan covers the public
+// channels of the team that are not excluded from moderation.
+func (p *Plugin) scanJobChannels(scope PolicyScope, scopeID string) ([]string, error) {
+	if scope == PolicyScopeChannel {
+		if _, appErr := p.API.GetChannel(scopeID); appErr != nil {
+			return nil, errors.Wrap(appErr, "failed to get channel")
+		}
+		return []string{scopeID}, nil
+	}
+
+	var channelIDs []string
+	for page := 0; ; page++ {
+		channels, appErr := p.API.GetPublicChannelsForTeam(scopeID, page, scanJobChannelsPageSize)
+		if appErr != nil {
+			return nil, errors.Wrap(appErr, "failed to get channels of team")
+		}
+
+		for _, channel := range channels {
+			if channel.DeleteAt != 0 {
+				continue
+			}
+			if excluded, err := p.excludedChannelStore.IsExcluded(channel.Id); err != nil {
+				return nil, errors.Wrap(err, "failed to check if channel is excluded")
+			} else if excluded {
+				continue
+			}
+			channelIDs = append(channelIDs, channel.Id)
+		}
+
+		if len(channels) < scanJobChannelsPageSize {
+			return channelIDs, nil
+		}
+	}
+}
+
+// scanProcessors returns the current processors, which are nil while moderation is disabled
+func (p *Plugin) scanProcessors() (*ModerationProcessor, *PostProcessor) {
+	return p.moderationProcessor, p.postProcessor
+}
+
+// scanJobRunner runs scan jobs on this node, one goroutine per job. Jobs are
+// paused and cancelled through their stored status, so they can be managed
+// from any node of a cluster.
+type scanJobRunner struct {
+	api   plugin.API
+	store ScanJobsStore
+
+	processors func() (*ModerationProcessor, *PostProcessor)
+
+	runningLock sync.Mutex
+	running     map[string]string // job ID -> runner ID
+
+	done chan struct{}
+}
+
+// scanPageProgress is what was scanned of a page of posts
+type scanPageProgress struct {
+	cursor      string
+	channelDone bool
+	scanned     int
+	skipped     int
+	flagged     int
+	failed      int
+	findings    []ScanFinding
+}
+
+func newScanJobRunner(api plugin.API, store ScanJobsStore, processors func() (*ModerationProcessor, *PostProcessor)) *scanJobRunner {
+	return &scanJobRunner{
+		api:        api,
+		store:      store,
+		processors: processors,
+		running:    make(map[string]string),
+		done:       make(chan struct{}),
+	}
+}
+
+// start periodically takes over running jobs that no runner is working on,
+// for example because the plugin was restarted
+func (r *scanJobRunner) start() {
+	go func() {
+		ticker := time.NewTicker(scanJobCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			r.takeOverStaleJobs()
+
+			select {
+			case <-ticker.C:
+			case <-r.done:
+				return
+			}
+		}
+	}()
+}
+
+func (r *scanJobRunner) stop() {
+	close(r.done)
+}
+
+func (r *scanJobRunner) stopped() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *scanJobRunner) startJob(job *ScanJob) (*ScanJob, error) {
+	now := model.GetMillis()
+	job.ID = model.NewId()
+	job.Status = ScanJobStatusRunning
+	job.RunnerID = model.NewId()
+	job.CreatedAt = now
+	job.UpdatedAt = now
+
+	if err := r.store.CreateJob(job); err != nil {
+		return nil, err
+	}
+
+	go r.run(job.ID, job.RunnerID)
+	return job, nil
+}
+
+func (r *scanJobRunner) pauseJob(id string) (*ScanJob, error) {
+	return r.store.UpdateJob(id, func(job *ScanJob) error {
+		if job.Status != ScanJobStatusRunning {
+			return ErrScanJobNotRunning
+		}
+		job.Status = ScanJobStatusPaused
+		job.UpdatedAt = model.GetMillis()
+		return nil
+	})
+}
+
+func (r *scanJobRunner) resumeJob(id string) (*ScanJob, error) {
+	job, err := r.store.UpdateJob(id, func(job *ScanJob) error {
+		if job.Status != ScanJobStatusPaused {
+			return ErrScanJobNotPaused
+		}
+		job.Status = ScanJobStatusRunning
+		job.RunnerID = model.NewId()
+		job.UpdatedAt = model.GetMillis()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go r.run(job.ID, job.RunnerID)
+	return job, nil
+}
+
+func (r *scanJobRunner) cancelJob(id string) (*ScanJob, error) {
+	return r.store.UpdateJob(id, func(job *ScanJob) error {
+		if job.Status.finished() {
+			return ErrScanJobFinished
+		}
+		job.Status = ScanJobStatusCancelled
+		job.UpdatedAt = model.GetMillis()
+		return nil
+	})
+}
+
+// takeOverStaleJobs resumes running jobs whose runner was released or stopped making progress
+func (r *scanJobRunner) takeOverStaleJobs() {
+	jobs, err := r.store.ListJobs()
+	if err != nil {
+		r.api.LogError("Failed to list content moderation scan jobs", "err", err)
+		return
+	}
+
+	for _, job := range jobs {
+		if job.Status != ScanJobStatusRunning || r.isRunning(job.ID) {
+			continue
+		}
+
+		runnerID := model.NewId()
+		_, err := r.store.UpdateJob(job.ID, func(job *ScanJob) error {
+			if job.Status != ScanJobStatusRunning || !job.stale() {
+				return errScanJobTakenOver
+			}
+			job.RunnerID = runnerID
+			job.UpdatedAt = model.GetMillis()
+			return nil
+		})
+		if errors.Is(err, errScanJobTakenOver) {
+			continue
+		}
+		if err != nil {
+			r.api.LogError("Failed to take over content moderation scan job", "job_id", job.ID, "err", err)
+			continue
+		}
+
+		r.api.LogInfo("Resuming content moderation scan job", "job_id", job.ID)
+		go r.run(job.ID, runnerID)
+	}
+}
+
+// stale returns true if no runner is working on the job
+func (j *ScanJob) stale() bool {
+	return j.RunnerID == "" || time.Since(time.UnixMilli(j.UpdatedAt)) > scanJobStaleAfter
+}
+
+func (r *scanJobRunner) isRunning(jobID string) bool {
+	r.runningLock.Lock()
+	defer r.runningLock.Unlock()
+	_, ok := r.running[jobID]
+	return ok
+}
+
+func (r *scanJobRunner) setRunning(jobID, runnerID string, running bool) {
+	r.runningLock.Lock()
+	defer r.runningLock.Unlock()
+	if running {
+		r.running[jobID] = runnerID
+	} else if r.running[jobID] == runnerID {
+		delete(r.running, jobID)
+	}
+}
+
+// run scans the job a page at a time until it is finished, paused, cancelled or
+// taken over by another runner
+func (r *scanJobRunner) run(jobID, runnerID string) {
+	r.setRunning(jobID, runnerID, true)
+	defer r.setRunning(jobID, runnerID, false)
+
+	for {
+		job, err := r.store.GetJob(jobID)
+		if err != nil {
+			r.api.LogError("Failed to get content moderation scan job", "job_id", jobID, "err", err)
+			return
+		}
+		if job == nil || job.Status != ScanJobStatusRunning || job.RunnerID != runnerID {
+			return
+		}
+
+		var progress scanPageProgress
+		if job.ChannelIndex < len(job.ChannelIDs) {
+			moderationProcessor, postProcessor := r.processors()
+			if moderationProcessor == nil || postProcessor == nil {
+				r.pauseAfterError(jobID, errors.New("content moderation is not active"))
+				return
+			}
+
+			progress, err = r.scanPage(moderationProcessor, postProcessor, job)
+			if err != nil {
+				r.pauseAfterError(jobID, err)
+				return
+			}
+		}
+
+		released := r.stopped()
+		job, err = r.store.UpdateJob(jobID, func(job *ScanJob) error {
+			if job.RunnerID != runnerID {
+				return errScanJobTakenOver
+			}
+			job.applyProgress(progress)
+			// A runner that is stopping releases the job so it is resumed right away
+			if released {
+				job.RunnerID = ""
+			}
+			return nil
+		})
+		if errors.Is(err, errScanJobTakenOver) {
+			return
+		}
+		if err != nil {
+			r.api.LogError("Failed to save progress of content moderation scan job", "job_id", jobID, "err", err)
+			return
+		}
+
+		if job.Status == ScanJobStatusCompleted {
+			r.api.LogInfo("Content moderation scan job completed", "job_id", jobID,
+				"scanned", job.Scanned, "flagged", job.Flagged, "skipped", job.Skipped, "failed", job.Failed)
+			r.notifyCompleted(job)
+			return
+		}
+		if released {
+			return
+		}
+	}
+}
+
+// pauseAfterError pauses a job that can't make progress, so an admin can resume it
+func (r *scanJobRunner) pauseAfterError(jobID string, err error) {
+	r.api.LogError("Pausing content moderation scan job", "job_id", jobID, "err", err)
+	if _, err := r.pauseJob(jobID); err != nil && !errors.Is(err, ErrScanJobNotRunning) {
+		r.api.LogError("Failed to pause content moderation scan job", "job_id", jobID, "err", err)
+	}
+}
+
+// applyProgress records a scanned page and moves on to the next channel once
+// the current one is done
+func (j *ScanJob) applyProgress(progress scanPageProgress) {
+	j.Scanned += progress.scanned
+	j.Skipped += progress.skipped
+	j.Flagged += progress.flagged
+	j.Failed += progress.failed
+	for _, finding := range progress.findings {
+		if len(j.Findings) >= maxScanJobFindings {
+			break
+		}
+		j.Findings = append(j.Findings, finding)
+	}
+
+	if progress.channelDone {
+		j.ChannelIndex++
+		j.Cursor = ""
+	} else if progress.cursor != "" {
+		j.Cursor = progress.cursor
+	}
+
+	if j.ChannelIndex >= len(j.ChannelIDs) && !j.Status.finished() {
+		j.Status = ScanJobStatusCompleted
+		j.RunnerID = ""
+	}
+	j.UpdatedAt = model.GetMillis()
+}
+
+// scanPage scans the next page of posts of the current channel, newest first
+func (r *scanJobRunner) scanPage(moderationProcessor *ModerationProcessor, postProcessor *PostProcessor, job *ScanJob) (scanPageProgress, error) {
+	channelID := job.ChannelIDs[job.ChannelIndex]
+
+	var posts *model.PostList
+	var appErr *model.AppError
+	if job.Cursor == "" {
+		posts, appErr = r.api.GetPostsForChannel(channelID, 0, scanJobPageSize)
+	} else {
+		posts, appErr = r.api.GetPostsBefore(channelID, job.Cursor, 0, scanJobPageSize)
+	}
+	if appErr != nil {
+		return scanPageProgress{}, errors.Wrap(appErr, "failed to get posts of channel")
+	}
+
+	progress := scanPageProgress{
+		channelDone: len(posts.Order) < scanJobPageSize,
+	}
+	for _, postID := range posts.Order {
+		if r.stopped() {
+			progress.channelDone = false
+			return progress, nil
+		}
+
+		post, ok := posts.Posts[postID]
+		if !ok {
+			continue
+		}
+		if post.CreateAt < job.Since {
+			progress.channelDone = true
+			return progress, nil
+		}
+		if post.CreateAt <= job.Until {
+			r.scanPost(moderationProcessor, postProcessor, job, post, &progress)
+		}
+		progress.cursor = postID
+	}
+	return progress, nil
+}
+
+// scanPost moderates a post and, if the job enforces the policy, acts on it like
+// on a new post. Posts are moderated one at a time so the job stays within the
+// rate limit without holding up new posts.
+func (r *scanJobRunner) scanPost(moderationProcessor *ModerationProcessor, postProcessor *PostProcessor, job *ScanJob, post *model.Post, progress *scanPageProgress) {
+	text := postText(post)
+	if post.DeleteAt != 0 || post.IsSystemMessage() || (text == "" && len(post.FileIds) == 0) {
+		progress.skipped++
+		return
+	}
+
+	record := plugin.MakeAuditRecord(auditEventTypeContentModeration, model.AuditStatusAttempt)
+	model.AddEventParameterAuditableToAuditRec(record, auditParamKeyPost, post)
+	record.AddMeta(auditMetaKeyScanJobID, job.ID)
+
+	if !postProcessor.shouldModerateUser(post.UserId, record) {
+		progress.skipped++
+		return
+	}
+
+	moderationProcessor.queueMessage(r.api, text, moderationPriorityNormal)
+	keys := postProcessor.queueAttachedContent(r.api, post)
+	if text == "" && len(keys) == 0 {
+		progress.skipped++
+		return
+	}
+
+	policy := postProcessor.resolvePolicy(r.api, post.ChannelId)
+	result := postProcessor.waitForPostResult(post, keys, waitForResultTimeout)
+	if result != nil {
+		result = policy.postResult(result)
+	}
+	if result == nil || result.code == moderationResultPending || result.code == moderationResultError {
+		err := errors.New("no moderation result")
+		if result != nil && result.err != nil {
+			err = result.err
+		}
+		r.api.LogWarn("Failed to moderate post in scan job", "job_id", job.ID, "post_id", post.Id, "err", err)
+		postProcessor.logAuditFail(r.api, record, "Failed to complete content moderation", err)
+		progress.failed++
+		return
+	}
+
+	progress.scanned++
+	record.AddMeta(auditMetaKeyResult, result.result)
+	if !policy.isFlagged(result) || postProcessor.isApproved(r.api, post) {
+		record.AddMeta(auditMetaKeyFlagged, false)
+		postProcessor.logAuditSuccess(r.api, record)
+		return
+	}
+
+	progress.flagged++
+	finding := ScanFinding{
+		PostID:    post.Id,
+		ChannelID: post.ChannelId,
+		UserID:    post.UserId,
+		CreateAt:  post.CreateAt,
+		Result:    result.result,
+	}
+	if job.Mode == ScanJobModeEnforce {
+		if postProcessor.enforcePolicy(r.api, post, policy, result.result, record) {
+			finding.Action = string(policy.enforcementAction())
+		}
+	} else {
+		record.AddMeta(auditMetaKeyFlagged, true)
+		postProcessor.logAuditSuccess(r.api, record)
+	}
+	progress.findings = append(progress.findings, finding)
+}
+
+// notifyCompleted sends the summary of a completed job to the admin who started it
+func (r *scanJobRunner) notifyCompleted(job *ScanJob) {
+	_, postProcessor := r.processors()
+	if postProcessor == nil {
+		return
+	}
+
+	message := fmt.Sprintf(scanJobCompletedTemplate, job.ID, job.describeScope(r.api),
+		job.Scanned, job.Flagged, job.Skipped, job.Failed, job.ID)
+	if err := postProcessor.sendDirectMessage(r.api, job.CreatedBy, message); err != nil {
+		r.api.LogError("Failed to notify user of completed scan job", "job_id", job.ID, "user_id", job.CreatedBy, "err", err)
+	}
+}
+
+// describeScope returns the channel or team name of the job, falling back to its ID
+func (j *ScanJob) describeScope(api plugin.API) string {
+	if j.Scope == PolicyScopeChannel {
+		if channel, appErr := api.GetChannel(j.ScopeID); appErr == nil {
+			return "~" + channel.Name
+		}
+		return "channel " + j.ScopeID
+	}
+	if team, appErr := api.GetTeam(j.ScopeID); appErr == nil {
+		return "team " + team.Name
+	}
+	return "team " + j.ScopeID
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+const (
+	scanJobKVKeyPrefix = "scan_job_"
+
+	// scanJobRetention is how long a job is kept once it is completed or cancelled
+	scanJobRetention = 30 * 24 * time.Hour
+
+	// maxScanJobFindings is the number of flagged posts kept in the report of a job
+	maxScanJobFindings = 200
+
+	// maxScanJobUpdateAttempts is how often an update is retried when the job was changed concurrently
+	maxScanJobUpdateAttempts = 5
+
+	// scanJobsIndexKVKey lists the IDs of all jobs
+	scanJobsIndexKVKey = "scan_jobs_index"
+)
+
+var ErrScanJobNotFound = errors.New("scan job not found")
+
+type ScanJobStatus string
+
+const (
+	ScanJobStatusRunning   ScanJobStatus = "running"
+	ScanJobStatusPaused    ScanJobStatus = "paused"
+	ScanJobStatusCancelled ScanJobStatus = "cancelled"
+	ScanJobStatusCompleted ScanJobStatus = "completed"
+)
+
+// finished returns true if the job can't be resumed anymore
+func (s ScanJobStatus) finished() bool {
+	return s == ScanJobStatusCancelled || s == ScanJobStatusCompleted
+}
+
+type ScanJobMode string
+
+const (
+	// ScanJobModeReport only reports the posts that would be flagged
+	ScanJobModeReport ScanJobMode = "report"
+
+	// ScanJobModeEnforce applies the moderation policy to flagged posts like it does for new posts
+	ScanJobModeEnforce ScanJobMode = "enforce"
+)
+
+// ScanFinding is a post flagged by a scan job
+type ScanFinding struct {
+	PostID    string            `json:"post_id"`
+	ChannelID string            `json:"channel_id"`
+	UserID    string            `json:"user_id"`
+	CreateAt  int64             `json:"create_at"`
+	Result    moderation.Result `json:"result"`
+	Action    string            `json:"action,omitempty"`
+}
+
+// ScanJob moderates the existing posts of a channel or team over a date range
+type ScanJob struct {
+	ID        string        `json:"id"`
+	Scope     PolicyScope   `json:"scope"`
+	ScopeID   string        `json:"scope_id"`
+	Mode      ScanJobMode   `json:"mode"`
+	Status    ScanJobStatus `json:"status"`
+	Since     int64         `json:"since"`
+	Until     int64         `json:"until"`
+	CreatedBy string        `json:"created_by"`
+	CreatedAt int64         `json:"created_at"`
+	UpdatedAt int64         `json:"updated_at"`
+
+	// RunnerID identifies the runner that owns the job, so a runner stops once
+	// the job is resumed somewhere else
+	RunnerID string `json:"runner_id,omitempty"`
+
+	// ChannelIDs are the channels to scan, and ChannelIndex the one being scanned.
+	// Cursor is the ID of the last scanned post of that channel.
+	ChannelIDs   []string `json:"channel_ids"`
+	ChannelIndex int      `json:"channel_index"`
+	Cursor       string   `json:"cursor,omitempty"`
+
+	Scanned  int           `json:"scanned"`
+	Skipped  int           `json:"skipped"`
+	Flagged  int           `json:"flagged"`
+	Failed   int           `json:"failed"`
+	Findings []ScanFinding `json:"findings,omitempty"`
+}
+
+type ScanJobsStore interface {
+	CreateJob(job *ScanJob) error
+	GetJob(id string) (*ScanJob, error)
+	// ListJobs returns all jobs, most recent first
+	ListJobs() ([]*ScanJob, error)
+	// UpdateJob applies update to the stored job and saves it, retrying if the job
+	// was changed concurrently. An error returned by update aborts the update.
+	UpdateJob(id string, update func(job *ScanJob) error) (*ScanJob, error)
+}
+
+type scanJobsStore struct {
+	api   plugin.API
+	index kvIndex
+}
+
+func newScanJobsStore(api plugin.API) *scanJobsStore {
+	return &scanJobsStore{
+		api:   api,
+		index: newKVIndex(api, scanJobsIndexKVKey),
+	}
+}
+
+func (s *scanJobsStore) CreateJob(job *ScanJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal scan job")
+	}
+
+	if appErr := s.api.KVSet(scanJobKVKeyPrefix+job.ID, data); appErr != nil {
+		return errors.Wrap(appErr, "failed to store scan job")
+	}
+	if err := s.index.add(job.ID); err != nil {
+		return errors.Wrap(err, "failed to add scan job to index")
+	}
+	return nil
+}
+
+func (s *scanJobsStore) GetJob(id string) (*ScanJob, error) {
+	job, _, err := s.getJobWithData(scanJobKVKeyPrefix + id)
+	return job, err
+}
+
+func (s *scanJobsStore) ListJobs() ([]*ScanJob, error) {
+	ids, err := s.index.list()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list scan jobs")
+	}
+
+	var jobs []*ScanJob
+	var expired []string
+	for _, id := range ids {
+		job, _, err := s.getJobWithData(scanJobKVKeyPrefix + id)
+		if err != nil {
+			return nil, err
+		}
+		if job == nil {
+			expired = append(expired, id)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	// Finished jobs expire without being removed from the index
+	if len(expired) > 0 {
+		if err := s.index.remove(expired...); err != nil {
+			s.api.LogWarn("Failed to remove expired scan jobs from index", "err", err)
+		}
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt > jobs[j].CreatedAt
+	})
+	return jobs, nil
+}
+
+func (s *scanJobsStore) UpdateJob(id string, update func(job *ScanJob) error) (*ScanJob, error) {
+	key := scanJobKVKeyPrefix + id
+	for attempt := 0; attempt < maxScanJobUpdateAttempts; attempt++ {
+		job, oldData, err := s.getJobWithData(key)
+		if err != nil {
+			return nil, err
+		}
+		if job == nil {
+			return nil, ErrScanJobNotFound
+		}
+
+		if err := update(job); err != nil {
+			return nil, err
+		}
+
+		data, err := json.Marshal(job)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal scan job")
+		}
+
+		saved, appErr := s.api.KVCompareAndSet(key, oldData, data)
+		if appErr != nil {
+			return nil, errors.Wrap(appErr, "failed to update scan job")
+		}
+		if !saved {
+			continue
+		}
+
+		// Finished jobs are only kept around for their report
+		if job.Status.finished() {
+			if appErr := s.api.KVSetWithExpiry(key, data, int64(scanJobRetention/time.Second)); appErr != nil {
+				return nil, errors.Wrap(appErr, "failed to set expiry of scan job")
+			}
+		}
+		return job, nil
+	}
+	return nil, errors.New("scan job was changed concurrently too often")
+}
+
+func (s *scanJobsStore) getJobWithData(key string) (*ScanJob, []byte, error) {
+	data, appErr := s.api.KVGet(key)
+	if appErr != nil {
+		return nil, nil, errors.Wrap(appErr, "failed to get scan job")
+	}
+	if data == nil {
+		return nil, nil, nil
+	}
+
+	var job ScanJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to unmarshal scan job")
+	}
+	return &job, data, nil
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var _ ScanJobsStore = (*MockScanJobsStore)(nil)
+
+// MockScanJobsStore keeps jobs as JSON so callers never share a job with the store
+type MockScanJobsStore struct {
+	lock sync.Mutex
+	jobs map[string][]byte
+}
+
+func NewMockScanJobsStore() *MockScanJobsStore {
+	return &MockScanJobsStore{jobs: make(map[string][]byte)}
+}
+
+func (m *MockScanJobsStore) CreateJob(job *ScanJob) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	m.jobs[job.ID] = data
+	return nil
+}
+
+func (m *MockScanJobsStore) GetJob(id string) (*ScanJob, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.getJobWithoutLock(id)
+}
+
+func (m *MockScanJobsStore) ListJobs() ([]*ScanJob, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var jobs []*ScanJob
+	for id := range m.jobs {
+		job, err := m.getJobWithoutLock(id)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+func (m *MockScanJobsStore) UpdateJob(id string, update func(job *ScanJob) error) (*ScanJob, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	job, err := m.getJobWithoutLock(id)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, ErrScanJobNotFound
+	}
+	if err := update(job); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+	m.jobs[id] = data
+	return job, nil
+}
+
+func (m *MockScanJobsStore) getJobWithoutLock(id string) (*ScanJob, error) {
+	data, ok := m.jobs[id]
+	if !ok {
+		return nil, nil
+	}
+	var job ScanJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// keywordModerator flags text containing "offensive"
+type keywordModerator struct{}
+
+func (m *keywordModerator) ModerateText(_ context.Context, text string) (moderation.Result, error) {
+	if strings.Contains(text, "offensive") {
+		return moderation.Result{"Hate": 6}, nil
+	}
+	return moderation.Result{"Hate": 0}, nil
+}
+
+func newScanTestRunner(t *testing.T, api *plugintest.API) (*scanJobRunner, *MockScanJobsStore) {
+	resultsCache := newModerationResultsCache()
+	moderationProcessor, err := newModerationProcessor(resultsCache, &keywordModerator{}, 4, nil, 6000, 10, 1, nil, nil, nil)
+	require.NoError(t, err)
+	moderationProcessor.start(api)
+	t.Cleanup(moderationProcessor.stop)
+
+	postProcessor := &PostProcessor{
+		botID:         "bot123",
+		resultsCache:  resultsCache,
+		defaultPolicy: effectivePolicy{threshold: 4, action: enforcementActionDelete},
+		postCache:     newPostCache(),
+		cleanupTicker: time.NewTicker(24 * time.Hour),
+	}
+
+	store := NewMockScanJobsStore()
+	runner := newScanJobRunner(api, store, func() (*ModerationProcessor, *PostProcessor) {
+		return moderationProcessor, postProcessor
+	})
+	return runner, store
+}
+
+func newScanTestPostList(posts ...*model.Post) *model.PostList {
+	list := model.NewPostList()
+	for _, post := range posts {
+		list.AddPost(post)
+		list.AddOrder(post.Id)
+	}
+	return list
+}
+
+func TestScanJobsStore_ListJobs(t *testing.T) {
+	api := newKVTestAPI()
+	store := newScanJobsStore(api)
+	require.NoError(t, store.CreateJob(&ScanJob{ID: "job1", CreatedAt: 2}))
+	require.NoError(t, store.CreateJob(&ScanJob{ID: "job2", CreatedAt: 3}))
+	require.NoError(t, store.CreateJob(&ScanJob{ID: "expired", CreatedAt: 4}))
+	require.Nil(t, api.KVDelete(scanJobKVKeyPrefix+"expired"))
+
+	jobs, err := store.ListJobs()
+	require.NoError(t, err)
+	var ids []string
+	for _, job := range jobs {
+		ids = append(ids, job.ID)
+	}
+	assert.Equal(t, []string{"job2", "job1"}, ids, "most recent jobs come first")
+
+	indexed, err := store.index.list()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"job1", "job2"}, indexed, "expired jobs are removed from the index")
+}
+
+func TestScanJobRequest_Validate(t *testing.T) {
+	valid := ScanJobRequest{Scope: PolicyScopeChannel, ScopeID: model.NewId(), Mode: ScanJobModeReport}
+	assert.NoError(t, valid.Validate())
+
+	invalid := map[string]func(r *ScanJobRequest){
+		"unknown scope":       func(r *ScanJobRequest) { r.Scope = "system" },
+		"invalid scope ID":    func(r *ScanJobRequest) { r.ScopeID = "channel" },
+		"unknown mode":        func(r *ScanJobRequest) { r.Mode = "delete" },
+		"negative date":       func(r *ScanJobRequest) { r.Since = -1 },
+		"reversed date range": func(r *ScanJobRequest) { r.Since, r.Until = 2000, 1000 },
+	}
+	for name, modify := range invalid {
+		request := valid
+		modify(&request)
+		assert.Error(t, request.Validate(), name)
+	}
+}
+
+func TestParseScanJobRequest(t *testing.T) {
+	args := &model.CommandArgs{ChannelId: model.NewId(), TeamId: model.NewId()}
+
+	request, err := parseScanJobRequest(args, []string{"team", "enforce", "2024-01-01", "2024-01-31"})
+	require.NoError(t, err)
+	assert.Equal(t, PolicyScopeTeam, request.Scope)
+	assert.Equal(t, args.TeamId, request.ScopeID)
+	assert.Equal(t, ScanJobModeEnforce, request.Mode)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli(), request.Since)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC).UnixMilli()-1, request.Until)
+
+	request, err = parseScanJobRequest(args, []string{"channel", "report"})
+	require.NoError(t, err)
+	assert.Equal(t, args.ChannelId, request.ScopeID)
+	assert.Zero(t, request.Since)
+	assert.Zero(t, request.Until)
+
+	_, err = parseScanJobRequest(args, []string{"channel", "report", "yesterday"})
+	assert.Error(t, err)
+	_, err = parseScanJobRequest(args, []string{"channel"})
+	assert.Error(t, err)
+}
+
+func TestScanJobRunner_run(t *testing.T) {
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	until := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	posts := newScanTestPostList(
+		&model.Post{Id: "newer", ChannelId: "channel1", UserId: "user1", Message: "offensive but too new", CreateAt: until + 1},
+		&model.Post{Id: "flagged", ChannelId: "channel1", UserId: "user1", Message: "offensive message", CreateAt: until - 1},
+		&model.Post{Id: "system", ChannelId: "channel1", UserId: "user1", Message: "joined the channel", Type: model.PostTypeJoinChannel, CreateAt: until - 2},
+		&model.Post{Id: "clean", ChannelId: "channel1", UserId: "user2", Message: "friendly message", CreateAt: since + 1},
+		&model.Post{Id: "older", ChannelId: "channel1", UserId: "user2", Message: "offensive but too old", CreateAt: since - 1},
+	)
+
+	newJob := func(mode ScanJobMode) *ScanJob {
+		return &ScanJob{
+			Scope:      PolicyScopeChannel,
+			ScopeID:    "channel1",
+			Mode:       mode,
+			Since:      since,
+			Until:      until,
+			CreatedBy:  "admin1",
+			ChannelIDs: []string{"channel1"},
+		}
+	}
+
+	expectCompletedNotification := func(api *plugintest.API) {
+		api.On("GetChannel", "channel1").Return(&model.Channel{Id: "channel1", Name: "town-square"}, nil)
+		api.On("GetDirectChannel", "bot123", "admin1").Return(&model.Channel{Id: "dm_admin"}, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "dm_admin"
+		})).Return(&model.Post{}, nil).Once()
+		api.On("LogInfo", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+	}
+
+	t.Run("reports flagged posts in the date range", func(t *testing.T) {
+		api := &plugintest.API{}
+		runner, store := newScanTestRunner(t, api)
+		api.On("GetPostsForChannel", "channel1", 0, scanJobPageSize).Return(posts, nil)
+		api.On("KVGet", mock.Anything).Return(nil, nil)
+		expectCompletedNotification(api)
+
+		job, err := runner.startJob(newJob(ScanJobModeReport))
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool {
+			stored, _ := store.GetJob(job.ID)
+			return stored.Status == ScanJobStatusCompleted
+		}, 5*time.Second, 10*time.Millisecond)
+
+		stored, err := store.GetJob(job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, stored.Scanned)
+		assert.Equal(t, 1, stored.Flagged)
+		assert.Equal(t, 1, stored.Skipped)
+		require.Len(t, stored.Findings, 1)
+		assert.Equal(t, "flagged", stored.Findings[0].PostID)
+		assert.Empty(t, stored.Findings[0].Action)
+		api.AssertNotCalled(t, "DeletePost", mock.Anything)
+	})
+
+	t.Run("enforces the policy on flagged posts", func(t *testing.T) {
+		api := &plugintest.API{}
+		runner, store := newScanTestRunner(t, api)
+		api.On("GetPostsForChannel", "channel1", 0, scanJobPageSize).Return(posts, nil)
+		api.On("KVGet", mock.Anything).Return(nil, nil)
+		api.On("DeletePost", "flagged").Return(nil).Once()
+		api.On("GetPluginID").Return("content-moderation").Maybe()
+		api.On("GetDirectChannel", "bot123", "user1").Return(&model.Channel{Id: "dm_user1"}, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "channel1" || post.ChannelId == "dm_user1"
+		})).Return(&model.Post{}, nil).Twice()
+		expectCompletedNotification(api)
+
+		job, err := runner.startJob(newJob(ScanJobModeEnforce))
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool {
+			stored, _ := store.GetJob(job.ID)
+			return stored.Status == ScanJobStatusCompleted
+		}, 5*time.Second, 10*time.Millisecond)
+
+		stored, err := store.GetJob(job.ID)
+		require.NoError(t, err)
+		require.Len(t, stored.Findings, 1)
+		assert.Equal(t, string(enforcementActionDelete), stored.Findings[0].Action)
+		api.AssertCalled(t, "DeletePost", "flagged")
+	})
+
+	t.Run("stops when the job is paused", func(t *testing.T) {
+		api := &plugintest.API{}
+		runner, store := newScanTestRunner(t, api)
+
+		job := newJob(ScanJobModeReport)
+		job.ID = model.NewId()
+		job.Status = ScanJobStatusPaused
+		job.RunnerID = "runner1"
+		require.NoError(t, store.CreateJob(job))
+
+		runner.run(job.ID, "runner1")
+
+		api.AssertNotCalled(t, "GetPostsForChannel", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("continues after the cursor", func(t *testing.T) {
+		api := &plugintest.API{}
+		runner, store := newScanTestRunner(t, api)
+		api.On("GetPostsBefore", "channel1", "flagged", 0, scanJobPageSize).Return(newScanTestPostList(posts.Posts["clean"], posts.Posts["older"]), nil)
+		api.On("KVGet", mock.Anything).Return(nil, nil)
+		expectCompletedNotification(api)
+
+		job := newJob(ScanJobModeReport)
+		job.ID = model.NewId()
+		job.Status = ScanJobStatusRunning
+		job.RunnerID = "runner1"
+		job.Cursor = "flagged"
+		job.Scanned = 1
+		require.NoError(t, store.CreateJob(job))
+
+		runner.run(job.ID, "runner1")
+
+		stored, err := store.GetJob(job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, ScanJobStatusCompleted, stored.Status)
+		assert.Equal(t, 2, stored.Scanned)
+		assert.Zero(t, stored.Flagged)
+	})
+}
+
+func TestScanJobRunner_updateJob(t *testing.T) {
+	api := &plugintest.API{}
+	runner, store := newScanTestRunner(t, api)
+
+	job := &ScanJob{ID: model.NewId(), Status: ScanJobStatusRunning, RunnerID: "runner1", ChannelIDs: []string{"channel1"}}
+	require.NoError(t, store.CreateJob(job))
+
+	_, err := runner.resumeJob(job.ID)
+	assert.ErrorIs(t, err, ErrScanJobNotPaused)
+
+	paused, err := runner.pauseJob(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ScanJobStatusPaused, paused.Status)
+
+	_, err = runner.pauseJob(job.ID)
+	assert.ErrorIs(t, err, ErrScanJobNotRunning)
+
+	cancelled, err := runner.cancelJob(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ScanJobStatusCancelled, cancelled.Status)
+
+	_, err = runner.cancelJob(job.ID)
+	assert.ErrorIs(t, err, ErrScanJobFinished)
+
+	_, err = runner.pauseJob(model.NewId())
+	assert.ErrorIs(t, err, ErrScanJobNotFound)
+}
+
+func TestScanJobRunner_takeOverStaleJobs(t *testing.T) {
+	api := &plugintest.API{}
+	runner, store := newScanTestRunner(t, api)
+	api.On("LogInfo", "Resuming content moderation scan job", "job_id", mock.Anything)
+	api.On("GetPostsForChannel", "channel1", 0, scanJobPageSize).Return(model.NewPostList(), nil)
+	api.On("LogInfo", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+	api.On("GetChannel", "channel1").Return(&model.Channel{Id: "channel1", Name: "town-square"}, nil)
+	api.On("GetDirectChannel", "bot123", "admin1").Return(&model.Channel{Id: "dm_admin"}, nil)
+	api.On("CreatePost", mock.Anything).Return(&model.Post{}, nil)
+
+	released := &ScanJob{ID: model.NewId(), Scope: PolicyScopeChannel, ScopeID: "channel1", Status: ScanJobStatusRunning,
+		CreatedBy: "admin1", ChannelIDs: []string{"channel1"}, UpdatedAt: model.GetMillis()}
+	active := &ScanJob{ID: model.NewId(), Status: ScanJobStatusRunning, RunnerID: "runner1",
+		ChannelIDs: []string{"channel1"}, UpdatedAt: model.GetMillis()}
+	require.NoError(t, store.CreateJob(released))
+	require.NoError(t, store.CreateJob(active))
+
+	runner.takeOverStaleJobs()
+
+	require.Eventually(t, func() bool {
+		stored, _ := store.GetJob(released.ID)
+		return stored.Status == ScanJobStatusCompleted
+	}, 5*time.Second, 10*time.Millisecond)
+
+	stored, err := store.GetJob(active.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ScanJobStatusRunning, stored.Status)
+	assert.Equal(t, "runner1", stored.RunnerID)
+}
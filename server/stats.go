@@ -0,0 +1,189 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/plugin"
+)
+
+const (
+	// statsFlushInterval is how often the counters of this node are added to the KV store
+	statsFlushInterval = 1 * time.Minute
+
+	defaultStatsReportDays = 30
+	maxStatsReportDays     = int(statsRetention / (24 * time.Hour))
+)
+
+// StatsReport contains the moderation statistics of a range of days
+type StatsReport struct {
+	From             string      `json:"from"`
+	To               string      `json:"to"`
+	Totals           *StatsDay   `json:"totals"`
+	AverageLatencyMs int64       `json:"average_latency_ms"`
+	Days             []*StatsDay `json:"days"`
+}
+
+// statsCollector counts moderation activity in memory and periodically adds the
+// counters to the daily statistics in the KV store, so posts don't each cost a
+// KV write. All methods can be called on a nil collector, which counts nothing.
+type statsCollector struct {
+	api   plugin.API
+	store StatsStore
+
+	lock    sync.Mutex
+	pending map[string]*StatsDay
+
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func newStatsCollector(api plugin.API, store StatsStore) *statsCollector {
+	return &statsCollector{
+		api:     api,
+		store:   store,
+		pending: make(map[string]*StatsDay),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+func (c *statsCollector) start() {
+	go func() {
+		defer close(c.stopped)
+
+		ticker := time.NewTicker(statsFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.flush()
+			case <-c.done:
+				c.flush()
+				return
+			}
+		}
+	}()
+}
+
+// stop flushes the remaining counters and waits for the collector to finish
+func (c *statsCollector) stop() {
+	close(c.done)
+	<-c.stopped
+}
+
+// update applies update to the pending counters of today
+func (c *statsCollector) update(update func(day *StatsDay)) {
+	if c == nil {
+		return
+	}
+	c.updateDay(time.Now().UTC().Format(statsDayLayout), update)
+}
+
+func (c *statsCollector) updateDay(date string, update func(day *StatsDay)) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	day, ok := c.pending[date]
+	if !ok {
+		day = newStatsDay(date)
+		c.pending[date] = day
+	}
+	update(day)
+}
+
+func (c *statsCollector) recordChecked() {
+	c.update(func(day *StatsDay) {
+		day.Checked++
+	})
+}
+
+func (c *statsCollector) recordFlagged(categories []string, action enforcementAction) {
+	c.update(func(day *StatsDay) {
+		day.Flagged++
+		for _, category := range categories {
+			day.FlaggedCategories[category]++
+		}
+		day.Actions[string(action)]++
+	})
+}
+
+func (c *statsCollector) recordError() {
+	c.update(func(day *StatsDay) {
+		day.Errors++
+	})
+}
+
+func (c *statsCollector) recordTimeout() {
+	c.update(func(day *StatsDay) {
+		day.Timeouts++
+	})
+}
+
+func (c *statsCollector) recordExcluded(reason string) {
+	c.update(func(day *StatsDay) {
+		day.Excluded[reason]++
+	})
+}
+
+func (c *statsCollector) observeQueueDepth(depth int) {
+	c.update(func(day *StatsDay) {
+		day.MaxQueueDepth = max(day.MaxQueueDepth, depth)
+	})
+}
+
+func (c *statsCollector) observeLatency(latency time.Duration) {
+	c.update(func(day *StatsDay) {
+		day.ProviderRequests++
+		day.ProviderLatencyTotalMs += latency.Milliseconds()
+		day.ProviderLatencyMaxMs = max(day.ProviderLatencyMaxMs, latency.Milliseconds())
+	})
+}
+
+// flush adds the pending counters to the KV store. Counters that could not be
+// stored are kept for the next flush.
+func (c *statsCollector) flush() {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	pending := c.pending
+	c.pending = make(map[string]*StatsDay)
+	c.lock.Unlock()
+
+	for date, delta := range pending {
+		if err := c.store.AddToDay(delta); err != nil {
+			c.api.LogWarn("Failed to store moderation statistics", "date", date, "err", err)
+			c.updateDay(date, func(day *StatsDay) {
+				day.add(delta)
+			})
+		}
+	}
+}
+
+// statsReport returns the moderation statistics of the last days, including today
+func (p *Plugin) statsReport(days int) (*StatsReport, error) {
+	// Include the counters of this node that were not flushed yet
+	p.stats.flush()
+
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, 1-days)
+
+	statsDays, err := p.statsStore.GetDays(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &StatsReport{
+		From:   from.Format(statsDayLayout),
+		To:     to.Format(statsDayLayout),
+		Totals: newStatsDay(""),
+		Days:   []*StatsDay{},
+	}
+	for _, day := range statsDays {
+		report.Totals.add(day)
+		report.Days = append(report.Days, day)
+	}
+	report.AverageLatencyMs = report.Totals.averageLatencyMs()
+	return report, nil
+}
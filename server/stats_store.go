@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+const (
+	statsDayKVKeyPrefix = "stats_day_"
+	statsDayLayout      = "2006-01-02"
+
+	// statsRetention keeps a little over a year of statistics for trend reports
+	statsRetention = 400 * 24 * time.Hour
+
+	// maxStatsUpdateAttempts is how often an update is retried when it was changed concurrently
+	maxStatsUpdateAttempts = 5
+)
+
+// StatsDay aggregates the moderation counters of a day, in UTC. The counters of
+// all nodes of a cluster are added to the same day.
+type StatsDay struct {
+	Date string `json:"date"`
+
+	// Checked counts the posts that were moderated, and Flagged the ones that
+	// violated the policy that applies to them
+	Checked           int            `json:"checked"`
+	Flagged           int            `json:"flagged"`
+	FlaggedCategories map[string]int `json:"flagged_categories"`
+	Actions           map[string]int `json:"actions"`
+
+	Errors   int            `json:"errors"`
+	Timeouts int            `json:"timeouts"`
+	Excluded map[string]int `json:"excluded"`
+
+	// MaxQueueDepth is the largest number of messages waiting for the provider
+	MaxQueueDepth int `json:"max_queue_depth"`
+
+	// ProviderRequests counts the requests sent to the moderation provider, along
+	// with their total and longest latency
+	ProviderRequests       int   `json:"provider_requests"`
+	ProviderLatencyTotalMs int64 `json:"provider_latency_total_ms"`
+	ProviderLatencyMaxMs   int64 `json:"provider_latency_max_ms"`
+}
+
+func newStatsDay(date string) *StatsDay {
+	return &StatsDay{
+		Date:              date,
+		FlaggedCategories: make(map[string]int),
+		Actions:           make(map[string]int),
+		Excluded:          make(map[string]int),
+	}
+}
+
+// add adds the counters of another day to this one
+func (d *StatsDay) add(other *StatsDay) {
+	d.Checked += other.Checked
+	d.Flagged += other.Flagged
+	d.Errors += other.Errors
+	d.Timeouts += other.Timeouts
+	d.ProviderRequests += other.ProviderRequests
+	d.ProviderLatencyTotalMs += other.ProviderLatencyTotalMs
+	d.MaxQueueDepth = max(d.MaxQueueDepth, other.MaxQueueDepth)
+	d.ProviderLatencyMaxMs = max(d.ProviderLatencyMaxMs, other.ProviderLatencyMaxMs)
+	d.FlaggedCategories = addCounts(d.FlaggedCategories, other.FlaggedCategories)
+	d.Actions = addCounts(d.Actions, other.Actions)
+	d.Excluded = addCounts(d.Excluded, other.Excluded)
+}
+
+// averageLatencyMs returns the average latency of the provider requests of the day
+func (d *StatsDay) averageLatencyMs() int64 {
+	if d.ProviderRequests == 0 {
+		return 0
+	}
+	return d.ProviderLatencyTotalMs / int64(d.ProviderRequests)
+}
+
+func addCounts(counts, other map[string]int) map[string]int {
+	if counts == nil {
+		counts = make(map[string]int, len(other))
+	}
+	for key, count := range other {
+		counts[key] += count
+	}
+	return counts
+}
+
+type StatsStore interface {
+	// AddToDay adds the counters of delta to the stored day with the same date
+	AddToDay(delta *StatsDay) error
+	// GetDays returns the aggregates of the days from from to to, skipping days without statistics
+	GetDays(from, to time.Time) ([]*StatsDay, error)
+}
+
+type statsStore struct {
+	api plugin.API
+}
+
+func newStatsStore(api plugin.API) *statsStore {
+	return &statsStore{
+		api: api,
+	}
+}
+
+func (s *statsStore) AddToDay(delta *StatsDay) error {
+	key := statsDayKVKeyPrefix + delta.Date
+	for attempt := 0; attempt < maxStatsUpdateAttempts; attempt++ {
+		day, oldData, err := s.getDayWithData(key)
+		if err != nil {
+			return err
+		}
+		if day == nil {
+			day = newStatsDay(delta.Date)
+		}
+
+		day.add(delta)
+
+		data, err := json.Marshal(day)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal moderation statistics")
+		}
+
+		saved, appErr := s.api.KVSetWithOptions(key, data, model.PluginKVSetOptions{
+			Atomic:          true,
+			OldValue:        oldData,
+			ExpireInSeconds: int64(statsRetention / time.Second),
+		})
+		if appErr != nil {
+			return errors.Wrap(appErr, "failed to store moderation statistics")
+		}
+		if saved {
+			return nil
+		}
+	}
+	return errors.New("moderation statistics were changed concurrently too often")
+}
+
+func (s *statsStore) GetDays(from, to time.Time) ([]*StatsDay, error) {
+	var days []*StatsDay
+	for t := from.UTC(); !t.After(to.UTC()); t = t.AddDate(0, 0, 1) {
+		day, _, err := s.getDayWithData(statsDayKVKeyPrefix + t.Format(statsDayLayout))
+		if err != nil {
+			return nil, err
+		}
+		if day != nil {
+			days = append(days, day)
+		}
+	}
+	return days, nil
+}
+
+func (s *statsStore) getDayWithData(key string) (*StatsDay, []byte, error) {
+	data, appErr := s.api.KVGet(key)
+	if appErr != nil {
+		return nil, nil, errors.Wrap(appErr, "failed to get moderation statistics")
+	}
+	if data == nil {
+		return nil, nil, nil
+	}
+
+	var day StatsDay
+	if err := json.Unmarshal(data, &day); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to unmarshal moderation statistics")
+	}
+	return &day, data, nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStatsStore fails every update
+type failingStatsStore struct{}
+
+func (failingStatsStore) AddToDay(*StatsDay) error {
+	return errors.New("kv store unavailable")
+}
+
+func (failingStatsStore) GetDays(time.Time, time.Time) ([]*StatsDay, error) {
+	return nil, nil
+}
+
+func TestStatsStore(t *testing.T) {
+	store := newStatsStore(newMonitorTestAPI())
+	today := time.Now().UTC().Format(statsDayLayout)
+
+	first := newStatsDay(today)
+	first.Checked = 3
+	first.FlaggedCategories["Hate"] = 1
+	first.MaxQueueDepth = 10
+	first.ProviderRequests = 3
+	first.ProviderLatencyTotalMs = 300
+	first.ProviderLatencyMaxMs = 200
+	require.NoError(t, store.AddToDay(first))
+
+	// Another node adds its own counters to the same day
+	second := newStatsDay(today)
+	second.Checked = 2
+	second.FlaggedCategories["Hate"] = 2
+	second.FlaggedCategories["Violence"] = 1
+	second.MaxQueueDepth = 4
+	second.ProviderRequests = 1
+	second.ProviderLatencyTotalMs = 500
+	second.ProviderLatencyMaxMs = 500
+	require.NoError(t, store.AddToDay(second))
+
+	days, err := store.GetDays(time.Now().AddDate(0, 0, -1), time.Now())
+	require.NoError(t, err)
+	require.Len(t, days, 1)
+	assert.Equal(t, 5, days[0].Checked)
+	assert.Equal(t, map[string]int{"Hate": 3, "Violence": 1}, days[0].FlaggedCategories)
+	assert.Equal(t, 10, days[0].MaxQueueDepth)
+	assert.Equal(t, int64(500), days[0].ProviderLatencyMaxMs)
+	assert.Equal(t, int64(200), days[0].averageLatencyMs())
+}
+
+func TestStatsCollector_flush(t *testing.T) {
+	t.Run("adds the counters to the store", func(t *testing.T) {
+		api := newMonitorTestAPI()
+		p := &Plugin{statsStore: newStatsStore(api)}
+		p.SetAPI(api)
+		p.stats = newStatsCollector(api, p.statsStore)
+
+		p.stats.recordChecked()
+		p.stats.recordChecked()
+		p.stats.recordFlagged([]string{"Hate"}, enforcementActionDelete)
+		p.stats.recordExcluded("excluded_channel_list")
+		p.stats.recordError()
+		p.stats.recordTimeout()
+		p.stats.observeQueueDepth(7)
+		p.stats.observeQueueDepth(3)
+		p.stats.observeLatency(100 * time.Millisecond)
+		p.stats.observeLatency(300 * time.Millisecond)
+
+		report, err := p.statsReport(defaultStatsReportDays)
+		require.NoError(t, err)
+		require.Len(t, report.Days, 1)
+		assert.Equal(t, 2, report.Totals.Checked)
+		assert.Equal(t, 1, report.Totals.Flagged)
+		assert.Equal(t, map[string]int{"Hate": 1}, report.Totals.FlaggedCategories)
+		assert.Equal(t, map[string]int{"delete": 1}, report.Totals.Actions)
+		assert.Equal(t, map[string]int{"excluded_channel_list": 1}, report.Totals.Excluded)
+		assert.Equal(t, 1, report.Totals.Errors)
+		assert.Equal(t, 1, report.Totals.Timeouts)
+		assert.Equal(t, 7, report.Totals.MaxQueueDepth)
+		assert.Equal(t, int64(200), report.AverageLatencyMs)
+		assert.Equal(t, int64(300), report.Totals.ProviderLatencyMaxMs)
+
+		// Counters are only added once
+		p.stats.flush()
+		report, err = p.statsReport(defaultStatsReportDays)
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.Totals.Checked)
+	})
+
+	t.Run("keeps counters that could not be stored", func(t *testing.T) {
+		api := newMonitorTestAPI()
+		api.On("LogWarn", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+		collector := newStatsCollector(api, failingStatsStore{})
+
+		collector.recordChecked()
+		collector.flush()
+		collector.recordChecked()
+
+		require.Len(t, collector.pending, 1)
+		for _, day := range collector.pending {
+			assert.Equal(t, 2, day.Checked)
+		}
+	})
+
+	t.Run("nil collector counts nothing", func(t *testing.T) {
+		var collector *statsCollector
+		collector.recordChecked()
+		collector.observeLatency(time.Second)
+		collector.flush()
+	})
+}
+
+func TestPostProcessor_processPost_stats(t *testing.T) {
+	api := newMonitorTestAPI()
+	api.On("GetChannel", "channel1").Return(&model.Channel{Id: "channel1", Type: model.ChannelTypeOpen}, nil)
+	api.On("LogError", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+
+	collector := newStatsCollector(api, newStatsStore(api))
+	cache := newModerationResultsCache()
+	processor := &PostProcessor{
+		botID:                "bot123",
+		excludedUsers:        map[string]struct{}{"excluded": {}},
+		excludedChannelStore: NewMockExcludedChannelsStore([]string{}),
+		defaultPolicy:        effectivePolicy{threshold: 4, action: enforcementActionFlag},
+		resultsCache:         cache,
+		postCache:            newPostCache(),
+		stats:                collector,
+	}
+
+	cache.setResultPending("fine")
+	cache.setModerationResultNotFlagged("fine", moderation.Result{"Hate": 0, "Violence": 0})
+	cache.setResultPending("offensive")
+	cache.setModerationResultFlagged("offensive", moderation.Result{"Hate": 5, "Violence": 2})
+	cache.setResultPending("failing")
+	cache.setModerationResultError("failing", errors.New("provider unavailable"))
+
+	posts := []*model.Post{
+		{Id: "post1", UserId: "user1", ChannelId: "channel1", Message: "fine"},
+		{Id: "post2", UserId: "user1", ChannelId: "channel1", Message: "offensive"},
+		{Id: "post3", UserId: "user1", ChannelId: "channel1", Message: "failing"},
+		{Id: "post4", UserId: "excluded", ChannelId: "channel1", Message: "fine"},
+	}
+	for _, post := range posts {
+		processor.processPost(api, post)
+	}
+
+	require.Len(t, collector.pending, 1)
+	for _, day := range collector.pending {
+		assert.Equal(t, 2, day.Checked)
+		assert.Equal(t, 1, day.Flagged)
+		assert.Equal(t, map[string]int{"Hate": 1}, day.FlaggedCategories)
+		assert.Equal(t, map[string]int{"flag": 1}, day.Actions)
+		assert.Equal(t, 1, day.Errors)
+		assert.Equal(t, map[string]int{"excluded_user_list": 1}, day.Excluded)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+// sanctionLevel is the escalation step reached by a user, ordered by severity
+type sanctionLevel int
+
+const (
+	sanctionNone sanctionLevel = iota
+	sanctionWarning
+	sanctionNotify
+	sanctionRestrict
+	sanctionDeactivate
+)
+
+func (l sanctionLevel) String() string {
+	switch l {
+	case sanctionWarning:
+		return "warning"
+	case sanctionNotify:
+		return "notify_admins"
+	case sanctionRestrict:
+		return "restrict"
+	case sanctionDeactivate:
+		return "deactivate"
+	default:
+		return "none"
+	}
+}
+
+const (
+	strikeWarningDMTemplate = "_One of your posts was removed by content moderation. You have received %d strike(s) in the last %d days. " +
+		"Further violations may lead to restrictions on your account._"
+	strikeRestrictedDMTemplate = "_One of your posts was removed by content moderation. You have received %d strikes in the last %d days " +
+		"and can't post until %s._"
+	strikeAdminNotificationTemplate = "@%s has received %d strikes in the last %d days for posts removed by content moderation. " +
+		"Sanction applied: **%s**.\n\nUse `/moderation strikes show %s` for details or `/moderation strikes reset %s` to lift all sanctions."
+	restrictedPostRejectionMessage = "You can't post right now because of repeated violations of the content policy. Your restriction ends at %s."
+)
+
+var ErrUserHasNoStrikes = errors.New("user has no strikes")
+
+// strikePolicy configures how strikes decay and when sanctions escalate
+type strikePolicy struct {
+	decay            time.Duration
+	restrictDuration time.Duration
+	thresholds       map[sanctionLevel]int
+}
+
+// levelFor returns the most severe sanction whose threshold is reached by count
+func (sp strikePolicy) levelFor(count int) sanctionLevel {
+	level := sanctionNone
+	for l := sanctionWarning; l <= sanctionDeactivate; l++ {
+		threshold := sp.thresholds[l]
+		if threshold > 0 && count >= threshold {
+			level = l
+		}
+	}
+	return level
+}
+
+func (sp strikePolicy) decayDays() int {
+	return int(sp.decay / (24 * time.Hour))
+}
+
+// recordStrike adds a strike for a removed post and escalates the sanctions of
+// its author if a new threshold was crossed. A user at the restrict level whose
+// restriction ended is restricted again.
+func (p *PostProcessor) recordStrike(api plugin.API, post *model.Post, result moderation.Result, auditRecord *model.AuditRecord) {
+	if p.strikesStore == nil {
+		return
+	}
+
+	now := time.Now()
+	var level sanctionLevel
+	var sanctioned bool
+	strikes, err := p.strikesStore.UpdateStrikes(post.UserId, func(s *UserStrikes) {
+		s.pruneStrikes(now.Add(-p.strikePolicy.decay).UnixMilli())
+		s.liftExpiredRestriction(now.UnixMilli())
+		s.Strikes = append(s.Strikes, Strike{
+			PostID:    post.Id,
+			ChannelID: post.ChannelId,
+			Result:    result,
+			CreatedAt: now.UnixMilli(),
+		})
+
+		previous := s.Level
+		level = p.strikePolicy.levelFor(len(s.Strikes))
+		s.Level = level
+		sanctioned = level > previous || (level == sanctionRestrict && !s.isRestricted(now.UnixMilli()))
+		if !sanctioned {
+			return
+		}
+		if level >= sanctionRestrict {
+			s.RestrictedUntil = now.Add(p.strikePolicy.restrictDuration).UnixMilli()
+		}
+		if level == sanctionDeactivate {
+			s.Deactivated = true
+		}
+	})
+	if err != nil {
+		api.LogError("Failed to record strike", "post_id", post.Id, "user_id", post.UserId, "err", err)
+		return
+	}
+
+	auditRecord.AddMeta(auditMetaKeyStrikes, len(strikes.Strikes))
+	if !sanctioned {
+		return
+	}
+	auditRecord.AddMeta(auditMetaKeySanction, level.String())
+	p.applySanction(api, strikes, level)
+}
+
+// removeStrike removes the strike given for a post, e.g. because the post was reinstated
+func (p *PostProcessor) removeStrike(api plugin.API, userID, postID string) {
+	if p.strikesStore == nil {
+		return
+	}
+
+	if _, err := p.strikesStore.UpdateStrikes(userID, func(s *UserStrikes) {
+		remaining := s.Strikes[:0]
+		for _, strike := range s.Strikes {
+			if strike.PostID != postID {
+				remaining = append(remaining, strike)
+			}
+		}
+		s.Strikes = remaining
+		s.liftExpiredRestriction(model.GetMillis())
+		s.Level = p.strikePolicy.levelFor(len(s.Strikes))
+	}); err != nil {
+		api.LogError("Failed to remove strike", "post_id", postID, "user_id", userID, "err", err)
+	}
+}
+
+func (p *PostProcessor) applySanction(api plugin.API, strikes *UserStrikes, level sanctionLevel) {
+	count, days := len(strikes.Strikes), p.strikePolicy.decayDays()
+
+	switch level {
+	case sanctionWarning, sanctionNotify:
+		if err := p.sendDirectMessage(api, strikes.UserID, fmt.Sprintf(strikeWarningDMTemplate, count, days)); err != nil {
+			api.LogError("Failed to warn user about strikes", "user_id", strikes.UserID, "err", err)
+		}
+	case sanctionRestrict:
+		until := time.UnixMilli(strikes.RestrictedUntil).UTC().Format(time.RFC1123)
+		if err := p.sendDirectMessage(api, strikes.UserID, fmt.Sprintf(strikeRestrictedDMTemplate, count, days, until)); err != nil {
+			api.LogError("Failed to notify user about posting restriction", "user_id", strikes.UserID, "err", err)
+		}
+	case sanctionDeactivate:
+		if err := api.UpdateUserActive(strikes.UserID, false); err != nil {
+			api.LogError("Failed to deactivate user", "user_id", strikes.UserID, "err", err)
+		}
+	}
+
+	if level >= sanctionNotify {
+		p.notifyAdmins(api, strikes, level)
+	}
+}
+
+// notifyAdmins lets reviewers and system admins know that a user crossed a strike threshold
+func (p *PostProcessor) notifyAdmins(api plugin.API, strikes *UserStrikes, level sanctionLevel) {
+	username := getUsername(api, strikes.UserID)
+	message := fmt.Sprintf(strikeAdminNotificationTemplate,
+		username, len(strikes.Strikes), p.strikePolicy.decayDays(), level, username, username)
+
+	admins := make(map[string]struct{}, len(p.reviewers))
+	for reviewerID := range p.reviewers {
+		admins[reviewerID] = struct{}{}
+	}
+	systemAdmins, err := api.GetUsers(&model.UserGetOptions{
+		Role:    model.SystemAdminRoleId,
+		Active:  true,
+		PerPage: 200,
+	})
+	if err != nil {
+		api.LogError("Failed to get system admins", "err", err)
+	}
+	for _, admin := range systemAdmins {
+		admins[admin.Id] = struct{}{}
+	}
+
+	for adminID := range admins {
+		if err := p.sendDirectMessage(api, adminID, message); err != nil {
+			api.LogError("Failed to notify admin of strikes", "user_id", strikes.UserID, "admin_id", adminID, "err", err)
+		}
+	}
+}
+
+// checkPostingRestriction returns a rejection reason if the author of a post is
+// temporarily restricted from posting. An empty string means the post is allowed.
+func (p *Plugin) checkPostingRestriction(post *model.Post) string {
+	if p.postProcessor == nil || p.postProcessor.strikesStore == nil {
+		return ""
+	}
+
+	strikes, err := p.postProcessor.strikesStore.GetStrikes(post.UserId)
+	if err != nil {
+		p.API.LogError("Failed to check posting restriction", "user_id", post.UserId, "err", err)
+		return ""
+	}
+	if strikes == nil || !strikes.isRestricted(model.GetMillis()) {
+		return ""
+	}
+
+	until := time.UnixMilli(strikes.RestrictedUntil).UTC().Format(time.RFC1123)
+	return fmt.Sprintf(restrictedPostRejectionMessage, until)
+}
+
+// resetStrikes removes all strikes of a user, lifts their posting restriction and
+// reactivates them if they were deactivated because of their strikes
+func (p *Plugin) resetStrikes(userID string, auditRecord *model.AuditRecord) error {
+	auditRecord.AddMeta(auditMetaKeyTargetUserID, userID)
+
+	strikes, err := p.strikesStore.GetStrikes(userID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get strikes")
+	}
+	if strikes == nil {
+		return ErrUserHasNoStrikes
+	}
+	auditRecord.AddMeta(auditMetaKeyStrikes, len(strikes.Strikes))
+	auditRecord.AddMeta(auditMetaKeySanction, strikes.Level.String())
+
+	if strikes.Deactivated {
+		if appErr := p.API.UpdateUserActive(userID, true); appErr != nil {
+			return errors.Wrap(appErr, "failed to reactivate user")
+		}
+	}
+
+	if _, err := p.strikesStore.DeleteStrikes(userID); err != nil {
+		return errors.Wrap(err, "failed to delete strikes")
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+)
+
+const (
+	strikesKVKeyPrefix = "strikes_user_"
+
+	// strikesIndexKVKey lists the IDs of the users with strikes
+	strikesIndexKVKey = "strikes_index"
+
+	// maxStrikesUpdateAttempts is how often an update of the strikes of a user is
+	// retried when they were changed concurrently
+	maxStrikesUpdateAttempts = 5
+)
+
+// Strike is a single post by a user that was removed by content moderation
+type Strike struct {
+	PostID    string            `json:"post_id"`
+	ChannelID string            `json:"channel_id"`
+	Result    moderation.Result `json:"result"`
+	CreatedAt int64             `json:"created_at"`
+}
+
+// UserStrikes tracks the strikes of a user and the sanctions applied to them
+type UserStrikes struct {
+	UserID          string        `json:"user_id"`
+	Strikes         []Strike      `json:"strikes"`
+	Level           sanctionLevel `json:"level"`
+	RestrictedUntil int64         `json:"restricted_until,omitempty"`
+	Deactivated     bool          `json:"deactivated,omitempty"`
+}
+
+// pruneStrikes drops strikes created before the given time
+func (s *UserStrikes) pruneStrikes(before int64) {
+	active := s.Strikes[:0]
+	for _, strike := range s.Strikes {
+		if strike.CreatedAt >= before {
+			active = append(active, strike)
+		}
+	}
+	s.Strikes = active
+}
+
+// liftExpiredRestriction clears a posting restriction that ended before now
+func (s *UserStrikes) liftExpiredRestriction(now int64) {
+	if s.RestrictedUntil != 0 && s.RestrictedUntil <= now {
+		s.RestrictedUntil = 0
+	}
+}
+
+// isRestricted returns true if the user is not allowed to post at the given time
+func (s *UserStrikes) isRestricted(now int64) bool {
+	return s.RestrictedUntil > now
+}
+
+// isEmpty returns true if there is nothing left to track for the user
+func (s *UserStrikes) isEmpty() bool {
+	return len(s.Strikes) == 0 && s.RestrictedUntil == 0 && !s.Deactivated
+}
+
+type StrikesStore interface {
+	GetStrikes(userID string) (*UserStrikes, error)
+	// UpdateStrikes applies update to the strikes of a user and saves the result.
+	// Users without strikes or sanctions are removed from the store. update is
+	// applied again if the strikes were changed concurrently.
+	UpdateStrikes(userID string, update func(*UserStrikes)) (*UserStrikes, error)
+	DeleteStrikes(userID string) (*UserStrikes, error)
+	ListStrikes() ([]*UserStrikes, error)
+}
+
+// strikesStore keeps the strikes of each user under their own key and the IDs of
+// all users with strikes in an index. Updates use compare-and-set, so the nodes
+// of a cluster can record strikes for the same user at the same time.
+type strikesStore struct {
+	api   plugin.API
+	index kvIndex
+}
+
+func newStrikesStore(api plugin.API) (*strikesStore, error) {
+	return &strikesStore{
+		api:   api,
+		index: newKVIndex(api, strikesIndexKVKey),
+	}, nil
+}
+
+func (s *strikesStore) GetStrikes(userID string) (*UserStrikes, error) {
+	strikes, _, err := s.getStrikes(userID)
+	return strikes, err
+}
+
+func (s *strikesStore) UpdateStrikes(userID string, update func(*UserStrikes)) (*UserStrikes, error) {
+	for attempt := 0; attempt < maxStrikesUpdateAttempts; attempt++ {
+		strikes, oldData, err := s.getStrikes(userID)
+		if err != nil {
+			return nil, err
+		}
+		if strikes == nil {
+			strikes = &UserStrikes{UserID: userID}
+		}
+		update(strikes)
+
+		if strikes.isEmpty() {
+			if oldData == nil {
+				return strikes, nil
+			}
+			saved, err := s.deleteStrikes(userID, oldData)
+			if err != nil {
+				return nil, err
+			}
+			if saved {
+				return strikes, nil
+			}
+			continue
+		}
+
+		data, err := json.Marshal(strikes)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal strikes")
+		}
+		saved, appErr := s.api.KVCompareAndSet(strikesKVKeyPrefix+userID, oldData, data)
+		if appErr != nil {
+			return nil, errors.Wrap(appErr, "failed to store strikes")
+		}
+		if !saved {
+			continue
+		}
+		if oldData == nil {
+			if err := s.index.add(userID); err != nil {
+				return nil, errors.Wrap(err, "failed to add user to strikes index")
+			}
+		}
+		return strikes, nil
+	}
+	return nil, errors.New("strikes were changed concurrently too often")
+}
+
+// DeleteStrikes removes all strikes and sanctions of a user and returns what was removed
+func (s *strikesStore) DeleteStrikes(userID string) (*UserStrikes, error) {
+	for attempt := 0; attempt < maxStrikesUpdateAttempts; attempt++ {
+		strikes, oldData, err := s.getStrikes(userID)
+		if err != nil || strikes == nil {
+			return nil, err
+		}
+
+		saved, err := s.deleteStrikes(userID, oldData)
+		if err != nil {
+			return nil, err
+		}
+		if saved {
+			return strikes, nil
+		}
+	}
+	return nil, errors.New("strikes were changed concurrently too often")
+}
+
+// ListStrikes returns every tracked user, with the most strikes first
+func (s *strikesStore) ListStrikes() ([]*UserStrikes, error) {
+	userIDs, err := s.index.list()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list users with strikes")
+	}
+
+	list := make([]*UserStrikes, 0, len(userIDs))
+	for _, userID := range userIDs {
+		strikes, err := s.GetStrikes(userID)
+		if err != nil {
+			return nil, err
+		}
+		// Removed by another node between reading the index and the strikes
+		if strikes == nil {
+			continue
+		}
+		list = append(list, strikes)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if len(list[i].Strikes) != len(list[j].Strikes) {
+			return len(list[i].Strikes) > len(list[j].Strikes)
+		}
+		return list[i].UserID < list[j].UserID
+	})
+	return list, nil
+}
+
+func (s *strikesStore) getStrikes(userID string) (*UserStrikes, []byte, error) {
+	data, appErr := s.api.KVGet(strikesKVKeyPrefix + userID)
+	if appErr != nil {
+		return nil, nil, errors.Wrap(appErr, "failed to get strikes")
+	}
+	if data == nil {
+		return nil, nil, nil
+	}
+
+	var strikes UserStrikes
+	if err := json.Unmarshal(data, &strikes); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to unmarshal strikes")
+	}
+	return &strikes, data, nil
+}
+
+// deleteStrikes removes the strikes of a user if they weren't changed since they
+// were read as oldData
+func (s *strikesStore) deleteStrikes(userID string, oldData []byte) (bool, error) {
+	deleted, appErr := s.api.KVSetWithOptions(strikesKVKeyPrefix+userID, nil, model.PluginKVSetOptions{
+		Atomic:   true,
+		OldValue: oldData,
+	})
+	if appErr != nil {
+		return false, errors.Wrap(appErr, "failed to delete strikes")
+	}
+	if !deleted {
+		return false, nil
+	}
+	if err := s.index.remove(userID); err != nil {
+		return false, errors.Wrap(err, "failed to remove user from strikes index")
+	}
+	return true, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrikesStore(t *testing.T) {
+	api := newKVTestAPI()
+	store, err := newStrikesStore(api)
+	require.NoError(t, err)
+
+	_, err = store.UpdateStrikes("user1", func(s *UserStrikes) {
+		s.Strikes = []Strike{{PostID: "post1"}}
+	})
+	require.NoError(t, err)
+	_, err = store.UpdateStrikes("user2", func(s *UserStrikes) {
+		s.Strikes = []Strike{{PostID: "post2"}, {PostID: "post3"}}
+		s.Level = sanctionNotify
+	})
+	require.NoError(t, err)
+
+	// Another node sees the strikes through the KV store
+	other, err := newStrikesStore(api)
+	require.NoError(t, err)
+	strikes, err := other.GetStrikes("user2")
+	require.NoError(t, err)
+	require.NotNil(t, strikes)
+	assert.Equal(t, sanctionNotify, strikes.Level)
+
+	list, err := other.ListStrikes()
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "user2", list[0].UserID, "users with the most strikes come first")
+	assert.Equal(t, "user1", list[1].UserID)
+
+	// Users without strikes are removed
+	_, err = other.UpdateStrikes("user1", func(s *UserStrikes) {
+		s.Strikes = nil
+	})
+	require.NoError(t, err)
+	deleted, err := store.DeleteStrikes("user2")
+	require.NoError(t, err)
+	require.NotNil(t, deleted)
+	assert.Len(t, deleted.Strikes, 2)
+
+	list, err = store.ListStrikes()
+	require.NoError(t, err)
+	assert.Empty(t, list)
+	deleted, err = store.DeleteStrikes("user2")
+	require.NoError(t, err)
+	assert.Nil(t, deleted)
+}
+
+func TestStrikesStore_concurrentUpdates(t *testing.T) {
+	api := newKVTestAPI()
+	stores := make([]*strikesStore, 3)
+	for i := range stores {
+		store, err := newStrikesStore(api)
+		require.NoError(t, err)
+		stores[i] = store
+	}
+
+	var wg sync.WaitGroup
+	for i, store := range stores {
+		wg.Add(1)
+		go func(node int, store *strikesStore) {
+			defer wg.Done()
+			_, err := store.UpdateStrikes("user1", func(s *UserStrikes) {
+				s.Strikes = append(s.Strikes, Strike{PostID: fmt.Sprintf("post%d", node)})
+			})
+			assert.NoError(t, err)
+		}(i, store)
+	}
+	wg.Wait()
+
+	strikes, err := stores[0].GetStrikes("user1")
+	require.NoError(t, err)
+	assert.Len(t, strikes.Strikes, len(stores), "no strike recorded by another node is lost")
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStrikePolicy() strikePolicy {
+	return strikePolicy{
+		decay:            30 * 24 * time.Hour,
+		restrictDuration: 24 * time.Hour,
+		thresholds: map[sanctionLevel]int{
+			sanctionWarning:    1,
+			sanctionNotify:     2,
+			sanctionRestrict:   3,
+			sanctionDeactivate: 0,
+		},
+	}
+}
+
+func newStrikesTestProcessor(t *testing.T, api *plugintest.API) *PostProcessor {
+	store, err := newStrikesStore(api)
+	require.NoError(t, err)
+
+	return &PostProcessor{
+		botID:         "bot123",
+		reviewers:     map[string]struct{}{},
+		strikesStore:  store,
+		strikePolicy:  newTestStrikePolicy(),
+		postCache:     newPostCache(),
+		cleanupTicker: time.NewTicker(24 * time.Hour),
+	}
+}
+
+func TestStrikePolicy_levelFor(t *testing.T) {
+	policy := newTestStrikePolicy()
+
+	assert.Equal(t, sanctionNone, policy.levelFor(0))
+	assert.Equal(t, sanctionWarning, policy.levelFor(1))
+	assert.Equal(t, sanctionNotify, policy.levelFor(2))
+	assert.Equal(t, sanctionRestrict, policy.levelFor(3))
+	assert.Equal(t, sanctionRestrict, policy.levelFor(10), "disabled sanctions are never reached")
+}
+
+func TestPostProcessor_recordStrike(t *testing.T) {
+	post := &model.Post{Id: "post123", UserId: "user456", ChannelId: "channel123"}
+	result := moderation.Result{"hate": 6}
+
+	t.Run("warns user on first strike", func(t *testing.T) {
+		api := newKVTestAPI()
+		processor := newStrikesTestProcessor(t, api)
+		api.On("GetDirectChannel", "bot123", "user456").Return(&model.Channel{Id: "dm_channel"}, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "dm_channel"
+		})).Return(&model.Post{}, nil).Once()
+
+		record := plugin.MakeAuditRecord("test", model.AuditStatusAttempt)
+		processor.recordStrike(api, post, result, record)
+
+		strikes, err := processor.strikesStore.GetStrikes("user456")
+		require.NoError(t, err)
+		require.Len(t, strikes.Strikes, 1)
+		assert.Equal(t, sanctionWarning, strikes.Level)
+		assert.Equal(t, 1, record.Meta[auditMetaKeyStrikes])
+		assert.Equal(t, "warning", record.Meta[auditMetaKeySanction])
+		api.AssertExpectations(t)
+	})
+
+	t.Run("restricts user and notifies admins when threshold is crossed", func(t *testing.T) {
+		api := newKVTestAPI()
+		processor := newStrikesTestProcessor(t, api)
+		processor.reviewers = map[string]struct{}{"reviewer789": {}}
+		now := model.GetMillis()
+		_, err := processor.strikesStore.UpdateStrikes("user456", func(s *UserStrikes) {
+			s.Strikes = []Strike{{PostID: "old1", CreatedAt: now}, {PostID: "old2", CreatedAt: now}}
+			s.Level = sanctionNotify
+		})
+		require.NoError(t, err)
+
+		api.On("GetDirectChannel", "bot123", "user456").Return(&model.Channel{Id: "dm_user"}, nil)
+		api.On("GetDirectChannel", "bot123", "reviewer789").Return(&model.Channel{Id: "dm_reviewer"}, nil)
+		api.On("GetDirectChannel", "bot123", "admin123").Return(&model.Channel{Id: "dm_admin"}, nil)
+		api.On("GetUsers", mock.Anything).Return([]*model.User{{Id: "admin123"}}, nil)
+		api.On("GetUser", "user456").Return(&model.User{Username: "offender"}, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "dm_user"
+		})).Return(&model.Post{}, nil).Once()
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "dm_reviewer"
+		})).Return(&model.Post{}, nil).Once()
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "dm_admin"
+		})).Return(&model.Post{}, nil).Once()
+
+		record := plugin.MakeAuditRecord("test", model.AuditStatusAttempt)
+		processor.recordStrike(api, post, result, record)
+
+		strikes, err := processor.strikesStore.GetStrikes("user456")
+		require.NoError(t, err)
+		assert.Equal(t, sanctionRestrict, strikes.Level)
+		assert.True(t, strikes.isRestricted(model.GetMillis()))
+		api.AssertExpectations(t)
+	})
+
+	t.Run("restricts user again after the restriction expired", func(t *testing.T) {
+		api := newKVTestAPI()
+		processor := newStrikesTestProcessor(t, api)
+		now := time.Now()
+		_, err := processor.strikesStore.UpdateStrikes("user456", func(s *UserStrikes) {
+			s.Strikes = []Strike{
+				{PostID: "old1", CreatedAt: now.Add(-3 * 24 * time.Hour).UnixMilli()},
+				{PostID: "old2", CreatedAt: now.Add(-3 * 24 * time.Hour).UnixMilli()},
+				{PostID: "old3", CreatedAt: now.Add(-2 * 24 * time.Hour).UnixMilli()},
+			}
+			s.Level = sanctionRestrict
+			s.RestrictedUntil = now.Add(-24 * time.Hour).UnixMilli()
+		})
+		require.NoError(t, err)
+
+		strikes, err := processor.strikesStore.GetStrikes("user456")
+		require.NoError(t, err)
+		require.False(t, strikes.isRestricted(now.UnixMilli()), "the first restriction has expired")
+
+		api.On("GetDirectChannel", "bot123", mock.Anything).Return(&model.Channel{Id: "dm_channel"}, nil)
+		api.On("GetUsers", mock.Anything).Return([]*model.User{}, nil)
+		api.On("GetUser", "user456").Return(&model.User{Username: "offender"}, nil)
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "dm_channel"
+		})).Return(&model.Post{}, nil).Once()
+
+		record := plugin.MakeAuditRecord("test", model.AuditStatusAttempt)
+		processor.recordStrike(api, post, result, record)
+
+		strikes, err = processor.strikesStore.GetStrikes("user456")
+		require.NoError(t, err)
+		assert.Equal(t, sanctionRestrict, strikes.Level)
+		assert.True(t, strikes.isRestricted(model.GetMillis()))
+		assert.Equal(t, "restrict", record.Meta[auditMetaKeySanction])
+		api.AssertExpectations(t)
+
+		// A strike during the restriction doesn't extend it
+		until := strikes.RestrictedUntil
+		record = plugin.MakeAuditRecord("test", model.AuditStatusAttempt)
+		processor.recordStrike(api, &model.Post{Id: "post789", UserId: "user456", ChannelId: "channel123"}, result, record)
+		strikes, err = processor.strikesStore.GetStrikes("user456")
+		require.NoError(t, err)
+		assert.Equal(t, until, strikes.RestrictedUntil)
+		assert.Nil(t, record.Meta[auditMetaKeySanction])
+	})
+
+	t.Run("does not count decayed strikes", func(t *testing.T) {
+		api := newKVTestAPI()
+		processor := newStrikesTestProcessor(t, api)
+		old := time.Now().Add(-60 * 24 * time.Hour).UnixMilli()
+		_, err := processor.strikesStore.UpdateStrikes("user456", func(s *UserStrikes) {
+			s.Strikes = []Strike{{PostID: "old1", CreatedAt: old}, {PostID: "old2", CreatedAt: old}}
+			s.Level = sanctionWarning
+		})
+		require.NoError(t, err)
+
+		record := plugin.MakeAuditRecord("test", model.AuditStatusAttempt)
+		processor.recordStrike(api, post, result, record)
+
+		strikes, err := processor.strikesStore.GetStrikes("user456")
+		require.NoError(t, err)
+		require.Len(t, strikes.Strikes, 1)
+		assert.Equal(t, "post123", strikes.Strikes[0].PostID)
+		assert.Equal(t, sanctionWarning, strikes.Level)
+		api.AssertExpectations(t)
+	})
+}
+
+func TestPostProcessor_removeStrike(t *testing.T) {
+	api := newKVTestAPI()
+	processor := newStrikesTestProcessor(t, api)
+	_, err := processor.strikesStore.UpdateStrikes("user456", func(s *UserStrikes) {
+		s.Strikes = []Strike{{PostID: "post123", CreatedAt: model.GetMillis()}}
+		s.Level = sanctionWarning
+	})
+	require.NoError(t, err)
+
+	processor.removeStrike(api, "user456", "post123")
+
+	strikes, err := processor.strikesStore.GetStrikes("user456")
+	require.NoError(t, err)
+	assert.Nil(t, strikes)
+}
+
+func TestPlugin_checkPostingRestriction(t *testing.T) {
+	api := newKVTestAPI()
+	processor := newStrikesTestProcessor(t, api)
+	_, err := processor.strikesStore.UpdateStrikes("restricted", func(s *UserStrikes) {
+		s.Strikes = []Strike{{PostID: "post1", CreatedAt: model.GetMillis()}}
+		s.RestrictedUntil = time.Now().Add(time.Hour).UnixMilli()
+	})
+	require.NoError(t, err)
+	_, err = processor.strikesStore.UpdateStrikes("expired", func(s *UserStrikes) {
+		s.Strikes = []Strike{{PostID: "post2", CreatedAt: model.GetMillis()}}
+		s.RestrictedUntil = time.Now().Add(-time.Hour).UnixMilli()
+	})
+	require.NoError(t, err)
+
+	p := &Plugin{postProcessor: processor}
+	p.SetAPI(api)
+
+	assert.NotEmpty(t, p.checkPostingRestriction(&model.Post{UserId: "restricted"}))
+	assert.Empty(t, p.checkPostingRestriction(&model.Post{UserId: "expired"}))
+	assert.Empty(t, p.checkPostingRestriction(&model.Post{UserId: "unknown"}))
+
+	p.postProcessor.strikesStore = nil
+	assert.Empty(t, p.checkPostingRestriction(&model.Post{UserId: "restricted"}))
+}
+
+func TestPlugin_pluginWritesOfRestrictedAuthor(t *testing.T) {
+	// newPlugin returns a plugin whose post writes run its hooks, like the server
+	// does, for a restricted author
+	newPlugin := func(t *testing.T) (*Plugin, *plugintest.API) {
+		api := newKVTestAPI()
+		processor := newStrikesTestProcessor(t, api)
+		_, err := processor.strikesStore.UpdateStrikes("user456", func(s *UserStrikes) {
+			s.Strikes = []Strike{
+				{PostID: "post1", CreatedAt: model.GetMillis()},
+				{PostID: "post2", CreatedAt: model.GetMillis()},
+				{PostID: "post123", CreatedAt: model.GetMillis()},
+			}
+			s.Level = sanctionRestrict
+			s.RestrictedUntil = time.Now().Add(time.Hour).UnixMilli()
+		})
+		require.NoError(t, err)
+
+		p := &Plugin{
+			configuration: &configuration{},
+			appealsStore:  NewMockAppealsStore(),
+			postProcessor: processor,
+		}
+		p.SetAPI(api)
+
+		api.On("UpdatePost", mock.Anything).Return(func(post *model.Post) (*model.Post, *model.AppError) {
+			if _, rejection := p.MessageWillBeUpdated(&plugin.Context{}, post, nil); rejection != "" {
+				return nil, model.NewAppError("UpdatePost", "rejected", nil, rejection, 400)
+			}
+			return post, nil
+		})
+		api.On("CreatePost", mock.Anything).Return(func(post *model.Post) (*model.Post, *model.AppError) {
+			if _, rejection := p.MessageWillBePosted(&plugin.Context{}, post); rejection != "" {
+				return nil, model.NewAppError("CreatePost", "rejected", nil, rejection, 400)
+			}
+			return post, nil
+		})
+		return p, api
+	}
+	post := &model.Post{Id: "post123", UserId: "user456", ChannelId: "channel123", Message: "message"}
+
+	t.Run("author can't update the post", func(t *testing.T) {
+		p, _ := newPlugin(t)
+		_, rejection := p.MessageWillBeUpdated(&plugin.Context{}, post.Clone(), nil)
+		assert.NotEmpty(t, rejection)
+		_, rejection = p.MessageWillBePosted(&plugin.Context{}, &model.Post{UserId: "user456", ChannelId: "channel123", Message: "new"})
+		assert.NotEmpty(t, rejection)
+	})
+
+	t.Run("hides and restores the post", func(t *testing.T) {
+		_, api := newPlugin(t)
+		api.On("GetPost", "post123").Return(&model.Post{Id: "post123", UserId: "user456", ChannelId: "channel123", Message: hiddenPostMessage}, nil)
+		require.NoError(t, hidePost(api, post.Clone()))
+		restored, err := restoreHiddenPost(api, post.Id)
+		require.NoError(t, err)
+		assert.Equal(t, "message", restored.Message)
+	})
+
+	t.Run("reinstates the post", func(t *testing.T) {
+		p, api := newPlugin(t)
+		api.On("GetDirectChannel", "bot123", "user456").Return(&model.Channel{Id: "dm_channel"}, nil)
+		api.On("GetUser", "moderator789").Return(&model.User{Username: "moderator"}, nil)
+		appeal := newTestAppeal(AppealStatusPending)
+		appeal.RootID = ""
+		appeal.Post.CreateAt = 1000
+		p.appealsStore.(*MockAppealsStore).appeals[appeal.ID] = appeal
+
+		_, err := p.reinstateAppeal(appeal.ID, "moderator789", plugin.MakeAuditRecord("test", model.AuditStatusAttempt))
+		require.NoError(t, err)
+		api.AssertCalled(t, "CreatePost", mock.MatchedBy(func(created *model.Post) bool {
+			return created.UserId == "user456" && created.Message == "original message"
+		}))
+
+		strikes, err := p.postProcessor.strikesStore.GetStrikes("user456")
+		require.NoError(t, err)
+		assert.Len(t, strikes.Strikes, 2, "the strike of the reinstated post is removed")
+	})
+}
+
+func TestPlugin_resetStrikes(t *testing.T) {
+	t.Run("removes strikes and reactivates user", func(t *testing.T) {
+		api := newKVTestAPI()
+		processor := newStrikesTestProcessor(t, api)
+		_, err := processor.strikesStore.UpdateStrikes("user456", func(s *UserStrikes) {
+			s.Strikes = []Strike{{PostID: "post1", CreatedAt: model.GetMillis()}}
+			s.Level = sanctionDeactivate
+			s.Deactivated = true
+		})
+		require.NoError(t, err)
+		api.On("UpdateUserActive", "user456", true).Return(nil)
+
+		p := &Plugin{postProcessor: processor, strikesStore: processor.strikesStore}
+		p.SetAPI(api)
+		record := plugin.MakeAuditRecord("test", model.AuditStatusAttempt)
+
+		require.NoError(t, p.resetStrikes("user456", record))
+		strikes, err := processor.strikesStore.GetStrikes("user456")
+		require.NoError(t, err)
+		assert.Nil(t, strikes)
+		api.AssertExpectations(t)
+	})
+
+	t.Run("returns error for user without strikes", func(t *testing.T) {
+		api := newKVTestAPI()
+		processor := newStrikesTestProcessor(t, api)
+
+		p := &Plugin{postProcessor: processor, strikesStore: processor.strikesStore}
+		p.SetAPI(api)
+		record := plugin.MakeAuditRecord("test", model.AuditStatusAttempt)
+
+		assert.ErrorIs(t, p.resetStrikes("user456", record), ErrUserHasNoStrikes)
+	})
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation"
+	"github.com/mattermost/mattermost-plugin-content-moderation/server/moderation/composite"
+	"github.com/pkg/errors"
+)
+
+// categoryThresholdDisabled is the configuration value used to turn off a category
+const categoryThresholdDisabled = "disabled"
+
+// Moderation severities range from minSeverity to maxSeverity, so thresholds outside
+// of it would flag everything or nothing
+const (
+	minSeverity = 0
+	maxSeverity = 6
+)
+
+// categoryThreshold overrides the moderation threshold for a single category
+type categoryThreshold struct {
+	value    int
+	disabled bool
+}
+
+// parseCategoryThresholds converts the configured per-category thresholds into overrides
+// keyed by lowercase category name. Empty values mean the category uses the default threshold.
+func parseCategoryThresholds(raw map[string]string) (map[string]categoryThreshold, error) {
+	thresholds := make(map[string]categoryThreshold)
+	for category, value := range raw {
+		category = strings.ToLower(strings.TrimSpace(category))
+		value = strings.TrimSpace(value)
+		if category == "" || value == "" {
+			continue
+		}
+
+		if strings.EqualFold(value, categoryThresholdDisabled) {
+			thresholds[category] = categoryThreshold{disabled: true}
+			continue
+		}
+
+		val, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not parse threshold value for category '%s': '%s'", category, value)
+		}
+		if val < minSeverity || val > maxSeverity {
+			return nil, errors.Errorf("threshold value for category '%s' must be between %d and %d: '%s'", category, minSeverity, maxSeverity, value)
+		}
+		thresholds[category] = categoryThreshold{value: val}
+	}
+	return thresholds, nil
+}
+
+// severityExceedsThresholds returns true if any category in the result meets its threshold.
+// Categories without an override use the default threshold, and disabled categories are ignored.
+func severityExceedsThresholds(result moderation.Result, defaultThreshold int, categoryThresholds map[string]categoryThreshold) bool {
+	for category, severity := range result {
+		threshold := defaultThreshold
+		if override, ok := categoryThresholds[strings.ToLower(category)]; ok {
+			if override.disabled {
+				continue
+			}
+			threshold = override.value
+		}
+		if severity >= threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// compositeThresholds returns the range of thresholds that apply to each category
+// across the global configuration and the team and channel policies, so the
+// composite moderator only stops consulting stages when text is flagged everywhere
+func compositeThresholds(defaultThreshold int, categoryThresholds map[string]categoryThreshold, policies []*ModerationPolicy) composite.Thresholds {
+	thresholds := composite.Thresholds{
+		Default:    composite.Range{Lowest: defaultThreshold, Highest: defaultThreshold},
+		Categories: make(map[string]composite.Range),
+	}
+
+	policyThresholds := make([]map[string]categoryThreshold, 0, len(policies))
+	for _, policy := range policies {
+		if policy.Threshold != "" {
+			// Policies are validated when they are stored
+			if val, err := strconv.Atoi(policy.Threshold); err == nil {
+				thresholds.Default = extendRange(thresholds.Default, val)
+			}
+		}
+		if overrides, err := parseCategoryThresholds(policy.CategoryThresholds); err == nil {
+			policyThresholds = append(policyThresholds, overrides)
+		}
+	}
+
+	// A global category threshold applies regardless of the default threshold of
+	// a policy, while other categories fall back to any of the default thresholds
+	for category, override := range categoryThresholds {
+		val := thresholdValue(override)
+		thresholds.Categories[category] = composite.Range{Lowest: val, Highest: val}
+	}
+	for _, overrides := range policyThresholds {
+		for category, override := range overrides {
+			r, ok := thresholds.Categories[category]
+			if !ok {
+				r = thresholds.Default
+			}
+			thresholds.Categories[category] = extendRange(r, thresholdValue(override))
+		}
+	}
+	return thresholds
+}
+
+// thresholdValue returns the threshold of a category override for the composite moderator
+func thresholdValue(override categoryThreshold) int {
+	if override.disabled {
+		return composite.Disabled
+	}
+	return override.value
+}
+
+// extendRange widens a range of thresholds to include a threshold
+func extendRange(r composite.Range, threshold int) composite.Range {
+	if threshold < r.Lowest {
+		r.Lowest = threshold
+	}
+	if threshold > r.Highest {
+		r.Highest = threshold
+	}
+	return r
+}
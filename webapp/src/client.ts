@@ -10,6 +10,44 @@ export interface ModerationStatusResponse {
     excluded: boolean;
 }
 
+export interface ModerationStatsDay {
+    date: string;
+    checked: number;
+    flagged: number;
+    flagged_categories: Record<string, number>;
+    actions: Record<string, number>;
+    errors: number;
+    timeouts: number;
+    excluded: Record<string, number>;
+    max_queue_depth: number;
+    provider_requests: number;
+    provider_latency_total_ms: number;
+    provider_latency_max_ms: number;
+}
+
+export interface ModerationStatsResponse {
+    from: string;
+    to: string;
+    totals: ModerationStatsDay;
+    average_latency_ms: number;
+    days: ModerationStatsDay[];
+}
+
+export interface ProviderTestResult {
+    moderator: string;
+    success: boolean;
+    latency_ms: number;
+    result?: Record<string, number>;
+    error?: string;
+    error_kind?: string;
+}
+
+export interface ConnectionTestResponse {
+    success: boolean;
+    config_error?: string;
+    providers: ProviderTestResult[];
+}
+
 export class Client {
     private baseUrl: string;
     private client4: Client4;
@@ -75,6 +113,47 @@ export class Client {
         return response.json();
     }
 
+    async getModerationStats(days: number): Promise<ModerationStatsResponse> {
+        const url = `${this.baseUrl}/stats?days=${days}`;
+        const options = {
+            method: 'GET',
+        };
+
+        const response = await fetch(url, this.client4.getOptions(options));
+
+        if (!response.ok) {
+            const text = await response.text();
+            throw new ClientError(this.client4.url, {
+                message: text || 'Failed to get moderation statistics',
+                status_code: response.status,
+                url,
+            });
+        }
+
+        return response.json();
+    }
+
+    async testConnection(moderatorConfig: unknown): Promise<ConnectionTestResponse> {
+        const url = `${this.baseUrl}/health/test`;
+        const options = {
+            method: 'POST',
+            body: JSON.stringify({moderator_config: moderatorConfig}),
+        };
+
+        const response = await fetch(url, this.client4.getOptions(options));
+
+        if (!response.ok) {
+            const text = await response.text();
+            throw new ClientError(this.client4.url, {
+                message: text || 'Failed to test the moderation provider connection',
+                status_code: response.status,
+                url,
+            });
+        }
+
+        return response.json();
+    }
+
     async createEphemeralPost(channelId: string, message: string, userId: string): Promise<void> {
         const url = '/api/v4/posts/ephemeral';
         const options = {
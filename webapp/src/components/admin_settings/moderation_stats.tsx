@@ -0,0 +1,203 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+import React, {useState, useEffect} from 'react';
+
+import {client} from '@/client';
+import type {ModerationStatsResponse} from '@/client';
+
+const RANGE_OPTIONS = [
+    {value: 7, label: 'Last 7 days'},
+    {value: 30, label: 'Last 30 days'},
+    {value: 90, label: 'Last 90 days'},
+    {value: 365, label: 'Last 365 days'},
+] as const;
+
+const cellStyle: React.CSSProperties = {
+    padding: '6px 12px',
+    borderBottom: '1px solid #e5e7eb',
+    textAlign: 'left',
+    fontSize: '14px',
+};
+
+const headerCellStyle: React.CSSProperties = {
+    ...cellStyle,
+    color: '#3f4350',
+    fontWeight: 600,
+};
+
+const sectionTitleStyle: React.CSSProperties = {
+    marginTop: '16px',
+    marginBottom: '8px',
+    color: '#3f4350',
+    fontSize: '14px',
+    fontWeight: 600,
+};
+
+const sumCounts = (counts?: Record<string, number>) => Object.values(counts || {}).reduce((total, count) => total + count, 0);
+
+const renderCounts = (title: string, counts?: Record<string, number>) => {
+    const names = Object.keys(counts || {}).sort();
+    if (names.length === 0) {
+        return null;
+    }
+
+    return (
+        <>
+            <div style={sectionTitleStyle}>{title}</div>
+            <table style={{borderCollapse: 'collapse'}}>
+                <tbody>
+                    {names.map((name) => (
+                        <tr key={name}>
+                            <td style={cellStyle}>{name}</td>
+                            <td style={cellStyle}>{counts![name]}</td>
+                        </tr>
+                    ))}
+                </tbody>
+            </table>
+        </>
+    );
+};
+
+// ModerationStats shows the daily moderation statistics in the System Console.
+// It is a read-only setting and never changes the plugin configuration.
+const ModerationStats: React.FC = () => {
+    const [days, setDays] = useState<number>(30);
+    const [stats, setStats] = useState<ModerationStatsResponse | null>(null);
+    const [error, setError] = useState<string>('');
+
+    useEffect(() => {
+        let cancelled = false;
+        setError('');
+        client.getModerationStats(days).then((response) => {
+            if (!cancelled) {
+                setStats(response);
+            }
+        }).catch((err) => {
+            if (!cancelled) {
+                setError(err.message || 'Failed to load moderation statistics');
+            }
+        });
+        return () => {
+            cancelled = true;
+        };
+    }, [days]);
+
+    const renderSummary = (response: ModerationStatsResponse) => {
+        const totals = response.totals;
+        const columns = [
+            {label: 'Posts checked', value: totals.checked},
+            {label: 'Flagged', value: totals.flagged},
+            {label: 'Errors', value: totals.errors},
+            {label: 'Timeouts', value: totals.timeouts},
+            {label: 'Excluded', value: sumCounts(totals.excluded)},
+            {label: 'Max queue depth', value: totals.max_queue_depth},
+            {label: 'Average latency', value: `${response.average_latency_ms} ms`},
+            {label: 'Max latency', value: `${totals.provider_latency_max_ms} ms`},
+        ];
+
+        return (
+            <table style={{borderCollapse: 'collapse', width: '100%'}}>
+                <thead>
+                    <tr>
+                        {columns.map((column) => (
+                            <th
+                                key={column.label}
+                                style={headerCellStyle}
+                            >
+                                {column.label}
+                            </th>
+                        ))}
+                    </tr>
+                </thead>
+                <tbody>
+                    <tr>
+                        {columns.map((column) => (
+                            <td
+                                key={column.label}
+                                style={cellStyle}
+                            >
+                                {column.value}
+                            </td>
+                        ))}
+                    </tr>
+                </tbody>
+            </table>
+        );
+    };
+
+    const renderDays = (response: ModerationStatsResponse) => {
+        if (response.days.length === 0) {
+            return null;
+        }
+
+        return (
+            <>
+                <div style={sectionTitleStyle}>{'Per day'}</div>
+                <table style={{borderCollapse: 'collapse', width: '100%'}}>
+                    <thead>
+                        <tr>
+                            <th style={headerCellStyle}>{'Date'}</th>
+                            <th style={headerCellStyle}>{'Checked'}</th>
+                            <th style={headerCellStyle}>{'Flagged'}</th>
+                            <th style={headerCellStyle}>{'Errors'}</th>
+                            <th style={headerCellStyle}>{'Timeouts'}</th>
+                            <th style={headerCellStyle}>{'Excluded'}</th>
+                        </tr>
+                    </thead>
+                    <tbody>
+                        {[...response.days].reverse().map((day) => (
+                            <tr key={day.date}>
+                                <td style={cellStyle}>{day.date}</td>
+                                <td style={cellStyle}>{day.checked}</td>
+                                <td style={cellStyle}>{day.flagged}</td>
+                                <td style={cellStyle}>{day.errors}</td>
+                                <td style={cellStyle}>{day.timeouts}</td>
+                                <td style={cellStyle}>{sumCounts(day.excluded)}</td>
+                            </tr>
+                        ))}
+                    </tbody>
+                </table>
+            </>
+        );
+    };
+
+    return (
+        <div>
+            <select
+                value={days}
+                onChange={(e) => setDays(Number(e.target.value))}
+                style={{
+                    marginBottom: '16px',
+                    padding: '8px 12px',
+                    border: '1px solid #d1d5db',
+                    borderRadius: '4px',
+                    fontSize: '14px',
+                }}
+            >
+                {RANGE_OPTIONS.map((option) => (
+                    <option
+                        key={option.value}
+                        value={option.value}
+                    >
+                        {option.label}
+                    </option>
+                ))}
+            </select>
+            {error && (
+                <p style={{color: '#d24b4e', fontSize: '14px'}}>{error}</p>
+            )}
+            {!error && stats && (
+                <>
+                    {renderSummary(stats)}
+                    {renderCounts('Flagged by category', stats.totals.flagged_categories)}
+                    {renderCounts('Flagged by action', stats.totals.actions)}
+                    {renderCounts('Excluded by reason', stats.totals.excluded)}
+                    {renderDays(stats)}
+                </>
+            )}
+        </div>
+    );
+};
+
+export default ModerationStats;
@@ -3,9 +3,11 @@
 
 import React, {useState, useEffect, useCallback} from 'react';
 
+import {client} from '@/client';
+import type {ConnectionTestResponse, ProviderTestResult} from '@/client';
 import {DEFAULT_AGENTS_SYSTEM_PROMPT} from '@/components/admin_settings/agents_constants';
 
-type ModeratorType = 'azure' | 'agents';
+type ModeratorType = 'azure' | 'agents' | 'openai' | 'blocklist' | 'composite';
 
 const THRESHOLD_OPTIONS = [
     {value: '2', label: 'Low (2)'},
@@ -13,6 +15,25 @@ const THRESHOLD_OPTIONS = [
     {value: '6', label: 'High (6)'},
 ] as const;
 
+const CATEGORY_THRESHOLD_OPTIONS = [
+    {value: '', label: 'Use moderation threshold'},
+    ...THRESHOLD_OPTIONS,
+    {value: 'disabled', label: 'Disabled'},
+] as const;
+
+const COMPOSITE_STRATEGY_OPTIONS = [
+    {value: 'first_flag', label: 'Stop at first flag'},
+    {value: 'max_severity', label: 'Highest severity of all providers'},
+    {value: 'escalate', label: 'Escalate close calls to the next provider'},
+] as const;
+
+const CATEGORIES = [
+    {key: 'Hate', label: 'Hate'},
+    {key: 'Sexual', label: 'Sexual'},
+    {key: 'Violence', label: 'Violence'},
+    {key: 'SelfHarm', label: 'Self-harm'},
+] as const;
+
 interface ModeratorConfigValue {
     type: ModeratorType;
     azure_endpoint?: string;
@@ -21,6 +42,17 @@ interface ModeratorConfigValue {
     agents_system_prompt?: string;
     agents_threshold?: string;
     agents_bot_username?: string;
+    openai_endpoint?: string;
+    openai_apiKey?: string;
+    openai_model?: string;
+    openai_threshold?: string;
+    blocklist_threshold?: string;
+    composite_moderators?: string;
+    composite_strategy?: string;
+    composite_threshold?: string;
+    composite_escalation_margin?: string;
+    fallback_type?: string;
+    category_thresholds?: Record<string, string>;
 }
 
 interface ModeratorConfigProps {
@@ -31,6 +63,16 @@ interface ModeratorConfigProps {
     config?: Record<string, unknown>;
 }
 
+// Describes the outcome of testing a provider, following its name
+const describeProviderTest = (provider: ProviderTestResult): string => {
+    if (provider.success) {
+        const categories = Object.keys(provider.result || {}).sort().join(', ') || 'none';
+        return ` connected in ${provider.latency_ms} ms. Returned categories: ${categories}`;
+    }
+    const kind = provider.error_kind ? ` (${provider.error_kind})` : '';
+    return ` failed${kind}: ${provider.error}`;
+};
+
 // Helper function to create default configuration values
 const createDefaultConfig = (type: ModeratorType = 'agents', existingValues?: Partial<ModeratorConfigValue>): ModeratorConfigValue => {
     const defaults: ModeratorConfigValue = {
@@ -41,6 +83,17 @@ const createDefaultConfig = (type: ModeratorType = 'agents', existingValues?: Pa
         agents_system_prompt: DEFAULT_AGENTS_SYSTEM_PROMPT,
         agents_threshold: THRESHOLD_OPTIONS[0].value, // '2'
         agents_bot_username: '',
+        openai_endpoint: '',
+        openai_apiKey: '',
+        openai_model: '',
+        openai_threshold: THRESHOLD_OPTIONS[0].value, // '2'
+        blocklist_threshold: THRESHOLD_OPTIONS[0].value, // '2'
+        composite_moderators: 'blocklist,agents',
+        composite_strategy: COMPOSITE_STRATEGY_OPTIONS[0].value, // 'first_flag'
+        composite_threshold: THRESHOLD_OPTIONS[0].value, // '2'
+        composite_escalation_margin: '1',
+        fallback_type: '',
+        category_thresholds: {},
         ...existingValues,
     };
     return defaults;
@@ -51,6 +104,9 @@ const ModeratorConfig: React.FC<ModeratorConfigProps> = ({id, value, onChange})
     const initialConfig = createDefaultConfig(value?.type, value);
     const [currentType, setCurrentType] = useState<ModeratorType>(initialConfig.type);
     const [values, setValues] = useState<ModeratorConfigValue>(initialConfig);
+    const [testing, setTesting] = useState<boolean>(false);
+    const [testReport, setTestReport] = useState<ConnectionTestResponse | null>(null);
+    const [testError, setTestError] = useState<string>('');
 
     // Update state when props change
     useEffect(() => {
@@ -87,6 +143,23 @@ const ModeratorConfig: React.FC<ModeratorConfigProps> = ({id, value, onChange})
         onChange(id, newValues);
     }, [values, onChange, id]);
 
+    const handleCategoryThresholdChange = useCallback((category: string, threshold: string) => {
+        const categoryThresholds = {...(values.category_thresholds || {})};
+        if (threshold) {
+            categoryThresholds[category] = threshold;
+        } else {
+            delete categoryThresholds[category];
+        }
+
+        const newValues = {
+            ...values,
+            category_thresholds: categoryThresholds,
+        };
+
+        setValues(newValues);
+        onChange(id, newValues);
+    }, [values, onChange, id]);
+
     // Memoized render functions to prevent unnecessary re-renders
     const renderAzureSettings = useCallback((settings: ModeratorConfigValue) => {
         const azureEndpoint = settings.azure_endpoint || '';
@@ -212,7 +285,7 @@ const ModeratorConfig: React.FC<ModeratorConfigProps> = ({id, value, onChange})
                             fontSize: '12px',
                         }}
                     >
-                        {'Severity threshold for all content categories (Low filters most aggressively).'}
+                        {'Default severity threshold for content categories (Low filters most aggressively).'}
                     </p>
                 </div>
             </>
@@ -344,13 +417,606 @@ const ModeratorConfig: React.FC<ModeratorConfigProps> = ({id, value, onChange})
                             fontSize: '12px',
                         }}
                     >
-                        {'Severity threshold for all content categories (Low filters most aggressively).'}
+                        {'Default severity threshold for content categories (Low filters most aggressively).'}
+                    </p>
+                </div>
+            </>
+        );
+    }, [handleFieldChange]);
+
+    const renderOpenAISettings = useCallback((settings: ModeratorConfigValue) => {
+        const openaiEndpoint = settings.openai_endpoint || '';
+        const openaiApiKey = settings.openai_apiKey || '';
+        const openaiModel = settings.openai_model || '';
+        const openaiThreshold = settings.openai_threshold || '2';
+
+        return (
+            <>
+                <div style={{marginBottom: '16px'}}>
+                    <label
+                        style={{
+                            display: 'block',
+                            marginBottom: '8px',
+                            color: '#3f4350',
+                            fontSize: '14px',
+                            fontWeight: '600',
+                        }}
+                    >
+                        {'API Base URL'}
+                    </label>
+                    <input
+                        type='text'
+                        value={openaiEndpoint}
+                        onChange={(e) => handleFieldChange('openai_endpoint', e.target.value)}
+                        placeholder='https://api.openai.com/v1'
+                        style={{
+                            width: '100%',
+                            padding: '8px 12px',
+                            border: '1px solid #d1d5db',
+                            borderRadius: '4px',
+                            fontSize: '14px',
+                            boxSizing: 'border-box',
+                        }}
+                    />
+                    <p
+                        style={{
+                            marginTop: '4px',
+                            marginBottom: '0',
+                            color: '#6b7280',
+                            fontSize: '12px',
+                        }}
+                    >
+                        {'The base URL of the OpenAI API, or of a self-hosted server with a compatible moderations API. Defaults to the OpenAI API when empty.'}
+                    </p>
+                </div>
+
+                <div style={{marginBottom: '16px'}}>
+                    <label
+                        style={{
+                            display: 'block',
+                            marginBottom: '8px',
+                            color: '#3f4350',
+                            fontSize: '14px',
+                            fontWeight: '600',
+                        }}
+                    >
+                        {'API Key'}
+                    </label>
+                    <input
+                        type='password'
+                        value={openaiApiKey}
+                        onChange={(e) => handleFieldChange('openai_apiKey', e.target.value)}
+                        placeholder='Enter your OpenAI API key'
+                        style={{
+                            width: '100%',
+                            padding: '8px 12px',
+                            border: '1px solid #d1d5db',
+                            borderRadius: '4px',
+                            fontSize: '14px',
+                            boxSizing: 'border-box',
+                        }}
+                    />
+                    <p
+                        style={{
+                            marginTop: '4px',
+                            marginBottom: '0',
+                            color: '#6b7280',
+                            fontSize: '12px',
+                        }}
+                    >
+                        {'Your OpenAI API key. Optional for self-hosted servers that do not require authentication.'}
+                    </p>
+                </div>
+
+                <div style={{marginBottom: '16px'}}>
+                    <label
+                        style={{
+                            display: 'block',
+                            marginBottom: '8px',
+                            color: '#3f4350',
+                            fontSize: '14px',
+                            fontWeight: '600',
+                        }}
+                    >
+                        {'Moderation Model'}
+                    </label>
+                    <input
+                        type='text'
+                        value={openaiModel}
+                        onChange={(e) => handleFieldChange('openai_model', e.target.value)}
+                        placeholder='omni-moderation-latest'
+                        style={{
+                            width: '100%',
+                            padding: '8px 12px',
+                            border: '1px solid #d1d5db',
+                            borderRadius: '4px',
+                            fontSize: '14px',
+                            boxSizing: 'border-box',
+                        }}
+                    />
+                    <p
+                        style={{
+                            marginTop: '4px',
+                            marginBottom: '0',
+                            color: '#6b7280',
+                            fontSize: '12px',
+                        }}
+                    >
+                        {'The moderation model to use. Defaults to omni-moderation-latest when empty.'}
+                    </p>
+                </div>
+
+                <div>
+                    <label
+                        style={{
+                            display: 'block',
+                            marginBottom: '8px',
+                            color: '#3f4350',
+                            fontSize: '14px',
+                            fontWeight: '600',
+                        }}
+                    >
+                        {'Moderation Threshold'}
+                    </label>
+                    <select
+                        value={openaiThreshold}
+                        onChange={(e) => handleFieldChange('openai_threshold', e.target.value)}
+                        style={{
+                            width: '100%',
+                            padding: '8px 12px',
+                            border: '1px solid #d1d5db',
+                            borderRadius: '4px',
+                            fontSize: '14px',
+                            boxSizing: 'border-box',
+                        }}
+                    >
+                        {THRESHOLD_OPTIONS.map((option) => (
+                            <option
+                                key={option.value}
+                                value={option.value}
+                            >
+                                {option.label}
+                            </option>
+                        ))}
+                    </select>
+                    <p
+                        style={{
+                            marginTop: '4px',
+                            marginBottom: '0',
+                            color: '#6b7280',
+                            fontSize: '12px',
+                        }}
+                    >
+                        {'Default severity threshold for content categories (Low filters most aggressively). Category scores are scaled to a 0-6 severity.'}
                     </p>
                 </div>
             </>
         );
     }, [handleFieldChange]);
 
+    const renderBlocklistSettings = useCallback((settings: ModeratorConfigValue) => {
+        const blocklistThreshold = settings.blocklist_threshold || '2';
+
+        return (
+            <div>
+                <label
+                    style={{
+                        display: 'block',
+                        marginBottom: '8px',
+                        color: '#3f4350',
+                        fontSize: '14px',
+                        fontWeight: '600',
+                    }}
+                >
+                    {'Moderation Threshold'}
+                </label>
+                <select
+                    value={blocklistThreshold}
+                    onChange={(e) => handleFieldChange('blocklist_threshold', e.target.value)}
+                    style={{
+                        width: '100%',
+                        padding: '8px 12px',
+                        border: '1px solid #d1d5db',
+                        borderRadius: '4px',
+                        fontSize: '14px',
+                        boxSizing: 'border-box',
+                    }}
+                >
+                    {THRESHOLD_OPTIONS.map((option) => (
+                        <option
+                            key={option.value}
+                            value={option.value}
+                        >
+                            {option.label}
+                        </option>
+                    ))}
+                </select>
+                <p
+                    style={{
+                        marginTop: '4px',
+                        marginBottom: '0',
+                        color: '#6b7280',
+                        fontSize: '12px',
+                    }}
+                >
+                    {'Default severity threshold for content categories. Blocklist entries are managed with the /moderation blocklist command.'}
+                </p>
+            </div>
+        );
+    }, [handleFieldChange]);
+
+    const renderCompositeSettings = useCallback((settings: ModeratorConfigValue) => {
+        const compositeModerators = settings.composite_moderators || '';
+        const compositeStrategy = settings.composite_strategy || 'first_flag';
+        const compositeThreshold = settings.composite_threshold || '2';
+        const compositeMargin = settings.composite_escalation_margin || '';
+        const stageTypes = compositeModerators.split(',').map((type) => type.trim());
+
+        return (
+            <>
+                <div style={{marginBottom: '16px'}}>
+                    <label
+                        style={{
+                            display: 'block',
+                            marginBottom: '8px',
+                            color: '#3f4350',
+                            fontSize: '14px',
+                            fontWeight: '600',
+                        }}
+                    >
+                        {'Providers'}
+                    </label>
+                    <input
+                        type='text'
+                        value={compositeModerators}
+                        onChange={(e) => handleFieldChange('composite_moderators', e.target.value)}
+                        placeholder='blocklist,agents'
+                        style={{
+                            width: '100%',
+                            padding: '8px 12px',
+                            border: '1px solid #d1d5db',
+                            borderRadius: '4px',
+                            fontSize: '14px',
+                            boxSizing: 'border-box',
+                        }}
+                    />
+                    <p
+                        style={{
+                            marginTop: '4px',
+                            marginBottom: '0',
+                            color: '#6b7280',
+                            fontSize: '12px',
+                        }}
+                    >
+                        {'Comma-separated list of providers to consult in order: azure, agents, openai or blocklist. Each provider uses its own settings below.'}
+                    </p>
+                </div>
+
+                <div style={{marginBottom: '16px'}}>
+                    <label
+                        style={{
+                            display: 'block',
+                            marginBottom: '8px',
+                            color: '#3f4350',
+                            fontSize: '14px',
+                            fontWeight: '600',
+                        }}
+                    >
+                        {'Strategy'}
+                    </label>
+                    <select
+                        value={compositeStrategy}
+                        onChange={(e) => handleFieldChange('composite_strategy', e.target.value)}
+                        style={{
+                            width: '100%',
+                            padding: '8px 12px',
+                            border: '1px solid #d1d5db',
+                            borderRadius: '4px',
+                            fontSize: '14px',
+                            boxSizing: 'border-box',
+                        }}
+                    >
+                        {COMPOSITE_STRATEGY_OPTIONS.map((option) => (
+                            <option
+                                key={option.value}
+                                value={option.value}
+                            >
+                                {option.label}
+                            </option>
+                        ))}
+                    </select>
+                    <p
+                        style={{
+                            marginTop: '4px',
+                            marginBottom: '0',
+                            color: '#6b7280',
+                            fontSize: '12px',
+                        }}
+                    >
+                        {'How the results of the providers are combined.'}
+                    </p>
+                </div>
+
+                {compositeStrategy === 'escalate' && (
+                    <div style={{marginBottom: '16px'}}>
+                        <label
+                            style={{
+                                display: 'block',
+                                marginBottom: '8px',
+                                color: '#3f4350',
+                                fontSize: '14px',
+                                fontWeight: '600',
+                            }}
+                        >
+                            {'Escalation Margin'}
+                        </label>
+                        <input
+                            type='number'
+                            min='0'
+                            max='6'
+                            value={compositeMargin}
+                            onChange={(e) => handleFieldChange('composite_escalation_margin', e.target.value)}
+                            style={{
+                                width: '100%',
+                                padding: '8px 12px',
+                                border: '1px solid #d1d5db',
+                                borderRadius: '4px',
+                                fontSize: '14px',
+                                boxSizing: 'border-box',
+                            }}
+                        />
+                        <p
+                            style={{
+                                marginTop: '4px',
+                                marginBottom: '0',
+                                color: '#6b7280',
+                                fontSize: '12px',
+                            }}
+                        >
+                            {'The next provider is asked for a second opinion when the highest severity is below the threshold by at most this much.'}
+                        </p>
+                    </div>
+                )}
+
+                <div style={{marginBottom: '24px'}}>
+                    <label
+                        style={{
+                            display: 'block',
+                            marginBottom: '8px',
+                            color: '#3f4350',
+                            fontSize: '14px',
+                            fontWeight: '600',
+                        }}
+                    >
+                        {'Moderation Threshold'}
+                    </label>
+                    <select
+                        value={compositeThreshold}
+                        onChange={(e) => handleFieldChange('composite_threshold', e.target.value)}
+                        style={{
+                            width: '100%',
+                            padding: '8px 12px',
+                            border: '1px solid #d1d5db',
+                            borderRadius: '4px',
+                            fontSize: '14px',
+                            boxSizing: 'border-box',
+                        }}
+                    >
+                        {THRESHOLD_OPTIONS.map((option) => (
+                            <option
+                                key={option.value}
+                                value={option.value}
+                            >
+                                {option.label}
+                            </option>
+                        ))}
+                    </select>
+                    <p
+                        style={{
+                            marginTop: '4px',
+                            marginBottom: '0',
+                            color: '#6b7280',
+                            fontSize: '12px',
+                        }}
+                    >
+                        {'Default severity threshold for content categories, applied to the combined result.'}
+                    </p>
+                </div>
+
+                {stageTypes.includes('azure') && renderAzureSettings(settings)}
+                {stageTypes.includes('agents') && renderAgentsSettings(settings)}
+                {stageTypes.includes('openai') && renderOpenAISettings(settings)}
+            </>
+        );
+    }, [handleFieldChange, renderAzureSettings, renderAgentsSettings, renderOpenAISettings]);
+
+    const renderFallbackSettings = useCallback((settings: ModeratorConfigValue) => {
+        const fallbackType = settings.fallback_type || '';
+        const compositeTypes = settings.type === 'composite' ? (settings.composite_moderators || '').split(',').map((type) => type.trim()) : [];
+        const showFallbackSettings = fallbackType !== settings.type && !compositeTypes.includes(fallbackType);
+
+        return (
+            <>
+                <div style={{marginTop: '24px', marginBottom: '16px'}}>
+                    <label
+                        style={{
+                            display: 'block',
+                            marginBottom: '8px',
+                            color: '#3f4350',
+                            fontSize: '14px',
+                            fontWeight: '600',
+                        }}
+                    >
+                        {'Fallback Provider'}
+                    </label>
+                    <select
+                        value={fallbackType}
+                        onChange={(e) => handleFieldChange('fallback_type', e.target.value)}
+                        style={{
+                            width: '100%',
+                            padding: '8px 12px',
+                            border: '1px solid #d1d5db',
+                            borderRadius: '4px',
+                            fontSize: '14px',
+                            boxSizing: 'border-box',
+                        }}
+                    >
+                        <option value=''>{'None'}</option>
+                        <option value='azure'>{'Azure AI Content Safety'}</option>
+                        <option value='agents'>{'Mattermost Agents Plugin'}</option>
+                        <option value='openai'>{'OpenAI Moderation API'}</option>
+                        <option value='blocklist'>{'Blocklist'}</option>
+                    </select>
+                    <p
+                        style={{
+                            marginTop: '4px',
+                            marginBottom: '0',
+                            color: '#6b7280',
+                            fontSize: '12px',
+                        }}
+                    >
+                        {'Provider used when the moderation provider fails or times out. After repeated failures all posts are sent to the fallback provider until the moderation provider recovers.'}
+                    </p>
+                </div>
+
+                {showFallbackSettings && fallbackType === 'azure' && renderAzureSettings(settings)}
+                {showFallbackSettings && fallbackType === 'agents' && renderAgentsSettings(settings)}
+                {showFallbackSettings && fallbackType === 'openai' && renderOpenAISettings(settings)}
+            </>
+        );
+    }, [handleFieldChange, renderAzureSettings, renderAgentsSettings, renderOpenAISettings]);
+
+    const renderCategoryThresholds = useCallback((settings: ModeratorConfigValue) => {
+        const categoryThresholds = settings.category_thresholds || {};
+
+        return (
+            <div style={{marginTop: '24px'}}>
+                <label
+                    style={{
+                        display: 'block',
+                        marginBottom: '8px',
+                        color: '#3f4350',
+                        fontSize: '14px',
+                        fontWeight: '600',
+                    }}
+                >
+                    {'Category Thresholds'}
+                </label>
+                {CATEGORIES.map((category) => (
+                    <div
+                        key={category.key}
+                        style={{
+                            display: 'flex',
+                            alignItems: 'center',
+                            marginBottom: '8px',
+                        }}
+                    >
+                        <span
+                            style={{
+                                width: '120px',
+                                color: '#3f4350',
+                                fontSize: '14px',
+                            }}
+                        >
+                            {category.label}
+                        </span>
+                        <select
+                            value={categoryThresholds[category.key] || ''}
+                            onChange={(e) => handleCategoryThresholdChange(category.key, e.target.value)}
+                            style={{
+                                flex: 1,
+                                padding: '8px 12px',
+                                border: '1px solid #d1d5db',
+                                borderRadius: '4px',
+                                fontSize: '14px',
+                                boxSizing: 'border-box',
+                            }}
+                        >
+                            {CATEGORY_THRESHOLD_OPTIONS.map((option) => (
+                                <option
+                                    key={option.value}
+                                    value={option.value}
+                                >
+                                    {option.label}
+                                </option>
+                            ))}
+                        </select>
+                    </div>
+                ))}
+                <p
+                    style={{
+                        marginTop: '4px',
+                        marginBottom: '0',
+                        color: '#6b7280',
+                        fontSize: '12px',
+                    }}
+                >
+                    {'Override the moderation threshold for individual categories. Disabled categories are never flagged.'}
+                </p>
+            </div>
+        );
+    }, [handleCategoryThresholdChange]);
+
+    // Tests the settings as shown, so they can be checked before they are saved
+    const handleTestConnection = useCallback(async () => {
+        setTesting(true);
+        setTestReport(null);
+        setTestError('');
+        try {
+            setTestReport(await client.testConnection(values));
+        } catch (error) {
+            setTestError((error as Error).message || 'Failed to test the connection');
+        } finally {
+            setTesting(false);
+        }
+    }, [values]);
+
+    const renderTestConnection = () => {
+        return (
+            <div style={{marginTop: '24px'}}>
+                <button
+                    type='button'
+                    className='btn btn-tertiary'
+                    onClick={handleTestConnection}
+                    disabled={testing}
+                >
+                    {testing ? 'Testing...' : 'Test connection'}
+                </button>
+                <p
+                    style={{
+                        marginTop: '4px',
+                        marginBottom: '0',
+                        color: '#6b7280',
+                        fontSize: '12px',
+                    }}
+                >
+                    {'Sends a harmless test message to every provider configured above and reports the result.'}
+                </p>
+                {testError && (
+                    <p style={{marginTop: '8px', color: '#d24b4e', fontSize: '14px'}}>{testError}</p>
+                )}
+                {testReport?.config_error && (
+                    <p style={{marginTop: '8px', color: '#d24b4e', fontSize: '14px'}}>
+                        {`Invalid configuration: ${testReport.config_error}`}
+                    </p>
+                )}
+                {testReport?.providers.map((provider) => (
+                    <div
+                        key={provider.moderator}
+                        style={{
+                            marginTop: '8px',
+                            color: provider.success ? '#06d6a0' : '#d24b4e',
+                            fontSize: '14px',
+                        }}
+                    >
+                        <strong>{provider.moderator}</strong>
+                        {describeProviderTest(provider)}
+                    </div>
+                ))}
+            </div>
+        );
+    };
+
     return (
         <div
             style={{
@@ -388,6 +1054,9 @@ const ModeratorConfig: React.FC<ModeratorConfigProps> = ({id, value, onChange})
                 >
                     <option value='azure'>{'Azure AI Content Safety'}</option>
                     <option value='agents'>{'Mattermost Agents Plugin'}</option>
+                    <option value='openai'>{'OpenAI Moderation API'}</option>
+                    <option value='blocklist'>{'Blocklist'}</option>
+                    <option value='composite'>{'Multiple Providers'}</option>
                 </select>
                 <p
                     style={{
@@ -403,6 +1072,12 @@ const ModeratorConfig: React.FC<ModeratorConfigProps> = ({id, value, onChange})
 
             {currentType === 'azure' && renderAzureSettings(values)}
             {currentType === 'agents' && renderAgentsSettings(values)}
+            {currentType === 'openai' && renderOpenAISettings(values)}
+            {currentType === 'blocklist' && renderBlocklistSettings(values)}
+            {currentType === 'composite' && renderCompositeSettings(values)}
+            {renderFallbackSettings(values)}
+            {renderCategoryThresholds(values)}
+            {renderTestConnection()}
         </div>
     );
 };